@@ -0,0 +1,43 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Backuper is implemented by the repositories writing a consistent copy of their storage while serving requests, e.g.
+// the bbolt repository
+type Backuper interface {
+	Backup(ctx context.Context, w io.Writer) (int64, error)
+}
+
+// WithBackup enables the backup route, streaming a copy of the storage of the given repository. The copy holds every
+// payment, so the gateway in front of the service must only let the operators through.
+func (h *PaymentHandler) WithBackup(backup Backuper) *PaymentHandler {
+	h.backup = backup
+	return h
+}
+
+// @Summary Streams a consistent copy of the repository storage
+// @Description Only served for the bbolt repository, the copy being opened as a bbolt file to restore it.
+// @ID get-backup
+// @Produce  octet-stream
+// @Success 200 {file} file "Copy of the bbolt file"
+// @Router /admin/backup [get]
+func (h *PaymentHandler) Backup(c *gin.Context) {
+	slog.InfoContext(c.Request.Context(), "Received request to back up the repository")
+	c.Header(ContentType, "application/octet-stream")
+	c.Header("Content-Disposition", `attachment; filename="payments.db"`)
+	c.Status(http.StatusOK)
+	n, err := h.backup.Backup(c.Request.Context(), c.Writer)
+	if err != nil {
+		// the status is already sent, the client gets a truncated file
+		slog.ErrorContext(c.Request.Context(), "Failed to back up the repository", "error", err, "bytes", n)
+		return
+	}
+	slog.InfoContext(c.Request.Context(), "Backed up the repository", "bytes", n)
+}
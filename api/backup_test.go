@@ -0,0 +1,62 @@
+package api_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"payment-service/api"
+	"payment-service/model"
+	"payment-service/repository"
+	"payment-service/test"
+)
+
+func TestPaymentHandler_BackupShouldStreamACopyOfTheBoltFile(t *testing.T) {
+	t.Logf("Given the payment API over a bbolt repository holding a payment")
+	{
+		dir := t.TempDir()
+		repo, err := repository.NewBoltRepository(filepath.Join(dir, "payments.db"))
+		if err != nil {
+			t.Fatalf("Failed to open the bbolt file %v", err)
+		}
+		defer repo.Close()
+		handler := api.NewPaymentHandler(repo, urlFx, urlCh).WithBackup(repo)
+		res := test.CreatePaymentAndAssertResponse(t, handler)
+
+		t.Logf("\tWhen sending backup request to endpoint %s", "\\admin\\backup")
+		{
+			req, err := http.NewRequest(http.MethodGet, "/admin/backup", nil)
+			w := httptest.NewRecorder()
+			handler.NewRouter().ServeHTTP(w, req)
+			test.AssertForCallErrorAndHttpStatusCode(err, t, w.Code, http.StatusOK)
+
+			path := filepath.Join(dir, "backup.db")
+			if err := ioutil.WriteFile(path, w.Body.Bytes(), 0600); err != nil {
+				t.Fatal(err)
+			}
+			restored, err := repository.NewBoltRepository(path)
+			if err != nil {
+				t.Fatalf("\t\tThe backup should open as a bbolt file %v %v", test.BallotX, err)
+			}
+			defer restored.Close()
+			_, err = restored.Find(context.Background(), api.DatabaseName, api.CollectionName, model.ID(res.ID))
+			check(t, err == nil, "The backup should contain the payment", err)
+		}
+	}
+
+	t.Logf("Given the payment API over a repository without backup")
+	{
+		router := api.NewPaymentHandler(repository.NewMemoryRepository(), urlFx, urlCh).NewRouter()
+
+		t.Logf("\tWhen sending backup request to endpoint %s", "\\admin\\backup")
+		{
+			req, err := http.NewRequest(http.MethodGet, "/admin/backup", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			test.AssertForCallErrorAndHttpStatusCode(err, t, w.Code, http.StatusNotFound)
+		}
+	}
+}
@@ -1,18 +1,32 @@
 package api
 
 import (
+	"context"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
-	"github.com/globalsign/mgo/bson"
 	_ "payment-service/docs"
+	"payment-service/event"
+	"payment-service/health"
 	"payment-service/logger"
+	"payment-service/metrics"
 	"payment-service/model"
+	"payment-service/payment"
+	"payment-service/privacy"
 	"payment-service/repository"
 	"payment-service/service"
+	"payment-service/tracing"
+	"payment-service/webhook"
 	"github.com/swaggo/gin-swagger"
-	"github.com/swaggo/gin-swagger/swaggerFiles"
+	swaggerFiles "github.com/swaggo/files"
+	"io/ioutil"
+	"log/slog"
 	"mime"
 	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strconv"
+	"time"
 )
 
 const (
@@ -20,25 +34,78 @@ const (
 	DatabaseName   = "PaymentDB"
 	CollectionName = "Payment"
 	ID             = "id"
+
+	// DefaultTimeout the deadline of the payment routes without a specific timeout
+	DefaultTimeout = 10 * time.Second
+
+	// StatusClientClosedRequest non standard status recorded when the client goes away before the response
+	StatusClientClosedRequest = 499
+
+	// MergePatchContentType the media type of a JSON merge patch (RFC 7396)
+	MergePatchContentType = "application/merge-patch+json"
+
+	// JSONPatchContentType the media type of a JSON patch (RFC 6902), which is not supported
+	JSONPatchContentType = "application/json-patch+json"
+
+	// PageNumber and PageSize the query parameters paginating the payment list
+	PageNumber = "page[number]"
+	PageSize   = "page[size]"
+
+	// DefaultPageSize the size of the pages when only page[number] is given
+	DefaultPageSize = 100
+
+	// FilterOrganisationID, FilterProcessedFrom and FilterProcessedTo the query parameters filtering the payment list,
+	// the processing dates bounding it in [from, to) as RFC 3339 dates or times
+	FilterOrganisationID = "filter[organisation_id]"
+	FilterProcessedFrom  = "filter[processing_date_from]"
+	FilterProcessedTo    = "filter[processing_date_to]"
 )
 
+// Timeouts the request deadline per route keyed by method and path e.g. "GET /payment/:id", a zero timeout running the
+// route without deadline as the event stream and the backup do by default
+type Timeouts map[string]time.Duration
+
 // PaymentHandler the card payment handler
 type PaymentHandler struct {
-	repo repository.Repository
-	fx   service.FXService
-	ch   service.ChargesService
+	payments *payment.Service
+	timeouts Timeouts
+	webhooks webhook.Store
+	stream   *event.Stream
+	health   *health.Registry
+	privacy  *privacy.Policy
+	backup   Backuper
 }
 
 // NewPaymentHandler creates a type of CardPaymentHandler
 func NewPaymentHandler(repo repository.Repository, fxUrl string, chUrl string) *PaymentHandler {
-	return &PaymentHandler{repo, service.NewFxService(fxUrl), service.NewChargesService(chUrl)}
+	return NewPaymentHandlerFor(NewPaymentService(repo, fxUrl, chUrl))
+}
+
+// NewPaymentHandlerFor creates a PaymentHandler serving the given payment use cases
+func NewPaymentHandlerFor(payments *payment.Service) *PaymentHandler {
+	// the event stream and the backup last as long as the client reads them
+	timeouts := Timeouts{http.MethodGet + " /payment/events": 0, http.MethodGet + " /admin/backup": 0}
+	return &PaymentHandler{payments, timeouts, nil, nil, nil, nil, nil}
+}
+
+// NewPaymentService creates the payment use cases storing the payments in the payment database
+func NewPaymentService(repo repository.Repository, fxUrl string, chUrl string) *payment.Service {
+	return payment.NewService(repo, service.NewFxService(fxUrl), service.NewChargesService(chUrl), DatabaseName, CollectionName)
+}
+
+// WithTimeouts overrides the request deadline of the given routes
+func (h *PaymentHandler) WithTimeouts(timeouts Timeouts) *PaymentHandler {
+	for route, timeout := range timeouts {
+		h.timeouts[route] = timeout
+	}
+	return h
 }
 
 //----------------------------------------------------------------------------------------
 //							Endpoint handlers
 //----------------------------------------------------------------------------------------
 
-// Health the health endpoint handler
+// Health the health endpoint handler, kept for the existing probes, see Live and Ready
 func (h *PaymentHandler) Health(c *gin.Context) {
 	c.JSON(http.StatusOK, model.HealthResponse{Message: "Up and running!"})
 }
@@ -51,6 +118,7 @@ func (h *PaymentHandler) Health(c *gin.Context) {
 // @Param new-tag body model.CreatePaymentRequest true "New tag"
 // @Success 201 {object} model.CreatePaymentResponse "Tag created"
 // @Failure 400 {object} model.ErrorResponse "Bad request"
+// @Failure 409 {object} model.ErrorResponse "Conflict"
 // @Failure 500 {object} model.ErrorResponse "Internal server error"
 // @Router /payment [post]
 func (h *PaymentHandler) CreatePayment(c *gin.Context) {
@@ -58,63 +126,120 @@ func (h *PaymentHandler) CreatePayment(c *gin.Context) {
 
 	// This will infer what binder to use depending on the content-type header.
 	if errB := c.ShouldBindWith(&req, binding.JSON); errB != nil {
-		logger.Error.Println(errB.Error())
+		slog.WarnContext(c.Request.Context(), "Failed to parse payment request", "error", errB)
 		setErrorResponse("Failed to parse payment request", http.StatusBadRequest, c)
 		return
 	}
 
-	logger.Info.Printf("Received request to create payment for organisationId: %s", req.OrganisationID)
-
-	// Get exchange rate from the mock service
-	fx := model.ForeignExchange{ExchangeRate: 1.0}
-
-	if foreignExchangeRequired(req) {
-		_, fx = h.fx.GetExchangeRate(req.BeneficiaryParty.Currency, req.DebtorParty.Currency, req.Amount)
-	}
-
-	// calculate the new amount based on the exchange rate
-	amount := getAmount(req.Amount, fx.ExchangeRate)
-
-	// Get charges information from the mock service
-	_, charges := h.ch.GetCharges(fx.ExchangeRate, req.BearerCode, req.BeneficiaryParty.Currency, req.DebtorParty.Currency)
-
-	// persisting payment into database
-	payment := buildPayment(amount, fx, charges, req)
-	logger.Info.Printf("Storing payment with ID %s", payment.ID.Hex())
-	err := h.repo.Insert(DatabaseName, CollectionName, payment)
-
+	slog.InfoContext(c.Request.Context(), "Received request to create payment", "organisation_id", req.OrganisationID)
+	stored, err := h.payments.Create(c.Request.Context(), req)
 	if err != nil {
-		logger.Error.Println(err.Error())
-		setErrorResponse("Failed to create payment", http.StatusInternalServerError, c)
+		setPaymentError(err, "Failed to create payment", c)
 		return
 	}
 
 	// if all good create success response
-	c.Writer.Header().Set(ContentType, mime.TypeByExtension("json"))
-	c.JSON(http.StatusCreated, model.CreatePaymentResponse{ID: payment.ID.Hex(), OrganisationId: payment.OrganisationId})
+	slog.InfoContext(c.Request.Context(), "Stored payment", "payment_id", stored.ID.String())
+	setCreatedResponse(stored, c)
 }
 
 // @Summary Get all payments
+// @Description The payments are paginated, ordered by ID, when page[number] or page[size] is given. links.next is the next page.
 // @ID get-payments
 // @Accept  json
 // @Produce  json
+// @Param page[number] query int false "Page number, from zero"
+// @Param page[size] query int false "Page size, 100 by default"
+// @Param filter[organisation_id] query string false "Only the payments of the organisation"
+// @Param filter[processing_date_from] query string false "Only the payments processed from the date, e.g. 2018-09-10"
+// @Param filter[processing_date_to] query string false "Only the payments processed before the date, e.g. 2018-09-11"
 // @Success 200 {object} model.PaymentResponse	"ok"
 // @Failure 400 {object} model.ErrorResponse "Bad request"
 // @Failure 500 {object} model.ErrorResponse "Internal server error"
 // @Router /payment [get]
 func (h *PaymentHandler) FindAllPayments(c *gin.Context) {
-	logger.Info.Println("Received request to query all payments")
-	resp, err := h.repo.FindAll(DatabaseName, CollectionName)
+	slog.InfoContext(c.Request.Context(), "Received request to query all payments")
+	number, paged := c.GetQuery(PageNumber)
+	size, sized := c.GetQuery(PageSize)
+	filter, err := listFilter(c)
+	if err != nil {
+		setErrorResponse("Invalid filter", http.StatusBadRequest, c)
+		return
+	}
+	if !paged && !sized && filter.IsZero() {
+		h.findAllPayments(c)
+		return
+	}
+
+	page := payment.Page{Size: DefaultPageSize, Filter: filter}
+	var errN, errS error
+	if paged {
+		page.Number, errN = strconv.Atoi(number)
+	}
+	if sized {
+		page.Size, errS = strconv.Atoi(size)
+	}
+	if errN != nil || errS != nil {
+		setErrorResponse("Invalid page", http.StatusBadRequest, c)
+		return
+	}
+	resp, more, err := h.payments.ListPage(c.Request.Context(), page)
+	if err == payment.ErrInvalidPage {
+		setErrorResponse("Invalid page", http.StatusBadRequest, c)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to query payments", "error", err)
+		setFailureResponse(err, "Failed to query payments", http.StatusInternalServerError, c)
+		return
+	}
+	if more {
+		next := url.Values{PageNumber: {strconv.Itoa(page.Number + 1)}, PageSize: {strconv.Itoa(page.Size)}}
+		for _, key := range []string{FilterOrganisationID, FilterProcessedFrom, FilterProcessedTo} {
+			if value := c.Query(key); value != "" {
+				next.Set(key, value)
+			}
+		}
+		resp.Next = c.Request.URL.Path + "?" + next.Encode()
+	}
+
+	// if all good create success response
+	c.Writer.Header().Set(ContentType, mime.TypeByExtension("json"))
+	c.JSON(http.StatusOK, h.masker(c).Payments(resp))
+}
+
+// Helper function to read the filter of the payment list, its dates being RFC 3339 dates or times
+func listFilter(c *gin.Context) (repository.Filter, error) {
+	filter := repository.Filter{OrganisationID: c.Query(FilterOrganisationID)}
+	for key, bound := range map[string]*time.Time{FilterProcessedFrom: &filter.ProcessedFrom, FilterProcessedTo: &filter.ProcessedTo} {
+		value := c.Query(key)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			if t, err = time.Parse(time.DateOnly, value); err != nil {
+				return repository.Filter{}, err
+			}
+		}
+		*bound = t
+	}
+	return filter, nil
+}
+
+// Helper function to write all the payments, unpaginated
+func (h *PaymentHandler) findAllPayments(c *gin.Context) {
+	resp, err := h.payments.List(c.Request.Context())
 
 	if err != nil {
-		logger.Error.Println(err.Error())
-		setErrorResponse("Failed to query payments", http.StatusInternalServerError, c)
+		slog.ErrorContext(c.Request.Context(), "Failed to query payments", "error", err)
+		setFailureResponse(err, "Failed to query payments", http.StatusInternalServerError, c)
 		return
 	}
 
 	// if all good create success response
 	c.Writer.Header().Set(ContentType, mime.TypeByExtension("json"))
-	c.JSON(http.StatusOK, resp)
+	c.JSON(http.StatusOK, h.masker(c).Payments(resp))
 }
 
 // @Summary Get a payment for given ID
@@ -128,18 +253,24 @@ func (h *PaymentHandler) FindAllPayments(c *gin.Context) {
 // @Router /payment/{id} [get]
 func (h *PaymentHandler) FindPayment(c *gin.Context) {
 
-	id := c.Params.ByName(ID)
-	logger.Info.Printf("Received request to query a payment for a given ID %s", id)
-	resp, err := h.repo.Find(DatabaseName, CollectionName, bson.ObjectIdHex(id))
+	id := paymentID(c)
+	slog.InfoContext(c.Request.Context(), "Received request to query a payment", "payment_id", id.String())
+	resp, err := h.payments.Get(c.Request.Context(), id)
 
-	if err != nil {
+	if err == payment.ErrNotFound {
 		c.JSON(http.StatusNotFound, model.EmptyBody{})
 		return
 	}
 
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to query payment", "error", err)
+		setFailureResponse(err, "Failed to query payment", http.StatusInternalServerError, c)
+		return
+	}
+
 	// if all good create success response
 	c.Writer.Header().Set(ContentType, mime.TypeByExtension("json"))
-	c.JSON(http.StatusOK, resp)
+	c.JSON(http.StatusOK, h.masker(c).Payments(resp))
 }
 
 // @Summary Delete a payment for given ID
@@ -152,76 +283,180 @@ func (h *PaymentHandler) FindPayment(c *gin.Context) {
 // @Failure 500 {object} model.ErrorResponse "Internal server error"
 // @Router /payment/{id} [delete]
 func (h *PaymentHandler) DeletePayment(c *gin.Context) {
-	id := c.Params.ByName(ID)
-	logger.Info.Printf("Received request to delete a payment for a given ID %s", id)
+	id := paymentID(c)
+	slog.InfoContext(c.Request.Context(), "Received request to delete a payment", "payment_id", id.String())
 
-	// query the payment first
-	_, errQ := h.repo.Find(DatabaseName, CollectionName, bson.ObjectIdHex(id))
-	if errQ != nil {
+	err := h.payments.Delete(c.Request.Context(), id)
+	if err == payment.ErrNotFound {
 		c.JSON(http.StatusNotFound, model.EmptyBody{})
 		return
 	}
 
-	err := h.repo.Delete(DatabaseName, CollectionName, bson.ObjectIdHex(id))
-
 	if err != nil {
-		setErrorResponse("Failed to delete payment", http.StatusInternalServerError, c)
+		slog.ErrorContext(c.Request.Context(), "Failed to delete payment", "error", err)
+		setFailureResponse(err, "Failed to delete payment", http.StatusInternalServerError, c)
 		return
 	}
 
 	// if all good create success response
-	logger.Info.Printf("Payment with id [%s] successfully deleted", id)
+	slog.InfoContext(c.Request.Context(), "Deleted payment", "payment_id", id.String())
 	c.Status(http.StatusNoContent)
 }
 
-// @Summary Update a payment for given ID - partial payment is not supported
+// @Summary Create or replace a payment for given ID - partial payment is not supported
+// @Description Replaces the payment when it exists. Payments with a client supplied UUID are created when missing.
 // @ID update-payment
 // @Accept  json
 // @Produce  json
+// @Success 201 {object} model.CreatePaymentResponse "Payment created"
 // @Success 204 "Payment updated"
 // @Failure 400 {object} model.ErrorResponse "Bad request"
 // @Failure 404 {object} model.ErrorResponse "Not found"
+// @Failure 409 {object} model.ErrorResponse "Conflict"
 // @Failure 500 {object} model.ErrorResponse "Internal server error"
 // @Router /payment/{id} [put]
 func (h *PaymentHandler) UpdatePayment(c *gin.Context) {
 	var req model.CreatePaymentRequest
 
-	id := c.Params.ByName(ID)
+	id := paymentID(c)
 
 	// This will infer what binder to use depending on the content-type header.
 	if errB := c.ShouldBindWith(&req, binding.JSON); errB != nil {
-		logger.Error.Println(errB.Error())
+		slog.WarnContext(c.Request.Context(), "Failed to parse update payment request", "error", errB)
 		setErrorResponse("Failed to parse update payment request", http.StatusBadRequest, c)
 		return
 	}
 
-	logger.Info.Printf("Received request to update payment for payment ID: %s", id)
+	slog.InfoContext(c.Request.Context(), "Received request to update payment", "payment_id", id.String())
+	stored, created, err := h.payments.Update(c.Request.Context(), id, req)
+	if err != nil {
+		setPaymentError(err, "Failed to update payment", c)
+		return
+	}
+
+	// if all good create success response
+	if created {
+		slog.InfoContext(c.Request.Context(), "Created the missing payment", "payment_id", stored.ID.String())
+		setCreatedResponse(stored, c)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
 
-	// Get exchange rate from the mock service
-	fx := model.ForeignExchange{ExchangeRate: 1.0}
+// @Summary Partially update a payment for given ID using a JSON merge patch (RFC 7396)
+// @Description FX and charges are only recalculated when the amount, currencies or bearer code change. The amount is in the beneficiary currency and stored as sent. Unknown members are refused, JSON Patch (RFC 6902) is not supported.
+// @ID patch-payment
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} model.PaymentResponse "Payment updated"
+// @Failure 400 {object} model.ErrorResponse "Bad request"
+// @Failure 404 {object} model.ErrorResponse "Not found"
+// @Failure 409 {object} model.ErrorResponse "Conflict"
+// @Failure 415 {object} model.ErrorResponse "Unsupported media type"
+// @Failure 500 {object} model.ErrorResponse "Internal server error"
+// @Router /payment/{id} [patch]
+func (h *PaymentHandler) PatchPayment(c *gin.Context) {
+	id := paymentID(c)
+	slog.InfoContext(c.Request.Context(), "Received request to patch payment", "payment_id", id.String())
 
-	if foreignExchangeRequired(req) {
-		_, fx = h.fx.GetExchangeRate(req.BeneficiaryParty.Currency, req.DebtorParty.Currency, req.Amount)
+	if c.ContentType() == JSONPatchContentType {
+		setErrorResponse("JSON Patch is not supported, send a JSON merge patch", http.StatusUnsupportedMediaType, c)
+		return
+	}
+	if ct := c.ContentType(); ct != MergePatchContentType && ct != binding.MIMEJSON {
+		setErrorResponse("Unsupported patch media type", http.StatusUnsupportedMediaType, c)
+		return
 	}
 
-	// calculate the new amount based on the exchange rate
-	amount := getAmount(req.Amount, fx.ExchangeRate)
+	patch, errR := ioutil.ReadAll(c.Request.Body)
+	if errR != nil {
+		slog.WarnContext(c.Request.Context(), "Failed to read payment patch", "error", errR)
+		setErrorResponse("Failed to parse payment patch", http.StatusBadRequest, c)
+		return
+	}
 
-	// Get charges information from the mock service
-	_, charges := h.ch.GetCharges(fx.ExchangeRate, req.BearerCode, req.BeneficiaryParty.Currency, req.DebtorParty.Currency)
+	resp, err := h.payments.Patch(c.Request.Context(), id, patch)
+	if err == payment.ErrNotFound {
+		c.JSON(http.StatusNotFound, model.EmptyBody{})
+		return
+	}
 
-	// persisting payment into database
-	payment := updatePayment(amount, fx, charges, req, bson.ObjectIdHex(id))
-	logger.Info.Printf("Updating payment with ID %s", payment.ID.Hex())
-	err := h.repo.Update(DatabaseName, CollectionName, bson.ObjectIdHex(id), payment)
 	if err != nil {
-		logger.Error.Println(err.Error())
-		setErrorResponse("Failed to update payment", http.StatusNotFound, c)
+		setPaymentError(err, "Failed to patch payment", c)
 		return
 	}
 
 	// if all good create success response
-	c.Status(http.StatusNoContent)
+	c.Writer.Header().Set(ContentType, mime.TypeByExtension("json"))
+	c.JSON(http.StatusOK, h.masker(c).Payments(resp))
+}
+
+//----------------------------------------------------------------------------------------
+//							Middleware
+//----------------------------------------------------------------------------------------
+
+// Deadline bounds the request context with the given timeout so that repository and service calls give up past it
+func Deadline(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// ValidateID rejects requests whose id path parameter is not a valid payment identifier
+func ValidateID(c *gin.Context) {
+	id, err := model.ParseID(c.Params.ByName(ID))
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "Invalid payment ID", "id", c.Params.ByName(ID))
+		setErrorResponse("Invalid payment ID", http.StatusBadRequest, c)
+		c.Abort()
+		return
+	}
+	c.Set(ID, id)
+	c.Next()
+}
+
+// RequestID reads the request ID of the X-Request-ID header, generating one when it is missing or invalid, and adds it
+// to the response headers and to the request context, for the logs and the downstream calls to carry it
+func RequestID(c *gin.Context) {
+	id := c.GetHeader(logger.RequestIDHeader)
+	if !logger.ValidRequestID(id) {
+		id = logger.NewRequestID()
+	}
+	c.Header(logger.RequestIDHeader, id)
+	c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), id))
+	c.Next()
+}
+
+// AccessLog logs the served requests along their status and duration, the server errors at the error level. The
+// query string is left out as it may hold personal data.
+func AccessLog(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+	level := slog.LevelInfo
+	if c.Writer.Status() >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	slog.LogAttrs(c.Request.Context(), level, "Served request",
+		slog.String("method", c.Request.Method),
+		slog.String("path", c.Request.URL.Path),
+		slog.Int("status", c.Writer.Status()),
+		slog.Int("size", c.Writer.Size()),
+		slog.Duration("duration", time.Since(start)),
+		slog.String("client_ip", c.ClientIP()))
+}
+
+// Recovery turns a panic of a handler into an internal server error, logging it along its stack
+func Recovery(c *gin.Context) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(c.Request.Context(), "Recovered from a panic", "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+			c.AbortWithStatus(http.StatusInternalServerError)
+		}
+	}()
+	c.Next()
 }
 
 //----------------------------------------------------------------------------------------
@@ -231,56 +466,105 @@ func (h *PaymentHandler) UpdatePayment(c *gin.Context) {
 // NewRouter creates an instance of the router
 func (h *PaymentHandler) NewRouter() *gin.Engine {
 	router := gin.New()
-	router.Use(gin.Logger())
-	router.Use(gin.Recovery())
+	router.Use(RequestID, Trace, AccessLog, Recovery)
 
 	// configure all the route
 	router.GET("/health", h.Health)
-	router.POST("/payment", h.CreatePayment)
-	router.GET("/payment", h.FindAllPayments)
-	router.GET("/payment/:id", h.FindPayment)
-	router.DELETE("/payment/:id", h.DeletePayment)
-	router.PUT("/payment/:id", h.UpdatePayment)
+	router.GET("/health/live", h.Live)
+	router.GET("/health/ready", h.Ready)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	h.handle(router, http.MethodPost, "/payment", h.CreatePayment)
+	h.handle(router, http.MethodGet, "/payment", h.FindAllPayments)
+	h.handle(router, http.MethodGet, "/payment/:id", ValidateID, h.FindPayment)
+	h.handle(router, http.MethodDelete, "/payment/:id", ValidateID, h.DeletePayment)
+	h.handle(router, http.MethodPut, "/payment/:id", ValidateID, h.UpdatePayment)
+	h.handle(router, http.MethodPatch, "/payment/:id", ValidateID, h.PatchPayment)
+	if h.stream != nil {
+		h.handle(router, http.MethodGet, "/payment/events", h.StreamEvents)
+		h.handle(router, http.MethodGet, "/payment/:id/history", ValidateID, h.PaymentHistory)
+	}
+	if h.webhooks != nil {
+		h.webhookRoutes(router)
+	}
+	if h.backup != nil {
+		h.handle(router, http.MethodGet, "/admin/backup", h.Backup)
+	}
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	return router
 }
 
-// helper function
-func setErrorResponse(msg string, status int, c *gin.Context) {
-	c.JSON(status, model.ErrorResponse{Message: msg, Code: status})
+// Helper function to register a route behind its metrics, span name and request deadline
+func (h *PaymentHandler) handle(router *gin.Engine, method, path string, handlers ...gin.HandlerFunc) {
+	router.Handle(method, path, h.chain(method, path, handlers...)...)
 }
 
-// Helper function to calculate the new amount based on the given exchange rate
-func getAmount(amount float64, rate float64) float64 {
-	return amount / rate
+// Helper function to prepend the metrics, span name and request deadline of a route to its handlers
+func (h *PaymentHandler) chain(method, path string, handlers ...gin.HandlerFunc) []gin.HandlerFunc {
+	timeout, ok := h.timeouts[method+" "+path]
+	if !ok {
+		timeout = DefaultTimeout
+	}
+	if timeout <= 0 {
+		return append([]gin.HandlerFunc{Metrics(path), TraceRoute(path)}, handlers...)
+	}
+	return append([]gin.HandlerFunc{Metrics(path), TraceRoute(path), Deadline(timeout)}, handlers...)
 }
 
-// Helper function to build payment instance
-func buildPayment(amount float64, fx model.ForeignExchange, charges model.ChargesInformation, req model.CreatePaymentRequest) model.Payment {
-
-	attr := buildAttr(amount, req, charges, fx)
+// Helper function to write the created response of a new payment
+func setCreatedResponse(payment model.Payment, c *gin.Context) {
+	c.Writer.Header().Set(ContentType, mime.TypeByExtension("json"))
+	c.JSON(http.StatusCreated, model.CreatePaymentResponse{ID: payment.ID.String(), OrganisationId: payment.OrganisationId})
+}
 
-	return model.Payment{Type: "Payment", ID: bson.NewObjectId(), OrganisationId: req.OrganisationID, Attributes: attr, Version: 0}
+// helper function
+func setErrorResponse(msg string, status int, c *gin.Context) {
+	c.JSON(status, model.ErrorResponse{Message: msg, Code: status, TraceID: tracing.TraceID(c.Request.Context())})
 }
 
-// Helper function to build payment instance
-func updatePayment(amount float64, fx model.ForeignExchange, charges model.ChargesInformation, req model.CreatePaymentRequest, oid bson.ObjectId) model.Payment {
-	attr := buildAttr(amount, req, charges, fx)
-	return model.Payment{Type: "Payment", ID: oid, OrganisationId: req.OrganisationID, Attributes: attr, Version: 0}
+// Helper function to write the error response of a failed payment use case
+func setPaymentError(err error, msg string, c *gin.Context) {
+	if _, ok := err.(payment.PricingError); ok {
+		slog.ErrorContext(c.Request.Context(), "Failed to price payment", "error", err)
+		setErrorResponse("Failed to price payment", http.StatusBadGateway, c)
+		return
+	}
+	switch err {
+	case payment.ErrInvalidID:
+		setErrorResponse("Invalid payment ID", http.StatusBadRequest, c)
+	case payment.ErrIDMismatch:
+		setErrorResponse("Payment ID does not match", http.StatusBadRequest, c)
+	case payment.ErrInvalidRequest:
+		setErrorResponse("Invalid payment request", http.StatusBadRequest, c)
+	case payment.ErrInvalidPatch:
+		setErrorResponse("Failed to parse payment patch", http.StatusBadRequest, c)
+	case payment.ErrImmutableField:
+		setErrorResponse("Payment id, type and version cannot be patched", http.StatusBadRequest, c)
+	case payment.ErrCalculatedField:
+		setErrorResponse("Payment currency, fx and charges cannot be patched", http.StatusBadRequest, c)
+	case payment.ErrNotFound:
+		setErrorResponse(msg, http.StatusNotFound, c)
+	case payment.ErrDuplicate:
+		setErrorResponse("Payment already exists", http.StatusConflict, c)
+	default:
+		slog.ErrorContext(c.Request.Context(), msg, "error", err)
+		setFailureResponse(err, msg, http.StatusInternalServerError, c)
+	}
 }
 
-// Helper function to determine if foreign exchange to this payment is relevant
-func foreignExchangeRequired(req model.CreatePaymentRequest) bool {
-	return req.DebtorParty.Currency != req.BeneficiaryParty.Currency
+// Helper function to write the error response of a failed call, cancelled or timed out requests get their own status
+func setFailureResponse(err error, msg string, status int, c *gin.Context) {
+	switch err {
+	case model.ErrCanceled:
+		setErrorResponse("Request cancelled", StatusClientClosedRequest, c)
+	case model.ErrDeadlineExceeded:
+		setErrorResponse("Request timed out", http.StatusGatewayTimeout, c)
+	default:
+		setErrorResponse(msg, status, c)
+	}
 }
 
-// Helper function to build payment attributes
-func buildAttr(amount float64, req model.CreatePaymentRequest, charges model.ChargesInformation, fx model.ForeignExchange) model.Attributes {
-	attr := model.Attributes{Amount: amount, BeneficiaryParty: req.BeneficiaryParty, DebtorParty: req.DebtorParty,
-		ChargesInformation: charges, Currency: req.BeneficiaryParty.Currency, EndToEndReference: req.EndToEndReference,
-		Fx: fx, NumericReference: req.NumericReference, PaymentID: req.PaymentID, PaymentPurpose: req.PaymentPurpose,
-		PaymentScheme: req.PaymentScheme, PaymentType: req.PaymentType, ProcessingDate: req.ProcessingDate, Reference: req.Reference,
-		SchemePaymentSubType: req.SchemePaymentSubType, SchemePaymentType: req.SchemePaymentType, SponsorParty: req.SponsorParty}
-	return attr
+// Helper function to get the payment ID validated by the ValidateID middleware
+func paymentID(c *gin.Context) model.ID {
+	return c.MustGet(ID).(model.ID)
 }
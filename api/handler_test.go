@@ -3,12 +3,11 @@ package api_test
 import (
 	"encoding/json"
 	"payment-service/api"
-	"payment-service/logger"
 	"payment-service/model"
 	"payment-service/test"
-	"gopkg.in/mgo.v2/bson"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -44,7 +43,7 @@ func TestPaymentHandler_CreatePayment(t *testing.T) {
 			body := test.CreatePaymentRequest(beneficiaryAccountNum, debtorAccountNumb, beneficiaryCurrency)
 
 			bytes, _ := json.Marshal(body)
-			logger.Info.Println(string(bytes))
+			t.Logf("\t\t%s", bytes)
 			w := httptest.NewRecorder()
 
 			req, err := test.HttpRequest(body, "/payment", http.MethodPost)
@@ -73,7 +72,7 @@ func TestPaymentHandler_CreatePaymentForeignExchangeNotRequired(t *testing.T) {
 			body := test.CreatePaymentRequest(beneficiaryAccountNum, debtorAccountNumb, "GBP")
 
 			bytes, _ := json.Marshal(body)
-			logger.Info.Println(string(bytes))
+			t.Logf("\t\t%s", bytes)
 			w := httptest.NewRecorder()
 
 			req, err := test.HttpRequest(body, "/payment", http.MethodPost)
@@ -102,7 +101,7 @@ func TestPaymentHandler_CreatePaymentWithEmptyBodyShouldReturnBadRequest(t *test
 			body := model.CreatePaymentRequest{}
 
 			bytes, _ := json.Marshal(body)
-			logger.Info.Println(string(bytes))
+			t.Logf("\t\t%s", bytes)
 			w := httptest.NewRecorder()
 
 			req, err := test.HttpRequest(body, "/payment", http.MethodPost)
@@ -176,7 +175,7 @@ func TestPaymentHandler_QueryForAGivenPaymentIdShouldReturn200(t *testing.T) {
 
 			var response model.PaymentResponse
 			json.NewDecoder(w.Body).Decode(&response)
-			if response.Data[0].ID.Hex() == res.ID {
+			if response.Data[0].ID.String() == res.ID {
 				t.Logf("\t\tThe payment ID should be %v %v", res.ID, test.CheckMark)
 			} else {
 				t.Errorf("\t\tThe payment ID should be %v %v %v", res.ID, test.BallotX, response.Data[0].ID)
@@ -192,7 +191,7 @@ func TestPaymentHandler_QueryForAGivenDummyID_ShouldReturnNotFound(t *testing.T)
 		{
 			handler := api.NewPaymentHandler(Repository, urlFx, urlCh)
 
-			req, err := test.HttpRequest(nil, "/payment/"+bson.NewObjectId().Hex(), http.MethodGet)
+			req, err := test.HttpRequest(nil, "/payment/"+model.NewID().String(), http.MethodGet)
 			router := handler.NewRouter()
 			w := httptest.NewRecorder()
 
@@ -254,7 +253,7 @@ func TestPaymentHandler_ForPaymentNotFoundDeleteShouldReturn404(t *testing.T) {
 
 			// delete the resource
 			w = httptest.NewRecorder()
-			req, err = test.HttpRequest(nil, "/payment/"+bson.NewObjectId().Hex(), http.MethodDelete)
+			req, err = test.HttpRequest(nil, "/payment/"+model.NewID().String(), http.MethodDelete)
 			router.ServeHTTP(w, req)
 
 			// assert successful delete
@@ -313,8 +312,8 @@ func TestPaymentHandler_AttemptUpdatePaymentNotFoundShouldReturn404(t *testing.T
 			// update payment
 			newDebtorAccNum := "GB29XABC101613434343"
 			update := test.CreatePaymentRequest(beneficiaryAccountNum, newDebtorAccNum, beneficiaryCurrency)
-			dummyId := bson.NewObjectId()
-			req, err := test.HttpRequest(update, "/payment/"+dummyId.Hex(), http.MethodPut)
+			dummyId := model.NewID()
+			req, err := test.HttpRequest(update, "/payment/"+dummyId.String(), http.MethodPut)
 
 			w := httptest.NewRecorder()
 			router := handler.NewRouter()
@@ -324,3 +323,167 @@ func TestPaymentHandler_AttemptUpdatePaymentNotFoundShouldReturn404(t *testing.T
 		}
 	}
 }
+
+func TestPaymentHandler_CreatePaymentWithClientIDShouldUseIt(t *testing.T) {
+	t.Logf("Given the need to create a payment with a client supplied UUID")
+	{
+		t.Logf("\tWhen sending Create Payment request twice to endpoint %s", "\\payment")
+		{
+			handler := api.NewPaymentHandler(Repository, urlFx, urlCh)
+			router := handler.NewRouter()
+			body := test.CreatePaymentRequest(beneficiaryAccountNum, debtorAccountNumb, beneficiaryCurrency)
+			body.ID = "0d4d0ab6-5b0a-4bd9-95f6-1f6b2c8a7e11"
+
+			w := httptest.NewRecorder()
+			req, err := test.HttpRequest(body, "/payment", http.MethodPost)
+			router.ServeHTTP(w, req)
+
+			test.AssertForCallErrorAndHttpStatusCode(err, t, w.Code, http.StatusCreated)
+
+			var response model.CreatePaymentResponse
+			json.NewDecoder(w.Body).Decode(&response)
+			if response.ID == body.ID {
+				t.Logf("\t\tThe response should contain the client payment id. %v %v", body.ID, test.CheckMark)
+			} else {
+				t.Errorf("\t\tThe response should contain the client payment id. %v %v %v", body.ID, test.BallotX, response.ID)
+			}
+
+			// the same ID cannot be used twice
+			w = httptest.NewRecorder()
+			req, err = test.HttpRequest(body, "/payment", http.MethodPost)
+			router.ServeHTTP(w, req)
+
+			test.AssertForCallErrorAndHttpStatusCode(err, t, w.Code, http.StatusConflict)
+		}
+	}
+}
+
+func TestPaymentHandler_QueryByPaymentIDAttributeShouldReturn200(t *testing.T) {
+	t.Logf("Given the need to Query a payment for its payment_id attribute")
+	{
+		t.Logf("\tWhen sending Query Payment request to endpoint %s", "\\payment")
+		{
+			handler := api.NewPaymentHandler(Repository, urlFx, urlCh)
+			router := handler.NewRouter()
+			body := test.CreatePaymentRequest(beneficiaryAccountNum, debtorAccountNumb, beneficiaryCurrency)
+			body.PaymentID = "4ee3a8d8-ca7b-4290-a52c-dd5b6165ec43"
+
+			w := httptest.NewRecorder()
+			req, err := test.HttpRequest(body, "/payment", http.MethodPost)
+			router.ServeHTTP(w, req)
+
+			test.AssertForCallErrorAndHttpStatusCode(err, t, w.Code, http.StatusCreated)
+
+			var created model.CreatePaymentResponse
+			json.NewDecoder(w.Body).Decode(&created)
+
+			w = httptest.NewRecorder()
+			req, err = test.HttpRequest(nil, "/payment/"+body.PaymentID, http.MethodGet)
+			router.ServeHTTP(w, req)
+
+			test.AssertForCallErrorAndHttpStatusCode(err, t, w.Code, http.StatusOK)
+
+			var response model.PaymentResponse
+			json.NewDecoder(w.Body).Decode(&response)
+			if response.Data[0].ID.String() == created.ID {
+				t.Logf("\t\tThe payment ID should be %v %v", created.ID, test.CheckMark)
+			} else {
+				t.Errorf("\t\tThe payment ID should be %v %v %v", created.ID, test.BallotX, response.Data[0].ID)
+			}
+		}
+	}
+}
+
+func TestPaymentHandler_UpdatePaymentWithUnknownClientIDShouldCreateIt(t *testing.T) {
+	t.Logf("Given the need to create or replace a payment")
+	{
+		t.Logf("\tWhen sending Update Payment request for an unknown UUID to endpoint %s", "\\payment")
+		{
+			handler := api.NewPaymentHandler(Repository, urlFx, urlCh)
+			router := handler.NewRouter()
+			id := "9b1f5c1e-3f5e-4a51-8d8e-3c4b8b9f2d10"
+			body := test.CreatePaymentRequest(beneficiaryAccountNum, debtorAccountNumb, beneficiaryCurrency)
+
+			w := httptest.NewRecorder()
+			req, err := test.HttpRequest(body, "/payment/"+id, http.MethodPut)
+			router.ServeHTTP(w, req)
+
+			test.AssertForCallErrorAndHttpStatusCode(err, t, w.Code, http.StatusCreated)
+
+			// the second call replaces the payment
+			w = httptest.NewRecorder()
+			req, err = test.HttpRequest(body, "/payment/"+id, http.MethodPut)
+			router.ServeHTTP(w, req)
+
+			test.AssertForCallErrorAndHttpStatusCode(err, t, w.Code, http.StatusNoContent)
+		}
+	}
+}
+
+func TestPaymentHandler_PatchPaymentShouldUpdateOnlyGivenFields(t *testing.T) {
+	t.Logf("Given the need to partially update a payment")
+	{
+		t.Logf("\tWhen sending a merge patch to endpoint %s", "\\payment")
+		{
+			handler := api.NewPaymentHandler(Repository, urlFx, urlCh)
+			res := test.CreatePaymentAndAssertResponse(t, handler)
+			router := handler.NewRouter()
+
+			req, err := http.NewRequest(http.MethodPatch, "/payment/"+res.ID, strings.NewReader(`{"reference": "Patched"}`))
+			req.Header.Set("Content-Type", api.MergePatchContentType)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			test.AssertForCallErrorAndHttpStatusCode(err, t, w.Code, http.StatusOK)
+
+			req, err = test.HttpRequest(nil, "/payment/"+res.ID, http.MethodGet)
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			test.AssertForCallErrorAndHttpStatusCode(err, t, w.Code, http.StatusOK)
+
+			var response model.PaymentResponse
+			json.NewDecoder(w.Body).Decode(&response)
+			if response.Data[0].Reference == "Patched" && response.Data[0].DebtorParty.AccountNumber == debtorAccountNumb {
+				t.Logf("\t\tOnly the reference should have changed %v", test.CheckMark)
+			} else {
+				t.Errorf("\t\tOnly the reference should have changed %v %v", test.BallotX, response.Data[0])
+			}
+		}
+	}
+}
+
+func TestPaymentHandler_QueryAllWithPageShouldReturnOnePage(t *testing.T) {
+	t.Logf("Given the need to query the payments one page at a time")
+	{
+		handler := api.NewPaymentHandler(Repository, urlFx, urlCh)
+		test.CreatePaymentAndAssertResponse(t, handler)
+		test.CreatePaymentAndAssertResponse(t, handler)
+		router := handler.NewRouter()
+
+		t.Logf("\tWhen sending query request to endpoint %s", "\\payment?page[size]=1")
+		{
+			req, err := http.NewRequest(http.MethodGet, "/payment?page[number]=0&page[size]=1", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			test.AssertForCallErrorAndHttpStatusCode(err, t, w.Code, http.StatusOK)
+			var response model.PaymentResponse
+			json.NewDecoder(w.Body).Decode(&response)
+			if len(response.Data) == 1 && response.Next == "/payment?page%5Bnumber%5D=1&page%5Bsize%5D=1" {
+				t.Logf("\t\tThe first page should link to the next one %v", test.CheckMark)
+			} else {
+				t.Errorf("\t\tThe first page should link to the next one %v %v", test.BallotX, response)
+			}
+		}
+
+		t.Logf("\tWhen sending an invalid page")
+		{
+			req, err := http.NewRequest(http.MethodGet, "/payment?page[size]=0", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			test.AssertForCallErrorAndHttpStatusCode(err, t, w.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -1,16 +1,21 @@
 package api_test
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/golang/mock/gomock"
 	"payment-service/api"
 	"payment-service/mocks"
 	"payment-service/model"
+	"payment-service/repository"
 	"payment-service/test"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 )
 
 // Handle DB insertion failure
@@ -28,7 +33,7 @@ func TestCratePayment_DBFailureShouldReturn500(t *testing.T) {
 			err := errors.New(expectedErrorMessage)
 
 			// set mock expectation
-			mockRepo.EXPECT().Insert(gomock.Any(), gomock.Any(), gomock.Any()).Return(err).Times(1)
+			mockRepo.EXPECT().Insert(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(err).Times(1)
 
 			handler := api.NewPaymentHandler(mockRepo, "urlFX", "urlCF")
 			router := handler.NewRouter()
@@ -66,8 +71,8 @@ func TestDeletePayment_DBFailureShouldReturn500(t *testing.T) {
 			err := errors.New(expectedErrorMessage)
 
 			// set mock expectation
-			mockRepo.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Any()).Return(err).Times(1)
-			mockRepo.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any()).Return(model.PaymentResponse{}, nil).Times(1)
+			mockRepo.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(err).Times(1)
+			mockRepo.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(model.PaymentResponse{}, nil).Times(1)
 
 			handler := api.NewPaymentHandler(mockRepo, "urlFX", "urlCF")
 			router := handler.NewRouter()
@@ -89,3 +94,381 @@ func TestDeletePayment_DBFailureShouldReturn500(t *testing.T) {
 		}
 	}
 }
+
+// Handle malformed payment ID
+func TestPaymentHandler_MalformedIDShouldReturn400(t *testing.T) {
+	t.Logf("Given the payment service is up and running")
+	{
+		for _, method := range []string{http.MethodGet, http.MethodDelete, http.MethodPut} {
+			t.Logf("\tWhen sending %s request with a malformed ID to endpoint:  \"%s\"", method, "\\payment\\not-an-id")
+			{
+				mockCtrl := gomock.NewController(t)
+				mockRepo := mocks.NewMockRepository(mockCtrl)
+
+				handler := api.NewPaymentHandler(mockRepo, "urlFX", "urlCF")
+				router := handler.NewRouter()
+
+				body := test.CreatePaymentRequest("31926819", "GB29XABC10161234567801", "USD")
+				req, err := test.HttpRequest(body, "/payment/not-an-id", method)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				// Assert response code status
+				test.AssertForCallErrorAndHttpStatusCode(err, t, w.Code, http.StatusBadRequest)
+
+				var response model.ErrorResponse
+				json.NewDecoder(w.Body).Decode(&response)
+
+				expectedResponse := model.ErrorResponse{Code: http.StatusBadRequest, Message: "Invalid payment ID"}
+
+				// check body response matches the expected response
+				test.CheckResponseMessage(response, expectedResponse, t, w)
+				mockCtrl.Finish()
+			}
+		}
+	}
+}
+
+// Filter the payment list by organisation and processing date
+func TestFindAllPayments_FilterShouldQueryTheMatchingPayments(t *testing.T) {
+	t.Logf("Given the payment service is up and running")
+	{
+		t.Logf("\tWhen sending Query Payments request filtered by organisation and processing date to endpoint:  \"%s\"", "\\payment")
+		{
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockRepo := mocks.NewMockRepository(mockCtrl)
+
+			filter := repository.Filter{OrganisationID: "org-a", ProcessedFrom: time.Date(2018, 9, 10, 0, 0, 0, 0, time.UTC),
+				ProcessedTo: time.Date(2018, 9, 11, 12, 0, 0, 0, time.UTC)}
+			stored := []model.Payment{storedPayment(), storedPayment()}
+			mockRepo.EXPECT().FindPage(gomock.Any(), gomock.Any(), gomock.Any(), filter, 0, 2).Return(model.PaymentResponse{Data: stored}, nil).Times(1)
+
+			handler := api.NewPaymentHandler(mockRepo, "urlFX", "urlCF")
+			router := handler.NewRouter()
+
+			query := url.Values{api.PageSize: {"1"}, api.FilterOrganisationID: {"org-a"}, api.FilterProcessedFrom: {"2018-09-10"},
+				api.FilterProcessedTo: {"2018-09-11T12:00:00Z"}}
+			req, err := http.NewRequest(http.MethodGet, "/payment?"+query.Encode(), nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			test.AssertForCallErrorAndHttpStatusCode(err, t, w.Code, http.StatusOK)
+			var response model.PaymentResponse
+			json.NewDecoder(w.Body).Decode(&response)
+			next, _ := url.Parse(response.Next)
+			check(t, len(response.Data) == 1 && next != nil && next.Query().Get(api.FilterOrganisationID) == "org-a" &&
+				next.Query().Get(api.FilterProcessedFrom) == "2018-09-10", "The next page should keep the filter", response.Next)
+		}
+
+		t.Logf("\tWhen sending Query Payments request with a malformed processing date to endpoint:  \"%s\"", "\\payment")
+		{
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			// no call to the repository is expected
+			mockRepo := mocks.NewMockRepository(mockCtrl)
+
+			router := api.NewPaymentHandler(mockRepo, "urlFX", "urlCF").NewRouter()
+			req, err := http.NewRequest(http.MethodGet, "/payment?filter[processing_date_from]=yesterday", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			test.AssertForCallErrorAndHttpStatusCode(err, t, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+// Handle malformed page parameters, refused before querying the payments
+func TestFindAllPayments_MalformedPageShouldReturn400(t *testing.T) {
+	t.Logf("Given the payment service is up and running")
+	{
+		for _, query := range []string{"page[number]=first", "page[size]=ten"} {
+			t.Logf("\tWhen sending Query Payments request with %s to endpoint:  \"%s\"", query, "\\payment")
+			{
+				mockCtrl := gomock.NewController(t)
+				// no call to the repository is expected
+				mockRepo := mocks.NewMockRepository(mockCtrl)
+
+				handler := api.NewPaymentHandler(mockRepo, "urlFX", "urlCF")
+				router := handler.NewRouter()
+
+				req, err := http.NewRequest(http.MethodGet, "/payment?"+query, nil)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				test.AssertForCallErrorAndHttpStatusCode(err, t, w.Code, http.StatusBadRequest)
+				mockCtrl.Finish()
+			}
+		}
+	}
+}
+
+// Handle client supplied ID which is not a UUID
+func TestCreatePayment_ClientObjectIdShouldReturn400(t *testing.T) {
+	t.Logf("Given the payment service is up and running")
+	{
+		t.Logf("\tWhen Sending Create Payment request with an ObjectId to endpoint:  \"%s\"", "\\payment")
+		{
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockRepo := mocks.NewMockRepository(mockCtrl)
+
+			body := test.CreatePaymentRequest("31926819", "GB29XABC10161234567801", "USD")
+			body.ID = "5bd7506a9900b30008edf576"
+
+			handler := api.NewPaymentHandler(mockRepo, "urlFX", "urlCF")
+			router := handler.NewRouter()
+
+			req, err := test.HttpRequest(body, "/payment", http.MethodPost)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Assert response code status
+			test.AssertForCallErrorAndHttpStatusCode(err, t, w.Code, http.StatusBadRequest)
+
+			var response model.ErrorResponse
+			json.NewDecoder(w.Body).Decode(&response)
+
+			expectedResponse := model.ErrorResponse{Code: http.StatusBadRequest, Message: "Invalid payment ID"}
+
+			// check body response matches the expected response
+			test.CheckResponseMessage(response, expectedResponse, t, w)
+		}
+	}
+}
+
+// Helper function returning a stored payment with a 2.0 exchange rate applied
+func storedPayment() model.Payment {
+	req := test.CreatePaymentRequest("31926819", "GB29XABC10161234567801", "USD")
+	return model.Payment{Type: "Payment", ID: "5bd7506a9900b30008edf576", OrganisationId: req.OrganisationID,
+		Attributes: model.Attributes{Amount: req.Amount / 2, BeneficiaryParty: req.BeneficiaryParty, DebtorParty: req.DebtorParty,
+			Currency: "USD", Reference: req.Reference, ChargesInformation: model.ChargesInformation{BearerCode: req.BearerCode},
+			Fx: model.ForeignExchange{ExchangeRate: 2.0, OriginalAmount: req.Amount, OriginalCurrency: "USD"}}}
+}
+
+// Helper function to send a merge patch for the stored payment
+func patchPayment(t *testing.T, mockRepo *mocks.MockRepository, patch string, contentType string) *httptest.ResponseRecorder {
+	handler := api.NewPaymentHandler(mockRepo, "urlFX", "urlCF")
+	router := handler.NewRouter()
+
+	req, err := http.NewRequest(http.MethodPatch, "/payment/5bd7506a9900b30008edf576", strings.NewReader(patch))
+	if err != nil {
+		t.Fatal("\t\tShould be able to create the Patch request.", test.BallotX, err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// Patch of a field unrelated to pricing
+func TestPatchPayment_ReferenceShouldNotRecalculateFX(t *testing.T) {
+	t.Logf("Given the payment service is up and running")
+	{
+		t.Logf("\tWhen Sending a merge patch changing the reference to endpoint:  \"%s\"", "\\payment\\{id}")
+		{
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockRepo := mocks.NewMockRepository(mockCtrl)
+
+			original := storedPayment()
+			expected := original
+			expected.Reference = "New reference"
+
+			mockRepo.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(model.PaymentResponse{Data: []model.Payment{original}}, nil).Times(1)
+			mockRepo.EXPECT().Patch(gomock.Any(), gomock.Any(), gomock.Any(), original.ID, original, expected).Return(nil).Times(1)
+
+			w := patchPayment(t, mockRepo, `{"reference": "New reference"}`, api.MergePatchContentType)
+
+			test.AssertForCallErrorAndHttpStatusCode(nil, t, w.Code, http.StatusOK)
+		}
+	}
+}
+
+// Patch of the amount
+func TestPatchPayment_AmountShouldRecalculateFX(t *testing.T) {
+	t.Logf("Given the payment service is up and running")
+	{
+		t.Logf("\tWhen Sending a merge patch changing the amount to endpoint:  \"%s\"", "\\payment\\{id}")
+		{
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockRepo := mocks.NewMockRepository(mockCtrl)
+
+			original := storedPayment()
+
+			mockRepo.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(model.PaymentResponse{Data: []model.Payment{original}}, nil).Times(1)
+			mockRepo.EXPECT().Patch(gomock.Any(), gomock.Any(), gomock.Any(), original.ID, original, gomock.Any()).Return(nil).Times(1)
+
+			w := patchPayment(t, mockRepo, `{"amount": 500}`, api.MergePatchContentType)
+
+			test.AssertForCallErrorAndHttpStatusCode(nil, t, w.Code, http.StatusOK)
+
+			var response model.PaymentResponse
+			json.NewDecoder(w.Body).Decode(&response)
+			if response.Data[0].Amount == 500 && response.Data[0].Fx.OriginalAmount == 1000 {
+				t.Logf("\t\tThe amount should be stored as sent, the original amount following the exchange rate %v %v", 500, test.CheckMark)
+			} else {
+				t.Errorf("\t\tThe amount should be stored as sent, the original amount following the exchange rate %v %v %v", 500, test.BallotX, response.Data[0].Attributes)
+			}
+		}
+	}
+}
+
+// Patch removing a required field
+func TestPatchPayment_RemovingOrganisationShouldReturn400(t *testing.T) {
+	t.Logf("Given the payment service is up and running")
+	{
+		t.Logf("\tWhen Sending a merge patch removing the organisation to endpoint:  \"%s\"", "\\payment\\{id}")
+		{
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockRepo := mocks.NewMockRepository(mockCtrl)
+
+			mockRepo.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(model.PaymentResponse{Data: []model.Payment{storedPayment()}}, nil).Times(1)
+
+			w := patchPayment(t, mockRepo, `{"organisation_id": null}`, api.MergePatchContentType)
+
+			test.AssertForCallErrorAndHttpStatusCode(nil, t, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+// Patch which is not a JSON object
+func TestPatchPayment_NullPatchShouldReturn400(t *testing.T) {
+	t.Logf("Given the payment service is up and running")
+	{
+		t.Logf("\tWhen Sending a null merge patch to endpoint:  \"%s\"", "\\payment\\{id}")
+		{
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockRepo := mocks.NewMockRepository(mockCtrl)
+
+			mockRepo.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(model.PaymentResponse{Data: []model.Payment{storedPayment()}}, nil).Times(1)
+
+			w := patchPayment(t, mockRepo, `null`, api.MergePatchContentType)
+
+			test.AssertForCallErrorAndHttpStatusCode(nil, t, w.Code, http.StatusBadRequest)
+
+			var response model.ErrorResponse
+			json.NewDecoder(w.Body).Decode(&response)
+
+			expectedResponse := model.ErrorResponse{Code: http.StatusBadRequest, Message: "Failed to parse payment patch"}
+
+			// check body response matches the expected response
+			test.CheckResponseMessage(response, expectedResponse, t, w)
+		}
+	}
+}
+
+// Patch with an unsupported media type
+func TestPatchPayment_UnsupportedMediaTypeShouldReturn415(t *testing.T) {
+	t.Logf("Given the payment service is up and running")
+	{
+		t.Logf("\tWhen Sending a patch as XML to endpoint:  \"%s\"", "\\payment\\{id}")
+		{
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockRepo := mocks.NewMockRepository(mockCtrl)
+
+			w := patchPayment(t, mockRepo, `<reference>New</reference>`, "application/xml")
+
+			test.AssertForCallErrorAndHttpStatusCode(nil, t, w.Code, http.StatusUnsupportedMediaType)
+		}
+	}
+}
+
+// Patch with an unknown member
+func TestPatchPayment_UnknownMemberShouldReturn400(t *testing.T) {
+	t.Logf("Given the payment service is up and running")
+	{
+		t.Logf("\tWhen Sending a merge patch with a misspelt member to endpoint:  \"%s\"", "\\payment\\{id}")
+		{
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockRepo := mocks.NewMockRepository(mockCtrl)
+
+			mockRepo.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(model.PaymentResponse{Data: []model.Payment{storedPayment()}}, nil).Times(1)
+
+			w := patchPayment(t, mockRepo, `{"amout": 5}`, api.MergePatchContentType)
+
+			test.AssertForCallErrorAndHttpStatusCode(nil, t, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+// Patch sent as a JSON patch, which is not supported
+func TestPatchPayment_JSONPatchShouldReturn415(t *testing.T) {
+	t.Logf("Given the payment service is up and running")
+	{
+		t.Logf("\tWhen Sending a JSON patch to endpoint:  \"%s\"", "\\payment\\{id}")
+		{
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockRepo := mocks.NewMockRepository(mockCtrl)
+
+			w := patchPayment(t, mockRepo, `[{"op": "replace", "path": "/reference", "value": "New"}]`, api.JSONPatchContentType)
+
+			test.AssertForCallErrorAndHttpStatusCode(nil, t, w.Code, http.StatusUnsupportedMediaType)
+
+			var response model.ErrorResponse
+			json.NewDecoder(w.Body).Decode(&response)
+			check(t, response.Message == "JSON Patch is not supported, send a JSON merge patch", "The error should name the supported format", response)
+		}
+	}
+}
+
+// Handle request running past the route deadline
+func TestFindPayment_RouteDeadlineShouldReturn504(t *testing.T) {
+	t.Logf("Given the payment service is up and running with a short deadline on the query route")
+	{
+		t.Logf("\tWhen Sending Query Payment request to endpoint:  \"%s\"", "\\payment\\{id}")
+		{
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockRepo := mocks.NewMockRepository(mockCtrl)
+
+			// the repository only returns once the request context is done
+			mockRepo.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+				func(ctx context.Context, db, col string, id model.ID) { <-ctx.Done() }).Return(
+				model.PaymentResponse{}, model.ErrDeadlineExceeded).Times(1)
+
+			handler := api.NewPaymentHandler(mockRepo, "urlFX", "urlCF").WithTimeouts(api.Timeouts{"GET /payment/:id": time.Millisecond})
+			router := handler.NewRouter()
+
+			req, err := test.HttpRequest(nil, "/payment/5bd7506a9900b30008edf576", http.MethodGet)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// Assert response code status
+			test.AssertForCallErrorAndHttpStatusCode(err, t, w.Code, http.StatusGatewayTimeout)
+		}
+	}
+}
+
+// Handle request cancelled by the client
+func TestCreatePayment_CancelledRequestShouldReturn499(t *testing.T) {
+	t.Logf("Given the payment service is up and running")
+	{
+		t.Logf("\tWhen Sending Create Payment request which is cancelled to endpoint:  \"%s\"", "\\payment")
+		{
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockRepo := mocks.NewMockRepository(mockCtrl)
+
+			handler := api.NewPaymentHandler(mockRepo, "urlFX", "urlCF")
+			router := handler.NewRouter()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			body := test.CreatePaymentRequest("31926819", "GB29XABC10161234567801", "USD")
+			req, err := test.HttpRequest(body, "/payment", http.MethodPost)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req.WithContext(ctx))
+
+			// Assert response code status
+			test.AssertForCallErrorAndHttpStatusCode(err, t, w.Code, api.StatusClientClosedRequest)
+		}
+	}
+}
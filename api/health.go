@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"payment-service/health"
+)
+
+// WithHealth makes the readiness of the service depend on the checks of the given registry
+func (h *PaymentHandler) WithHealth(registry *health.Registry) *PaymentHandler {
+	h.health = registry
+	return h
+}
+
+// @Summary Tells whether the service process is alive
+// @Description The dependencies are not checked, a failing liveness meaning the process should be restarted.
+// @ID get-health-live
+// @Produce  json
+// @Success 200 {object} health.Report "ok"
+// @Router /health/live [get]
+func (h *PaymentHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, health.Report{Status: health.StatusUp})
+}
+
+// @Summary Tells whether the service is ready to serve requests
+// @Description Checks the repository and the upstream services, the results being cached for a few seconds. The
+// @Description service is degraded, but still ready, when only the optional upstream services are unavailable.
+// @ID get-health-ready
+// @Produce  json
+// @Success 200 {object} health.Report "ready"
+// @Failure 503 {object} health.Report "not ready"
+// @Router /health/ready [get]
+func (h *PaymentHandler) Ready(c *gin.Context) {
+	report := health.Report{Status: health.StatusUp}
+	if h.health != nil {
+		report = h.health.Check(c.Request.Context())
+	}
+	status := http.StatusOK
+	if report.Status == health.StatusDown {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
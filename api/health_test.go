@@ -0,0 +1,77 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"payment-service/api"
+	"payment-service/health"
+	"payment-service/test"
+)
+
+func TestPaymentHandler_LivenessAndReadiness(t *testing.T) {
+	t.Logf("Given the repository check and an optional upstream check")
+	{
+		var upstream error
+		registry := health.NewRegistry(time.Second, 0)
+		registry.Register("repository", health.CheckerFunc(Repository.Ping), true)
+		registry.Register("fx", health.CheckerFunc(func(context.Context) error { return upstream }), false)
+		router := api.NewPaymentHandler(Repository, urlFx, urlCh).WithHealth(registry).NewRouter()
+
+		t.Logf("\tWhen checking \"%s\" and \"%s\"", "\\health\\live", "\\health\\ready")
+		{
+			w, report := probe(router, "/health/live")
+			test.AssertForCallErrorAndHttpStatusCode(nil, t, w.Code, http.StatusOK)
+			check(t, report.Status == health.StatusUp && len(report.Checks) == 0, "The liveness should not check the dependencies", report)
+
+			w, report = probe(router, "/health/ready")
+			test.AssertForCallErrorAndHttpStatusCode(nil, t, w.Code, http.StatusOK)
+			check(t, report.Status == health.StatusUp && report.Checks["repository"].Status == health.StatusUp,
+				"The service should be ready", report)
+		}
+
+		t.Logf("\tWhen the upstream service is unavailable")
+		{
+			upstream = errors.New("connection refused")
+			w, report := probe(router, "/health/ready")
+			test.AssertForCallErrorAndHttpStatusCode(nil, t, w.Code, http.StatusOK)
+			check(t, report.Status == health.StatusDegraded && report.Checks["fx"].Error == "connection refused",
+				"The service should be degraded but ready", report)
+		}
+
+		t.Logf("\tWhen the repository is unavailable")
+		{
+			registry.Register("repository", health.CheckerFunc(func(context.Context) error { return errors.New("no reachable servers") }), true)
+			w, report := probe(router, "/health/ready")
+			test.AssertForCallErrorAndHttpStatusCode(nil, t, w.Code, http.StatusServiceUnavailable)
+			check(t, report.Status == health.StatusDown, "The service should not be ready", report)
+
+			w, _ = probe(router, "/health/live")
+			test.AssertForCallErrorAndHttpStatusCode(nil, t, w.Code, http.StatusOK)
+		}
+	}
+}
+
+// Helper function to call a health endpoint, decoding its report
+func probe(router http.Handler, path string) (*httptest.ResponseRecorder, health.Report) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	router.ServeHTTP(w, req)
+	var report health.Report
+	json.Unmarshal(w.Body.Bytes(), &report)
+	return w, report
+}
+
+// Helper function to log an expectation with a check mark, failing the test with a ballot when it is not met
+func check(t *testing.T, ok bool, expectation string, got interface{}) {
+	if ok {
+		t.Logf("\t\t%s. %v", expectation, test.CheckMark)
+	} else {
+		t.Errorf("\t\t%s. %v %v", expectation, got, test.BallotX)
+	}
+}
@@ -1,53 +1,55 @@
 package api_test
 
 import (
+	"context"
+	"fmt"
+	"payment-service/api"
 	"payment-service/repository"
-	"io/ioutil"
+	"payment-service/test/mongotest"
 	"os"
 	"testing"
-
-	"github.com/globalsign/mgo"
-	"github.com/globalsign/mgo/dbtest"
 )
 
 const (
 	DBName = "test"
-	dir    = "testing-db"
 )
 
-var Server dbtest.DBServer
-var Session *mgo.Session
+var Server *mongotest.Server
 
-var Repository *repository.MongoRepository
+var Repository repository.Repository
 
 // TestMain wraps all tests with the needed initialized mock DB and fixtures
 // This test runs before other integration test. It starts an instance of mongo db in the background (provided you have mongo
-// installed on the server on which this test will be running) and shuts it down.
+// installed on the server on which this test will be running, or MONGO_TEST_URL points at one) and shuts it down.
 func TestMain(m *testing.M) {
 
-	// The tempdir is created so MongoDB has a location to store its files.
-	// Contents are wiped once the server stops
-	tempDir, _ := ioutil.TempDir("", dir)
-	Server.SetPath(tempDir)
-
-	// My main session var is now set to the temporary MongoDB instance
-	Session = Server.Session()
-
-	Repository = &repository.MongoRepository{Session}
+	// Start a mongod storing its files in a temporary directory wiped once the server stops
+	var err error
+	Server, err = mongotest.Start()
+	if err == mongotest.ErrUnavailable {
+		// run the integration tests against the in memory repository instead
+		fmt.Println("using the in memory repository:", err)
+		Repository = repository.NewMemoryRepository()
+		Repository.EnsureIndexes(context.Background(), api.DatabaseName, api.CollectionName)
+		os.Exit(m.Run())
+	}
+	if err != nil {
+		fmt.Println("failed to start mongo:", err)
+		os.Exit(1)
+	}
+
+	Repository = &repository.MongoRepository{Client: Server.Client}
+	Repository.EnsureIndexes(context.Background(), api.DatabaseName, api.CollectionName)
 
 	// Run the test suite
 	retCode := m.Run()
 
-	// Make sure we DropDatabase so we make absolutely sure nothing is left or locked while wiping the data and
-	// close session
-	Session.DB(DBName).DropDatabase()
-	Session.Close()
+	// Make sure we DropDatabase so we make absolutely sure nothing is left or locked while wiping the data
+	Server.Client.Database(DBName).Drop(context.Background())
 
 	// Stop shuts down the temporary server and removes data on disk.
 	Server.Stop()
 
-	os.RemoveAll(tempDir)
-
 	// call with result of m.Run()
 	os.Exit(retCode)
 }
@@ -0,0 +1,68 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"payment-service/api"
+	"payment-service/logger"
+	"payment-service/test"
+)
+
+func TestPaymentHandler_RequestIDShouldBeLoggedAndReturned(t *testing.T) {
+	t.Logf("Given the payment API logging as JSON")
+	{
+		var out bytes.Buffer
+		l, _ := logger.New(&out, "info", logger.FormatJSON)
+		defer slog.SetDefault(slog.Default())
+		slog.SetDefault(l)
+		router := api.NewPaymentHandler(Repository, urlFx, urlCh).NewRouter()
+
+		t.Logf("\tWhen sending a request with an X-Request-ID header")
+		{
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, "/payment", nil)
+			req.Header.Set(logger.RequestIDHeader, "req-1")
+			router.ServeHTTP(w, req)
+			test.AssertForCallErrorAndHttpStatusCode(err, t, w.Code, http.StatusOK)
+			check(t, w.Header().Get(logger.RequestIDHeader) == "req-1", "The request ID should be returned", w.Header())
+
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			tagged := 0
+			var access map[string]interface{}
+			for _, line := range lines {
+				var record map[string]interface{}
+				json.Unmarshal([]byte(line), &record)
+				if record[logger.RequestIDKey] == "req-1" {
+					tagged++
+				}
+				if record["msg"] == "Served request" {
+					access = record
+				}
+			}
+			check(t, len(lines) >= 2 && tagged == len(lines), "Every log line should carry the request ID", out.String())
+			check(t, access != nil && access["status"] == float64(http.StatusOK) && access["path"] == "/payment",
+				"The request should have been logged once served", access)
+		}
+
+		t.Logf("\tWhen sending a request without or with an invalid X-Request-ID header")
+		{
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/health", nil)
+			router.ServeHTTP(w, req)
+			generated := w.Header().Get(logger.RequestIDHeader)
+			check(t, logger.ValidRequestID(generated), "A request ID should be generated", generated)
+
+			w = httptest.NewRecorder()
+			req.Header.Set(logger.RequestIDHeader, "forged\" msg=\"x")
+			router.ServeHTTP(w, req)
+			check(t, w.Header().Get(logger.RequestIDHeader) != req.Header.Get(logger.RequestIDHeader) &&
+				w.Header().Get(logger.RequestIDHeader) != generated, "The invalid request ID should be replaced", w.Header())
+		}
+	}
+}
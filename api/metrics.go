@@ -0,0 +1,18 @@
+package api
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"payment-service/metrics"
+)
+
+// Metrics records the duration and status of the requests of the given route, e.g. /payment/:id, the route rather
+// than the path keeping one series per route
+func Metrics(route string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		metrics.ObserveHTTP(c.Request.Method, route, c.Writer.Status(), start)
+	}
+}
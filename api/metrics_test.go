@@ -0,0 +1,41 @@
+package api_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"payment-service/api"
+	"payment-service/test"
+)
+
+func TestPaymentHandler_MetricsShouldBeExposed(t *testing.T) {
+	t.Logf("Given the payment API")
+	{
+		handler := api.NewPaymentHandler(Repository, urlFx, urlCh)
+		res := test.CreatePaymentAndAssertResponse(t, handler)
+		router := handler.NewRouter()
+
+		t.Logf("\tWhen querying a payment then the metrics")
+		{
+			req, _ := http.NewRequest(http.MethodGet, "/payment/"+res.ID, nil)
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+			router.ServeHTTP(w, req)
+			test.AssertForCallErrorAndHttpStatusCode(err, t, w.Code, http.StatusOK)
+			body, _ := ioutil.ReadAll(w.Body)
+			exposed := string(body)
+
+			check(t, strings.Contains(exposed, `payment_service_http_request_duration_seconds_count{method="GET",route="/payment/:id",status="200"}`) &&
+				strings.Contains(exposed, `payment_service_http_request_duration_seconds_count{method="POST",route="/payment",status="201"}`),
+				"The requests should be counted by route rather than path", exposed)
+			check(t, strings.Contains(exposed, `payment_service_payments_created_total{currency="GBP",scheme="FPS"}`) &&
+				strings.Contains(exposed, `payment_service_upstream_request_duration_seconds_count{service="charges"}`) &&
+				!strings.Contains(exposed, res.ID), "The business and upstream metrics should be exposed", exposed)
+		}
+	}
+}
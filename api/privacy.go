@@ -0,0 +1,21 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"payment-service/privacy"
+)
+
+// WithPrivacy masks the party details of the payments and events returned to the roles not unmasked by the given
+// policy, the role being read from the header of the policy
+func (h *PaymentHandler) WithPrivacy(policy *privacy.Policy) *PaymentHandler {
+	h.privacy = policy
+	return h
+}
+
+// Helper function to get the Masker of the role of the caller, nil when the party details may be returned as is
+func (h *PaymentHandler) masker(c *gin.Context) *privacy.Masker {
+	if h.privacy == nil {
+		return nil
+	}
+	return h.privacy.Masker(c.GetHeader(h.privacy.RoleHeader))
+}
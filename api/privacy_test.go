@@ -0,0 +1,64 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"payment-service/api"
+	"payment-service/model"
+	"payment-service/privacy"
+	"payment-service/test"
+)
+
+func TestPaymentHandler_PartiesShouldBeMaskedForAllButTheUnmaskedRoles(t *testing.T) {
+	t.Logf("Given the payment API leaving the party details unmasked for the admin role only")
+	{
+		policy, _ := privacy.NewPolicy(privacy.DefaultRoleHeader, []string{"admin"}, privacy.DefaultFields)
+		handler := api.NewPaymentHandler(Repository, urlFx, urlCh).WithPrivacy(policy)
+		res := test.CreatePaymentAndAssertResponse(t, handler)
+		router := handler.NewRouter()
+
+		t.Logf("\tWhen a viewer queries the payment")
+		{
+			payment, code := findPayment(router, res.ID, "viewer")
+			check(t, code == http.StatusOK && payment.DebtorParty.AccountNumber == "GB****************7801" &&
+				payment.BeneficiaryParty.AccountNumber == "****6819" && payment.BeneficiaryParty.Name == "W****** J******* O****" &&
+				payment.DebtorParty.AccountName == "E* B**** B****" && payment.SponsorParty.AccountNumber == "****1234",
+				"The names and account numbers should be masked", payment)
+			check(t, payment.BeneficiaryParty.BankID == "403000" && payment.Amount == 200.42,
+				"The other details should be returned as is", payment)
+		}
+
+		t.Logf("\tWhen a caller without role header queries the payment")
+		{
+			payment, code := findPayment(router, res.ID, "")
+			check(t, code == http.StatusOK && payment.DebtorParty.AccountNumber == "GB****************7801" &&
+				payment.BeneficiaryParty.Name == "W****** J******* O****", "The party details should be masked", payment)
+		}
+
+		t.Logf("\tWhen an admin queries the payment")
+		{
+			payment, code := findPayment(router, res.ID, "admin")
+			check(t, code == http.StatusOK && payment.DebtorParty.AccountNumber == "GB29XABC10161234567801" &&
+				payment.BeneficiaryParty.Name == "Wilfred Jeremiah Owens", "The party details should be returned as is", payment)
+		}
+	}
+}
+
+// Helper function to query a payment with the given role
+func findPayment(router http.Handler, id, role string) (model.Payment, int) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/payment/"+id, nil)
+	if role != "" {
+		req.Header.Set(privacy.DefaultRoleHeader, role)
+	}
+	router.ServeHTTP(w, req)
+	var resp model.PaymentResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Data) == 0 {
+		return model.Payment{}, w.Code
+	}
+	return resp.Data[0], w.Code
+}
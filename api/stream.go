@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"payment-service/event"
+	"payment-service/model"
+	"payment-service/privacy"
+)
+
+const (
+	// LastEventID the header of a reconnecting event stream client, the id of the last event it received
+	LastEventID = "Last-Event-ID"
+
+	// ResetEvent sent first when events after Last-Event-ID are no longer available, the client having to reload the
+	// payments before relying on the stream again
+	ResetEvent = "reset"
+
+	// KeepAliveInterval the interval of the comments keeping idle streams open through proxies
+	KeepAliveInterval = 15 * time.Second
+)
+
+// WithStream enables the payment event stream, fed by the given stream
+func (h *PaymentHandler) WithStream(stream *event.Stream) *PaymentHandler {
+	h.stream = stream
+	return h
+}
+
+// @Summary Streams the payment events as Server-Sent Events
+// @Description Each event is a CloudEvents JSON message. Reconnecting clients send Last-Event-ID to resume, a reset event meaning events were missed.
+// @ID stream-payment-events
+// @Produce  text/event-stream
+// @Param organisation_id query string false "Organisation ID"
+// @Param scheme query string false "Payment scheme"
+// @Param status query string false "Payment status"
+// @Success 200 {string} string "Event stream"
+// @Router /payment/events [get]
+func (h *PaymentHandler) StreamEvents(c *gin.Context) {
+	filter := eventFilter(c.Query(OrganisationID), c.Query("scheme"), c.Query("status"))
+	after, _ := strconv.ParseUint(c.GetHeader(LastEventID), 10, 64)
+	backlog, complete, sub := h.stream.Subscribe(after, filter)
+	defer sub.Cancel()
+	masker := h.masker(c)
+	slog.InfoContext(c.Request.Context(), "Streaming payment events", "after", after)
+
+	header := c.Writer.Header()
+	header.Set(ContentType, "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if !complete {
+		fmt.Fprintf(c.Writer, "event: %s\ndata: {}\n\n", ResetEvent)
+	}
+	for _, entry := range backlog {
+		if err := writeEvent(c, masker, entry); err != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(KeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case entry, ok := <-sub.C:
+			if !ok {
+				// the client fell behind, it reconnects and resumes from the log
+				if sub.Lagged() {
+					slog.InfoContext(c.Request.Context(), "Disconnecting slow payment event consumer")
+				}
+				return
+			}
+			if err := writeEvent(c, masker, entry); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
+
+// @Summary Get the recent events of a payment
+// @Description The events are the ones kept by the event stream of the instance, the last 1000 payment events.
+// @ID get-payment-history
+// @Produce  json
+// @Success 200 {object} model.EventResponse "ok"
+// @Failure 400 {object} model.ErrorResponse "Bad request"
+// @Router /payment/{id}/history [get]
+func (h *PaymentHandler) PaymentHistory(c *gin.Context) {
+	id := paymentID(c)
+	slog.InfoContext(c.Request.Context(), "Received request to query the history of a payment", "payment_id", id.String())
+
+	resp := model.EventResponse{Data: []model.Event{}}
+	for _, entry := range h.stream.Events(func(e model.Event) bool { return e.PaymentID == id }) {
+		resp.Data = append(resp.Data, h.masker(c).Event(entry.Event))
+	}
+	c.Writer.Header().Set(ContentType, mime.TypeByExtension("json"))
+	c.JSON(http.StatusOK, resp)
+}
+
+// Helper function to write an event of the stream, its parties masked by the masker of the caller
+func writeEvent(c *gin.Context, masker *privacy.Masker, entry event.Entry) error {
+	data, err := json.Marshal(event.NewCloudEvent(event.DefaultSource, masker.Event(entry.Event)))
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to encode the event", "error", err)
+		return err
+	}
+	_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", entry.Seq, entry.Event.Type, data)
+	return err
+}
+
+// Helper function to build the filter of the streamed events, empty criteria matching every event
+func eventFilter(organisationID, scheme, status string) func(model.Event) bool {
+	return func(e model.Event) bool {
+		return (organisationID == "" || e.Payment.OrganisationId == organisationID) &&
+			(scheme == "" || e.Payment.PaymentScheme == scheme) &&
+			(status == "" || e.Payment.Status == status)
+	}
+}
@@ -0,0 +1,155 @@
+package api_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"payment-service/api"
+	"payment-service/event"
+	"payment-service/model"
+	"payment-service/test"
+)
+
+func TestPaymentHandler_StreamEvents(t *testing.T) {
+	t.Logf("Given the need to stream the payment events of an organisation")
+	{
+		stream := event.NewStream(10, 10)
+		server := httptest.NewServer(api.NewPaymentHandler(Repository, urlFx, urlCh).WithStream(stream).NewRouter())
+		defer server.Close()
+
+		payment := model.Payment{ID: model.NewID(), OrganisationId: "org-1", Status: "accepted"}
+		payment.PaymentScheme = "FPS"
+		created := model.NewEvent(model.PaymentCreated, payment)
+		stream.Publish(context.Background(), created)
+
+		t.Logf("\tWhen connecting to endpoint %s", "\\payment\\events")
+		{
+			resp, lines := connect(t, server.URL+"/payment/events?organisation_id=org-1&scheme=FPS", "")
+			defer resp.Body.Close()
+			test.AssertForCallErrorAndHttpStatusCode(nil, t, resp.StatusCode, http.StatusOK)
+			if strings.HasPrefix(resp.Header.Get(api.ContentType), "text/event-stream") {
+				t.Logf("\t\tThe response should be an event stream. %v", test.CheckMark)
+			} else {
+				t.Errorf("\t\tThe response should be an event stream. %v %v", resp.Header, test.BallotX)
+			}
+
+			other := payment
+			other.OrganisationId = "org-2"
+			stream.Publish(context.Background(), model.NewEvent(model.PaymentCreated, other))
+			deleted := model.NewEvent(model.PaymentDeleted, payment)
+			stream.Publish(context.Background(), deleted)
+
+			id, name, data := next(t, lines)
+			if name == model.PaymentDeleted && strings.Contains(data, deleted.ID) {
+				t.Logf("\t\tThe stream should only carry the later events of the organisation. %v", test.CheckMark)
+			} else {
+				t.Errorf("\t\tThe stream should only carry the later events of the organisation. %s %s %v", name, data, test.BallotX)
+			}
+
+			t.Logf("\tWhen reconnecting with the Last-Event-ID header")
+			{
+				stream.Publish(context.Background(), model.NewEvent(model.PaymentUpdated, payment))
+				resumed, lines := connect(t, server.URL+"/payment/events?organisation_id=org-1", id)
+				defer resumed.Body.Close()
+				_, name, _ := next(t, lines)
+				if name == model.PaymentUpdated {
+					t.Logf("\t\tThe stream should resume after the last received event. %v", test.CheckMark)
+				} else {
+					t.Errorf("\t\tThe stream should resume after the last received event. %s %v", name, test.BallotX)
+				}
+			}
+
+			t.Logf("\tWhen reconnecting after events no longer logged")
+			{
+				reset, lines := connect(t, server.URL+"/payment/events", "1")
+				defer reset.Body.Close()
+				_, name, _ := next(t, lines)
+				if name == api.ResetEvent {
+					t.Logf("\t\tThe stream should start with a reset event. %v", test.CheckMark)
+				} else {
+					t.Errorf("\t\tThe stream should start with a reset event. %s %v", name, test.BallotX)
+				}
+			}
+		}
+	}
+}
+
+func TestPaymentHandler_PaymentHistory(t *testing.T) {
+	t.Logf("Given the need to query the history of a payment")
+	{
+		stream := event.NewStream(10, 10)
+		router := api.NewPaymentHandler(Repository, urlFx, urlCh).WithStream(stream).NewRouter()
+
+		payment := model.Payment{ID: model.NewID(), OrganisationId: "org-1"}
+		stream.Publish(context.Background(), model.NewEvent(model.PaymentCreated, payment))
+		stream.Publish(context.Background(), model.NewEvent(model.PaymentCreated, model.Payment{ID: model.NewID()}))
+		stream.Publish(context.Background(), model.NewEvent(model.PaymentUpdated, payment))
+
+		t.Logf("\tWhen sending query request to endpoint %s", "\\payment\\{id}\\history")
+		{
+			req, err := http.NewRequest(http.MethodGet, "/payment/"+payment.ID.String()+"/history", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			test.AssertForCallErrorAndHttpStatusCode(err, t, w.Code, http.StatusOK)
+			var response model.EventResponse
+			json.NewDecoder(w.Body).Decode(&response)
+			if len(response.Data) == 2 && response.Data[0].Type == model.PaymentCreated && response.Data[1].Type == model.PaymentUpdated {
+				t.Logf("\t\tThe events of the payment should be returned oldest first. %v", test.CheckMark)
+			} else {
+				t.Errorf("\t\tThe events of the payment should be returned oldest first. %v %v", response, test.BallotX)
+			}
+		}
+	}
+}
+
+// Helper function to open an event stream, returning the response and its lines
+func connect(t *testing.T, url, lastEventID string) (*http.Response, <-chan string) {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if lastEventID != "" {
+		req.Header.Set(api.LastEventID, lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to connect to the event stream %v", err)
+	}
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	return resp, lines
+}
+
+// Helper function to read the next event of a stream
+func next(t *testing.T, lines <-chan string) (id, name, data string) {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			switch {
+			case !ok:
+				t.Fatalf("The event stream ended")
+			case line == "" && name != "":
+				return id, name, data
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			}
+		case <-timeout:
+			t.Fatalf("No event received")
+		}
+	}
+}
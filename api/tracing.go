@@ -0,0 +1,30 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"payment-service/tracing"
+)
+
+// Trace serves the request in a server span continuing the trace of its traceparent header, or starting one, for the
+// logs and error responses to carry the trace ID and the downstream calls to propagate it. The health and metrics
+// probes are not traced.
+func Trace(c *gin.Context) {
+	if path := c.Request.URL.Path; strings.HasPrefix(path, "/health") || path == "/metrics" {
+		c.Next()
+		return
+	}
+	ctx, span := tracing.StartHTTP(c.Request)
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+	tracing.EndHTTP(span, c.Writer.Status())
+}
+
+// TraceRoute names the span of the request after its route, e.g. GET /payment/:id
+func TraceRoute(route string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tracing.SetRoute(c.Request.Context(), c.Request.Method, route)
+		c.Next()
+	}
+}
@@ -0,0 +1,68 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+	"payment-service/api"
+	"payment-service/logger"
+	"payment-service/model"
+	"payment-service/tracing"
+)
+
+func TestPaymentHandler_TraceShouldBeContinuedAndReturnedOnErrors(t *testing.T) {
+	t.Logf("Given the payment API traced and logging as JSON")
+	{
+		tracing.Setup(context.Background(), tracing.Options{Exporter: tracing.ExporterNone})
+		recorder := tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		defer otel.SetTracerProvider(noop.NewTracerProvider())
+		var out bytes.Buffer
+		l, _ := logger.New(&out, "info", logger.FormatJSON)
+		defer slog.SetDefault(slog.Default())
+		slog.SetDefault(l)
+		router := api.NewPaymentHandler(tracing.InstrumentRepository(Repository), urlFx, urlCh).NewRouter()
+		traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+
+		t.Logf("\tWhen querying a missing payment with a traceparent header")
+		{
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/payment/"+model.NewID().String(), nil)
+			req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+			router.ServeHTTP(w, req)
+
+			spans := recorder.Ended()
+			var server sdktrace.ReadOnlySpan
+			for _, span := range spans {
+				if span.Name() == "GET /payment/:id" {
+					server = span
+				}
+			}
+			check(t, server != nil && server.SpanContext().TraceID().String() == traceID && len(spans) >= 2,
+				"The trace of the caller should have been continued by the request and repository spans", spans)
+			check(t, strings.Contains(out.String(), `"trace_id":"`+traceID+`"`), "The logs should carry the trace ID", out.String())
+		}
+
+		t.Logf("\tWhen sending an invalid payment ID")
+		{
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/payment/invalid", nil)
+			router.ServeHTTP(w, req)
+
+			var response model.ErrorResponse
+			json.NewDecoder(w.Body).Decode(&response)
+			check(t, w.Code == http.StatusBadRequest && len(response.TraceID) == 32 && response.TraceID != traceID,
+				"The error response should carry the ID of the trace started by the service", response)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"payment-service/model"
+	"payment-service/webhook"
+)
+
+const (
+	// OrganisationID the query parameter scoping the webhook routes. It is trusted as is, the gateway in front of the
+	// service being relied upon to only let the callers through with their own organisation.
+	OrganisationID = "organisation_id"
+
+	webhookParam  = "webhook"
+	deliveryParam = "delivery"
+)
+
+// WithWebhooks enables the webhook routes, the subscriptions and deliveries being kept in the given store
+func (h *PaymentHandler) WithWebhooks(store webhook.Store) *PaymentHandler {
+	h.webhooks = store
+	return h
+}
+
+// @Summary Subscribes an endpoint to the events of the organisation payments
+// @Description The deliveries are signed with the returned secret, which is generated when missing and never returned again.
+// @ID create-webhook
+// @Accept  json
+// @Produce  json
+// @Param webhook body model.CreateWebhookRequest true "Webhook"
+// @Success 201 {object} model.Webhook "Webhook created"
+// @Failure 400 {object} model.ErrorResponse "Bad request"
+// @Failure 500 {object} model.ErrorResponse "Internal server error"
+// @Router /webhooks [post]
+func (h *PaymentHandler) CreateWebhook(c *gin.Context) {
+	var req model.CreateWebhookRequest
+	if errB := c.ShouldBindWith(&req, binding.JSON); errB != nil {
+		slog.WarnContext(c.Request.Context(), "Failed to parse create webhook request", "error", errB)
+		setErrorResponse("Failed to parse create webhook request", http.StatusBadRequest, c)
+		return
+	}
+	if err := webhook.CheckURL(c.Request.Context(), net.DefaultResolver, req.URL); err != nil {
+		slog.WarnContext(c.Request.Context(), "Refused webhook URL", "error", err)
+		message := "Invalid webhook URL"
+		if err == webhook.ErrForbiddenAddress {
+			message = "Webhook URL must resolve to public addresses"
+		}
+		setErrorResponse(message, http.StatusBadRequest, c)
+		return
+	}
+	for _, t := range req.EventTypes {
+		if !model.IsEventType(t) {
+			setErrorResponse("Unknown event type "+t, http.StatusBadRequest, c)
+			return
+		}
+	}
+
+	slog.InfoContext(c.Request.Context(), "Received request to create a webhook", "organisation_id", req.OrganisationID)
+	secret := req.Secret
+	if secret == "" {
+		var err error
+		if secret, err = webhook.NewSecret(); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to generate the webhook secret", "error", err)
+			setErrorResponse("Failed to create webhook", http.StatusInternalServerError, c)
+			return
+		}
+	}
+	hook := model.Webhook{ID: model.NewID().String(), OrganisationID: req.OrganisationID, URL: req.URL,
+		EventTypes: req.EventTypes, Secret: secret, CreatedAt: time.Now().UTC()}
+	if err := h.webhooks.CreateWebhook(c.Request.Context(), hook); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to create webhook", "error", err)
+		setFailureResponse(err, "Failed to create webhook", http.StatusInternalServerError, c)
+		return
+	}
+	c.JSON(http.StatusCreated, hook)
+}
+
+// @Summary Query the webhooks of an organisation
+// @ID find-webhooks
+// @Produce  json
+// @Param organisation_id query string true "Organisation ID"
+// @Success 200 {object} model.WebhookResponse "ok"
+// @Failure 400 {object} model.ErrorResponse "Bad request"
+// @Failure 500 {object} model.ErrorResponse "Internal server error"
+// @Router /webhooks [get]
+func (h *PaymentHandler) FindWebhooks(c *gin.Context) {
+	organisationID := c.Query(OrganisationID)
+	slog.InfoContext(c.Request.Context(), "Received request to query the webhooks", "organisation_id", organisationID)
+	webhooks, err := h.webhooks.Webhooks(c.Request.Context(), organisationID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to query webhooks", "error", err)
+		setFailureResponse(err, "Failed to query webhooks", http.StatusInternalServerError, c)
+		return
+	}
+	resp := model.WebhookResponse{Data: []model.Webhook{}}
+	for _, w := range webhooks {
+		w.Secret = ""
+		resp.Data = append(resp.Data, w)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Summary Delete a webhook of an organisation, its pending deliveries being dead-lettered
+// @ID delete-webhook
+// @Param organisation_id query string true "Organisation ID"
+// @Success 204 "Webhook deleted"
+// @Failure 400 {object} model.ErrorResponse "Bad request"
+// @Failure 404 {object} model.ErrorResponse "Not found"
+// @Failure 500 {object} model.ErrorResponse "Internal server error"
+// @Router /webhooks/{webhook} [delete]
+func (h *PaymentHandler) DeleteWebhook(c *gin.Context) {
+	id := c.Params.ByName(webhookParam)
+	slog.InfoContext(c.Request.Context(), "Received request to delete a webhook", "webhook_id", id)
+	err := h.webhooks.DeleteWebhook(c.Request.Context(), c.Query(OrganisationID), id)
+	if err == webhook.ErrNotFound {
+		setErrorResponse("Webhook not found", http.StatusNotFound, c)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to delete webhook", "error", err)
+		setFailureResponse(err, "Failed to delete webhook", http.StatusInternalServerError, c)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Query the delivery log of a webhook
+// @ID find-webhook-deliveries
+// @Produce  json
+// @Param organisation_id query string true "Organisation ID"
+// @Success 200 {object} model.DeliveryResponse "ok"
+// @Failure 400 {object} model.ErrorResponse "Bad request"
+// @Failure 404 {object} model.ErrorResponse "Not found"
+// @Failure 500 {object} model.ErrorResponse "Internal server error"
+// @Router /webhooks/{webhook}/deliveries [get]
+func (h *PaymentHandler) FindDeliveries(c *gin.Context) {
+	organisationID, id := c.Query(OrganisationID), c.Params.ByName(webhookParam)
+	slog.InfoContext(c.Request.Context(), "Received request to query the deliveries of a webhook", "webhook_id", id)
+	deliveries, err := h.webhooks.Deliveries(c.Request.Context(), organisationID, id)
+	if err == nil && len(deliveries) == 0 {
+		// tell an unknown webhook from one without deliveries
+		_, err = h.webhooks.Webhook(c.Request.Context(), organisationID, id)
+	}
+	if err == webhook.ErrNotFound {
+		setErrorResponse("Webhook not found", http.StatusNotFound, c)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to query deliveries", "error", err)
+		setFailureResponse(err, "Failed to query deliveries", http.StatusInternalServerError, c)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []model.Delivery{}
+	}
+	c.JSON(http.StatusOK, model.DeliveryResponse{Data: deliveries})
+}
+
+// @Summary Deliver an event to a webhook again, whatever the outcome of the previous attempts
+// @ID redeliver-webhook-delivery
+// @Produce  json
+// @Param organisation_id query string true "Organisation ID"
+// @Success 202 {object} model.Delivery "Delivery scheduled"
+// @Failure 400 {object} model.ErrorResponse "Bad request"
+// @Failure 404 {object} model.ErrorResponse "Not found"
+// @Failure 409 {object} model.ErrorResponse "Delivery being attempted"
+// @Failure 500 {object} model.ErrorResponse "Internal server error"
+// @Router /webhooks/{webhook}/deliveries/{delivery}/redeliver [post]
+func (h *PaymentHandler) Redeliver(c *gin.Context) {
+	id := c.Params.ByName(deliveryParam)
+	slog.InfoContext(c.Request.Context(), "Received request to redeliver", "delivery_id", id)
+	delivery, err := webhook.Redeliver(c.Request.Context(), h.webhooks, c.Query(OrganisationID), c.Params.ByName(webhookParam), id)
+	if err == webhook.ErrNotFound {
+		setErrorResponse("Delivery not found", http.StatusNotFound, c)
+		return
+	}
+	if err == webhook.ErrLeaseLost {
+		setErrorResponse("Delivery is being attempted, try again", http.StatusConflict, c)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to redeliver", "error", err)
+		setFailureResponse(err, "Failed to redeliver", http.StatusInternalServerError, c)
+		return
+	}
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+// RequireOrganisation rejects the requests without organisation_id query parameter
+func RequireOrganisation(c *gin.Context) {
+	if c.Query(OrganisationID) == "" {
+		setErrorResponse("Missing organisation_id", http.StatusBadRequest, c)
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// Helper function to register the webhook routes
+func (h *PaymentHandler) webhookRoutes(router *gin.Engine) {
+	h.handle(router, http.MethodPost, "/webhooks", h.CreateWebhook)
+	h.handle(router, http.MethodGet, "/webhooks", RequireOrganisation, h.FindWebhooks)
+	h.handle(router, http.MethodDelete, "/webhooks/:webhook", RequireOrganisation, h.DeleteWebhook)
+	h.handle(router, http.MethodGet, "/webhooks/:webhook/deliveries", RequireOrganisation, h.FindDeliveries)
+	h.handle(router, http.MethodPost, "/webhooks/:webhook/deliveries/:delivery/redeliver", RequireOrganisation, h.Redeliver)
+}
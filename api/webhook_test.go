@@ -0,0 +1,110 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"payment-service/api"
+	"payment-service/model"
+	"payment-service/test"
+	"payment-service/webhook"
+)
+
+func TestPaymentHandler_Webhooks(t *testing.T) {
+	t.Logf("Given the need to subscribe an endpoint to the payment events of an organisation")
+	{
+		store := webhook.NewMemoryStore()
+		router := api.NewPaymentHandler(Repository, urlFx, urlCh).WithWebhooks(store).NewRouter()
+
+		t.Logf("\tWhen sending Create Webhook requests to endpoint %s", "\\webhooks")
+		{
+			w := serve(router, http.MethodPost, "/webhooks",
+				model.CreateWebhookRequest{OrganisationID: "org-1", URL: "https://203.0.113.10/hooks", EventTypes: []string{model.PaymentCreated}})
+			test.CheckStatus(w, t, http.StatusCreated)
+			var hook model.Webhook
+			json.NewDecoder(w.Body).Decode(&hook)
+			if hook.ID != "" && strings.HasPrefix(hook.Secret, "whsec_") {
+				t.Logf("\t\tThe response should contain the webhook id and its secret. %v", test.CheckMark)
+			} else {
+				t.Errorf("\t\tThe response should contain the webhook id and its secret. %v %v", hook, test.BallotX)
+			}
+
+			w = serve(router, http.MethodPost, "/webhooks", model.CreateWebhookRequest{OrganisationID: "org-1", URL: "ftp://203.0.113.10"})
+			test.CheckStatus(w, t, http.StatusBadRequest)
+			w = serve(router, http.MethodPost, "/webhooks",
+				model.CreateWebhookRequest{OrganisationID: "org-1", URL: "https://203.0.113.10", EventTypes: []string{"payment.unknown"}})
+			test.CheckStatus(w, t, http.StatusBadRequest)
+			for _, private := range []string{"http://127.0.0.1:8080/hooks", "http://10.1.2.3/hooks", "http://169.254.169.254/latest/meta-data"} {
+				w = serve(router, http.MethodPost, "/webhooks", model.CreateWebhookRequest{OrganisationID: "org-1", URL: private})
+				test.CheckStatus(w, t, http.StatusBadRequest)
+			}
+
+			t.Logf("\tWhen querying the webhooks of the organisation")
+			{
+				w = serve(router, http.MethodGet, "/webhooks", nil)
+				test.CheckStatus(w, t, http.StatusBadRequest)
+
+				w = serve(router, http.MethodGet, "/webhooks?organisation_id=org-1", nil)
+				test.CheckStatus(w, t, http.StatusOK)
+				var resp model.WebhookResponse
+				json.NewDecoder(w.Body).Decode(&resp)
+				if len(resp.Data) == 1 && resp.Data[0].ID == hook.ID && resp.Data[0].Secret == "" {
+					t.Logf("\t\tThe response should contain the webhook without its secret. %v", test.CheckMark)
+				} else {
+					t.Errorf("\t\tThe response should contain the webhook without its secret. %v %v", resp, test.BallotX)
+				}
+			}
+
+			t.Logf("\tWhen querying and redelivering the deliveries of the webhook")
+			{
+				payment := model.Payment{ID: model.NewID(), OrganisationId: "org-1"}
+				webhook.NewDispatcher(store).Publish(context.Background(), model.NewEvent(model.PaymentCreated, payment))
+
+				w = serve(router, http.MethodGet, "/webhooks/"+hook.ID+"/deliveries?organisation_id=org-2", nil)
+				test.CheckStatus(w, t, http.StatusNotFound)
+
+				w = serve(router, http.MethodGet, "/webhooks/"+hook.ID+"/deliveries?organisation_id=org-1", nil)
+				test.CheckStatus(w, t, http.StatusOK)
+				var resp model.DeliveryResponse
+				json.NewDecoder(w.Body).Decode(&resp)
+				if len(resp.Data) == 1 && resp.Data[0].Status == model.DeliveryPending {
+					t.Logf("\t\tThe response should contain the pending delivery. %v", test.CheckMark)
+				} else {
+					t.Fatalf("\t\tThe response should contain the pending delivery. %v %v", resp, test.BallotX)
+				}
+
+				w = serve(router, http.MethodPost, "/webhooks/"+hook.ID+"/deliveries/"+resp.Data[0].ID+"/redeliver?organisation_id=org-1", nil)
+				test.CheckStatus(w, t, http.StatusAccepted)
+				w = serve(router, http.MethodPost, "/webhooks/"+hook.ID+"/deliveries/unknown/redeliver?organisation_id=org-1", nil)
+				test.CheckStatus(w, t, http.StatusNotFound)
+			}
+
+			t.Logf("\tWhen deleting the webhook")
+			{
+				w = serve(router, http.MethodDelete, "/webhooks/"+hook.ID+"?organisation_id=org-2", nil)
+				test.CheckStatus(w, t, http.StatusNotFound)
+				w = serve(router, http.MethodDelete, "/webhooks/"+hook.ID+"?organisation_id=org-1", nil)
+				test.CheckStatus(w, t, http.StatusNoContent)
+				w = serve(router, http.MethodGet, "/webhooks/"+hook.ID+"/deliveries?organisation_id=org-1", nil)
+				test.CheckStatus(w, t, http.StatusOK)
+			}
+		}
+	}
+}
+
+// Helper function to serve a request, the body being encoded in json when not nil
+func serve(router http.Handler, method, url string, body interface{}) *httptest.ResponseRecorder {
+	var req *http.Request
+	if body != nil {
+		req, _ = test.HttpRequest(body, url, method)
+	} else {
+		req, _ = http.NewRequest(method, url, nil)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
@@ -0,0 +1,213 @@
+// Package client is a typed Go client of the payment API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	mrand "math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"payment-service/logger"
+)
+
+// Retry defaults
+const (
+	DefaultMaxRetries = 3
+	DefaultMinBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff = 5 * time.Second
+	DefaultTimeout    = 30 * time.Second
+)
+
+// TokenSource returns the bearer token authenticating a request, called before every attempt
+type TokenSource func(ctx context.Context) (string, error)
+
+// StaticToken returns a TokenSource always returning the given token
+func StaticToken(token string) TokenSource {
+	return func(context.Context) (string, error) { return token, nil }
+}
+
+// Client calls the payment API at BaseURL. Idempotent requests failing with a 5xx or 429 status, or without response,
+// are retried up to MaxRetries times with an exponential backoff between MinBackoff and MaxBackoff, honouring
+// Retry-After. The other requests are only retried on 429, the server not having processed them. The request ID of
+// the context, see logger.WithRequestID, is sent in the X-Request-ID header for the calls to show in the server logs.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+
+	// Token authenticates the requests when not nil
+	Token TokenSource
+
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// New creates a Client of the API at the given base URL, e.g. http://localhost:8080
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+		MaxRetries: DefaultMaxRetries,
+		MinBackoff: DefaultMinBackoff,
+		MaxBackoff: DefaultMaxBackoff,
+	}
+}
+
+// request a call to the API
+type request struct {
+	method      string
+	path        string
+	body        interface{}
+	contentType string
+
+	// idempotent requests may be sent again whatever the outcome of the previous attempt
+	idempotent bool
+}
+
+// Helper function to send a request, retrying it on failure, and decode the JSON response into out when not nil.
+// Responses other than 2xx are returned as an *Error, along their status.
+func (c *Client) do(ctx context.Context, req request, out interface{}) (int, error) {
+	var body []byte
+	switch b := req.body.(type) {
+	case nil:
+	case []byte:
+		body = b
+	case json.RawMessage:
+		body = b
+	default:
+		var err error
+		if body, err = json.Marshal(b); err != nil {
+			return 0, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, req, body)
+		if err != nil {
+			if !req.idempotent || attempt >= c.MaxRetries || ctx.Err() != nil {
+				return 0, err
+			}
+			if err := c.sleep(ctx, c.backoff(attempt)); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		if retryable(resp.StatusCode, req.idempotent) && attempt < c.MaxRetries {
+			wait := c.backoff(attempt)
+			if after := retryAfter(resp); after > 0 {
+				wait = after
+			}
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+			if err := c.sleep(ctx, wait); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		return resp.StatusCode, decodeResponse(resp, out)
+	}
+}
+
+// Helper function to decode a JSON response into out when not nil, or the error of a response other than 2xx
+func decodeResponse(resp *http.Response, out interface{}) error {
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return decodeError(resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode the response: %s", err)
+	}
+	return nil
+}
+
+// Helper function to wait before the next attempt, unless the context is done first
+func (c *Client) sleep(ctx context.Context, wait time.Duration) error {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Helper function to send one attempt of a request
+func (c *Client) send(ctx context.Context, req request, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	r, err := http.NewRequest(req.method, c.BaseURL+req.path, reader)
+	if err != nil {
+		return nil, err
+	}
+	r = r.WithContext(ctx)
+	r.Header.Set("Accept", "application/json, application/problem+json")
+	if body != nil {
+		contentType := req.contentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		r.Header.Set("Content-Type", contentType)
+	}
+	if c.Token != nil {
+		token, err := c.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	if id := logger.RequestID(ctx); id != "" {
+		r.Header.Set(logger.RequestIDHeader, id)
+	}
+	return c.HTTPClient.Do(r)
+}
+
+// Helper function to get the delay before the next attempt, doubling from MinBackoff up to MaxBackoff with jitter
+func (c *Client) backoff(attempt int) time.Duration {
+	wait := float64(c.MinBackoff) * math.Pow(2, float64(attempt))
+	if wait > float64(c.MaxBackoff) {
+		wait = float64(c.MaxBackoff)
+	}
+	// full jitter over the upper half, spreading the retries of concurrent clients
+	return time.Duration(wait/2 + mrand.Float64()*wait/2)
+}
+
+// Helper function to tell whether a response status is worth another attempt
+func retryable(status int, idempotent bool) bool {
+	return status == http.StatusTooManyRequests || idempotent && status >= 500
+}
+
+// Helper function to read the Retry-After header, in seconds, of a response
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Helper function to generate a random (version 4) UUID
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
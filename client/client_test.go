@@ -0,0 +1,290 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"payment-service/api"
+	"payment-service/client"
+	"payment-service/model"
+	"payment-service/repository"
+	"payment-service/test"
+)
+
+func TestClient_ShouldManageThePayments(t *testing.T) {
+	t.Logf("Given a client of the payment API")
+	{
+		c := newClient(t, newRouter())
+		ctx := context.Background()
+
+		t.Logf("\tWhen creating a payment")
+		{
+			req := test.CreatePaymentRequest("31926819", "GB29XABC10161234567801", "USD")
+			created, err := c.Create(ctx, req)
+			check(t, err == nil && created.ID != "", "The payment should have been created", err)
+
+			stored, err := c.Get(ctx, created.ID)
+			check(t, err == nil && stored.ID.String() == created.ID && stored.Fx.ExchangeRate > 0,
+				"The priced payment should be returned", err)
+
+			req.Reference = "Updated reference"
+			isNew, err := c.Update(ctx, created.ID, req)
+			check(t, err == nil && !isNew, "The payment should have been replaced", err)
+
+			patched, err := c.Patch(ctx, created.ID, map[string]interface{}{"payment_purpose": "Patched purpose"})
+			check(t, err == nil && patched.PaymentPurpose == "Patched purpose" && patched.Reference == req.Reference,
+				"The patch should have been applied", err)
+
+			err = c.Delete(ctx, created.ID)
+			check(t, err == nil, "The payment should have been deleted", err)
+			_, err = c.Get(ctx, created.ID)
+			check(t, client.IsNotFound(err), "The payment should no longer be found", err)
+		}
+
+		t.Logf("\tWhen creating a payment with a client chosen ID")
+		{
+			req := test.CreatePaymentRequest("31926819", "GB29XABC10161234567801", "USD")
+			req.ID = "9b5b6e3a-1a3c-4d9e-8b1e-0f6f1c2d3e4f"
+			isNew, err := c.Update(ctx, req.ID, req)
+			check(t, err == nil && isNew, "Updating the missing payment should create it", err)
+		}
+	}
+}
+
+func TestClient_ShouldIterateOverThePages(t *testing.T) {
+	t.Logf("Given seven payments")
+	{
+		c := newClient(t, newRouter())
+		ctx := context.Background()
+		for i := 0; i < 7; i++ {
+			if _, err := c.Create(ctx, test.CreatePaymentRequest("31926819", "GB29XABC10161234567801", "USD")); err != nil {
+				t.Fatalf("Failed to create payment %v", err)
+			}
+		}
+
+		t.Logf("\tWhen listing them three at a time")
+		{
+			seen := map[string]bool{}
+			it := c.List(ctx, client.ListOptions{PageSize: 3})
+			for it.Next() {
+				seen[it.Payment().ID.String()] = true
+			}
+			check(t, it.Err() == nil && len(seen) == 7, "All the payments should be returned once", len(seen))
+		}
+
+		t.Logf("\tWhen listing the payments of another organisation")
+		{
+			req := test.CreatePaymentRequest("31926819", "GB29XABC10161234567801", "USD")
+			req.OrganisationID = "org-b"
+			if _, err := c.Create(ctx, req); err != nil {
+				t.Fatalf("Failed to create payment %v", err)
+			}
+			var listed []model.Payment
+			it := c.List(ctx, client.ListOptions{PageSize: 3, OrganisationID: "org-b"})
+			for it.Next() {
+				listed = append(listed, it.Payment())
+			}
+			check(t, it.Err() == nil && len(listed) == 1 && listed[0].OrganisationId == "org-b",
+				"Only the payment of the organisation should be returned", listed)
+		}
+	}
+}
+
+func TestClient_ShouldRetryTheIdempotentRequests(t *testing.T) {
+	t.Logf("Given a server failing the first requests")
+	{
+		router := newRouter()
+		flaky := &flakyHandler{next: router, failures: 2, status: http.StatusServiceUnavailable}
+		c := newClient(t, flaky)
+		ctx := context.Background()
+
+		t.Logf("\tWhen creating a payment")
+		{
+			created, err := c.Create(ctx, test.CreatePaymentRequest("31926819", "GB29XABC10161234567801", "USD"))
+			check(t, err == nil && created.ID != "", "The payment should have been created", err)
+			check(t, flaky.count() == 3, "The request should have been sent three times", flaky.count())
+		}
+
+		t.Logf("\tWhen the response of a stored payment is lost")
+		{
+			flaky.reset(1, http.StatusBadGateway)
+			flaky.process = true
+			req := test.CreatePaymentRequest("31926819", "GB29XABC10161234567801", "USD")
+			created, err := c.Create(ctx, req)
+			check(t, err == nil && created.ID != "", "The retry should return the stored payment", err)
+			stored, err := c.Get(ctx, created.ID)
+			check(t, err == nil && stored.Reference == req.Reference, "The payment should have been stored as sent", err)
+
+			count := 0
+			for it := c.List(ctx, client.ListOptions{}); it.Next(); {
+				count++
+			}
+			check(t, count == 2, "The payment should have been stored once", count)
+		}
+
+		t.Logf("\tWhen the server keeps failing")
+		{
+			flaky.reset(10, http.StatusInternalServerError)
+			flaky.process = false
+			_, err := c.Get(ctx, model.NewID().String())
+			e, ok := err.(*client.Error)
+			check(t, ok && e.StatusCode == http.StatusInternalServerError, "The last error should be returned", err)
+			check(t, flaky.count() == client.DefaultMaxRetries+1, "The request should have been retried MaxRetries times", flaky.count())
+		}
+	}
+}
+
+func TestClient_ShouldOnlyRetryTheOtherRequestsWhenThrottled(t *testing.T) {
+	t.Logf("Given a payment with its own ID")
+	{
+		router := newRouter()
+		flaky := &flakyHandler{next: router}
+		c := newClient(t, flaky)
+		ctx := context.Background()
+		req := test.CreatePaymentRequest("31926819", "GB29XABC10161234567801", "USD")
+		req.ID = "0d3c2b1a-5f4e-4a7b-9c8d-1e2f3a4b5c6d"
+
+		t.Logf("\tWhen the creation fails with 503")
+		{
+			flaky.reset(1, http.StatusServiceUnavailable)
+			_, err := c.Create(ctx, req)
+			check(t, err != nil && flaky.count() == 1, "The creation should not have been retried", err)
+		}
+
+		t.Logf("\tWhen the creation is throttled")
+		{
+			flaky.reset(1, http.StatusTooManyRequests)
+			created, err := c.Create(ctx, req)
+			check(t, err == nil && created.ID == req.ID && flaky.count() == 2, "The creation should have been retried", err)
+		}
+
+		t.Logf("\tWhen creating it again")
+		{
+			_, err := c.Create(ctx, req)
+			check(t, client.IsConflict(err), "The payment already stored should be a conflict", err)
+		}
+	}
+}
+
+func TestClient_ShouldInjectTheToken(t *testing.T) {
+	t.Logf("Given a client with a token source")
+	{
+		var auth string
+		c := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth = r.Header.Get("Authorization")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"data":[{"id":"5c1a3b4e2f8fb814b56fa181"}],"links":{}}`))
+		}))
+		c.Token = client.StaticToken("secret")
+
+		t.Logf("\tWhen sending a request")
+		{
+			_, err := c.Get(context.Background(), "5c1a3b4e2f8fb814b56fa181")
+			check(t, err == nil && auth == "Bearer secret", "The bearer token should have been sent", auth)
+		}
+	}
+}
+
+func TestClient_ShouldDecodeTheErrors(t *testing.T) {
+	t.Logf("Given a server answering with problem details")
+	{
+		c := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", client.ProblemContentType)
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"type":"https://example.com/probs/scope","title":"Forbidden","status":403,"detail":"Missing scope payments:read","instance":"/payment"}`))
+		}))
+
+		t.Logf("\tWhen sending a request")
+		{
+			_, err := c.Get(context.Background(), "5c1a3b4e2f8fb814b56fa181")
+			e, ok := err.(*client.Error)
+			check(t, ok && e.StatusCode == http.StatusForbidden && e.Type == "https://example.com/probs/scope" &&
+				e.Detail == "Missing scope payments:read" && e.Instance == "/payment", "The problem should be decoded", err)
+		}
+	}
+
+	t.Logf("Given the payment API")
+	{
+		c := newClient(t, newRouter())
+
+		t.Logf("\tWhen sending an invalid ID")
+		{
+			_, err := c.Get(context.Background(), "not-an-id")
+			e, ok := err.(*client.Error)
+			check(t, ok && e.StatusCode == http.StatusBadRequest && e.Detail == "Invalid payment ID",
+				"The error response should be decoded", err)
+		}
+	}
+}
+
+// flakyHandler fails the first requests with the given status before passing them to the next handler. When process
+// is set the failing requests are processed first, their response being lost.
+type flakyHandler struct {
+	next    http.Handler
+	process bool
+
+	mu       sync.Mutex
+	failures int
+	status   int
+	requests int
+}
+
+func (h *flakyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	h.requests++
+	fail := h.failures > 0
+	h.failures--
+	h.mu.Unlock()
+
+	if !fail {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	if h.process {
+		h.next.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	w.WriteHeader(h.status)
+}
+
+// Helper function to fail the next requests, resetting the request count
+func (h *flakyHandler) reset(failures, status int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failures, h.status, h.requests = failures, status, 0
+}
+
+// Helper function to get the number of requests received
+func (h *flakyHandler) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.requests
+}
+
+// Helper function to create the router of the payment API over an in memory repository
+func newRouter() http.Handler {
+	repo := repository.NewMemoryRepository()
+	repo.EnsureIndexes(context.Background(), api.DatabaseName, api.CollectionName)
+	return api.NewPaymentHandler(repo, "urlFX", "urlCF").NewRouter()
+}
+
+// Helper function to serve the handler and create a client of it, with short backoffs
+func newClient(t *testing.T, handler http.Handler) *client.Client {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	c := client.New(srv.URL)
+	c.MinBackoff, c.MaxBackoff = time.Millisecond, 10*time.Millisecond
+	return c
+}
+
+// Helper function to log an expectation with a check mark, failing the test with a ballot when it is not met
+func check(t *testing.T, ok bool, expectation string, got interface{}) {
+	if ok {
+		t.Logf("\t\t%s. %v", expectation, test.CheckMark)
+	} else {
+		t.Errorf("\t\t%s. %v %v", expectation, got, test.BallotX)
+	}
+}
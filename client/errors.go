@@ -0,0 +1,77 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	"payment-service/model"
+)
+
+// ProblemContentType the media type of the problem details (RFC 7807) describing an error
+const ProblemContentType = "application/problem+json"
+
+// Error an error response of the API. Problem details (RFC 7807) are decoded into their fields, the error responses
+// of the payment API having their message as Detail.
+type Error struct {
+	StatusCode int    `json:"status"`
+	Type       string `json:"type,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+	Instance   string `json:"instance,omitempty"`
+}
+
+// Error returns the status code along the details of the error
+func (e *Error) Error() string {
+	msg := e.Detail
+	if msg == "" {
+		msg = e.Title
+	}
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	return fmt.Sprintf("payment API error %d: %s", e.StatusCode, msg)
+}
+
+// IsNotFound tells whether the error is a 404 Not Found response
+func IsNotFound(err error) bool {
+	return statusCode(err) == http.StatusNotFound
+}
+
+// IsConflict tells whether the error is a 409 Conflict response
+func IsConflict(err error) bool {
+	return statusCode(err) == http.StatusConflict
+}
+
+// Helper function to get the status code of an *Error, zero for other errors
+func statusCode(err error) int {
+	if e, ok := err.(*Error); ok {
+		return e.StatusCode
+	}
+	return 0
+}
+
+// Helper function to decode the error of a response, whatever its body
+func decodeError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode, Title: http.StatusText(resp.StatusCode)}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil || len(body) == 0 {
+		return apiErr
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == ProblemContentType {
+		if json.Unmarshal(body, apiErr) == nil && apiErr.StatusCode == 0 {
+			apiErr.StatusCode = resp.StatusCode
+		}
+		return apiErr
+	}
+
+	var errResp model.ErrorResponse
+	if json.Unmarshal(body, &errResp) == nil && errResp.Message != "" {
+		apiErr.Detail = errResp.Message
+	}
+	return apiErr
+}
@@ -0,0 +1,171 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"payment-service/model"
+)
+
+// MergePatchContentType the media type of a JSON merge patch (RFC 7396)
+const MergePatchContentType = "application/merge-patch+json"
+
+// Create prices and stores a new payment. A payment without ID is given a random UUID and stored with PUT
+// /payment/{id}, which creates the missing payments, so that the request is safely retried: a retry finding the payment
+// stored by an attempt whose response was lost replaces it with the same request. A payment with its own ID is sent
+// with POST, only retried on 429, a payment already stored with the ID being returned as a conflict.
+func (c *Client) Create(ctx context.Context, req model.CreatePaymentRequest) (model.CreatePaymentResponse, error) {
+	var resp model.CreatePaymentResponse
+	if req.ID != "" {
+		_, err := c.do(ctx, request{method: http.MethodPost, path: "/payment", body: req}, &resp)
+		return resp, err
+	}
+
+	id, err := newUUID()
+	if err != nil {
+		return resp, err
+	}
+	req.ID = id
+	if _, err := c.Update(ctx, id, req); err != nil {
+		return resp, err
+	}
+	return model.CreatePaymentResponse{ID: id, OrganisationId: req.OrganisationID}, nil
+}
+
+// Get returns the payment with the given ID or payment ID
+func (c *Client) Get(ctx context.Context, id string) (model.Payment, error) {
+	var resp model.PaymentResponse
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: paymentPath(id), idempotent: true}, &resp); err != nil {
+		return model.Payment{}, err
+	}
+	if len(resp.Data) == 0 {
+		return model.Payment{}, &Error{StatusCode: http.StatusNotFound, Title: http.StatusText(http.StatusNotFound)}
+	}
+	return resp.Data[0], nil
+}
+
+// Update replaces the payment with the given ID, creating it when the ID is a UUID which does not exist yet. It tells
+// whether the payment was created.
+func (c *Client) Update(ctx context.Context, id string, req model.CreatePaymentRequest) (bool, error) {
+	status, err := c.do(ctx, request{method: http.MethodPut, path: paymentPath(id), body: req, idempotent: true}, nil)
+	return status == http.StatusCreated, err
+}
+
+// Patch applies a JSON merge patch (RFC 7396) to the payment with the given ID and returns the updated payment. The
+// patch is either its JSON encoding or any value encoded as JSON, e.g. a map.
+func (c *Client) Patch(ctx context.Context, id string, patch interface{}) (model.Payment, error) {
+	var resp model.PaymentResponse
+	_, err := c.do(ctx, request{
+		method:      http.MethodPatch,
+		path:        paymentPath(id),
+		body:        patch,
+		contentType: MergePatchContentType,
+		idempotent:  true,
+	}, &resp)
+	if err != nil || len(resp.Data) == 0 {
+		return model.Payment{}, err
+	}
+	return resp.Data[0], nil
+}
+
+// Delete deletes the payment with the given ID
+func (c *Client) Delete(ctx context.Context, id string) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: paymentPath(id), idempotent: true}, nil)
+	return err
+}
+
+// History returns the recent events of the payment with the given ID, oldest first
+func (c *Client) History(ctx context.Context, id string) ([]model.Event, error) {
+	var resp model.EventResponse
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: paymentPath(id) + "/history", idempotent: true}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// ListOptions the options of List
+type ListOptions struct {
+	// PageSize the number of payments fetched per request, the server default when zero
+	PageSize int
+
+	// OrganisationID only the payments of the organisation when set
+	OrganisationID string
+
+	// ProcessedFrom and ProcessedTo only the payments processed in [ProcessedFrom, ProcessedTo), a zero bound leaving
+	// the range open on its side
+	ProcessedFrom time.Time
+	ProcessedTo   time.Time
+}
+
+// List returns an iterator over all the payments, ordered by ID, fetching them one page at a time
+func (c *Client) List(ctx context.Context, opts ListOptions) *Iterator {
+	query := url.Values{"page[number]": {"0"}}
+	if opts.PageSize > 0 {
+		query.Set("page[size]", strconv.Itoa(opts.PageSize))
+	}
+	if opts.OrganisationID != "" {
+		query.Set("filter[organisation_id]", opts.OrganisationID)
+	}
+	if !opts.ProcessedFrom.IsZero() {
+		query.Set("filter[processing_date_from]", opts.ProcessedFrom.Format(time.RFC3339Nano))
+	}
+	if !opts.ProcessedTo.IsZero() {
+		query.Set("filter[processing_date_to]", opts.ProcessedTo.Format(time.RFC3339Nano))
+	}
+	return &Iterator{client: c, ctx: ctx, next: "/payment?" + query.Encode()}
+}
+
+// Iterator iterates over the payments of List:
+//
+//	it := c.List(ctx, client.ListOptions{})
+//	for it.Next() {
+//		p := it.Payment()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type Iterator struct {
+	client *Client
+	ctx    context.Context
+
+	// next the path of the next page, empty after the last one
+	next    string
+	page    []model.Payment
+	current model.Payment
+	err     error
+}
+
+// Next advances to the next payment, fetching the next page when needed. It returns false after the last payment or
+// on error.
+func (it *Iterator) Next() bool {
+	for len(it.page) == 0 {
+		if it.err != nil || it.next == "" {
+			return false
+		}
+		var resp model.PaymentResponse
+		_, it.err = it.client.do(it.ctx, request{method: http.MethodGet, path: it.next, idempotent: true}, &resp)
+		if it.err != nil {
+			return false
+		}
+		it.page, it.next = resp.Data, resp.Next
+	}
+	it.current, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Payment returns the current payment
+func (it *Iterator) Payment() model.Payment {
+	return it.current
+}
+
+// Err returns the error which stopped the iteration, if any
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Helper function to get the path of a payment
+func paymentPath(id string) string {
+	return "/payment/" + url.PathEscape(id)
+}
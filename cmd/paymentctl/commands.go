@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"payment-service/client"
+	"payment-service/model"
+)
+
+// Helper function running the create command
+func create(ctx context.Context, c *client.Client, args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("create", "[-o table|json] -f file...", "Creates the payments of JSON or YAML files, each holding a payment request, a list of them or\n"+
+		"several YAML documents. - reads the standard input.", stderr)
+	var files fileList
+	flags.Var(&files, "f", "file of payment requests, may be repeated")
+	format := flags.String("o", "table", "output format, table or json")
+	if err := flags.Parse(args); err != nil || len(files) == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var reqs []model.CreatePaymentRequest
+	for _, file := range files {
+		read, err := readRequests(file)
+		if err != nil {
+			fmt.Fprintf(stderr, "Failed to read %s: %s\n", file, err)
+			return 1
+		}
+		reqs = append(reqs, read...)
+	}
+
+	var created []model.CreatePaymentResponse
+	failed := false
+	for _, req := range reqs {
+		resp, err := c.Create(ctx, req)
+		if err != nil {
+			fmt.Fprintf(stderr, "Failed to create payment %s: %s\n", req.Reference, err)
+			failed = true
+			continue
+		}
+		created = append(created, resp)
+	}
+
+	if *format == "json" {
+		writeJSON(stdout, created)
+	} else {
+		t := newTable(stdout, "ID", "ORGANISATION")
+		for _, resp := range created {
+			t.row(resp.ID, resp.OrganisationId)
+		}
+		t.flush()
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// Helper function running the list command
+func list(ctx context.Context, c *client.Client, args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("list", "[-o table|json] [filters]", "Lists the payments matching the filters.", stderr)
+	format := flags.String("o", "table", "output format, table or json")
+	var filter filter
+	filter.register(flags)
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	payments, err := filter.payments(ctx, c)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to list payments: %s\n", err)
+		return 1
+	}
+	if *format == "json" {
+		writeJSON(stdout, payments)
+		return 0
+	}
+	t := newTable(stdout, "ID", "ORGANISATION", "STATUS", "SCHEME", "AMOUNT", "CURRENCY", "PROCESSING DATE", "REFERENCE")
+	for _, p := range payments {
+		t.row(p.ID.String(), p.OrganisationId, status(p), p.PaymentScheme, amount(p.Amount), p.Currency,
+			p.ProcessingDate.Format("2006-01-02"), p.Reference)
+	}
+	t.flush()
+	return 0
+}
+
+// Helper function running the get command
+func get(ctx context.Context, c *client.Client, args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("get", "id", "Shows a payment as JSON.", stderr)
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	p, err := c.Get(ctx, flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to get payment %s: %s\n", flags.Arg(0), err)
+		return 1
+	}
+	writeJSON(stdout, p)
+	return 0
+}
+
+// Helper function running the history command
+func history(ctx context.Context, c *client.Client, args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("history", "[-o table|json] id", "Shows the recent events of a payment, oldest first.", stderr)
+	format := flags.String("o", "table", "output format, table or json")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	events, err := c.History(ctx, flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to get the history of payment %s: %s\n", flags.Arg(0), err)
+		return 1
+	}
+	if *format == "json" {
+		writeJSON(stdout, events)
+		return 0
+	}
+	t := newTable(stdout, "OCCURRED AT", "EVENT", "STATUS", "AMOUNT", "REFERENCE")
+	for _, e := range events {
+		s := status(e.Payment)
+		if e.Type == model.PaymentStatusChanged {
+			previous := e.PreviousStatus
+			if previous == "" {
+				previous = model.StatusPending
+			}
+			s = previous + " -> " + s
+		}
+		t.row(e.OccurredAt.Format("2006-01-02 15:04:05"), e.Type, s, amount(e.Payment.Amount), e.Payment.Reference)
+	}
+	t.flush()
+	return 0
+}
+
+// Helper function running the cancel command, patching the status of the payments of the arguments
+func cancel(ctx context.Context, c *client.Client, args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("cancel", "id...", "Cancels payments.", stderr)
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	code := 0
+	for _, id := range flags.Args() {
+		if _, err := c.Patch(ctx, id, map[string]string{"status": model.StatusCancelled}); err != nil {
+			fmt.Fprintf(stderr, "Failed to cancel payment %s: %s\n", id, err)
+			code = 1
+			continue
+		}
+		fmt.Fprintf(stdout, "%s %s\n", id, model.StatusCancelled)
+	}
+	return code
+}
+
+// Helper function to create the flag set of a command
+func newFlagSet(name, arguments, description string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: paymentctl %s %s\n\n%s\n\nFlags:\n", name, arguments, description)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// fileList the values of a repeated file flag
+type fileList []string
+
+func (f *fileList) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *fileList) Set(file string) error {
+	*f = append(*f, file)
+	return nil
+}
+
+// Helper function to read the payment requests of a JSON or YAML file. The documents are decoded as YAML, a superset
+// of JSON, then converted to JSON so that the requests have the field names of the API.
+func readRequests(file string) ([]model.CreatePaymentRequest, error) {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var reqs []model.CreatePaymentRequest
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc interface{}
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		jsonDoc, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := doc.([]interface{}); ok {
+			var list []model.CreatePaymentRequest
+			if err := json.Unmarshal(jsonDoc, &list); err != nil {
+				return nil, err
+			}
+			reqs = append(reqs, list...)
+			continue
+		}
+		var req model.CreatePaymentRequest
+		if err := json.Unmarshal(jsonDoc, &req); err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, req)
+	}
+	if len(reqs) == 0 {
+		return nil, fmt.Errorf("no payment request")
+	}
+	return reqs, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables overriding the configuration file
+const (
+	ConfigEnv  = "PAYMENTCTL_CONFIG"
+	ProfileEnv = "PAYMENTCTL_PROFILE"
+	URLEnv     = "PAYMENTCTL_URL"
+	TokenEnv   = "PAYMENTCTL_TOKEN"
+)
+
+// DefaultURL the API of the default profile when no configuration file defines it
+const DefaultURL = "http://localhost:8080"
+
+// Profile the payment API an operator talks to
+type Profile struct {
+	URL   string `yaml:"url"`
+	Token string `yaml:"token,omitempty"`
+}
+
+// Config the profiles of the configuration file, e.g.
+//
+//	current: local
+//	profiles:
+//	  local:
+//	    url: http://localhost:8080
+//	  staging:
+//	    url: https://payments.staging.example.com
+//	    token: ...
+type Config struct {
+	Current  string             `yaml:"current"`
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// Helper function to get the default configuration file, ~/.paymentctl.yaml
+func defaultConfigPath() string {
+	if path := os.Getenv(ConfigEnv); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".paymentctl.yaml"
+	}
+	return filepath.Join(home, ".paymentctl.yaml")
+}
+
+// Helper function to read the configuration file, a missing file being an empty configuration
+func loadConfig(path string) (Config, error) {
+	var config Config
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("invalid configuration file %s: %s", path, err)
+	}
+	return config, nil
+}
+
+// Helper function to resolve the profile to use: the given name, else PAYMENTCTL_PROFILE, else the current profile
+// of the configuration. PAYMENTCTL_URL and PAYMENTCTL_TOKEN override the profile.
+func (c Config) profile(name string) (Profile, error) {
+	if name == "" {
+		name = os.Getenv(ProfileEnv)
+	}
+	if name == "" {
+		name = c.Current
+	}
+
+	profile := Profile{URL: DefaultURL}
+	if name != "" {
+		p, ok := c.Profiles[name]
+		if !ok {
+			return profile, fmt.Errorf("unknown profile %s", name)
+		}
+		profile = p
+	}
+	if url := os.Getenv(URLEnv); url != "" {
+		profile.URL = url
+	}
+	if token := os.Getenv(TokenEnv); token != "" {
+		profile.Token = token
+	}
+	return profile, nil
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"payment-service/client"
+	"payment-service/model"
+)
+
+// Pain001Namespace the namespace of the ISO 20022 customer credit transfer initiation messages exported
+const Pain001Namespace = "urn:iso:std:iso:20022:tech:xsd:pain.001.001.03"
+
+// csvHeader the columns of the CSV export
+var csvHeader = []string{"id", "organisation_id", "status", "payment_id", "payment_scheme", "payment_type", "amount",
+	"currency", "processing_date", "reference", "end_to_end_reference", "debtor_name", "debtor_account_number",
+	"debtor_bank_id", "beneficiary_name", "beneficiary_account_number", "beneficiary_bank_id", "bearer_code",
+	"exchange_rate"}
+
+// Helper function running the export command
+func export(ctx context.Context, c *client.Client, args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("export", "[-format csv|iso] [-out file] [filters]", "Exports the payments matching the filters as CSV or "+
+		"as an ISO 20022 customer credit transfer\ninitiation (pain.001.001.03), one payment information block per payment.", stderr)
+	format := flags.String("format", "csv", "export format, csv or iso")
+	outFile := flags.String("out", "", "file to write, the standard output by default")
+	initiator := flags.String("initiator", "payment-service", "name of the initiating party of the ISO message")
+	var filter filter
+	filter.register(flags)
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 || (*format != "csv" && *format != "iso") {
+		flags.Usage()
+		return 2
+	}
+
+	payments, err := filter.payments(ctx, c)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to list payments: %s\n", err)
+		return 1
+	}
+
+	out := stdout
+	if *outFile != "" {
+		f, err := os.Create(*outFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "Failed to create %s: %s\n", *outFile, err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if *format == "iso" {
+		err = writePain001(out, payments, *initiator, time.Now())
+	} else {
+		err = writeCSV(out, payments)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to export payments: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// Helper function to write the payments as CSV
+func writeCSV(out io.Writer, payments []model.Payment) error {
+	w := csv.NewWriter(out)
+	w.Write(csvHeader)
+	for _, p := range payments {
+		w.Write([]string{p.ID.String(), p.OrganisationId, status(p), p.PaymentID, p.PaymentScheme, p.PaymentType,
+			amount(p.Amount), p.Currency, p.ProcessingDate.Format("2006-01-02"), p.Reference, p.EndToEndReference,
+			p.DebtorParty.Name, p.DebtorParty.AccountNumber, p.DebtorParty.BankID, p.BeneficiaryParty.Name,
+			p.BeneficiaryParty.AccountNumber, p.BeneficiaryParty.BankID, p.ChargesInformation.BearerCode,
+			strconv.FormatFloat(p.Fx.ExchangeRate, 'f', -1, 64)})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+//----------------------------------------------------------------------------------------
+//							ISO 20022 pain.001.001.03
+//----------------------------------------------------------------------------------------
+
+type document struct {
+	XMLName  xml.Name `xml:"Document"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Initiate initiation
+}
+
+type initiation struct {
+	XMLName     xml.Name `xml:"CstmrCdtTrfInitn"`
+	GroupHeader groupHeader
+	Payments    []paymentInformation
+}
+
+type groupHeader struct {
+	XMLName          xml.Name `xml:"GrpHdr"`
+	MessageID        string   `xml:"MsgId"`
+	CreationDateTime string   `xml:"CreDtTm"`
+	Transactions     int      `xml:"NbOfTxs"`
+	ControlSum       string   `xml:"CtrlSum"`
+	InitiatingParty  party    `xml:"InitgPty"`
+}
+
+type paymentInformation struct {
+	XMLName           xml.Name    `xml:"PmtInf"`
+	ID                string      `xml:"PmtInfId"`
+	Method            string      `xml:"PmtMtd"`
+	Transactions      int         `xml:"NbOfTxs"`
+	ControlSum        string      `xml:"CtrlSum"`
+	ExecutionDate     string      `xml:"ReqdExctnDt"`
+	Debtor            party       `xml:"Dbtr"`
+	DebtorAccount     account     `xml:"DbtrAcct"`
+	DebtorAgent       agent       `xml:"DbtrAgt"`
+	ChargeBearer      string      `xml:"ChrgBr,omitempty"`
+	CreditTransaction transaction `xml:"CdtTrfTxInf"`
+}
+
+type transaction struct {
+	PaymentID       paymentID   `xml:"PmtId"`
+	Amount          instructed  `xml:"Amt>InstdAmt"`
+	CreditorAgent   agent       `xml:"CdtrAgt"`
+	Creditor        party       `xml:"Cdtr"`
+	CreditorAccount account     `xml:"CdtrAcct"`
+	RemittanceInfo  *remittance `xml:"RmtInf,omitempty"`
+}
+
+type paymentID struct {
+	InstructionID string `xml:"InstrId"`
+	EndToEndID    string `xml:"EndToEndId"`
+}
+
+type instructed struct {
+	Currency string `xml:"Ccy,attr"`
+	Amount   string `xml:",chardata"`
+}
+
+type party struct {
+	Name    string   `xml:"Nm,omitempty"`
+	Address *address `xml:"PstlAdr,omitempty"`
+}
+
+type address struct {
+	Line string `xml:"AdrLine"`
+}
+
+type account struct {
+	IBAN  string `xml:"Id>IBAN,omitempty"`
+	Other *other `xml:"Id>Othr,omitempty"`
+}
+
+type other struct {
+	ID     string `xml:"Id"`
+	Scheme string `xml:"SchmeNm>Cd,omitempty"`
+}
+
+type agent struct {
+	Member *member `xml:"FinInstnId>ClrSysMmbId,omitempty"`
+	Other  string  `xml:"FinInstnId>Othr>Id,omitempty"`
+}
+
+type member struct {
+	ClearingSystem string `xml:"ClrSysId>Cd,omitempty"`
+	ID             string `xml:"MmbId"`
+}
+
+type remittance struct {
+	Unstructured string `xml:"Ustrd"`
+}
+
+// Helper function to write the payments as a pain.001 message
+func writePain001(out io.Writer, payments []model.Payment, initiator string, now time.Time) error {
+	doc := document{Xmlns: Pain001Namespace}
+	total := 0.0
+	for _, p := range payments {
+		total += p.Amount
+		doc.Initiate.Payments = append(doc.Initiate.Payments, paymentInformation{
+			ID:            p.ID.String(),
+			Method:        "TRF",
+			Transactions:  1,
+			ControlSum:    amount(p.Amount),
+			ExecutionDate: p.ProcessingDate.Format("2006-01-02"),
+			Debtor:        isoParty(p.DebtorParty),
+			DebtorAccount: isoAccount(p.DebtorParty),
+			DebtorAgent:   isoAgent(p.DebtorParty),
+			ChargeBearer:  p.ChargesInformation.BearerCode,
+			CreditTransaction: transaction{
+				PaymentID:       paymentID{InstructionID: p.ID.String(), EndToEndID: endToEndID(p)},
+				Amount:          instructed{Currency: p.Currency, Amount: amount(p.Amount)},
+				CreditorAgent:   isoAgent(p.BeneficiaryParty),
+				Creditor:        isoParty(p.BeneficiaryParty),
+				CreditorAccount: isoAccount(p.BeneficiaryParty),
+				RemittanceInfo:  remittanceInfo(p.Reference),
+			},
+		})
+	}
+	doc.Initiate.GroupHeader = groupHeader{
+		MessageID:        "PAYMENTCTL-" + now.UTC().Format("20060102150405"),
+		CreationDateTime: now.UTC().Format("2006-01-02T15:04:05"),
+		Transactions:     len(payments),
+		ControlSum:       amount(total),
+		InitiatingParty:  party{Name: initiator},
+	}
+
+	if _, err := io.WriteString(out, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(out)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, "\n")
+	return err
+}
+
+// Helper function to get a party with its address when known
+func isoParty(p model.Party) party {
+	iso := party{Name: p.Name}
+	if p.Address != "" {
+		iso.Address = &address{Line: p.Address}
+	}
+	return iso
+}
+
+// Helper function to get the agent of a party, its bank as a clearing system member, the agent being mandatory
+func isoAgent(p model.Party) agent {
+	if p.BankID == "" {
+		return agent{Other: "NOTPROVIDED"}
+	}
+	return agent{Member: &member{ClearingSystem: p.BankIDCode, ID: p.BankID}}
+}
+
+// Helper function to get the account of a party, by IBAN or its other identification
+func isoAccount(p model.Party) account {
+	if p.AccountNumberCode == "IBAN" {
+		return account{IBAN: p.AccountNumber}
+	}
+	return account{Other: &other{ID: p.AccountNumber, Scheme: p.AccountNumberCode}}
+}
+
+// Helper function to get the end to end identification of a payment, required by the scheme
+func endToEndID(p model.Payment) string {
+	if p.EndToEndReference != "" {
+		return p.EndToEndReference
+	}
+	return "NOTPROVIDED"
+}
+
+// Helper function to get the unstructured remittance information of a reference, omitted when empty
+func remittanceInfo(reference string) *remittance {
+	if reference == "" {
+		return nil
+	}
+	return &remittance{Unstructured: reference}
+}
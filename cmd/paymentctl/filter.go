@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strings"
+
+	"payment-service/client"
+	"payment-service/model"
+)
+
+// ListPageSize the number of payments fetched per request when listing
+const ListPageSize = 100
+
+// filter the criteria of the listed and exported payments, empty criteria matching every payment
+type filter struct {
+	organisationID string
+	status         string
+	scheme         string
+	currency       string
+}
+
+// Helper function to register the filter flags of a command
+func (f *filter) register(flags *flag.FlagSet) {
+	flags.StringVar(&f.organisationID, "org", "", "only the payments of the organisation")
+	flags.StringVar(&f.status, "status", "", "only the payments in the status, e.g. pending")
+	flags.StringVar(&f.scheme, "scheme", "", "only the payments of the scheme, e.g. FPS")
+	flags.StringVar(&f.currency, "currency", "", "only the payments in the currency, e.g. GBP")
+}
+
+// Helper function to tell whether a payment matches the filter
+func (f *filter) match(p model.Payment) bool {
+	return (f.organisationID == "" || p.OrganisationId == f.organisationID) &&
+		(f.status == "" || status(p) == f.status) &&
+		(f.scheme == "" || strings.EqualFold(p.PaymentScheme, f.scheme)) &&
+		(f.currency == "" || strings.EqualFold(p.Currency, f.currency))
+}
+
+// Helper function to list the payments matching the filter, the API filtering the list by organisation only
+func (f *filter) payments(ctx context.Context, c *client.Client) ([]model.Payment, error) {
+	payments := []model.Payment{}
+	it := c.List(ctx, client.ListOptions{PageSize: ListPageSize, OrganisationID: f.organisationID})
+	for it.Next() {
+		if f.match(it.Payment()) {
+			payments = append(payments, it.Payment())
+		}
+	}
+	return payments, it.Err()
+}
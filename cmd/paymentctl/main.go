@@ -0,0 +1,88 @@
+// Command paymentctl operates the payment service through its REST API.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"payment-service/client"
+)
+
+const usage = `Usage: paymentctl [-profile name] [-config file] <command> [flags] [args]
+
+Commands:
+  create   create payments from JSON or YAML files
+  list     list the payments, optionally filtered
+  get      show a payment
+  history  show the recent events of a payment
+  cancel   cancel payments
+  export   export the payments as CSV or ISO 20022 (pain.001)
+
+Run paymentctl <command> -h for the flags of a command.
+
+The API is given by a profile of the configuration file (~/.paymentctl.yaml or $PAYMENTCTL_CONFIG), PAYMENTCTL_URL
+and PAYMENTCTL_TOKEN overriding it.
+
+Flags:
+`
+
+// command runs a subcommand with its arguments, returning the exit code
+type command func(ctx context.Context, c *client.Client, args []string, stdout, stderr io.Writer) int
+
+var commands = map[string]command{
+	"create":  create,
+	"list":    list,
+	"get":     get,
+	"history": history,
+	"cancel":  cancel,
+	"export":  export,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// Helper function running paymentctl, returning the exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("paymentctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	configPath := flags.String("config", defaultConfigPath(), "configuration file holding the profiles")
+	profileName := flags.String("profile", "", "profile of the configuration file to use")
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown command %s\n", flags.Arg(0))
+		flags.Usage()
+		return 2
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	profile, err := config.profile(*profileName)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	c := client.New(profile.URL)
+	if profile.Token != "" {
+		c.Token = client.StaticToken(profile.Token)
+	}
+
+	return cmd(context.Background(), c, flags.Args()[1:], stdout, stderr)
+}
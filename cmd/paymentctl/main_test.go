@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"payment-service/api"
+	"payment-service/event"
+	"payment-service/model"
+	"payment-service/repository"
+	"payment-service/test"
+)
+
+const paymentsYAML = `
+- organisation_id: org-yaml
+  beneficiary_party: {AccountName: W Owens, AccountNumber: "31926819", account_number_code: BBAN, bank_id_code: GBDSC, name: Wilfred Owens, currency: USD}
+  debtor_party: {AccountName: EJ Brown, AccountNumber: GB29XABC10161234567801, account_number_code: IBAN, bank_id_code: GBDSC, name: Emelia Brown, currency: GBP}
+  payment_scheme: FPS
+  reference: First YAML payment
+  amount: 100.5
+  bearer_code: SHAR
+  processing_date: 2018-10-24T10:00:00Z
+- organisation_id: org-yaml
+  beneficiary_party: {AccountName: W Owens, AccountNumber: "31926819", name: Wilfred Owens, currency: GBP}
+  debtor_party: {AccountName: EJ Brown, AccountNumber: "12345678", name: Emelia Brown, currency: GBP}
+  payment_scheme: BACS
+  reference: Second YAML payment
+  amount: 20
+  bearer_code: DEBT
+`
+
+func TestPaymentctl_ShouldOperateThePayments(t *testing.T) {
+	t.Logf("Given paymentctl and a profile of the payment API")
+	{
+		env := newEnv(t)
+
+		t.Logf("\tWhen creating payments from YAML and JSON files")
+		{
+			yamlFile := filepath.Join(t.TempDir(), "payments.yaml")
+			ioutil.WriteFile(yamlFile, []byte(paymentsYAML), 0600)
+			out, code := env.run("create", "-o", "json", "-f", yamlFile, "-f", "../../samples/paymentRequest.json")
+			var created []model.CreatePaymentResponse
+			json.Unmarshal([]byte(out), &created)
+			check(t, code == 0 && len(created) == 3, "The three payments should have been created", out)
+
+			t.Logf("\tWhen listing the payments of an organisation")
+			{
+				out, code := env.run("list", "-org", "org-yaml", "-o", "json")
+				var payments []model.Payment
+				json.Unmarshal([]byte(out), &payments)
+				check(t, code == 0 && len(payments) == 2, "The two YAML payments should be listed", out)
+
+				out, code = env.run("list", "-scheme", "bacs")
+				lines := strings.Split(strings.TrimSpace(out), "\n")
+				check(t, code == 0 && len(lines) == 2 && strings.HasPrefix(lines[0], "ID") &&
+					strings.Contains(lines[1], "Second YAML payment"), "The BACS payment should be listed as a table", out)
+			}
+
+			t.Logf("\tWhen cancelling a payment")
+			{
+				id := created[0].ID
+				out, code := env.run("cancel", id)
+				check(t, code == 0 && strings.TrimSpace(out) == id+" cancelled", "The payment should have been cancelled", out)
+				out, code = env.run("list", "-status", model.StatusCancelled, "-o", "json")
+				check(t, code == 0 && strings.Contains(out, id), "The payment should be listed as cancelled", out)
+				_, code = env.run("cancel", model.NewID().String())
+				check(t, code == 1, "Cancelling an unknown payment should fail", code)
+
+				env.relay.Flush(context.Background())
+				out, code = env.run("history", id)
+				check(t, code == 0 && strings.Contains(out, "pending -> cancelled"),
+					"The history should show the status change", out)
+			}
+
+			t.Logf("\tWhen exporting the payments")
+			{
+				out, code := env.run("export", "-org", "org-yaml")
+				rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+				check(t, code == 0 && err == nil && len(rows) == 3 && rows[0][0] == "id", "The CSV should have a header and two rows", out)
+
+				sum := 0.0
+				for _, row := range rows[1:] {
+					a, _ := strconv.ParseFloat(row[6], 64)
+					sum += a
+				}
+
+				out, code = env.run("export", "-format", "iso", "-org", "org-yaml")
+				var doc struct {
+					Transactions int      `xml:"CstmrCdtTrfInitn>GrpHdr>NbOfTxs"`
+					ControlSum   string   `xml:"CstmrCdtTrfInitn>GrpHdr>CtrlSum"`
+					IBANs        []string `xml:"CstmrCdtTrfInitn>PmtInf>DbtrAcct>Id>IBAN"`
+				}
+				err = xml.Unmarshal([]byte(out), &doc)
+				check(t, code == 0 && err == nil && doc.Transactions == 2 && doc.ControlSum == amount(sum) &&
+					len(doc.IBANs) == 1 && doc.IBANs[0] == "GB29XABC10161234567801", "The pain.001 message should hold the two payments", out)
+			}
+		}
+	}
+}
+
+func TestPaymentctl_ShouldRejectInvalidUsage(t *testing.T) {
+	t.Logf("Given paymentctl")
+	{
+		env := newEnv(t)
+
+		t.Logf("\tWhen running invalid commands")
+		{
+			_, code := env.run("unknown")
+			check(t, code == 2, "An unknown command should be a usage error", code)
+			_, code = env.run("create")
+			check(t, code == 2, "Creating without files should be a usage error", code)
+			_, code = env.run("export", "-format", "pdf")
+			check(t, code == 2, "An unknown export format should be a usage error", code)
+			code = run([]string{"-config", env.config, "-profile", "missing", "list"}, &bytes.Buffer{}, &bytes.Buffer{})
+			check(t, code == 1, "An unknown profile should fail", code)
+		}
+	}
+}
+
+// env a payment API served over an in memory repository and the configuration of paymentctl talking to it
+type env struct {
+	t      *testing.T
+	config string
+	relay  *event.Relay
+}
+
+// Helper function to serve the payment API and write a configuration file with its profile
+func newEnv(t *testing.T) *env {
+	t.Setenv(URLEnv, "")
+	t.Setenv(TokenEnv, "")
+	t.Setenv(ProfileEnv, "")
+
+	repo := repository.NewMemoryRepository()
+	repo.EnsureIndexes(context.Background(), api.DatabaseName, api.CollectionName)
+	stream := event.NewStream(event.DefaultStreamSize, event.DefaultStreamBuffer)
+	srv := httptest.NewServer(api.NewPaymentHandler(repo, "urlFX", "urlCF").WithStream(stream).NewRouter())
+	t.Cleanup(srv.Close)
+
+	config := filepath.Join(t.TempDir(), "paymentctl.yaml")
+	ioutil.WriteFile(config, []byte("current: local\nprofiles:\n  local:\n    url: http://localhost:1\n  test:\n    url: "+srv.URL+"\n"), 0600)
+	return &env{t: t, config: config, relay: event.NewRelay(repo, stream, api.DatabaseName)}
+}
+
+// Helper function to run paymentctl with the test profile, returning its output and exit code
+func (e *env) run(args ...string) (string, int) {
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"-config", e.config, "-profile", "test"}, args...), &stdout, &stderr)
+	if stderr.Len() > 0 {
+		e.t.Logf("\t\t%s", strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), code
+}
+
+// Helper function to log an expectation with a check mark, failing the test with a ballot when it is not met
+func check(t *testing.T, ok bool, expectation string, got interface{}) {
+	if ok {
+		t.Logf("\t\t%s. %v", expectation, test.CheckMark)
+	} else {
+		t.Errorf("\t\t%s. %v %v", expectation, got, test.BallotX)
+	}
+}
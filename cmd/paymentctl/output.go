@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"payment-service/model"
+)
+
+// table writes aligned columns
+type table struct {
+	w *tabwriter.Writer
+}
+
+// Helper function to start a table with the given header
+func newTable(out io.Writer, header ...string) *table {
+	t := &table{w: tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)}
+	t.row(header...)
+	return t
+}
+
+func (t *table) row(cells ...string) {
+	fmt.Fprintln(t.w, strings.Join(cells, "\t"))
+}
+
+func (t *table) flush() {
+	t.w.Flush()
+}
+
+// Helper function to write a value as indented JSON
+func writeJSON(out io.Writer, v interface{}) {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+// Helper function to get the status of a payment, payments without one being pending
+func status(p model.Payment) string {
+	if p.Status == "" {
+		return model.StatusPending
+	}
+	return p.Status
+}
+
+// Helper function to format an amount with two decimals
+func amount(a float64) string {
+	return strconv.FormatFloat(a, 'f', 2, 64)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"payment-service/config"
+)
+
+const configUsage = `Usage: payment-service [flags] config print
+
+Prints the effective configuration, from the defaults, the configuration file, the environment and the flags, with
+the secrets redacted, then reports the invalid settings.
+`
+
+// Helper function running the config subcommand, returning the exit code
+func configure(cfg config.Config, args []string, out io.Writer) int {
+	if len(args) != 1 || args[0] != "print" {
+		fmt.Fprint(out, configUsage)
+		return 2
+	}
+	if err := cfg.Print(out); err != nil {
+		fmt.Fprintf(out, "Failed to print the configuration: %s\n", err)
+		return 1
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+	return 0
+}
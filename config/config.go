@@ -0,0 +1,266 @@
+// Package config loads the settings of the payment service from a YAML file, the environment and command-line flags.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"payment-service/health"
+	"payment-service/logger"
+	"payment-service/model"
+	"payment-service/privacy"
+	"payment-service/tracing"
+)
+
+// FileEnv the environment variable naming the configuration file, overridden by the -config flag
+const FileEnv = "CONFIG_FILE"
+
+// Config the settings of the payment service. Each setting is read, by increasing precedence, from its default, the
+// YAML configuration file (yaml tag), the environment (env tag, the first variable set winning) and the command-line
+// (flag tag). Secret settings may also be read from the file named by their environment variable suffixed with _FILE,
+// e.g. MONGO_URL_FILE, and are redacted when printed.
+type Config struct {
+	Environment     string        `yaml:"environment" env:"ENVIRONMENT" flag:"environment" usage:"deployment environment, e.g. development or production"`
+	StartupTimeout  time.Duration `yaml:"startup_timeout" env:"STARTUP_TIMEOUT" flag:"startup-timeout" usage:"time given to the repository to become available on startup, e.g. 1m"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"time given to drain the requests and stop the workers on SIGTERM, e.g. 30s"`
+	Log             Log           `yaml:"log"`
+	HTTP            HTTP          `yaml:"http"`
+	Health          Health        `yaml:"health"`
+	Privacy         Privacy       `yaml:"privacy"`
+	Tracing         Tracing       `yaml:"tracing"`
+	GRPC            GRPC          `yaml:"grpc"`
+	Repository      Repository    `yaml:"repository"`
+	Mongo           Mongo         `yaml:"mongo"`
+	Pricing         Pricing       `yaml:"pricing"`
+	Kafka           Kafka         `yaml:"kafka"`
+}
+
+// Log the settings of the logs
+type Log struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" flag:"log-level" usage:"lowest level logged, debug, info, warn or error"`
+	Format string `yaml:"format" env:"LOG_FORMAT" flag:"log-format" usage:"json, or text to read the logs in a terminal"`
+}
+
+// HTTP the settings of the REST API
+type HTTP struct {
+	Addr string `yaml:"addr" env:"HTTP_ADDR" flag:"http-addr" usage:"address of the REST API"`
+}
+
+// Health the settings of the readiness checks
+type Health struct {
+	Timeout  time.Duration `yaml:"timeout" env:"HEALTH_TIMEOUT" flag:"health-timeout" usage:"time given to each dependency check"`
+	CacheTTL time.Duration `yaml:"cache_ttl" env:"HEALTH_CACHE_TTL" flag:"health-cache-ttl" usage:"how long the result of a dependency check is reused"`
+}
+
+// Privacy the roles receiving unmasked party details, the personal data being always masked in the logs
+type Privacy struct {
+	RoleHeader    string   `yaml:"role_header" env:"PRIVACY_ROLE_HEADER" flag:"privacy-role-header" usage:"header, or gRPC metadata key, carrying the role of the caller set by the gateway"`
+	UnmaskedRoles []string `yaml:"unmasked_roles" env:"PRIVACY_UNMASKED_ROLES" flag:"privacy-unmasked-roles" usage:"comma separated roles receiving unmasked party details, the other roles and the callers without role getting them masked"`
+	MaskedFields  []string `yaml:"masked_fields" env:"PRIVACY_MASKED_FIELDS" flag:"privacy-masked-fields" usage:"comma separated party fields masked, account_name, account_number, address, name or bank_id"`
+}
+
+// Tracing the export of the OpenTelemetry spans of the requests
+type Tracing struct {
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER" flag:"tracing-exporter" usage:"none, stdout or otlp"`
+	Endpoint    string  `yaml:"endpoint" env:"TRACING_ENDPOINT,OTEL_EXPORTER_OTLP_ENDPOINT" flag:"tracing-endpoint" usage:"URL of the OTLP gRPC collector of the otlp exporter"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" flag:"tracing-sample-ratio" usage:"share of the traces started by the service which are sampled, from 0 to 1"`
+}
+
+// GRPC the settings of the gRPC API
+type GRPC struct {
+	Addr string `yaml:"addr" env:"GRPC_ADDR" flag:"grpc-addr" usage:"address of the gRPC API"`
+}
+
+// Repository the settings of the payment storage
+type Repository struct {
+	URL string `yaml:"url" env:"REPOSITORY_URL" flag:"repository-url" secret:"true" usage:"repository URL, mongodb://, postgres://, bolt:// or memory://, the mongo URL by default"`
+}
+
+// Mongo the settings of the mongo repository
+type Mongo struct {
+	URL            string `yaml:"url" env:"MONGO_URL,MONGO_URI" flag:"mongo-url" secret:"true" usage:"mongo connection string"`
+	PoolLimit      int    `yaml:"pool_limit" env:"MONGO_POOL_LIMIT" flag:"mongo-pool-limit" usage:"maximum number of connections per server, zero for the driver default"`
+	ReadPreference string `yaml:"read_preference" env:"MONGO_READ_PREFERENCE" flag:"mongo-read-preference" usage:"primary, primaryPreferred, secondary, secondaryPreferred or nearest"`
+	WriteConcern   string `yaml:"write_concern" env:"MONGO_WRITE_CONCERN" flag:"mongo-write-concern" usage:"number of nodes acknowledging writes or majority"`
+	Journal        bool   `yaml:"journal" env:"MONGO_JOURNAL" flag:"mongo-journal" usage:"wait for the journal commit on writes"`
+}
+
+// Pricing the services pricing the payments
+type Pricing struct {
+	FXURL      string `yaml:"fx_url" env:"FX_URL" flag:"fx-url" usage:"URL of the foreign exchange service"`
+	ChargesURL string `yaml:"charges_url" env:"CHARGES_URL" flag:"charges-url" usage:"URL of the charges service"`
+}
+
+// Kafka the settings of the event publisher, the events being logged when no broker is given
+type Kafka struct {
+	Brokers      []string          `yaml:"brokers" env:"KAFKA_BROKERS" flag:"kafka-brokers" usage:"comma separated Kafka brokers"`
+	Topics       map[string]string `yaml:"topics" env:"KAFKA_TOPICS" flag:"kafka-topics" usage:"comma separated EventType=topic assignments"`
+	DefaultTopic string            `yaml:"default_topic" env:"KAFKA_DEFAULT_TOPIC" flag:"kafka-default-topic" usage:"topic of the event types without a topic of their own"`
+}
+
+// Default returns the default settings, a local development setup
+func Default() Config {
+	return Config{
+		Environment:     "development",
+		StartupTimeout:  time.Minute,
+		ShutdownTimeout: 30 * time.Second,
+		Log:             Log{Level: "info", Format: logger.FormatJSON},
+		HTTP:            HTTP{Addr: ":8080"},
+		Health:          Health{Timeout: health.DefaultTimeout, CacheTTL: health.DefaultCacheTTL},
+		Privacy:         Privacy{RoleHeader: privacy.DefaultRoleHeader, UnmaskedRoles: []string{"admin"}, MaskedFields: append([]string(nil), privacy.DefaultFields...)},
+		Tracing:         Tracing{Exporter: tracing.ExporterNone, Endpoint: "http://localhost:4317", SampleRatio: 1},
+		GRPC:            GRPC{Addr: ":9000"},
+		Mongo:           Mongo{URL: "mongodb://localhost:27017/payment-db"},
+		Pricing:         Pricing{FXURL: "http://localhost:9090/fx", ChargesURL: "http://localhost:9090/ch"},
+		Kafka:           Kafka{DefaultTopic: "payments"},
+	}
+}
+
+// RepositoryURL returns the URL of the repository, defaulting to the mongo URL
+func (c Config) RepositoryURL() string {
+	if c.Repository.URL != "" {
+		return c.Repository.URL
+	}
+	return c.Mongo.URL
+}
+
+// Validate checks the settings, reporting all the invalid ones
+func (c Config) Validate() error {
+	var errs []string
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	if c.StartupTimeout <= 0 {
+		invalid("startup_timeout %s is not positive", c.StartupTimeout)
+	}
+	if c.ShutdownTimeout <= 0 {
+		invalid("shutdown_timeout %s is not positive", c.ShutdownTimeout)
+	}
+	if c.Health.Timeout <= 0 {
+		invalid("health.timeout %s is not positive", c.Health.Timeout)
+	}
+	if c.Health.CacheTTL < 0 {
+		invalid("health.cache_ttl %s is negative", c.Health.CacheTTL)
+	}
+	if c.Privacy.RoleHeader == "" {
+		invalid("privacy.role_header is empty")
+	}
+	for _, field := range c.Privacy.MaskedFields {
+		if !privacy.IsField(field) {
+			invalid("privacy.masked_fields has an unknown party field %q", field)
+		}
+	}
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout:
+	case tracing.ExporterOTLP:
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("tracing.endpoint %q is not an http(s) URL", c.Tracing.Endpoint)
+		}
+	default:
+		invalid("tracing.exporter %q is not none, stdout or otlp", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		invalid("tracing.sample_ratio %v is not between 0 and 1", c.Tracing.SampleRatio)
+	}
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		invalid("log.level %q is not debug, info, warn or error", c.Log.Level)
+	}
+	if c.Log.Format != logger.FormatJSON && c.Log.Format != logger.FormatText {
+		invalid("log.format %q is not json or text", c.Log.Format)
+	}
+	if _, _, err := net.SplitHostPort(c.HTTP.Addr); err != nil {
+		invalid("http.addr %q is not a host:port address", c.HTTP.Addr)
+	}
+	if _, _, err := net.SplitHostPort(c.GRPC.Addr); err != nil {
+		invalid("grpc.addr %q is not a host:port address", c.GRPC.Addr)
+	}
+	switch scheme(c.RepositoryURL()) {
+	case "mongodb", "mongodb+srv", "postgres", "postgresql", "bolt", "memory":
+	default:
+		invalid("repository.url has an unsupported scheme %q", scheme(c.RepositoryURL()))
+	}
+	if c.Mongo.PoolLimit < 0 {
+		invalid("mongo.pool_limit %d is negative", c.Mongo.PoolLimit)
+	}
+	switch c.Mongo.ReadPreference {
+	case "", "primary", "primaryPreferred", "secondary", "secondaryPreferred", "nearest":
+	default:
+		invalid("mongo.read_preference %q is unknown", c.Mongo.ReadPreference)
+	}
+	if w := c.Mongo.WriteConcern; w != "" && w != "majority" {
+		if n, err := strconv.Atoi(w); err != nil || n < 0 {
+			invalid("mongo.write_concern %q is neither a number of nodes nor majority", w)
+		}
+	}
+	for name, u := range map[string]string{"pricing.fx_url": c.Pricing.FXURL, "pricing.charges_url": c.Pricing.ChargesURL} {
+		if parsed, err := url.Parse(u); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			invalid("%s %q is not an http(s) URL", name, u)
+		}
+	}
+	for eventType, topic := range c.Kafka.Topics {
+		if !model.IsEventType(eventType) {
+			invalid("kafka.topics has an unknown event type %q", eventType)
+		}
+		if topic == "" {
+			invalid("kafka.topics has no topic for %s", eventType)
+		}
+	}
+	if len(c.Kafka.Brokers) > 0 && c.Kafka.DefaultTopic == "" {
+		invalid("kafka.default_topic is empty")
+	}
+
+	if len(errs) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Redacted returns a copy of the settings with the secrets redacted: the password of URLs, the whole value otherwise
+func (c Config) Redacted() Config {
+	redacted := c
+	for _, s := range settings(&redacted) {
+		if s.secret && s.value.String() != "" {
+			s.value.SetString(redact(s.value.String()))
+		}
+	}
+	return redacted
+}
+
+// Print writes the settings as YAML, secrets redacted
+func (c Config) Print(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// Helper function to redact a secret, keeping the parts of a URL other than its password
+func redact(secret string) string {
+	if u, err := url.Parse(secret); err == nil && u.Scheme != "" && u.Opaque == "" {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.UserPassword(u.User.Username(), "xxxxx")
+		}
+		return u.String()
+	}
+	return "xxxxx"
+}
+
+// Helper function to get the scheme of a URL
+func scheme(u string) string {
+	if i := strings.Index(u, "://"); i > 0 {
+		return u[:i]
+	}
+	return ""
+}
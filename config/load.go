@@ -0,0 +1,239 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// setting a field of Config along its sources
+type setting struct {
+	env    []string
+	flag   string
+	usage  string
+	secret bool
+	value  reflect.Value
+}
+
+// Load reads the settings from their defaults, the configuration file given by the -config flag or CONFIG_FILE, the
+// environment and the command-line flags of args. It returns the arguments following the flags, e.g. a subcommand.
+// The settings are not validated, see Validate. Flag errors and usage are written to output.
+func Load(args []string, output io.Writer) (Config, []string, error) {
+	cfg := Default()
+
+	flags := flag.NewFlagSet("payment-service", flag.ContinueOnError)
+	flags.SetOutput(output)
+	file := flags.String("config", os.Getenv(FileEnv), "YAML configuration file, $"+FileEnv+" by default")
+	given := make(map[string]string)
+	for _, s := range settings(&cfg) {
+		if s.flag != "" {
+			flags.Var(&flagValue{name: s.flag, def: format(s.value), boolean: s.value.Kind() == reflect.Bool, given: given}, s.flag, s.usage+envUsage(s))
+		}
+	}
+	flags.Usage = func() {
+		fmt.Fprint(output, "Usage: payment-service [flags] [migrate|config print]\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	if *file != "" {
+		if err := loadFile(&cfg, *file); err != nil {
+			return cfg, nil, err
+		}
+	}
+	for _, s := range settings(&cfg) {
+		if err := loadEnv(s); err != nil {
+			return cfg, nil, err
+		}
+		if raw, ok := given[s.flag]; ok && s.flag != "" {
+			if err := set(s.value, raw); err != nil {
+				return cfg, nil, fmt.Errorf("invalid -%s: %s", s.flag, err)
+			}
+		}
+	}
+	return cfg, flags.Args(), nil
+}
+
+// Helper function to read the YAML configuration file over the settings, unknown keys being errors
+func loadFile(cfg *Config, file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read the configuration file: %s", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && err != io.EOF {
+		return fmt.Errorf("invalid configuration file %s: %s", file, err)
+	}
+	return nil
+}
+
+// Helper function to read a setting from its environment variables, or for secrets from the file named by their
+// _FILE variant. The first variable set wins, empty variables being ignored as compose sets the unset ones it passes.
+func loadEnv(s setting) error {
+	for _, name := range s.env {
+		if s.secret {
+			if file := os.Getenv(name + "_FILE"); file != "" {
+				data, err := ioutil.ReadFile(file)
+				if err != nil {
+					return fmt.Errorf("failed to read %s_FILE: %s", name, err)
+				}
+				return set(s.value, strings.TrimSpace(string(data)))
+			}
+		}
+		if raw := os.Getenv(name); raw != "" {
+			if err := set(s.value, raw); err != nil {
+				return fmt.Errorf("invalid %s: %s", name, err)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// Helper function to list the settings of a Config, walking its nested structs
+func settings(cfg *Config) []setting {
+	return walk(reflect.ValueOf(cfg).Elem())
+}
+
+// Helper function to list the settings of a struct value
+func walk(v reflect.Value) []setting {
+	var found []setting
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			found = append(found, walk(v.Field(i))...)
+			continue
+		}
+		s := setting{flag: field.Tag.Get("flag"), usage: field.Tag.Get("usage"),
+			secret: field.Tag.Get("secret") == "true", value: v.Field(i)}
+		if env := field.Tag.Get("env"); env != "" {
+			s.env = strings.Split(env, ",")
+		}
+		found = append(found, s)
+	}
+	return found
+}
+
+// Helper function to parse a raw value into a setting: comma separated values for lists, key=value pairs for maps and
+// e.g. 30s for durations
+func set(v reflect.Value, raw string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%q is not a duration", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", raw)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		var list []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	case reflect.Map:
+		m := make(map[string]string)
+		for _, pair := range strings.Split(raw, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+				return fmt.Errorf("%q is not a key=value pair", pair)
+			}
+			m[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+		v.Set(reflect.ValueOf(m))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// Helper function to format a setting the way it is parsed by set
+func format(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Slice:
+		return strings.Join(v.Interface().([]string), ",")
+	case reflect.Map:
+		var pairs []string
+		for k, val := range v.Interface().(map[string]string) {
+			pairs = append(pairs, k+"="+val)
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// Helper function to mention the environment variables of a setting in its flag usage
+func envUsage(s setting) string {
+	if len(s.env) == 0 {
+		return ""
+	}
+	return " ($" + strings.Join(s.env, ", $") + ")"
+}
+
+// flagValue records the raw value of a flag, applied once the file and environment are loaded
+type flagValue struct {
+	name    string
+	def     string
+	boolean bool
+	given   map[string]string
+}
+
+func (f *flagValue) String() string {
+	if f == nil {
+		return ""
+	}
+	if raw, ok := f.given[f.name]; ok {
+		return raw
+	}
+	return f.def
+}
+
+func (f *flagValue) Set(raw string) error {
+	f.given[f.name] = raw
+	return nil
+}
+
+// IsBoolFlag allows boolean flags without value, e.g. -mongo-journal
+func (f *flagValue) IsBoolFlag() bool {
+	return f.boolean
+}
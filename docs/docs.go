@@ -1,27 +1,88 @@
-// GENERATED BY THE COMMAND ABOVE; DO NOT EDIT
-// This file was generated by swaggo/swag at
-// 2018-10-29 23:08:50.338515 +0000 GMT m=+0.027970621
+// Code generated by swaggo/swag. DO NOT EDIT.
 
 package docs
 
-import (
-	"github.com/swaggo/swag"
-)
+import "github.com/swaggo/swag"
 
-var doc = `{
+const docTemplate = `{
+    "schemes": {{ marshal .Schemes }},
     "swagger": "2.0",
     "info": {
-        "description": "{{.Description}}",
+        "description": "{{escape .Description}}",
         "title": "{{.Title}}",
         "contact": {},
-        "license": {},
+        "license": {
+            "name": "Apache 2.0",
+            "url": "http://www.apache.org/licenses/LICENSE-2.0.html"
+        },
         "version": "{{.Version}}"
     },
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/backup": {
+            "get": {
+                "description": "Only served for the bbolt repository, the copy being opened as a bbolt file to restore it.",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "summary": "Streams a consistent copy of the repository storage",
+                "operationId": "get-backup",
+                "responses": {
+                    "200": {
+                        "description": "Copy of the bbolt file",
+                        "schema": {
+                            "type": "file"
+                        }
+                    }
+                }
+            }
+        },
+        "/health/live": {
+            "get": {
+                "description": "The dependencies are not checked, a failing liveness meaning the process should be restarted.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Tells whether the service process is alive",
+                "operationId": "get-health-live",
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    }
+                }
+            }
+        },
+        "/health/ready": {
+            "get": {
+                "description": "Checks the repository and the upstream services, the results being cached for a few seconds. The\nservice is degraded, but still ready, when only the optional upstream services are unavailable.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Tells whether the service is ready to serve requests",
+                "operationId": "get-health-ready",
+                "responses": {
+                    "200": {
+                        "description": "ready",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    },
+                    "503": {
+                        "description": "not ready",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    }
+                }
+            }
+        },
         "/payment": {
             "get": {
+                "description": "The payments are paginated, ordered by ID, when page[number] or page[size] is given. links.next is the next page.",
                 "consumes": [
                     "application/json"
                 ],
@@ -30,25 +91,54 @@ var doc = `{
                 ],
                 "summary": "Get all payments",
                 "operationId": "get-payments",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page number, from zero",
+                        "name": "page[number]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, 100 by default",
+                        "name": "page[size]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only the payments of the organisation",
+                        "name": "filter[organisation_id]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only the payments processed from the date, e.g. 2018-09-10",
+                        "name": "filter[processing_date_from]",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only the payments processed before the date, e.g. 2018-09-11",
+                        "name": "filter[processing_date_to]",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "ok",
                         "schema": {
-                            "type": "object",
                             "$ref": "#/definitions/model.PaymentResponse"
                         }
                     },
                     "400": {
                         "description": "Bad request",
                         "schema": {
-                            "type": "object",
                             "$ref": "#/definitions/model.ErrorResponse"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "object",
                             "$ref": "#/definitions/model.ErrorResponse"
                         }
                     }
@@ -71,7 +161,6 @@ var doc = `{
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "type": "object",
                             "$ref": "#/definitions/model.CreatePaymentRequest"
                         }
                     }
@@ -80,27 +169,68 @@ var doc = `{
                     "201": {
                         "description": "Tag created",
                         "schema": {
-                            "type": "object",
                             "$ref": "#/definitions/model.CreatePaymentResponse"
                         }
                     },
                     "400": {
                         "description": "Bad request",
                         "schema": {
-                            "type": "object",
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
                             "$ref": "#/definitions/model.ErrorResponse"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "object",
                             "$ref": "#/definitions/model.ErrorResponse"
                         }
                     }
                 }
             }
         },
+        "/payment/events": {
+            "get": {
+                "description": "Each event is a CloudEvents JSON message. Reconnecting clients send Last-Event-ID to resume, a reset event meaning events were missed.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "summary": "Streams the payment events as Server-Sent Events",
+                "operationId": "stream-payment-events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Organisation ID",
+                        "name": "organisation_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Payment scheme",
+                        "name": "scheme",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Payment status",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Event stream",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
         "/payment/{id}": {
             "get": {
                 "consumes": [
@@ -115,64 +245,70 @@ var doc = `{
                     "200": {
                         "description": "ok",
                         "schema": {
-                            "type": "object",
                             "$ref": "#/definitions/model.PaymentResponse"
                         }
                     },
                     "400": {
                         "description": "Bad request",
                         "schema": {
-                            "type": "object",
                             "$ref": "#/definitions/model.ErrorResponse"
                         }
                     },
                     "404": {
                         "description": "Not found",
                         "schema": {
-                            "type": "object",
                             "$ref": "#/definitions/model.ErrorResponse"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "object",
                             "$ref": "#/definitions/model.ErrorResponse"
                         }
                     }
                 }
             },
             "put": {
+                "description": "Replaces the payment when it exists. Payments with a client supplied UUID are created when missing.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Update a payment for given ID - partial payment is not supported",
+                "summary": "Create or replace a payment for given ID - partial payment is not supported",
                 "operationId": "update-payment",
                 "responses": {
+                    "201": {
+                        "description": "Payment created",
+                        "schema": {
+                            "$ref": "#/definitions/model.CreatePaymentResponse"
+                        }
+                    },
                     "204": {
                         "description": "Payment updated"
                     },
                     "400": {
                         "description": "Bad request",
                         "schema": {
-                            "type": "object",
                             "$ref": "#/definitions/model.ErrorResponse"
                         }
                     },
                     "404": {
                         "description": "Not found",
                         "schema": {
-                            "type": "object",
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
                             "$ref": "#/definitions/model.ErrorResponse"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "object",
                             "$ref": "#/definitions/model.ErrorResponse"
                         }
                     }
@@ -194,21 +330,303 @@ var doc = `{
                     "400": {
                         "description": "Bad request",
                         "schema": {
-                            "type": "object",
                             "$ref": "#/definitions/model.ErrorResponse"
                         }
                     },
                     "404": {
                         "description": "Not found",
                         "schema": {
-                            "type": "object",
                             "$ref": "#/definitions/model.ErrorResponse"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "object",
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "FX and charges are only recalculated when the amount, currencies or bearer code change. The amount is in the beneficiary currency and stored as sent. Unknown members are refused, JSON Patch (RFC 6902) is not supported.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Partially update a payment for given ID using a JSON merge patch (RFC 7396)",
+                "operationId": "patch-payment",
+                "responses": {
+                    "200": {
+                        "description": "Payment updated",
+                        "schema": {
+                            "$ref": "#/definitions/model.PaymentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported media type",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payment/{id}/history": {
+            "get": {
+                "description": "The events are the ones kept by the event stream of the instance, the last 1000 payment events.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get the recent events of a payment",
+                "operationId": "get-payment-history",
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/model.EventResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Query the webhooks of an organisation",
+                "operationId": "find-webhooks",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Organisation ID",
+                        "name": "organisation_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/model.WebhookResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "The deliveries are signed with the returned secret, which is generated when missing and never returned again.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Subscribes an endpoint to the events of the organisation payments",
+                "operationId": "create-webhook",
+                "parameters": [
+                    {
+                        "description": "Webhook",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.CreateWebhookRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Webhook created",
+                        "schema": {
+                            "$ref": "#/definitions/model.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{webhook}": {
+            "delete": {
+                "summary": "Delete a webhook of an organisation, its pending deliveries being dead-lettered",
+                "operationId": "delete-webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Organisation ID",
+                        "name": "organisation_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Webhook deleted"
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{webhook}/deliveries": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Query the delivery log of a webhook",
+                "operationId": "find-webhook-deliveries",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Organisation ID",
+                        "name": "organisation_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/model.DeliveryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{webhook}/deliveries/{delivery}/redeliver": {
+            "post": {
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Deliver an event to a webhook again, whatever the outcome of the previous attempts",
+                "operationId": "redeliver-webhook-delivery",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Organisation ID",
+                        "name": "organisation_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Delivery scheduled",
+                        "schema": {
+                            "$ref": "#/definitions/model.Delivery"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Delivery being attempted",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
                             "$ref": "#/definitions/model.ErrorResponse"
                         }
                     }
@@ -217,6 +635,40 @@ var doc = `{
         }
     },
     "definitions": {
+        "health.Report": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/health.Result"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "health.Result": {
+            "type": "object",
+            "properties": {
+                "checked_at": {
+                    "type": "string"
+                },
+                "duration": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "required": {
+                    "type": "boolean"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
         "model.Charge": {
             "type": "object",
             "properties": {
@@ -231,20 +683,20 @@ var doc = `{
         "model.ChargesInformation": {
             "type": "object",
             "properties": {
-                "bearerCode": {
+                "BearerCode": {
                     "type": "string"
                 },
+                "SenderCharges": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Charge"
+                    }
+                },
                 "receiver_charges_amount": {
                     "type": "number"
                 },
                 "receiver_charges_currency": {
                     "type": "string"
-                },
-                "senderCharges": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/model.Charge"
-                    }
                 }
             }
         },
@@ -253,6 +705,8 @@ var doc = `{
             "required": [
                 "amount",
                 "bearer_code",
+                "beneficiary_party",
+                "debtor_party",
                 "organisation_id"
             ],
             "properties": {
@@ -263,16 +717,17 @@ var doc = `{
                     "type": "string"
                 },
                 "beneficiary_party": {
-                    "type": "object",
                     "$ref": "#/definitions/model.Party"
                 },
                 "debtor_party": {
-                    "type": "object",
                     "$ref": "#/definitions/model.Party"
                 },
                 "end_to_end_reference": {
                     "type": "string"
                 },
+                "id": {
+                    "type": "string"
+                },
                 "numeric_reference": {
                     "type": "string"
                 },
@@ -291,6 +746,9 @@ var doc = `{
                 "payment_type": {
                     "type": "string"
                 },
+                "processing_date": {
+                    "type": "string"
+                },
                 "reference": {
                     "type": "string"
                 },
@@ -301,7 +759,6 @@ var doc = `{
                     "type": "string"
                 },
                 "sponsor_party": {
-                    "type": "object",
                     "$ref": "#/definitions/model.SponsorParty"
                 }
             }
@@ -317,14 +774,128 @@ var doc = `{
                 }
             }
         },
+        "model.CreateWebhookRequest": {
+            "type": "object",
+            "required": [
+                "organisation_id",
+                "url"
+            ],
+            "properties": {
+                "event_types": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "organisation_id": {
+                    "type": "string"
+                },
+                "secret": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.Delivery": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "delivered_at": {
+                    "type": "string"
+                },
+                "event_id": {
+                    "type": "string"
+                },
+                "event_type": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_status_code": {
+                    "type": "integer"
+                },
+                "next_attempt": {
+                    "type": "string"
+                },
+                "organisation_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "webhook_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.DeliveryResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Delivery"
+                    }
+                }
+            }
+        },
         "model.ErrorResponse": {
             "type": "object",
             "properties": {
-                "code": {
+                "Code": {
                     "type": "integer"
                 },
                 "message": {
                     "type": "string"
+                },
+                "trace_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.Event": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "occurred_at": {
+                    "type": "string"
+                },
+                "payment": {
+                    "$ref": "#/definitions/model.Payment"
+                },
+                "payment_id": {
+                    "type": "string"
+                },
+                "previous_status": {
+                    "description": "PreviousStatus the status before a PaymentStatusChanged event",
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.EventResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Event"
+                    }
                 }
             }
         },
@@ -345,13 +916,25 @@ var doc = `{
                 }
             }
         },
+        "model.Links": {
+            "type": "object",
+            "properties": {
+                "Self": {
+                    "type": "string"
+                },
+                "next": {
+                    "description": "Next the link of the next page of a paginated list, empty on the last page",
+                    "type": "string"
+                }
+            }
+        },
         "model.Party": {
             "type": "object",
             "properties": {
-                "accountName": {
+                "AccountName": {
                     "type": "string"
                 },
-                "accountNumber": {
+                "AccountNumber": {
                     "type": "string"
                 },
                 "account_number_code": {
@@ -380,29 +963,25 @@ var doc = `{
         "model.Payment": {
             "type": "object",
             "properties": {
+                "BeneficiaryParty": {
+                    "$ref": "#/definitions/model.Party"
+                },
                 "amount": {
                     "type": "number"
                 },
-                "beneficiaryParty": {
-                    "type": "object",
-                    "$ref": "#/definitions/model.Party"
-                },
                 "charges_information": {
-                    "type": "object",
                     "$ref": "#/definitions/model.ChargesInformation"
                 },
                 "currency": {
                     "type": "string"
                 },
                 "debtor_party": {
-                    "type": "object",
                     "$ref": "#/definitions/model.Party"
                 },
                 "end_to_end_reference": {
                     "type": "string"
                 },
                 "fx": {
-                    "type": "object",
                     "$ref": "#/definitions/model.ForeignExchange"
                 },
                 "id": {
@@ -439,9 +1018,11 @@ var doc = `{
                     "type": "string"
                 },
                 "sponsor_party": {
-                    "type": "object",
                     "$ref": "#/definitions/model.SponsorParty"
                 },
+                "status": {
+                    "type": "string"
+                },
                 "type": {
                     "type": "string"
                 },
@@ -459,8 +1040,8 @@ var doc = `{
                         "$ref": "#/definitions/model.Payment"
                     }
                 },
-                "self": {
-                    "type": "string"
+                "links": {
+                    "$ref": "#/definitions/model.Links"
                 }
             }
         },
@@ -477,24 +1058,61 @@ var doc = `{
                     "type": "string"
                 }
             }
+        },
+        "model.Webhook": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "event_types": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "organisation_id": {
+                    "type": "string"
+                },
+                "secret": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.WebhookResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Webhook"
+                    }
+                }
+            }
         }
     }
 }`
 
-type swaggerInfo struct {
-	Version     string
-	Host        string
-	BasePath    string
-	Title       string
-	Description string
-}
-
 // SwaggerInfo holds exported Swagger Info so clients can modify it
-type s struct{}
-
-func (s *s) ReadDoc() string {
-	return doc
+var SwaggerInfo = &swag.Spec{
+	Version:          "1.0",
+	Host:             "",
+	BasePath:         "/",
+	Schemes:          []string{},
+	Title:            "Payment Service API",
+	Description:      "",
+	InfoInstanceName: "swagger",
+	SwaggerTemplate:  docTemplate,
+	LeftDelim:        "{{",
+	RightDelim:       "}}",
 }
+
 func init() {
-	swag.Register(swag.Name, &s{})
+	swag.Register(SwaggerInfo.InstanceName(), SwaggerInfo)
 }
@@ -0,0 +1,52 @@
+package event
+
+import (
+	"time"
+
+	"payment-service/model"
+)
+
+// CloudEvents attributes of the payment events
+const (
+	// CloudEventsSpecVersion the version of the CloudEvents specification the events follow
+	CloudEventsSpecVersion = "1.0"
+
+	// CloudEventsContentType the content type of an event in the structured content mode
+	CloudEventsContentType = "application/cloudevents+json"
+
+	// CloudEventsTypePrefix prefixes the payment event types, e.g. tech.form3.payment.PaymentCreated
+	CloudEventsTypePrefix = "tech.form3.payment."
+
+	// DefaultSource the source of the events published by the service
+	DefaultSource = "/payment-service"
+)
+
+// CloudEvent a payment event in the CloudEvents JSON format, its data being the payment as returned by the API
+type CloudEvent struct {
+	SpecVersion     string        `json:"specversion"`
+	ID              string        `json:"id"`
+	Source          string        `json:"source"`
+	Type            string        `json:"type"`
+	Subject         string        `json:"subject"`
+	Time            time.Time     `json:"time"`
+	DataContentType string        `json:"datacontenttype"`
+	Data            model.Payment `json:"data"`
+
+	// PreviousStatus extension attribute of the status change events
+	PreviousStatus string `json:"previousstatus,omitempty"`
+}
+
+// NewCloudEvent converts a payment event into a CloudEvent from the given source, the payment ID being its subject
+func NewCloudEvent(source string, event model.Event) CloudEvent {
+	return CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		ID:              event.ID,
+		Source:          source,
+		Type:            CloudEventsTypePrefix + event.Type,
+		Subject:         event.PaymentID.String(),
+		Time:            event.OccurredAt,
+		DataContentType: "application/json",
+		Data:            event.Payment,
+		PreviousStatus:  event.PreviousStatus,
+	}
+}
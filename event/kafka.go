@@ -0,0 +1,93 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"payment-service/model"
+)
+
+// DefaultTopic the topic of the event types without a topic of their own
+const DefaultTopic = "payments"
+
+// KafkaPublisher publishes the payment events to Kafka, or any broker speaking the Kafka protocol such as Redpanda.
+//
+// Each event is a message in the CloudEvents structured content mode, keyed by the payment ID. Partitioning on the key
+// keeps the events of a payment in one partition, so that consumers read them in the order they were published.
+type KafkaPublisher struct {
+	Writer *kafka.Writer
+
+	// Topics the topic of each event type, the other types being published to DefaultTopic
+	Topics       map[string]string
+	DefaultTopic string
+
+	// Source the CloudEvents source of the events
+	Source string
+}
+
+// NewKafkaPublisher creates a KafkaPublisher writing to the given brokers. Messages are acknowledged by all the in
+// sync replicas and are not retried by the writer, the Relay retrying the failed events itself.
+func NewKafkaPublisher(brokers []string, topics map[string]string) *KafkaPublisher {
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Balancer:     kafka.Murmur2Balancer{},
+		RequiredAcks: kafka.RequireAll,
+		MaxAttempts:  1,
+		BatchTimeout: 10 * time.Millisecond,
+	}
+	return &KafkaPublisher{Writer: writer, Topics: topics, DefaultTopic: DefaultTopic, Source: DefaultSource}
+}
+
+// Publish writes the event to the topic of its type and waits for the brokers to acknowledge it
+func (p *KafkaPublisher) Publish(ctx context.Context, event model.Event) error {
+	value, err := json.Marshal(NewCloudEvent(p.Source, event))
+	if err != nil {
+		return err
+	}
+	message := kafka.Message{
+		Topic:   p.Topic(event.Type),
+		Key:     []byte(event.PaymentID.String()),
+		Value:   value,
+		Headers: []kafka.Header{{Key: "content-type", Value: []byte(CloudEventsContentType)}},
+		Time:    event.OccurredAt,
+	}
+	return p.Writer.WriteMessages(ctx, message)
+}
+
+// Topic returns the topic the events of the given type are published to
+func (p *KafkaPublisher) Topic(eventType string) string {
+	if topic, ok := p.Topics[eventType]; ok {
+		return topic
+	}
+	return p.DefaultTopic
+}
+
+// Close flushes and closes the writer
+func (p *KafkaPublisher) Close() error {
+	return p.Writer.Close()
+}
+
+// ParseTopics parses a comma separated list of event type to topic assignments, e.g.
+// PaymentCreated=payments.created,PaymentDeleted=payments.deleted
+func ParseTopics(s string) (map[string]string, error) {
+	topics := make(map[string]string)
+	for _, assignment := range strings.Split(s, ",") {
+		if strings.TrimSpace(assignment) == "" {
+			continue
+		}
+		parts := strings.SplitN(assignment, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid topic assignment %q, expected EventType=topic", assignment)
+		}
+		eventType := strings.TrimSpace(parts[0])
+		if !model.IsEventType(eventType) {
+			return nil, fmt.Errorf("unknown event type %q", eventType)
+		}
+		topics[eventType] = strings.TrimSpace(parts[1])
+	}
+	return topics, nil
+}
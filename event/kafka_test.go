@@ -0,0 +1,178 @@
+package event_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"payment-service/event"
+	"payment-service/model"
+	"payment-service/test"
+	"payment-service/test/kafkatest"
+)
+
+// cluster the broker the publisher writes to, returning the messages of a topic
+type cluster struct {
+	publisher func(topics map[string]string) *event.KafkaPublisher
+	topic     func(t *testing.T, name string) string
+	messages  func(t *testing.T, topic string) []kafka.Message
+}
+
+func TestKafkaPublisher_StandIn(t *testing.T) {
+	broker := kafkatest.NewBroker(4)
+	testKafkaPublisher(t, cluster{
+		publisher: func(topics map[string]string) *event.KafkaPublisher {
+			publisher := event.NewKafkaPublisher(nil, topics)
+			publisher.Writer.Addr = broker.Addr()
+			publisher.Writer.Transport = broker
+			return publisher
+		},
+		topic: func(t *testing.T, name string) string {
+			return fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+		},
+		messages: func(t *testing.T, topic string) []kafka.Message {
+			return broker.Messages(topic)
+		},
+	})
+}
+
+func TestKafkaPublisher_Redpanda(t *testing.T) {
+	c, err := kafkatest.Start()
+	if err == kafkatest.ErrUnavailable {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("Failed to reach the test cluster %v %v", test.BallotX, err)
+	}
+	testKafkaPublisher(t, cluster{
+		publisher: func(topics map[string]string) *event.KafkaPublisher {
+			return event.NewKafkaPublisher(c.Brokers, topics)
+		},
+		topic: func(t *testing.T, name string) string {
+			topic := fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+			if err := c.CreateTopic(topic, 4); err != nil {
+				t.Fatalf("Failed to create the topic %s %v %v", topic, test.BallotX, err)
+			}
+			return topic
+		},
+		messages: func(t *testing.T, topic string) []kafka.Message {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			messages, err := c.Messages(ctx, topic)
+			if err != nil {
+				t.Fatalf("Failed to read the topic %s %v %v", topic, test.BallotX, err)
+			}
+			return messages
+		},
+	})
+}
+
+// Helper function running the publisher tests against a cluster
+func testKafkaPublisher(t *testing.T, c cluster) {
+	t.Logf("Given a publisher with a topic for the status changes")
+	{
+		payments, statuses := c.topic(t, "payments"), c.topic(t, "payment-statuses")
+		publisher := c.publisher(map[string]string{model.PaymentStatusChanged: statuses})
+		publisher.DefaultTopic = payments
+		defer publisher.Close()
+
+		first := model.Payment{Type: "Payment", ID: model.NewID(), OrganisationId: "743d5b63-8e6f-432e-a8fa-c5d8d2ee5fcb"}
+		first.Reference = "Payment for Em's piano lessons"
+		second := model.Payment{Type: "Payment", ID: model.NewID(), OrganisationId: first.OrganisationId}
+		accepted := first
+		accepted.Status = "accepted"
+		events := append([]model.Event{model.NewEvent(model.PaymentCreated, first), model.NewEvent(model.PaymentCreated, second)},
+			model.UpdateEvents(first, accepted)...)
+		events = append(events, model.NewEvent(model.PaymentDeleted, accepted))
+
+		t.Logf("\tWhen publishing the events of two payments")
+		{
+			for _, e := range events {
+				err := publisher.Publish(context.Background(), e)
+				check(t, err == nil, "The "+e.Type+" event should have been published", err)
+			}
+
+			messages := c.messages(t, payments)
+			check(t, len(messages) == 4, "The events without a topic of their own should have gone to the default topic", len(messages))
+			changes := c.messages(t, statuses)
+			check(t, len(changes) == 1, "The status change should have gone to its own topic", len(changes))
+
+			var types []string
+			partition := -1
+			for _, m := range messages {
+				if string(m.Key) != first.ID.String() {
+					continue
+				}
+				var ce event.CloudEvent
+				json.Unmarshal(m.Value, &ce)
+				types = append(types, ce.Type)
+				check(t, partition < 0 || m.Partition == partition, "The events of a payment should share a partition", m.Partition)
+				partition = m.Partition
+			}
+			expected := []string{"tech.form3.payment.PaymentCreated", "tech.form3.payment.PaymentUpdated", "tech.form3.payment.PaymentDeleted"}
+			check(t, strings.Join(types, ",") == strings.Join(expected, ","), "The events should be keyed by payment ID, in order", types)
+		}
+
+		t.Logf("\tWhen reading the status change")
+		{
+			changes := c.messages(t, statuses)
+			if len(changes) != 1 {
+				t.Fatalf("\t\tThe status change should have been published %v", test.BallotX)
+			}
+			var ce event.CloudEvent
+			err := json.Unmarshal(changes[0].Value, &ce)
+			check(t, err == nil, "The message should be JSON", err)
+			check(t, ce.SpecVersion == "1.0" && ce.ID == events[3].ID && ce.Source == event.DefaultSource,
+				"The message should be a CloudEvent", ce)
+			check(t, ce.Type == "tech.form3.payment.PaymentStatusChanged" && ce.Subject == first.ID.String(),
+				"The type and subject should identify the event and payment", ce)
+			check(t, ce.Data.ID == first.ID && ce.Data.Status == "accepted" && ce.Data.Reference == first.Reference,
+				"The data should be the payment", ce.Data)
+			check(t, ce.PreviousStatus == "" && ce.Time.Equal(events[3].OccurredAt), "The extension and time should have been set", ce)
+			check(t, len(changes[0].Headers) == 1 && string(changes[0].Headers[0].Value) == event.CloudEventsContentType,
+				"The content type header should be the CloudEvents structured mode", changes[0].Headers)
+		}
+	}
+}
+
+func TestKafkaPublisher_ShouldFailWhenTheBrokerFails(t *testing.T) {
+	t.Logf("Given a broker failing the produce requests")
+	{
+		broker := kafkatest.NewBroker(1)
+		broker.Fail(errors.New("not enough replicas"))
+		publisher := event.NewKafkaPublisher(nil, nil)
+		publisher.Writer.Addr = broker.Addr()
+		publisher.Writer.Transport = broker
+		defer publisher.Close()
+
+		t.Logf("\tWhen publishing an event")
+		{
+			payment := model.Payment{ID: model.NewID()}
+			err := publisher.Publish(context.Background(), model.NewEvent(model.PaymentCreated, payment))
+			check(t, err != nil, "The publish should fail, for the relay to retry it", err)
+
+			broker.Fail(nil)
+			err = publisher.Publish(context.Background(), model.NewEvent(model.PaymentCreated, payment))
+			check(t, err == nil && len(broker.Messages(event.DefaultTopic)) == 1, "The publish should succeed once the broker recovers", err)
+		}
+	}
+}
+
+func TestParseTopics(t *testing.T) {
+	t.Logf("Given topic assignments")
+	{
+		topics, err := event.ParseTopics("PaymentCreated=payments.created, PaymentDeleted = payments.deleted,")
+		check(t, err == nil && len(topics) == 2 && topics[model.PaymentCreated] == "payments.created" &&
+			topics[model.PaymentDeleted] == "payments.deleted", "The assignments should have been parsed", topics)
+
+		_, err = event.ParseTopics("PaymentCreated")
+		check(t, err != nil, "An assignment without topic should be rejected", err)
+		_, err = event.ParseTopics("PaymentRefunded=refunds")
+		check(t, err != nil, "An unknown event type should be rejected", err)
+	}
+}
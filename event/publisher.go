@@ -0,0 +1,48 @@
+// Package event publishes the payment domain events recorded in the repository outbox.
+package event
+
+import (
+	"context"
+	"log/slog"
+
+	"payment-service/model"
+)
+
+// Publisher delivers payment domain events to the downstream consumers
+type Publisher interface {
+
+	// Publish delivers the event, an error meaning it must be published again
+	Publish(ctx context.Context, event model.Event) error
+}
+
+// PublisherFunc adapts a function to the Publisher interface
+type PublisherFunc func(ctx context.Context, event model.Event) error
+
+// Publish calls f(ctx, event)
+func (f PublisherFunc) Publish(ctx context.Context, event model.Event) error {
+	return f(ctx, event)
+}
+
+// LogPublisher writes the events to the info log, the default publisher when no broker is configured
+type LogPublisher struct{}
+
+// Publish logs the event, the personal data of its payment being masked by the logger
+func (LogPublisher) Publish(ctx context.Context, event model.Event) error {
+	slog.InfoContext(ctx, "Payment event", "event", event)
+	return nil
+}
+
+// Publishers publishes each event to all the publishers, in order. Every publisher is called even when one fails,
+// the first error being returned for the relay to publish the event again to all of them.
+type Publishers []Publisher
+
+// Publish publishes the event to every publisher
+func (publishers Publishers) Publish(ctx context.Context, event model.Event) error {
+	var first error
+	for _, p := range publishers {
+		if err := p.Publish(ctx, event); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,146 @@
+package event
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"payment-service/model"
+	"payment-service/repository"
+)
+
+// Relay defaults
+const (
+	DefaultInterval   = time.Second
+	DefaultBatchSize  = 100
+	DefaultMinBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff = time.Minute
+)
+
+// Relay polls the outbox of a db and publishes the pending events, removing them from the outbox once published.
+//
+// Delivery is at least once: an event is acknowledged after it has been published, so that it is published again
+// when the acknowledgement fails or the service stops in between. Events of the same payment are published in the
+// order they were recorded: when publishing one fails, the later events of that payment are held back until it is
+// retried, with an exponential backoff, and succeeds. Events of the other payments are not delayed: the held back
+// payments are left out of the outbox queries, so that their events do not take the place of the others in a batch.
+type Relay struct {
+	Outbox    repository.Outbox
+	Publisher Publisher
+	DB        string
+
+	// Interval between two polls of the outbox
+	Interval time.Duration
+
+	// BatchSize the maximum number of events published, or failing to be, per poll
+	BatchSize int
+
+	// MinBackoff and MaxBackoff bound the delay before publishing again the events of a payment
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// payments waiting for their backoff to elapse, only accessed by the goroutine flushing the outbox
+	retries map[model.ID]retry
+}
+
+// a payment whose events failed to be published
+type retry struct {
+	attempts int
+	next     time.Time
+}
+
+// NewRelay creates a Relay publishing the events of the db with the default settings
+func NewRelay(outbox repository.Outbox, publisher Publisher, db string) *Relay {
+	return &Relay{
+		Outbox:     outbox,
+		Publisher:  publisher,
+		DB:         db,
+		Interval:   DefaultInterval,
+		BatchSize:  DefaultBatchSize,
+		MinBackoff: DefaultMinBackoff,
+		MaxBackoff: DefaultMaxBackoff,
+	}
+}
+
+// Run flushes the outbox every Interval until the context is done
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.Flush(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Failed to relay the payment events", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush publishes a batch of pending events, returning how many were published. Failing to publish an event is not
+// an error, the event staying in the outbox until it is retried.
+//
+// The outbox is queried again, leaving out the payments held back so far, until BatchSize events have been attempted
+// or none is pending. Every query attempts at least one event, publishing it or holding back its payment.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	if r.retries == nil {
+		r.retries = make(map[model.ID]retry)
+	}
+
+	now := time.Now()
+	var held []model.ID
+	for id, retry := range r.retries {
+		if now.Before(retry.next) {
+			held = append(held, id)
+		}
+	}
+	published, attempted := 0, 0
+	for attempted < r.BatchSize {
+		events, err := r.Outbox.Pending(ctx, r.DB, r.BatchSize-attempted, held...)
+		if err != nil {
+			return published, err
+		}
+		if len(events) == 0 {
+			break
+		}
+
+		failed := make(map[model.ID]bool)
+		for _, event := range events {
+			if failed[event.PaymentID] {
+				continue
+			}
+			attempted++
+			if err := r.Publisher.Publish(ctx, event); err != nil {
+				slog.WarnContext(ctx, "Failed to publish the payment event", "type", event.Type, "event_id", event.ID,
+					"payment_id", event.PaymentID.String(), "error", err)
+				failed[event.PaymentID] = true
+				held = append(held, event.PaymentID)
+				r.backoff(event.PaymentID, now)
+				continue
+			}
+			delete(r.retries, event.PaymentID)
+			if err := r.Outbox.Ack(ctx, r.DB, event.ID); err != nil {
+				return published, err
+			}
+			published++
+		}
+		if len(failed) == 0 {
+			// either the batch is complete or the outbox is drained
+			break
+		}
+	}
+	return published, nil
+}
+
+// Helper function to delay the next attempt to publish the events of a payment, doubling the delay on every failure
+func (r *Relay) backoff(id model.ID, now time.Time) {
+	retry := r.retries[id]
+	delay := r.MaxBackoff
+	if retry.attempts < 32 && r.MinBackoff<<uint(retry.attempts) < delay {
+		delay = r.MinBackoff << uint(retry.attempts)
+	}
+	retry.attempts++
+	retry.next = now.Add(delay)
+	r.retries[id] = retry
+}
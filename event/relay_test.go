@@ -0,0 +1,246 @@
+package event_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"payment-service/event"
+	"payment-service/model"
+	"payment-service/repository"
+	"payment-service/test"
+)
+
+const db = "relay"
+
+// recorder a publisher recording the published events, failing the payments listed in fail
+type recorder struct {
+	mu        sync.Mutex
+	published []model.Event
+	fail      map[model.ID]bool
+}
+
+func (r *recorder) Publish(ctx context.Context, e model.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail[e.PaymentID] {
+		return errors.New("broker unavailable")
+	}
+	r.published = append(r.published, e)
+	return nil
+}
+
+func (r *recorder) types(id model.ID) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var types []string
+	for _, e := range r.published {
+		if e.PaymentID == id {
+			types = append(types, e.Type)
+		}
+	}
+	return types
+}
+
+func TestRelay_ShouldPublishAndAcknowledgeThePendingEvents(t *testing.T) {
+	t.Logf("Given a created then updated payment")
+	{
+		repo := repository.NewMemoryRepository()
+		payment := insert(t, repo)
+		update(t, repo, payment, "accepted")
+
+		t.Logf("\tWhen flushing the outbox")
+		{
+			publisher := &recorder{}
+			relay := event.NewRelay(repo, publisher, db)
+			n, err := relay.Flush(context.Background())
+			check(t, err == nil && n == 3, "The three events should have been published", n)
+			check(t, equal(publisher.types(payment.ID), model.PaymentCreated, model.PaymentUpdated, model.PaymentStatusChanged),
+				"The events should have been published in order", publisher.types(payment.ID))
+
+			pending, _ := repo.Pending(context.Background(), db, 10)
+			check(t, len(pending) == 0, "The published events should have been acknowledged", pending)
+		}
+	}
+}
+
+func TestRelay_ShouldHoldBackTheEventsOfAFailingPayment(t *testing.T) {
+	t.Logf("Given two payments, the events of the first one failing to be published")
+	{
+		repo := repository.NewMemoryRepository()
+		failing := insert(t, repo)
+		other := insert(t, repo)
+		update(t, repo, failing, "accepted")
+
+		publisher := &recorder{fail: map[model.ID]bool{failing.ID: true}}
+		relay := event.NewRelay(repo, publisher, db)
+		relay.MinBackoff = 50 * time.Millisecond
+
+		t.Logf("\tWhen flushing the outbox")
+		{
+			n, err := relay.Flush(context.Background())
+			check(t, err == nil && n == 1, "Only the event of the other payment should have been published", n)
+			check(t, equal(publisher.types(other.ID), model.PaymentCreated), "The other payment should not be delayed", publisher.types(other.ID))
+			pending, _ := repo.Pending(context.Background(), db, 10)
+			check(t, len(pending) == 3, "The events of the failing payment should stay in the outbox", pending)
+		}
+
+		t.Logf("\tWhen the publisher recovers before the backoff elapsed")
+		{
+			publisher.mu.Lock()
+			publisher.fail = nil
+			publisher.mu.Unlock()
+			n, err := relay.Flush(context.Background())
+			check(t, err == nil && n == 0, "Nothing should have been published during the backoff", n)
+		}
+
+		t.Logf("\tWhen flushing after the backoff")
+		{
+			time.Sleep(60 * time.Millisecond)
+			n, err := relay.Flush(context.Background())
+			check(t, err == nil && n == 3, "The held back events should have been published", n)
+			check(t, equal(publisher.types(failing.ID), model.PaymentCreated, model.PaymentUpdated, model.PaymentStatusChanged),
+				"The events of the payment should have been published in order", publisher.types(failing.ID))
+		}
+	}
+}
+
+func TestRelay_ShouldNotLetAFailingPaymentFillTheBatch(t *testing.T) {
+	t.Logf("Given a failing payment with more events than a batch, recorded before those of another payment")
+	{
+		repo := repository.NewMemoryRepository()
+		failing := insert(t, repo)
+		update(t, repo, failing, "accepted")
+		update(t, repo, failing, "settled")
+		other := insert(t, repo)
+
+		publisher := &recorder{fail: map[model.ID]bool{failing.ID: true}}
+		relay := event.NewRelay(repo, publisher, db)
+		relay.BatchSize = 2
+
+		t.Logf("\tWhen flushing the outbox")
+		{
+			n, err := relay.Flush(context.Background())
+			check(t, err == nil && n == 1, "The event of the other payment should have been published", n)
+			check(t, equal(publisher.types(other.ID), model.PaymentCreated), "The other payment should not be delayed", publisher.types(other.ID))
+		}
+
+		t.Logf("\tWhen flushing again during the backoff")
+		{
+			later := insert(t, repo)
+			n, err := relay.Flush(context.Background())
+			check(t, err == nil && n == 1, "The event of the payment inserted since should have been published", n)
+			check(t, equal(publisher.types(later.ID), model.PaymentCreated), "The held back events should not fill the batch", publisher.types(later.ID))
+		}
+	}
+}
+
+func TestRelay_ShouldPublishAgainWhenTheAcknowledgementFails(t *testing.T) {
+	t.Logf("Given an outbox failing to acknowledge the events")
+	{
+		repo := repository.NewMemoryRepository()
+		payment := insert(t, repo)
+		outbox := &failingAck{MemoryRepository: repo}
+		publisher := &recorder{}
+		relay := event.NewRelay(outbox, publisher, db)
+
+		t.Logf("\tWhen flushing the outbox twice")
+		{
+			_, err := relay.Flush(context.Background())
+			check(t, err != nil, "The first flush should fail", err)
+			outbox.healthy = true
+			n, err := relay.Flush(context.Background())
+			check(t, err == nil && n == 1, "The second flush should have succeeded", err)
+			check(t, equal(publisher.types(payment.ID), model.PaymentCreated, model.PaymentCreated),
+				"The event should have been published at least once", publisher.types(payment.ID))
+		}
+	}
+}
+
+func TestRelay_ShouldRunUntilTheContextIsDone(t *testing.T) {
+	t.Logf("Given a running relay")
+	{
+		repo := repository.NewMemoryRepository()
+		publisher := &recorder{}
+		relay := event.NewRelay(repo, publisher, db)
+		relay.Interval = 10 * time.Millisecond
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			relay.Run(ctx)
+			close(done)
+		}()
+
+		t.Logf("\tWhen a payment is inserted then the context cancelled")
+		{
+			payment := insert(t, repo)
+			deadline := time.Now().Add(time.Second)
+			for len(publisher.types(payment.ID)) == 0 && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+			}
+			check(t, len(publisher.types(payment.ID)) == 1, "The event should have been published", publisher.types(payment.ID))
+
+			cancel()
+			select {
+			case <-done:
+				check(t, true, "The relay should have stopped", nil)
+			case <-time.After(time.Second):
+				check(t, false, "The relay should have stopped", nil)
+			}
+		}
+	}
+}
+
+// failingAck an outbox failing the acknowledgements until healthy
+type failingAck struct {
+	*repository.MemoryRepository
+	healthy bool
+}
+
+func (f *failingAck) Ack(ctx context.Context, db string, ids ...string) error {
+	if !f.healthy {
+		return errors.New("connection reset")
+	}
+	return f.MemoryRepository.Ack(ctx, db, ids...)
+}
+
+// Helper function to insert a new payment
+func insert(t *testing.T, repo repository.Repository) model.Payment {
+	payment := model.Payment{Type: "Payment", ID: model.NewID(), OrganisationId: "743d5b63-8e6f-432e-a8fa-c5d8d2ee5fcb"}
+	if err := repo.Insert(context.Background(), db, "payments", payment); err != nil {
+		t.Fatalf("\t\tThe insert should have been successful %v %v", test.BallotX, err)
+	}
+	return payment
+}
+
+// Helper function to update the status of a payment
+func update(t *testing.T, repo repository.Repository, payment model.Payment, status string) {
+	payment.Status = status
+	if err := repo.Update(context.Background(), db, "payments", payment.ID, payment); err != nil {
+		t.Fatalf("\t\tThe update should have been successful %v %v", test.BallotX, err)
+	}
+}
+
+// Helper function to compare event types
+func equal(types []string, expected ...string) bool {
+	if len(types) != len(expected) {
+		return false
+	}
+	for i := range types {
+		if types[i] != expected[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Helper function to log the outcome of an expectation
+func check(t *testing.T, ok bool, expectation string, got interface{}) {
+	if ok {
+		t.Logf("\t\t%s %v", expectation, test.CheckMark)
+	} else {
+		t.Errorf("\t\t%s %v %v", expectation, test.BallotX, got)
+	}
+}
@@ -0,0 +1,160 @@
+package event
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"payment-service/model"
+)
+
+const (
+	// DefaultStreamSize the number of events kept by a Stream for the subscribers resuming after a disconnection
+	DefaultStreamSize = 1000
+
+	// DefaultStreamBuffer the number of events a subscriber may lag behind before being disconnected
+	DefaultStreamBuffer = 64
+)
+
+// Entry an event of the stream log, along its position in the log
+type Entry struct {
+	Seq   uint64
+	Event model.Event
+}
+
+// Subscription receives the events published to a Stream after it subscribed. C is closed when the subscriber is
+// disconnected, either by Cancel or for falling behind, the latter being told by Lagged.
+type Subscription struct {
+	C <-chan Entry
+
+	c      chan Entry
+	filter func(model.Event) bool
+	lagged bool
+	stream *Stream
+}
+
+// Stream a Publisher fanning the events out to in process subscribers, keeping the last events in a bounded log for
+// the subscribers to resume from. Publishing never blocks: a subscriber whose buffer is full is disconnected and
+// expected to subscribe again from the last event it received.
+type Stream struct {
+	Size   int
+	Buffer int
+
+	mu          sync.Mutex
+	log         []Entry
+	next        uint64
+	subscribers map[*Subscription]bool
+	closed      bool
+}
+
+// NewStream creates a Stream keeping the last size events, each subscriber buffering up to buffer events
+func NewStream(size, buffer int) *Stream {
+	// sequences start from the clock for the positions to keep increasing across restarts
+	return &Stream{Size: size, Buffer: buffer, next: uint64(time.Now().UnixNano()), subscribers: make(map[*Subscription]bool)}
+}
+
+// Publish appends the event to the log and sends it to the subscribers it matches. An event already in the log, e.g.
+// published again by the relay, is ignored.
+func (s *Stream) Publish(ctx context.Context, event model.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.log {
+		if e.Event.ID == event.ID {
+			return nil
+		}
+	}
+
+	entry := Entry{Seq: s.next, Event: event}
+	s.next++
+	s.log = append(s.log, entry)
+	if len(s.log) > s.Size {
+		s.log = append(s.log[:0], s.log[len(s.log)-s.Size:]...)
+	}
+
+	for sub := range s.subscribers {
+		if !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.c <- entry:
+		default:
+			sub.lagged = true
+			s.remove(sub)
+		}
+	}
+	return nil
+}
+
+// Subscribe returns the logged events after the given position which match the filter, and a subscription to the
+// ones published next. Complete is false when events after the position are no longer in the log, a position of
+// zero meaning no replay at all.
+func (s *Stream) Subscribe(after uint64, filter func(model.Event) bool) (backlog []Entry, complete bool, sub *Subscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	complete = true
+	if after != 0 {
+		complete = (len(s.log) > 0 && s.log[0].Seq <= after+1) || (len(s.log) == 0 && after+1 >= s.next)
+		for _, e := range s.log {
+			if e.Seq > after && filter(e.Event) {
+				backlog = append(backlog, e)
+			}
+		}
+	}
+
+	c := make(chan Entry, s.Buffer)
+	sub = &Subscription{C: c, c: c, filter: filter, stream: s}
+	if s.closed {
+		close(c)
+		return backlog, complete, sub
+	}
+	s.subscribers[sub] = true
+	return backlog, complete, sub
+}
+
+// Events returns the logged events which match the filter, oldest first
+func (s *Stream) Events(filter func(model.Event) bool) []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []Entry
+	for _, e := range s.log {
+		if filter(e.Event) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Close disconnects all the subscribers, e.g. on shutdown for them to resume from another instance. The subscriptions
+// made afterwards are closed right away, the stream still logging the published events.
+func (s *Stream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for sub := range s.subscribers {
+		s.remove(sub)
+	}
+}
+
+// Cancel disconnects the subscription, closing C
+func (sub *Subscription) Cancel() {
+	sub.stream.mu.Lock()
+	defer sub.stream.mu.Unlock()
+	sub.stream.remove(sub)
+}
+
+// Lagged tells whether the subscription was disconnected for falling behind the published events
+func (sub *Subscription) Lagged() bool {
+	sub.stream.mu.Lock()
+	defer sub.stream.mu.Unlock()
+	return sub.lagged
+}
+
+// Helper function to disconnect a subscriber, the stream being locked
+func (s *Stream) remove(sub *Subscription) {
+	if s.subscribers[sub] {
+		delete(s.subscribers, sub)
+		close(sub.c)
+	}
+}
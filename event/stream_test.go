@@ -0,0 +1,109 @@
+package event_test
+
+import (
+	"context"
+	"testing"
+
+	"payment-service/event"
+	"payment-service/model"
+)
+
+func TestStream_ShouldFanOutTheEventsToTheMatchingSubscribers(t *testing.T) {
+	t.Logf("Given a stream with two subscribers, one of them filtering the events of an organisation")
+	{
+		stream := event.NewStream(10, 10)
+		_, _, all := stream.Subscribe(0, func(model.Event) bool { return true })
+		_, _, org := stream.Subscribe(0, func(e model.Event) bool { return e.Payment.OrganisationId == "org-1" })
+
+		t.Logf("\tWhen publishing the events of two organisations")
+		{
+			first := model.NewEvent(model.PaymentCreated, model.Payment{ID: model.NewID(), OrganisationId: "org-1"})
+			second := model.NewEvent(model.PaymentCreated, model.Payment{ID: model.NewID(), OrganisationId: "org-2"})
+			stream.Publish(context.Background(), first)
+			stream.Publish(context.Background(), second)
+			stream.Publish(context.Background(), first)
+
+			check(t, len(all.C) == 2, "The first subscriber should have received both events once", len(all.C))
+			check(t, len(org.C) == 1, "The second subscriber should only have received the event of its organisation", len(org.C))
+			a, b := <-all.C, <-all.C
+			check(t, a.Event.ID == first.ID && b.Event.ID == second.ID && b.Seq == a.Seq+1,
+				"The events should have been received in order", []event.Entry{a, b})
+		}
+	}
+}
+
+func TestStream_ShouldResumeFromTheLog(t *testing.T) {
+	t.Logf("Given a stream keeping the last three events")
+	{
+		stream := event.NewStream(3, 10)
+		_, _, sub := stream.Subscribe(0, func(model.Event) bool { return true })
+		for i := 0; i < 5; i++ {
+			stream.Publish(context.Background(), model.NewEvent(model.PaymentCreated, model.Payment{ID: model.NewID()}))
+		}
+		var seqs []uint64
+		for i := 0; i < 5; i++ {
+			seqs = append(seqs, (<-sub.C).Seq)
+		}
+
+		t.Logf("\tWhen resuming after an event still in the log")
+		{
+			backlog, complete, _ := stream.Subscribe(seqs[2], func(model.Event) bool { return true })
+			check(t, complete && len(backlog) == 2 && backlog[0].Seq == seqs[3], "The later events should be replayed", backlog)
+
+			backlog, complete, _ = stream.Subscribe(seqs[4], func(model.Event) bool { return true })
+			check(t, complete && len(backlog) == 0, "Nothing should be replayed after the last event", backlog)
+		}
+
+		t.Logf("\tWhen resuming after an event no longer in the log")
+		{
+			backlog, complete, _ := stream.Subscribe(seqs[0], func(model.Event) bool { return true })
+			check(t, !complete && len(backlog) == 3, "The retained events should be replayed and the gap reported", backlog)
+		}
+	}
+}
+
+func TestStream_ShouldDisconnectSlowSubscribersWithoutBlocking(t *testing.T) {
+	t.Logf("Given a subscriber which does not consume its events")
+	{
+		stream := event.NewStream(10, 2)
+		_, _, slow := stream.Subscribe(0, func(model.Event) bool { return true })
+		_, _, cancelled := stream.Subscribe(0, func(model.Event) bool { return true })
+		cancelled.Cancel()
+
+		t.Logf("\tWhen publishing more events than it buffers")
+		{
+			for i := 0; i < 4; i++ {
+				err := stream.Publish(context.Background(), model.NewEvent(model.PaymentCreated, model.Payment{ID: model.NewID()}))
+				check(t, err == nil, "Publishing should not block nor fail", err)
+			}
+
+			n := 0
+			for range slow.C {
+				n++
+			}
+			check(t, n == 2 && slow.Lagged(), "The subscriber should have been disconnected once its buffer was full", n)
+			_, ok := <-cancelled.C
+			check(t, !ok && !cancelled.Lagged(), "The cancelled subscription should have been closed", ok)
+		}
+	}
+}
+
+func TestStream_ShouldDisconnectTheSubscribersOnClose(t *testing.T) {
+	t.Logf("Given a stream with a subscriber")
+	{
+		stream := event.NewStream(10, 2)
+		_, _, sub := stream.Subscribe(0, func(model.Event) bool { return true })
+
+		t.Logf("\tWhen closing the stream")
+		{
+			stream.Close()
+			_, ok := <-sub.C
+			check(t, !ok && !sub.Lagged(), "The subscriber should have been disconnected", ok)
+
+			err := stream.Publish(context.Background(), model.NewEvent(model.PaymentCreated, model.Payment{ID: model.NewID()}))
+			backlog, _, late := stream.Subscribe(1, func(model.Event) bool { return true })
+			_, ok = <-late.C
+			check(t, err == nil && len(backlog) == 1 && !ok, "The events should still be logged, new subscriptions being closed", backlog)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	// MinRetryDelay and MaxRetryDelay bound the exponential delay between the attempts of Retry
+	MinRetryDelay = 500 * time.Millisecond
+	MaxRetryDelay = 10 * time.Second
+)
+
+// HTTP checks an upstream service answers at the given URL, any response other than a server error meaning it is up
+func HTTP(client *http.Client, url string) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("%s answered %s", url, resp.Status)
+		}
+		return nil
+	})
+}
+
+// Retry calls attempt until it succeeds or the context is done, waiting exponentially longer between the attempts.
+// It returns the last error of attempt once the context is done, e.g. for the service to wait for its dependencies
+// on startup rather than failing while they start too.
+func Retry(ctx context.Context, what string, attempt func(ctx context.Context) error) error {
+	delay := MinRetryDelay
+	for {
+		err := attempt(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		slog.WarnContext(ctx, "Dependency unavailable, retrying", "operation", what, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > MaxRetryDelay {
+			delay = MaxRetryDelay
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Package health checks the dependencies of the payment service, the repository and the upstream services, for the
+// readiness of the service to be reported along the state of each of them.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// StatusUp every check passed
+	StatusUp = "up"
+
+	// StatusDegraded only checks of optional dependencies failed, the service keeps serving requests
+	StatusDegraded = "degraded"
+
+	// StatusDown a check of a required dependency failed
+	StatusDown = "down"
+
+	// DefaultTimeout bounds how long a check may take, a check running longer being failed
+	DefaultTimeout = 2 * time.Second
+
+	// DefaultCacheTTL how long the result of a check is reused, for frequent probes not to load the dependencies
+	DefaultCacheTTL = 5 * time.Second
+)
+
+// Checker checks a dependency, returning why it is unavailable
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to a Checker, e.g. the Ping of a repository
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls the function
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Report the readiness of the service along the result of each check
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// Result the last result of a check
+type Result struct {
+	Status    string    `json:"status"`
+	Required  bool      `json:"required"`
+	Error     string    `json:"error,omitempty"`
+	Duration  string    `json:"duration"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Registry runs the registered checks, concurrently and each within its timeout, caching their results
+type Registry struct {
+	Timeout  time.Duration
+	CacheTTL time.Duration
+
+	mu     sync.Mutex
+	checks map[string]*check
+}
+
+// check a registered checker and its cached result, mu serializing the runs for concurrent probes to share one
+type check struct {
+	checker  Checker
+	required bool
+
+	mu     sync.Mutex
+	result Result
+	valid  time.Time
+}
+
+// NewRegistry creates an empty Registry with the given check timeout and cache duration
+func NewRegistry(timeout, cacheTTL time.Duration) *Registry {
+	return &Registry{Timeout: timeout, CacheTTL: cacheTTL, checks: make(map[string]*check)}
+}
+
+// Register adds a check under the given name, replacing the one with the same name. A required check failing makes
+// the service down, an optional one only degraded.
+func (r *Registry) Register(name string, checker Checker, required bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = &check{checker: checker, required: required}
+}
+
+// Check runs the checks whose cached result expired and reports the readiness of the service
+func (r *Registry) Check(ctx context.Context) Report {
+	r.mu.Lock()
+	checks := make(map[string]*check, len(r.checks))
+	for name, c := range r.checks {
+		checks[name] = c
+	}
+	r.mu.Unlock()
+
+	results := make(map[string]Result, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, c := range checks {
+		wg.Add(1)
+		go func(name string, c *check) {
+			defer wg.Done()
+			result := r.run(ctx, c)
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(name, c)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Checks: results}
+	for _, result := range results {
+		if result.Status == StatusUp {
+			continue
+		}
+		if result.Required {
+			report.Status = StatusDown
+		} else if report.Status == StatusUp {
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+// Helper function to run a check, or reuse its result until it expires
+func (r *Registry) run(ctx context.Context, c *check) Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if now.Before(c.valid) {
+		return c.result
+	}
+
+	// the result is shared by every probe until it expires, so the check does not stop with the probe which ran it
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.Timeout)
+	defer cancel()
+	// a check ignoring its context is failed at the deadline all the same, for the probes not to hang
+	done := make(chan error, 1)
+	go func() {
+		done <- c.checker.Check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	c.result = Result{Status: StatusUp, Required: c.required, Duration: time.Since(now).Round(time.Millisecond).String(),
+		CheckedAt: now.UTC()}
+	if err != nil {
+		c.result.Status = StatusDown
+		c.result.Error = err.Error()
+	}
+	c.valid = now.Add(r.CacheTTL)
+	return c.result
+}
@@ -0,0 +1,160 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"payment-service/health"
+	"payment-service/test"
+)
+
+func TestRegistry_ShouldReportTheStatusOfTheChecks(t *testing.T) {
+	t.Logf("Given a registry with a required and an optional check")
+	{
+		var repoErr, fxErr atomic.Value
+		repoErr.Store(errors.New(""))
+		fxErr.Store(errors.New(""))
+		registry := health.NewRegistry(time.Second, 0)
+		registry.Register("repository", failing(&repoErr), true)
+		registry.Register("fx", failing(&fxErr), false)
+
+		t.Logf("\tWhen every check passes")
+		{
+			report := registry.Check(context.Background())
+			check(t, report.Status == health.StatusUp && len(report.Checks) == 2 && report.Checks["fx"].Status == health.StatusUp,
+				"The service should be up", report)
+		}
+
+		t.Logf("\tWhen the optional check fails")
+		{
+			fxErr.Store(errors.New("connection refused"))
+			report := registry.Check(context.Background())
+			check(t, report.Status == health.StatusDegraded && report.Checks["fx"].Error == "connection refused",
+				"The service should be degraded", report)
+		}
+
+		t.Logf("\tWhen the required check fails")
+		{
+			repoErr.Store(errors.New("no reachable servers"))
+			report := registry.Check(context.Background())
+			check(t, report.Status == health.StatusDown && report.Checks["repository"].Status == health.StatusDown &&
+				report.Checks["repository"].Required, "The service should be down", report)
+		}
+	}
+}
+
+func TestRegistry_ShouldTimeOutAndCacheTheChecks(t *testing.T) {
+	t.Logf("Given a check hanging past the timeout")
+	{
+		var calls int32
+		registry := health.NewRegistry(50*time.Millisecond, time.Minute)
+		registry.Register("fx", health.CheckerFunc(func(ctx context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(100 * time.Millisecond)
+			return nil
+		}), true)
+
+		t.Logf("\tWhen checking twice within the cache duration")
+		{
+			report := registry.Check(context.Background())
+			check(t, report.Status == health.StatusDown && report.Checks["fx"].Error == context.DeadlineExceeded.Error(),
+				"The check ignoring its deadline should have failed", report)
+			again := registry.Check(context.Background())
+			check(t, atomic.LoadInt32(&calls) == 1 && again.Checks["fx"].CheckedAt == report.Checks["fx"].CheckedAt,
+				"The result should have been reused", calls)
+		}
+	}
+}
+
+func TestRegistry_ShouldNotFailTheChecksOfACancelledProbe(t *testing.T) {
+	t.Logf("Given a check of a registry caching the results")
+	{
+		registry := health.NewRegistry(time.Second, time.Minute)
+		registry.Register("repository", health.CheckerFunc(func(ctx context.Context) error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(10 * time.Millisecond):
+				return nil
+			}
+		}), true)
+
+		t.Logf("\tWhen the probe running the check is cancelled")
+		{
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			report := registry.Check(ctx)
+			check(t, report.Status == health.StatusUp, "The check should have run to completion", report)
+			again := registry.Check(context.Background())
+			check(t, again.Status == health.StatusUp, "The next probes should not get the cancellation", again)
+		}
+	}
+}
+
+func TestHTTP_ShouldFailOnServerErrors(t *testing.T) {
+	t.Logf("Given upstream services")
+	{
+		up := httptest.NewServer(http.NotFoundHandler())
+		defer up.Close()
+		down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer down.Close()
+
+		t.Logf("\tWhen checking them")
+		{
+			err := health.HTTP(http.DefaultClient, up.URL).Check(context.Background())
+			check(t, err == nil, "A service answering should be up", err)
+			err = health.HTTP(http.DefaultClient, down.URL).Check(context.Background())
+			check(t, err != nil, "A service answering with a server error should be down", err)
+			err = health.HTTP(http.DefaultClient, "http://127.0.0.1:1").Check(context.Background())
+			check(t, err != nil, "An unreachable service should be down", err)
+		}
+	}
+}
+
+func TestRetry_ShouldRetryUntilTheDeadline(t *testing.T) {
+	t.Logf("Given a dependency available on the second attempt")
+	{
+		attempts := 0
+		err := health.Retry(context.Background(), "connect", func(context.Context) error {
+			if attempts++; attempts < 2 {
+				return errors.New("connection refused")
+			}
+			return nil
+		})
+		check(t, err == nil && attempts == 2, "The second attempt should have succeeded", attempts)
+
+		t.Logf("\tWhen it never becomes available")
+		{
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			err := health.Retry(ctx, "connect", func(context.Context) error { return errors.New("connection refused") })
+			check(t, err != nil && err.Error() == "connection refused", "The last error should be returned at the deadline", err)
+		}
+	}
+}
+
+// Helper function to create a check failing with the stored error, unless its message is empty
+func failing(err *atomic.Value) health.Checker {
+	return health.CheckerFunc(func(context.Context) error {
+		if e := err.Load().(error); e.Error() != "" {
+			return e
+		}
+		return nil
+	})
+}
+
+// Helper function to log an expectation with a check mark, failing the test with a ballot when it is not met
+func check(t *testing.T, ok bool, expectation string, got interface{}) {
+	if ok {
+		t.Logf("\t\t%s. %v", expectation, test.CheckMark)
+	} else {
+		t.Errorf("\t\t%s. %v %v", expectation, got, test.BallotX)
+	}
+}
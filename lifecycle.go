@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// lifecycle runs the servers and background workers of the service, and stops them along the resources they use in
+// the reverse order of their registration, so that the servers stop accepting requests first and the repository is
+// closed last.
+type lifecycle struct {
+	mu    sync.Mutex
+	stops []stop
+
+	// failed receives the error of a server which stopped on its own, signals the signals stopping the service
+	failed  chan error
+	signals chan os.Signal
+}
+
+// stop stops a component, giving up when the context is done
+type stop struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Helper function to create an empty lifecycle, stopped by the given signals
+func newLifecycle(signals ...os.Signal) *lifecycle {
+	l := &lifecycle{failed: make(chan error, 1), signals: make(chan os.Signal, 1)}
+	signal.Notify(l.signals, signals...)
+	return l
+}
+
+// server runs serve in the background, stopped by the given function. Serve returning other than on stop, e.g. when
+// its address is in use, stops the whole service.
+func (l *lifecycle) server(name string, serve func() error, stopFn func(ctx context.Context) error) {
+	stopping := make(chan struct{})
+	l.register(name, func(ctx context.Context) error {
+		close(stopping)
+		return stopFn(ctx)
+	})
+	go func() {
+		err := serve()
+		select {
+		case <-stopping:
+		default:
+			select {
+			case l.failed <- fmt.Errorf("%s stopped: %v", name, err):
+			default:
+			}
+		}
+	}()
+}
+
+// worker runs a background worker until it is stopped, waiting for it to return
+func (l *lifecycle) worker(name string, run func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		run(ctx)
+	}()
+	l.register(name, func(stopCtx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-stopCtx.Done():
+			return stopCtx.Err()
+		}
+	})
+}
+
+// closer closes a resource on shutdown
+func (l *lifecycle) closer(name string, close func() error) {
+	l.register(name, func(context.Context) error {
+		return close()
+	})
+}
+
+// Helper function to register the stop of a component
+func (l *lifecycle) register(name string, fn func(ctx context.Context) error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stops = append(l.stops, stop{name: name, fn: fn})
+}
+
+// wait blocks until one of the signals is received or a server fails, returning the failure. The signals are no longer
+// handled afterwards, a second one killing the process if the shutdown hangs.
+func (l *lifecycle) wait() error {
+	defer signal.Stop(l.signals)
+
+	select {
+	case sig := <-l.signals:
+		slog.Info("Shutting down", "signal", sig.String())
+		return nil
+	case err := <-l.failed:
+		return err
+	}
+}
+
+// shutdown stops the components in the reverse order of their registration, all within the timeout. A component
+// failing to stop does not prevent the next ones from stopping, the first error being returned.
+func (l *lifecycle) shutdown(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	l.mu.Lock()
+	stops := l.stops
+	l.stops = nil
+	l.mu.Unlock()
+
+	var first error
+	for i := len(stops) - 1; i >= 0; i-- {
+		start := time.Now()
+		if err := stops[i].fn(ctx); err != nil {
+			slog.Error("Failed to stop", "component", stops[i].name, "error", err)
+			if first == nil {
+				first = fmt.Errorf("failed to stop the %s: %s", stops[i].name, err)
+			}
+			continue
+		}
+		slog.Info("Stopped", "component", stops[i].name, "duration", time.Since(start).Round(time.Millisecond))
+	}
+	return first
+}
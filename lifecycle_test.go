@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"payment-service/test"
+)
+
+func TestLifecycle_ShouldDrainTheRequestsThenStopTheWorkersInReverseOrder(t *testing.T) {
+	t.Logf("Given a server with an in-flight request, a worker and a repository")
+	{
+		var stopped []string
+		app := newLifecycle(syscall.SIGTERM)
+		app.closer("repository", func() error {
+			stopped = append(stopped, "repository")
+			return nil
+		})
+		app.worker("relay", func(ctx context.Context) {
+			<-ctx.Done()
+			stopped = append(stopped, "relay")
+		})
+
+		started := make(chan struct{})
+		l, _ := net.Listen("tcp", "127.0.0.1:0")
+		srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+			w.WriteHeader(http.StatusCreated)
+		})}
+		app.server("REST API", func() error { return srv.Serve(l) }, func(ctx context.Context) error {
+			err := srv.Shutdown(ctx)
+			stopped = append(stopped, "REST API")
+			return err
+		})
+
+		status := make(chan int, 1)
+		go func() {
+			resp, err := http.Post("http://"+l.Addr().String(), "application/json", nil)
+			if err != nil {
+				status <- 0
+				return
+			}
+			resp.Body.Close()
+			status <- resp.StatusCode
+		}()
+		<-started
+
+		t.Logf("\tWhen receiving SIGTERM")
+		{
+			syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+			err := app.wait()
+			check(t, err == nil, "The signal should have been received", err)
+
+			err = app.shutdown(time.Second)
+			check(t, err == nil, "The shutdown should have completed", err)
+			check(t, <-status == http.StatusCreated, "The in-flight request should have been served", stopped)
+			check(t, strings.Join(stopped, ",") == "REST API,relay,repository", "The components should have been stopped in reverse order", stopped)
+		}
+	}
+}
+
+func TestLifecycle_ShouldReportTheFailures(t *testing.T) {
+	t.Logf("Given a server failing to serve and a worker ignoring its stop")
+	{
+		app := newLifecycle(syscall.SIGTERM)
+		closed := false
+		app.closer("repository", func() error {
+			closed = true
+			return errors.New("already closed")
+		})
+		block := make(chan struct{})
+		defer close(block)
+		app.worker("relay", func(context.Context) { <-block })
+		app.server("REST API", func() error { return errors.New("address already in use") }, func(context.Context) error { return nil })
+
+		t.Logf("\tWhen waiting for a signal then shutting down")
+		{
+			err := app.wait()
+			check(t, err != nil && strings.Contains(err.Error(), "address already in use"), "The server failure should have been returned", err)
+
+			err = app.shutdown(50 * time.Millisecond)
+			check(t, err != nil && strings.Contains(err.Error(), "relay"), "The worker not stopping in time should be reported", err)
+			check(t, closed, "The repository should still have been closed", closed)
+		}
+	}
+}
+
+// Helper function to log an expectation with a check mark, failing the test with a ballot when it is not met
+func check(t *testing.T, ok bool, expectation string, got interface{}) {
+	if ok {
+		t.Logf("\t\t%s. %v", expectation, test.CheckMark)
+	} else {
+		t.Errorf("\t\t%s. %v %v", expectation, got, test.BallotX)
+	}
+}
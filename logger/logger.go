@@ -1,27 +1,89 @@
+// Package logger configures the structured logs of the payment service. The records are written with log/slog, those
+// written with a request context carrying the IDs of the request and of its trace, see WithRequestID. The personal
+// data of the records, the names and account numbers of the payment parties, are masked whatever the way they are
+// logged.
 package logger
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+	"payment-service/privacy"
 )
 
-var (
-	// Trace the trace logger
-	Trace *log.Logger
+const (
+	// FormatJSON writes a JSON object per record, for the log collectors
+	FormatJSON = "json"
 
-	// Info the info logger
-	Info *log.Logger
+	// FormatText writes key=value pairs, easier to read in a terminal
+	FormatText = "text"
 
-	// Warning the warning logger
-	Warning *log.Logger
+	// RequestIDKey the attribute of the request ID in the records
+	RequestIDKey = "request_id"
 
-	// Error the error logger
-	Error *log.Logger
+	// TraceIDKey and SpanIDKey the attributes of the trace and span IDs in the records
+	TraceIDKey = "trace_id"
+	SpanIDKey  = "span_id"
 )
 
-func init() {
-	Trace = log.New(os.Stdout, "TRACE:", log.Ldate|log.Ltime|log.Lshortfile)
-	Info = log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
-	Warning = log.New(os.Stdout, "WARNING: ", log.Ldate|log.Ltime|log.Lshortfile)
-	Error = log.New(os.Stdout, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
+// New creates a logger writing the records of the given level and above to w, in the given format
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unknown log level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		return slog.New(handler{slog.NewJSONHandler(w, opts)}), nil
+	case FormatText:
+		return slog.New(handler{slog.NewTextHandler(w, opts)}), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q", format)
+}
+
+// Setup makes the default slog logger, and the standard log package, write to stdout with the given level and format
+func Setup(level, format string) error {
+	l, err := New(os.Stdout, level, format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(l)
+	return nil
+}
+
+// handler masks the personal data of the records and adds the request, trace and span IDs of the context to them
+type handler struct {
+	slog.Handler
+}
+
+// Handle masks the message and attributes, adds the request, trace and span ID attributes then writes the record
+func (h handler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, privacy.MaskText(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(redact(a))
+		return true
+	})
+	if id := RequestID(ctx); id != "" {
+		redacted.AddAttrs(slog.String(RequestIDKey, id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		redacted.AddAttrs(slog.String(TraceIDKey, sc.TraceID().String()), slog.String(SpanIDKey, sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, redacted)
+}
+
+// WithAttrs masks the given attributes, and keeps adding the request ID to the records
+func (h handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return handler{h.Handler.WithAttrs(redactAll(attrs))}
+}
+
+// WithGroup keeps adding the request ID to the records of the given group
+func (h handler) WithGroup(name string) slog.Handler {
+	return handler{h.Handler.WithGroup(name)}
 }
@@ -0,0 +1,113 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"payment-service/logger"
+	"payment-service/model"
+	"payment-service/test"
+)
+
+func TestNew_ShouldWriteLeveledRecordsWithTheRequestID(t *testing.T) {
+	t.Logf("Given a JSON logger of the info level")
+	{
+		var out bytes.Buffer
+		l, err := logger.New(&out, "info", logger.FormatJSON)
+		check(t, err == nil, "The logger should have been created", err)
+
+		t.Logf("\tWhen logging with a request context")
+		{
+			ctx := logger.WithRequestID(context.Background(), "req-1")
+			l.DebugContext(ctx, "Hidden")
+			l.With("component", "api").InfoContext(ctx, "Stored payment", "payment_id", "p-1")
+
+			var record map[string]interface{}
+			err := json.Unmarshal(out.Bytes(), &record)
+			check(t, err == nil && record["level"] == "INFO" && record["msg"] == "Stored payment" && record["payment_id"] == "p-1" &&
+				record["component"] == "api" && record[logger.RequestIDKey] == "req-1", "A single record should carry the request ID", out.String())
+		}
+	}
+
+	t.Logf("Given an invalid level or format")
+	{
+		_, err := logger.New(&bytes.Buffer{}, "verbose", logger.FormatJSON)
+		check(t, err != nil, "The level should be rejected", err)
+		_, err = logger.New(&bytes.Buffer{}, "info", "xml")
+		check(t, err != nil, "The format should be rejected", err)
+	}
+}
+
+func TestRequestID_ShouldBeValidatedAndForwarded(t *testing.T) {
+	t.Logf("Given client request IDs")
+	{
+		check(t, logger.ValidRequestID("5f2b-42:a.b_c"), "A printable ID should be accepted", nil)
+		check(t, !logger.ValidRequestID("") && !logger.ValidRequestID("a b") && !logger.ValidRequestID("a\nmsg=forged") &&
+			!logger.ValidRequestID(strings.Repeat("a", logger.MaxRequestIDLength+1)), "Empty, long or unprintable IDs should be rejected", nil)
+		check(t, logger.ValidRequestID(logger.NewRequestID()) && logger.NewRequestID() != logger.NewRequestID(),
+			"The generated IDs should be valid and unique", nil)
+	}
+
+	t.Logf("Given a downstream service called with the request context")
+	{
+		var received string
+		downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = r.Header.Get(logger.RequestIDHeader)
+		}))
+		defer downstream.Close()
+		client := &http.Client{Transport: logger.Transport{}}
+
+		t.Logf("\tWhen calling it")
+		{
+			req, _ := http.NewRequest(http.MethodGet, downstream.URL, nil)
+			resp, err := client.Do(req.WithContext(logger.WithRequestID(context.Background(), "req-2")))
+			if err == nil {
+				resp.Body.Close()
+			}
+			check(t, err == nil && received == "req-2" && req.Header.Get(logger.RequestIDHeader) == "",
+				"The request ID should have been forwarded without changing the request", received)
+		}
+	}
+}
+
+func TestNew_ShouldMaskThePersonalData(t *testing.T) {
+	t.Logf("Given a JSON logger")
+	{
+		var out bytes.Buffer
+		l, _ := logger.New(&out, "info", logger.FormatJSON)
+		party := model.Party{AccountName: "EJ Brown", AccountNumber: "GB29XABC10161234567801", Name: "Emelia Brown",
+			Address: "1 Clarendon Road", BankID: "403000"}
+		payment := model.Payment{ID: model.NewID()}
+		payment.BeneficiaryParty = party
+
+		t.Logf("\tWhen logging payments, parties and errors holding personal data")
+		{
+			l.With("name", "Wilfred Owens").Info("Failed to pay 31926819",
+				"payment", payment, "event", model.NewEvent(model.PaymentCreated, payment),
+				"error", errors.New("account GB29XABC10161234567801 is closed"), "payment_id", payment.ID.String())
+
+			logged := out.String()
+			check(t, !strings.Contains(logged, "GB29XABC10161234567801") && !strings.Contains(logged, "31926819") &&
+				!strings.Contains(logged, "Emelia") && !strings.Contains(logged, "Wilfred") && !strings.Contains(logged, "Clarendon"),
+				"The names, addresses and account numbers should have been masked", logged)
+			check(t, strings.Contains(logged, "GB****************7801") && strings.Contains(logged, "E***** B****") &&
+				strings.Contains(logged, payment.ID.String()) && strings.Contains(logged, "403000"),
+				"The masked values should keep their last digits and initials, the other values being kept", logged)
+		}
+	}
+}
+
+// Helper function to log an expectation with a check mark, failing the test with a ballot when it is not met
+func check(t *testing.T, ok bool, expectation string, got interface{}) {
+	if ok {
+		t.Logf("\t\t%s. %v", expectation, test.CheckMark)
+	} else {
+		t.Errorf("\t\t%s. %v %v", expectation, got, test.BallotX)
+	}
+}
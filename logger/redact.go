@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"log/slog"
+
+	"payment-service/model"
+	"payment-service/privacy"
+)
+
+// masks of the attributes whose key tells they hold personal data
+var sensitive = map[string]func(string) string{
+	privacy.FieldAccountName:   privacy.MaskName,
+	privacy.FieldAccountNumber: privacy.MaskAccountNumber,
+	privacy.FieldAddress:       privacy.MaskAll,
+	privacy.FieldName:          privacy.MaskName,
+	"iban":                     privacy.MaskAccountNumber,
+}
+
+// Helper function to mask the personal data of an attribute: the payments, their parties, events and requests are
+// masked by privacy.Logs, the attributes named after a party field by the mask of the field, and the IBANs and
+// account numbers of the other strings and errors by privacy.MaskText
+func redact(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		if mask, ok := sensitive[a.Key]; ok {
+			return slog.String(a.Key, mask(v.String()))
+		}
+		return slog.String(a.Key, privacy.MaskText(v.String()))
+	case slog.KindGroup:
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redactAll(v.Group())...)}
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case model.Party:
+			return slog.Any(a.Key, privacy.Logs.Party(x))
+		case model.Payment:
+			return slog.Any(a.Key, privacy.Logs.Payment(x))
+		case model.PaymentResponse:
+			return slog.Any(a.Key, privacy.Logs.Payments(x))
+		case model.Event:
+			return slog.Any(a.Key, privacy.Logs.Event(x))
+		case model.CreatePaymentRequest:
+			return slog.Any(a.Key, privacy.Logs.Request(x))
+		case error:
+			return slog.String(a.Key, privacy.MaskText(x.Error()))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+// Helper function to mask the personal data of attributes
+func redactAll(attrs []slog.Attr) []slog.Attr {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redact(a)
+	}
+	return redacted
+}
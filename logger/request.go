@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+const (
+	// RequestIDHeader the header carrying the request ID, accepted from the clients, returned in the responses and
+	// forwarded to the downstream services
+	RequestIDHeader = "X-Request-ID"
+
+	// MaxRequestIDLength the length above which a client request ID is replaced by a generated one
+	MaxRequestIDLength = 128
+)
+
+// key of the request ID in a context
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of the context carrying the given request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by the context, empty when there is none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID generates a random request ID
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ValidRequestID tells whether a request ID given by a client may be used as is: not empty, not longer than
+// MaxRequestIDLength and only made of printable ASCII characters other than spaces, for it not to forge log lines
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > MaxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// Transport forwards the request ID of the request context to the downstream services in the RequestIDHeader
+type Transport struct {
+	// Base the transport sending the requests, http.DefaultTransport when nil
+	Base http.RoundTripper
+}
+
+// RoundTrip sends the request with the request ID header, unless it is already set
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	id := RequestID(req.Context())
+	if id == "" || req.Header.Get(RequestIDHeader) != "" {
+		return base.RoundTrip(req)
+	}
+	// a RoundTripper must not modify the request
+	clone := req.Clone(req.Context())
+	clone.Header.Set(RequestIDHeader, id)
+	return base.RoundTrip(clone)
+}
@@ -1,31 +1,31 @@
 package main
 
 import (
+	"github.com/gin-gonic/gin"
+	"context"
+	"flag"
 	"fmt"
 	"payment-service/api"
+	"payment-service/config"
 	_ "payment-service/docs"
+	"payment-service/event"
+	"payment-service/health"
+	"payment-service/logger"
+	"payment-service/metrics"
+	"payment-service/privacy"
 	"payment-service/repository"
-	"log"
+	"payment-service/rpc"
+	"payment-service/service"
+	"payment-service/tracing"
+	"payment-service/webhook"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"syscall"
 )
 
-// default mongo url
-var mongoUrl = "mongodb://localhost:27017/payment-db"
-
-const (
-	port  = ":8080"
-	fxUrl = "http://localhost:9090/fx"
-	chUrl = "http://localhost:9090/ch"
-)
-
-func init() {
-	url, exists := os.LookupEnv("MONGO_URL")
-	if exists {
-		mongoUrl = url
-	}
-}
-
 // @BasePath /
 // @title Payment Service API
 // @version 1.0
@@ -35,14 +35,192 @@ func init() {
 
 // @BasePath /
 func main() {
-	fmt.Println("Starting main")
-	fmt.Printf("Connecting to mongo on %s", mongoUrl)
-	repo := repository.NewRepository(mongoUrl)
-	router := api.NewPaymentHandler(repo, fxUrl, chUrl).NewRouter()
+	cfg, args, err := config.Load(os.Args[1:], os.Stderr)
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err != nil {
+		fatal("Failed to load the configuration", err)
+	}
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			os.Exit(migrate(cfg, args[1:], os.Stdout))
+		case "config":
+			os.Exit(configure(cfg, args[1:], os.Stdout))
+		}
+		slog.Error("Unknown command", "command", args[0])
+		os.Exit(2)
+	}
+	if err := cfg.Validate(); err != nil {
+		fatal("Invalid configuration", err)
+	}
+	if err := logger.Setup(cfg.Log.Level, cfg.Log.Format); err != nil {
+		fatal("Failed to set up the logs", err)
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{Exporter: cfg.Tracing.Exporter,
+		Endpoint: cfg.Tracing.Endpoint, SampleRatio: cfg.Tracing.SampleRatio, Environment: cfg.Environment})
+	if err != nil {
+		fatal("Failed to set up the tracing", err)
+	}
+	// gin lists the routes in debug mode, which is only useful along the debug logs
+	if cfg.Log.Level != "debug" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	slog.Info("Starting the payment service", "environment", cfg.Environment, "repository", cfg.Redacted().RepositoryURL())
+	repo, err := openRepository(cfg)
+	if err != nil {
+		fatal("Failed to open the repository", err, "timeout", cfg.StartupTimeout)
+	}
+	// the components are stopped in the reverse order: the APIs, the workers, the publisher, the repository then the
+	// tracer provider flushing the spans
+	app := newLifecycle(syscall.SIGINT, syscall.SIGTERM)
+	app.register("tracer provider", shutdownTracing)
+	app.closer("repository", repo.Close)
+	// the payment use cases record their repository operations in the metrics and traces, the outbox relay polling
+	// outside of any request in the metrics only
+	instrumented := metrics.InstrumentRepository(repo)
+	traced := metrics.InstrumentRepository(tracing.InstrumentRepository(repo))
+
+	webhooks, err := newWebhookStore(repo)
+	if err != nil {
+		fatal("Failed to create the webhook store", err)
+	}
+
+	// publish the payment events recorded in the outbox, to the broker, the webhooks and the event stream
+	publisher, err := newPublisher(cfg.Kafka)
+	if err != nil {
+		fatal("Failed to create the event publisher", err)
+	}
+	if closer, ok := publisher.(io.Closer); ok {
+		app.closer("event publisher", closer.Close)
+	}
+	app.worker("webhook worker", webhook.NewWorker(webhooks).Run)
+	stream := event.NewStream(event.DefaultStreamSize, event.DefaultStreamBuffer)
+	publishers := event.Publishers{publisher, webhook.NewDispatcher(webhooks), stream}
+	app.worker("outbox relay", event.NewRelay(instrumented, publishers, api.DatabaseName).Run)
+
+	// the REST and gRPC APIs share the payment use cases and mask the party details for the same roles
+	payments := api.NewPaymentService(traced, cfg.Pricing.FXURL, cfg.Pricing.ChargesURL)
+	policy, err := privacy.NewPolicy(cfg.Privacy.RoleHeader, cfg.Privacy.UnmaskedRoles, cfg.Privacy.MaskedFields)
+	if err != nil {
+		fatal("Failed to create the privacy policy", err)
+	}
+	if err := serveGRPC(app, cfg.GRPC.Addr, rpc.NewServer(payments).WithPrivacy(policy)); err != nil {
+		fatal("Failed to listen for gRPC requests", err)
+	}
+
+	handler := api.NewPaymentHandlerFor(payments).WithWebhooks(webhooks).WithStream(stream).WithHealth(newHealthChecks(cfg, repo)).
+		WithPrivacy(policy)
+	if backup, ok := repo.(api.Backuper); ok {
+		handler.WithBackup(backup)
+	}
+	router := handler.NewRouter()
 	srv := &http.Server{
-		Addr:    port,
+		Addr:    cfg.HTTP.Addr,
 		Handler: router,
 	}
-	log.Printf("Starting the paymet server on %s", srv.Addr)
-	log.Fatalln(srv.ListenAndServe())
+	// the event streams never go idle, they are disconnected for the shutdown not to wait for them
+	srv.RegisterOnShutdown(stream.Close)
+	slog.Info("Starting the payment REST API", "addr", srv.Addr)
+	app.server("REST API", srv.ListenAndServe, srv.Shutdown)
+
+	failure := app.wait()
+	if failure != nil {
+		slog.Error("Shutting down after a failure", "error", failure)
+	}
+	if err := app.shutdown(cfg.ShutdownTimeout); err != nil || failure != nil {
+		os.Exit(1)
+	}
+	slog.Info("Stopped the payment service")
+}
+
+// Helper function to log an error preventing the service from running, then exit
+func fatal(msg string, err error, args ...interface{}) {
+	slog.Error(msg, append([]interface{}{"error", err}, args...)...)
+	os.Exit(1)
+}
+
+// Helper function to open the repository and create the payment indexes, retrying while the storage starts
+func openRepository(cfg config.Config) (repository.Repository, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.StartupTimeout)
+	defer cancel()
+	var repo repository.Repository
+	err := health.Retry(ctx, "open the repository", func(ctx context.Context) error {
+		opened, err := repository.Open(cfg.RepositoryURL(), mongoOptions(cfg))
+		if err != nil {
+			return err
+		}
+		if err := opened.EnsureIndexes(ctx, api.DatabaseName, api.CollectionName); err != nil {
+			opened.Close()
+			return fmt.Errorf("failed to create the payment indexes: %s", err)
+		}
+		repo = opened
+		return nil
+	})
+	return repo, err
+}
+
+// Helper function to create the readiness checks: the repository is required, the pricing services are optional as
+// they are mocked for now
+func newHealthChecks(cfg config.Config, repo repository.Repository) *health.Registry {
+	checks := health.NewRegistry(cfg.Health.Timeout, cfg.Health.CacheTTL)
+	checks.Register("repository", health.CheckerFunc(repo.Ping), true)
+	checks.Register("fx", health.HTTP(service.HTTPClient, cfg.Pricing.FXURL), false)
+	checks.Register("charges", health.HTTP(service.HTTPClient, cfg.Pricing.ChargesURL), false)
+	return checks
+}
+
+// Helper function to serve the gRPC API on the given address, the in-flight calls being drained on shutdown and
+// aborted at its deadline
+func serveGRPC(app *lifecycle, addr string, server *rpc.Server) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := rpc.NewGRPCServerFor(server)
+	slog.Info("Starting the payment gRPC API", "addr", addr)
+	app.server("gRPC API", func() error { return srv.Serve(l) }, func(ctx context.Context) error {
+		stopped := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+			return nil
+		case <-ctx.Done():
+			srv.Stop()
+			return ctx.Err()
+		}
+	})
+	return nil
+}
+
+// Helper function to create the event publisher, Kafka when brokers are configured and the log otherwise
+func newPublisher(cfg config.Kafka) (event.Publisher, error) {
+	if len(cfg.Brokers) == 0 {
+		return event.LogPublisher{}, nil
+	}
+	publisher := event.NewKafkaPublisher(cfg.Brokers, cfg.Topics)
+	publisher.DefaultTopic = cfg.DefaultTopic
+	return publisher, nil
+}
+
+// Helper function to get the mongo pool and consistency options of the configuration
+func mongoOptions(cfg config.Config) repository.Options {
+	return repository.Options{PoolLimit: cfg.Mongo.PoolLimit, ReadPreference: cfg.Mongo.ReadPreference,
+		WriteConcern: cfg.Mongo.WriteConcern, Journal: cfg.Mongo.Journal}
+}
+
+// Helper function to create the webhook store, in the payment database with mongo and in memory otherwise
+func newWebhookStore(repo repository.Repository) (webhook.Store, error) {
+	mongoRepo, ok := repo.(*repository.MongoRepository)
+	if !ok {
+		slog.Warn("The webhooks are kept in memory with this repository, they are lost on restart")
+		return webhook.NewMemoryStore(), nil
+	}
+	store := webhook.NewMongoStore(mongoRepo.Client.Database(api.DatabaseName))
+	return store, store.EnsureIndexes(context.Background())
 }
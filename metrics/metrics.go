@@ -0,0 +1,136 @@
+// Package metrics exposes the Prometheus metrics of the payment service: the served HTTP requests, the repository
+// operations, the calls to the pricing services and the created payments.
+package metrics
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"payment-service/model"
+)
+
+// Namespace the prefix of the metric names
+const Namespace = "payment_service"
+
+// The pricing services called by the payment use cases, see ObserveUpstream
+const (
+	UpstreamFX      = "fx"
+	UpstreamCharges = "charges"
+)
+
+var (
+	// Registry the registry of the payment service metrics, along the Go runtime and process ones
+	Registry = prometheus.NewRegistry()
+
+	// HTTPRequestDuration the duration of the served HTTP requests by method, route and status, its count being the
+	// number of requests
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of the served HTTP requests by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// RepositoryDuration the duration of the repository operations by method
+	RepositoryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "repository_operation_duration_seconds",
+		Help:      "Duration of the repository operations by method.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation"})
+
+	// RepositoryErrors the failed repository operations by method, a missing or duplicate payment not being a failure
+	RepositoryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "repository_operation_errors_total",
+		Help:      "Failed repository operations by method.",
+	}, []string{"operation"})
+
+	// UpstreamDuration the duration of the calls to the pricing services by service
+	UpstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Duration of the calls to the pricing services by service.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service"})
+
+	// UpstreamErrors the failed calls to the pricing services by service
+	UpstreamErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "upstream_request_errors_total",
+		Help:      "Failed calls to the pricing services by service.",
+	}, []string{"service"})
+
+	// PaymentsCreated the created payments by scheme and currency
+	PaymentsCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "payments_created_total",
+		Help:      "Created payments by scheme and currency.",
+	}, []string{"scheme", "currency"})
+
+	// PaymentsAmount the total amount of the created payments by currency
+	PaymentsAmount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "payments_created_amount_total",
+		Help:      "Total amount of the created payments by currency.",
+	}, []string{"currency"})
+)
+
+// label values given by the clients are kept when they look like a scheme or currency, for a forged value not to
+// create a new series
+var labelValue = regexp.MustCompile(`^[A-Za-z0-9_-]{1,16}$`)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		RepositoryDuration,
+		RepositoryErrors,
+		UpstreamDuration,
+		UpstreamErrors,
+		PaymentsCreated,
+		PaymentsAmount,
+	)
+}
+
+// Handler serves the metrics of the Registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveHTTP records a served HTTP request of the given route, e.g. /payment/:id
+func ObserveHTTP(method, route string, status int, start time.Time) {
+	HTTPRequestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+}
+
+// ObserveUpstream records a call to the given pricing service, started at start and failed when err is not nil
+func ObserveUpstream(service string, start time.Time, err error) {
+	UpstreamDuration.WithLabelValues(service).Observe(time.Since(start).Seconds())
+	if err != nil {
+		UpstreamErrors.WithLabelValues(service).Inc()
+	}
+}
+
+// PaymentCreated counts a created payment and adds its amount to the total of its currency, a counter not going down
+// for negative amounts
+func PaymentCreated(p model.Payment) {
+	currency := label(p.Currency)
+	PaymentsCreated.WithLabelValues(label(p.PaymentScheme), currency).Inc()
+	if p.Amount > 0 {
+		PaymentsAmount.WithLabelValues(currency).Add(p.Amount)
+	}
+}
+
+// Helper function to bound a label value given by a client
+func label(v string) string {
+	if labelValue.MatchString(v) {
+		return v
+	}
+	return "other"
+}
@@ -0,0 +1,78 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"payment-service/metrics"
+	"payment-service/mocks"
+	"payment-service/model"
+	"payment-service/repository"
+	"payment-service/test"
+)
+
+func TestInstrumentRepository_ShouldRecordTheOperationsAndFailures(t *testing.T) {
+	t.Logf("Given an instrumented repository")
+	{
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockRepo := mocks.NewMockRepository(mockCtrl)
+		repo := metrics.InstrumentRepository(mockRepo)
+		ctx := context.Background()
+		finds := testutil.ToFloat64(metrics.RepositoryErrors.WithLabelValues("find"))
+		deletes := testutil.ToFloat64(metrics.RepositoryErrors.WithLabelValues("delete"))
+
+		t.Logf("\tWhen operations fail or find no payment")
+		{
+			failure := errors.New("connection reset")
+			mockRepo.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(model.PaymentResponse{}, repository.ErrNotFound)
+			mockRepo.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(failure)
+
+			_, errF := repo.Find(ctx, "db", "col", model.NewID())
+			errD := repo.Delete(ctx, "db", "col", model.NewID())
+			check(t, errF == repository.ErrNotFound && errD == failure, "The errors should be returned as is", errD)
+			check(t, testutil.ToFloat64(metrics.RepositoryErrors.WithLabelValues("find")) == finds &&
+				testutil.ToFloat64(metrics.RepositoryErrors.WithLabelValues("delete")) == deletes+1,
+				"Only the failure should be counted", nil)
+			check(t, testutil.CollectAndCount(metrics.RepositoryDuration) >= 2, "The durations should be recorded", nil)
+		}
+	}
+}
+
+func TestPaymentCreated_ShouldBoundTheLabelsGivenByTheClients(t *testing.T) {
+	t.Logf("Given created payments")
+	{
+		payment := model.Payment{Attributes: model.Attributes{Amount: 100.5, Currency: "NOK", PaymentScheme: "FPS"}}
+		forged := model.Payment{Attributes: model.Attributes{Amount: -1, Currency: "NOK\"} 1\n", PaymentScheme: "FPS"}}
+		created := testutil.ToFloat64(metrics.PaymentsCreated.WithLabelValues("FPS", "NOK"))
+		amount := testutil.ToFloat64(metrics.PaymentsAmount.WithLabelValues("NOK"))
+
+		t.Logf("\tWhen counting them")
+		{
+			metrics.PaymentCreated(payment)
+			metrics.PaymentCreated(forged)
+			metrics.ObserveUpstream(metrics.UpstreamFX, time.Now(), errors.New("timeout"))
+
+			check(t, testutil.ToFloat64(metrics.PaymentsCreated.WithLabelValues("FPS", "NOK")) == created+1 &&
+				testutil.ToFloat64(metrics.PaymentsAmount.WithLabelValues("NOK")) == amount+100.5,
+				"The payment and its amount should be counted", nil)
+			check(t, testutil.ToFloat64(metrics.PaymentsCreated.WithLabelValues("FPS", "other")) >= 1,
+				"The forged currency should be counted as other", nil)
+			check(t, testutil.ToFloat64(metrics.UpstreamErrors.WithLabelValues(metrics.UpstreamFX)) >= 1,
+				"The failed FX call should be counted", nil)
+		}
+	}
+}
+
+// Helper function to log an expectation with a check mark, failing the test with a ballot when it is not met
+func check(t *testing.T, ok bool, expectation string, got interface{}) {
+	if ok {
+		t.Logf("\t\t%s. %v", expectation, test.CheckMark)
+	} else {
+		t.Errorf("\t\t%s. %v %v", expectation, got, test.BallotX)
+	}
+}
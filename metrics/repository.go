@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"payment-service/model"
+	"payment-service/repository"
+)
+
+// Repository records the duration and failures of the payment and outbox operations of the wrapped repository, the
+// other operations being passed through
+type Repository struct {
+	repository.Repository
+}
+
+// InstrumentRepository wraps the repository for its operations to be recorded
+func InstrumentRepository(repo repository.Repository) *Repository {
+	return &Repository{repo}
+}
+
+// Insert records the insertion of a payment
+func (r *Repository) Insert(ctx context.Context, db, col string, content interface{}) error {
+	start := time.Now()
+	return observe("insert", start, r.Repository.Insert(ctx, db, col, content))
+}
+
+// FindAll records the query of all the payments
+func (r *Repository) FindAll(ctx context.Context, db, col string) (model.PaymentResponse, error) {
+	start := time.Now()
+	resp, err := r.Repository.FindAll(ctx, db, col)
+	return resp, observe("find_all", start, err)
+}
+
+// FindPage records the query of a page of the payments
+func (r *Repository) FindPage(ctx context.Context, db, col string, filter repository.Filter, skip, limit int) (model.PaymentResponse, error) {
+	start := time.Now()
+	resp, err := r.Repository.FindPage(ctx, db, col, filter, skip, limit)
+	return resp, observe("find_page", start, err)
+}
+
+// Find records the query of a payment
+func (r *Repository) Find(ctx context.Context, db, col string, id model.ID) (model.PaymentResponse, error) {
+	start := time.Now()
+	resp, err := r.Repository.Find(ctx, db, col, id)
+	return resp, observe("find", start, err)
+}
+
+// Delete records the deletion of a payment
+func (r *Repository) Delete(ctx context.Context, db, col string, id model.ID) error {
+	start := time.Now()
+	return observe("delete", start, r.Repository.Delete(ctx, db, col, id))
+}
+
+// Update records the replacement of a payment
+func (r *Repository) Update(ctx context.Context, db, col string, id model.ID, content interface{}) error {
+	start := time.Now()
+	return observe("update", start, r.Repository.Update(ctx, db, col, id, content))
+}
+
+// Patch records the partial update of a payment
+func (r *Repository) Patch(ctx context.Context, db, col string, id model.ID, original, patched model.Payment) error {
+	start := time.Now()
+	return observe("patch", start, r.Repository.Patch(ctx, db, col, id, original, patched))
+}
+
+// Pending records the query of the outbox
+func (r *Repository) Pending(ctx context.Context, db string, limit int, exclude ...model.ID) ([]model.Event, error) {
+	start := time.Now()
+	events, err := r.Repository.Pending(ctx, db, limit, exclude...)
+	return events, observe("pending", start, err)
+}
+
+// Ack records the removal of published events from the outbox
+func (r *Repository) Ack(ctx context.Context, db string, ids ...string) error {
+	start := time.Now()
+	return observe("ack", start, r.Repository.Ack(ctx, db, ids...))
+}
+
+// Helper function to record a repository operation and return its error
+func observe(operation string, start time.Time, err error) error {
+	RepositoryDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil && err != repository.ErrNotFound && err != repository.ErrDuplicate {
+		RepositoryErrors.WithLabelValues(operation).Inc()
+	}
+	return err
+}
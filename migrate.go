@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"payment-service/api"
+	"payment-service/config"
+	"payment-service/migration"
+	"payment-service/model"
+	"payment-service/repository"
+)
+
+const migrateUsage = `Usage: payment-service migrate [flags] up|down|status
+
+Migrates the stored payments from one schema version to another. up applies the pending steps, down reverts the
+applied steps above the target version and status lists the steps and the outdated documents.
+
+Flags:
+`
+
+// The commands of the migrate subcommand
+var migrateCommands = map[string]bool{"up": true, "down": true, "status": true}
+
+// Helper function running the migrate subcommand, returning the exit code
+func migrate(cfg config.Config, args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(out)
+	target := flags.Int("to", -1, "target schema version, defaults to the latest for up and 0 for down")
+	dryRun := flags.Bool("dry-run", false, "report what would be migrated without changing anything")
+	flags.Usage = func() {
+		fmt.Fprint(out, migrateUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 || !migrateCommands[flags.Arg(0)] {
+		flags.Usage()
+		return 2
+	}
+
+	repo, err := repository.Open(cfg.RepositoryURL(), mongoOptions(cfg))
+	if err != nil {
+		fmt.Fprintf(out, "Failed to open the repository: %s\n", err)
+		return 1
+	}
+	defer repo.Close()
+
+	switch r := repo.(type) {
+	case *repository.MongoRepository:
+		migrator := migration.NewMigrator(r.Client.Database(api.DatabaseName), api.CollectionName)
+		migrator.DryRun = *dryRun
+		migrator.Log = out
+		err = migrateMongo(context.Background(), migrator, flags.Arg(0), *target, out)
+	case *repository.PostgresRepository:
+		// the SQL migrations have been applied when opening the repository
+		fmt.Fprintln(out, "The postgres schema is migrated by its SQL migrations on startup")
+	case *repository.BoltRepository:
+		fmt.Fprintf(out, "Stored payments are upgraded to schema version %d when read\n", model.SchemaVersion)
+	default:
+		fmt.Fprintf(out, "Payments kept in memory are always in schema version %d\n", model.SchemaVersion)
+	}
+	if err != nil {
+		fmt.Fprintf(out, "Migration failed: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// Helper function running a migrate command against mongo
+func migrateMongo(ctx context.Context, migrator *migration.Migrator, command string, target int, out io.Writer) error {
+	switch command {
+	case "up":
+		if target < 0 {
+			target = model.SchemaVersion
+		}
+		return migrator.Up(ctx, target)
+	case "down":
+		if target < 0 {
+			target = 0
+		}
+		return migrator.Down(ctx, target)
+	case "status":
+		applied, err := migrator.Applied(ctx)
+		if err != nil {
+			return err
+		}
+		at := make(map[int]migration.Record)
+		for _, r := range applied {
+			at[r.Version] = r
+		}
+		for _, step := range migrator.Steps {
+			status := "pending"
+			if r, ok := at[step.Version]; ok {
+				status = "applied " + r.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(out, "%d\t%s\t%s\n", step.Version, step.Description, status)
+		}
+		outdated, err := migrator.Pending(ctx, model.SchemaVersion)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%d documents older than schema version %d\n", outdated, model.SchemaVersion)
+		return nil
+	}
+	return fmt.Errorf("unknown migrate command %q", command)
+}
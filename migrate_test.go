@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"payment-service/config"
+	"payment-service/repository"
+)
+
+func TestMigrate_ShouldRefuseUnknownCommands(t *testing.T) {
+	t.Logf("Given a bbolt repository")
+	{
+		cfg := config.Default()
+		cfg.Repository.URL = "bolt://" + filepath.Join(t.TempDir(), "payments.db")
+
+		t.Logf("\tWhen running an unknown migrate command")
+		{
+			var out bytes.Buffer
+			code := migrate(cfg, []string{"frobnicate"}, &out)
+			check(t, code == 2 && strings.Contains(out.String(), "Usage"), "The usage should have been printed", out.String())
+		}
+	}
+}
+
+func TestMigrate_ShouldCloseTheRepository(t *testing.T) {
+	t.Logf("Given a bbolt repository")
+	{
+		cfg := config.Default()
+		cfg.Repository.URL = "bolt://" + filepath.Join(t.TempDir(), "payments.db")
+
+		t.Logf("\tWhen running the migrate status command")
+		{
+			var out bytes.Buffer
+			code := migrate(cfg, []string{"status"}, &out)
+			check(t, code == 0 && strings.Contains(out.String(), "schema version"), "The command should have succeeded", out.String())
+
+			// bbolt locks the file until it is closed, the repository failing to open after a second otherwise
+			repo, err := repository.Open(cfg.RepositoryURL(), repository.Options{})
+			check(t, err == nil, "The file should have been released", err)
+			if err == nil {
+				repo.Close()
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Package migration upgrades the stored payment documents from one schema version to the next.
+//
+// Each Step changes the shape of the documents and can be reverted. Documents record the version they are stored in,
+// so that the repositories upgrade outdated documents lazily when reading them, while the migrate command upgrades,
+// or downgrades, a whole collection and records the applied steps in the migrations collection.
+package migration
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"payment-service/model"
+)
+
+// VersionKey the key of the schema version in the stored documents
+const VersionKey = "schemaversion"
+
+// Step a versioned change of the stored payment documents
+type Step struct {
+	Version     int
+	Description string
+
+	// Up changes a document stored in the previous version into this version
+	Up func(doc bson.M) error
+
+	// Down reverts Up
+	Down func(doc bson.M) error
+}
+
+// Steps the ordered migration steps, the last version being model.SchemaVersion
+var Steps = []Step{
+	{Version: 1, Description: "Record the schema version on every payment", Up: noop, Down: noop},
+}
+
+// Version returns the schema version of a document, zero for documents stored before versioning
+func Version(doc bson.M) int {
+	switch v := doc[VersionKey].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+// Upgrade applies the steps above the version of the document, up to the target version. It reports whether the
+// document was changed.
+func Upgrade(doc bson.M, steps []Step, target int) (bool, error) {
+	changed := false
+	for _, step := range steps {
+		if step.Version <= Version(doc) || step.Version > target {
+			continue
+		}
+		if err := step.Up(doc); err != nil {
+			return changed, fmt.Errorf("migration %d failed: %s", step.Version, err)
+		}
+		doc[VersionKey] = step.Version
+		changed = true
+	}
+	return changed, nil
+}
+
+// Downgrade reverts the steps above the target version, down from the version of the document. It reports whether
+// the document was changed.
+func Downgrade(doc bson.M, steps []Step, target int) (bool, error) {
+	changed := false
+	for i := len(steps) - 1; i >= 0; i-- {
+		step := steps[i]
+		if step.Version > Version(doc) || step.Version <= target {
+			continue
+		}
+		if err := step.Down(doc); err != nil {
+			return changed, fmt.Errorf("migration %d rollback failed: %s", step.Version, err)
+		}
+		setVersion(doc, previous(steps, i))
+		changed = true
+	}
+	return changed, nil
+}
+
+// DecodePayment decodes a stored payment, upgrading the document to model.SchemaVersion first when it is outdated
+func DecodePayment(data []byte) (model.Payment, error) {
+	var payment model.Payment
+	var doc bson.M
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return payment, err
+	}
+	if Version(doc) >= model.SchemaVersion {
+		err := bson.Unmarshal(data, &payment)
+		return payment, err
+	}
+
+	if _, err := Upgrade(doc, Steps, model.SchemaVersion); err != nil {
+		return payment, err
+	}
+	upgraded, err := bson.Marshal(doc)
+	if err != nil {
+		return payment, err
+	}
+	err = bson.Unmarshal(upgraded, &payment)
+	return payment, err
+}
+
+// Helper function to get the version preceding the step at the given index
+func previous(steps []Step, i int) int {
+	if i == 0 {
+		return 0
+	}
+	return steps[i-1].Version
+}
+
+// Helper function to set the version of a document, documents stored before versioning having none
+func setVersion(doc bson.M, version int) {
+	if version == 0 {
+		delete(doc, VersionKey)
+		return
+	}
+	doc[VersionKey] = version
+}
+
+// Helper function for the steps which only record the version
+func noop(doc bson.M) error {
+	return nil
+}
@@ -0,0 +1,167 @@
+package migration_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"payment-service/migration"
+	"payment-service/model"
+	"payment-service/test"
+	"payment-service/test/mongotest"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// steps renaming the reference and upper casing the currency
+var steps = []migration.Step{
+	{Version: 1, Description: "rename ref", Up: rename("ref", "reference"), Down: rename("reference", "ref")},
+	{Version: 2, Description: "upper case currency",
+		Up: func(doc bson.M) error {
+			doc["currency"] = strings.ToUpper(doc["currency"].(string))
+			return nil
+		},
+		Down: func(doc bson.M) error {
+			doc["currency"] = strings.ToLower(doc["currency"].(string))
+			return nil
+		}},
+}
+
+func TestUpgrade_ShouldApplyTheStepsAboveTheDocumentVersion(t *testing.T) {
+	t.Logf("Given a document stored before versioning")
+	{
+		t.Logf("\tWhen upgrading it to version 1 then to the latest version")
+		{
+			doc := bson.M{"ref": "piano lessons", "currency": "gbp"}
+			changed, err := migration.Upgrade(doc, steps, 1)
+			check(t, changed && err == nil && doc["reference"] == "piano lessons" && doc["currency"] == "gbp",
+				"Only the first step should have been applied", doc)
+
+			changed, err = migration.Upgrade(doc, steps, 2)
+			check(t, changed && err == nil && doc["currency"] == "GBP" && migration.Version(doc) == 2,
+				"The second step should have been applied", doc)
+
+			changed, _ = migration.Upgrade(doc, steps, 2)
+			check(t, !changed, "Upgrading an up to date document should not change it", doc)
+		}
+	}
+}
+
+func TestDowngrade_ShouldRevertTheStepsAboveTheTarget(t *testing.T) {
+	t.Logf("Given a document in version 2")
+	{
+		t.Logf("\tWhen downgrading it to version 0")
+		{
+			doc := bson.M{"reference": "piano lessons", "currency": "GBP", migration.VersionKey: int32(2)}
+			changed, err := migration.Downgrade(doc, steps, 0)
+			_, versioned := doc[migration.VersionKey]
+			check(t, changed && err == nil && doc["ref"] == "piano lessons" && doc["currency"] == "gbp" && !versioned,
+				"The document should be back in its original shape", doc)
+		}
+	}
+}
+
+func TestUpgrade_FailingStepShouldReturnAnError(t *testing.T) {
+	t.Logf("Given a step failing")
+	{
+		failing := []migration.Step{{Version: 1, Up: func(bson.M) error { return errors.New("boom") }}}
+
+		t.Logf("\tWhen upgrading a document")
+		{
+			doc := bson.M{}
+			_, err := migration.Upgrade(doc, failing, 1)
+			check(t, err != nil && migration.Version(doc) == 0, "The upgrade should have failed leaving the version", err)
+		}
+	}
+}
+
+func TestDecodePayment_ShouldUpgradeOutdatedDocuments(t *testing.T) {
+	t.Logf("Given a payment stored before versioning")
+	{
+		data, _ := bson.Marshal(bson.M{"_id": "1b4e28ba-2fa1-11d2-883f-0016d3cca427", "type": "Payment",
+			"attributes": bson.M{"reference": "piano lessons"}})
+
+		t.Logf("\tWhen decoding it")
+		{
+			payment, err := migration.DecodePayment(data)
+			check(t, err == nil && payment.Reference == "piano lessons" && payment.SchemaVersion == model.SchemaVersion,
+				"The payment should have been upgraded to the current schema version", payment)
+		}
+	}
+}
+
+func TestSteps_LastVersionShouldBeTheModelSchemaVersion(t *testing.T) {
+	last := migration.Steps[len(migration.Steps)-1]
+	check(t, last.Version == model.SchemaVersion, "The last step should match model.SchemaVersion", last.Version)
+	for i := 1; i < len(migration.Steps); i++ {
+		check(t, migration.Steps[i].Version > migration.Steps[i-1].Version, "The steps should be ordered", migration.Steps[i].Version)
+	}
+}
+
+func TestMigrator_UpAndDown(t *testing.T) {
+	server, err := mongotest.Start()
+	if err == mongotest.ErrUnavailable {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+	ctx := context.Background()
+	db := server.Client.Database("migration-test")
+	defer db.Drop(ctx)
+
+	t.Logf("Given documents stored before versioning")
+	{
+		db.Collection("payments").InsertMany(ctx, []interface{}{
+			bson.M{"ref": "a", "currency": "gbp"}, bson.M{"ref": "b", "currency": "usd"}})
+		migrator := &migration.Migrator{DB: db, Collection: "payments", Steps: steps, Log: os.Stdout}
+
+		t.Logf("\tWhen running a dry run")
+		{
+			migrator.DryRun = true
+			migrator.Up(ctx, 2)
+			pending, _ := migrator.Pending(ctx, 2)
+			applied, _ := migrator.Applied(ctx)
+			check(t, pending == 2 && len(applied) == 0, "Nothing should have changed", pending)
+		}
+
+		t.Logf("\tWhen migrating up")
+		{
+			migrator.DryRun = false
+			err := migrator.Up(ctx, 2)
+			pending, _ := migrator.Pending(ctx, 2)
+			applied, _ := migrator.Applied(ctx)
+			check(t, err == nil && pending == 0 && len(applied) == 2, "The documents should have been upgraded", err)
+			n, _ := db.Collection("payments").CountDocuments(ctx, bson.M{"currency": "GBP", "reference": "a"})
+			check(t, n == 1, "The steps should have been applied", n)
+		}
+
+		t.Logf("\tWhen migrating down to version 1")
+		{
+			err := migrator.Down(ctx, 1)
+			applied, _ := migrator.Applied(ctx)
+			n, _ := db.Collection("payments").CountDocuments(ctx, bson.M{"currency": "usd", migration.VersionKey: 1})
+			check(t, err == nil && len(applied) == 1 && n == 1, "The second step should have been reverted", err)
+		}
+	}
+}
+
+// Helper function returning a step renaming a key
+func rename(from, to string) func(doc bson.M) error {
+	return func(doc bson.M) error {
+		doc[to] = doc[from]
+		delete(doc, from)
+		return nil
+	}
+}
+
+// Helper function to log the outcome of an expectation
+func check(t *testing.T, ok bool, expectation string, got interface{}) {
+	if ok {
+		t.Logf("\t\t%s %v", expectation, test.CheckMark)
+	} else {
+		t.Errorf("\t\t%s %v %v", expectation, test.BallotX, got)
+	}
+}
@@ -0,0 +1,181 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MigrationsCollection the collection recording the applied steps
+const MigrationsCollection = "migrations"
+
+// Record of an applied step
+type Record struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"appliedat"`
+}
+
+// Migrator upgrades and downgrades the payment documents of a mongo collection
+type Migrator struct {
+	DB         *mongo.Database
+	Collection string
+	Steps      []Step
+
+	// DryRun reports what would be migrated without changing anything
+	DryRun bool
+
+	// Log receives a line per step, discarded when nil
+	Log io.Writer
+}
+
+// NewMigrator creates a Migrator applying Steps to the given collection
+func NewMigrator(db *mongo.Database, collection string) *Migrator {
+	return &Migrator{DB: db, Collection: collection, Steps: Steps}
+}
+
+// Applied returns the applied steps, in version order
+func (m *Migrator) Applied(ctx context.Context) ([]Record, error) {
+	cursor, err := m.DB.Collection(MigrationsCollection).Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	err = cursor.All(ctx, &records)
+	return records, err
+}
+
+// Pending counts the documents stored in a version older than the given one
+func (m *Migrator) Pending(ctx context.Context, version int) (int64, error) {
+	return m.DB.Collection(m.Collection).CountDocuments(ctx, olderThan(version))
+}
+
+// Up applies, in order, the steps up to the target version which are not recorded as applied
+func (m *Migrator) Up(ctx context.Context, target int) error {
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, step := range m.Steps {
+		if step.Version > target || applied[step.Version] {
+			continue
+		}
+		n, err := m.migrate(ctx, olderThan(step.Version), func(doc bson.M) (bool, error) {
+			return Upgrade(doc, m.Steps, step.Version)
+		})
+		if err != nil {
+			return err
+		}
+		m.logf("up %d %s: %d documents", step.Version, step.Description, n)
+		if m.DryRun {
+			continue
+		}
+		record := Record{Version: step.Version, Description: step.Description, AppliedAt: time.Now().UTC()}
+		if _, err := m.DB.Collection(MigrationsCollection).InsertOne(ctx, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Down reverts, in reverse order, the applied steps above the target version
+func (m *Migrator) Down(ctx context.Context, target int) error {
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return err
+	}
+	for i := len(m.Steps) - 1; i >= 0; i-- {
+		step := m.Steps[i]
+		if step.Version <= target || !applied[step.Version] {
+			continue
+		}
+		n, err := m.migrate(ctx, bson.M{VersionKey: step.Version}, func(doc bson.M) (bool, error) {
+			return Downgrade(doc, m.Steps[:i+1], previous(m.Steps, i))
+		})
+		if err != nil {
+			return err
+		}
+		m.logf("down %d %s: %d documents", step.Version, step.Description, n)
+		if m.DryRun {
+			continue
+		}
+		if _, err := m.DB.Collection(MigrationsCollection).DeleteOne(ctx, bson.M{"_id": step.Version}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Helper function to transform the documents matching the filter, returning how many were (or would be) changed.
+// Each document is replaced only if its version did not change meanwhile, e.g. by the service rewriting it.
+func (m *Migrator) migrate(ctx context.Context, filter bson.M, transform func(doc bson.M) (bool, error)) (int, error) {
+	col := m.DB.Collection(m.Collection)
+	cursor, err := col.Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	n := 0
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return n, err
+		}
+		guard := bson.M{"_id": doc["_id"], VersionKey: doc[VersionKey]}
+		if _, ok := doc[VersionKey]; !ok {
+			guard[VersionKey] = bson.M{"$exists": false}
+		}
+		changed, err := transform(doc)
+		if err != nil {
+			return n, err
+		}
+		if !changed {
+			continue
+		}
+		n++
+		if m.DryRun {
+			continue
+		}
+		if _, err := col.ReplaceOne(ctx, guard, doc); err != nil {
+			return n, err
+		}
+	}
+	return n, cursor.Err()
+}
+
+// Helper function to get the set of applied versions
+func (m *Migrator) appliedVersions(ctx context.Context) (map[int]bool, error) {
+	records, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int]bool)
+	for _, r := range records {
+		applied[r.Version] = true
+	}
+	return applied, nil
+}
+
+// Helper function to write a line to the log
+func (m *Migrator) logf(format string, args ...interface{}) {
+	w := m.Log
+	if w == nil {
+		w = ioutil.Discard
+	}
+	if m.DryRun {
+		format = "[dry-run] " + format
+	}
+	fmt.Fprintf(w, format+"\n", args...)
+}
+
+// Helper function to build the filter of the documents stored in a version older than the given one
+func olderThan(version int) bson.M {
+	return bson.M{"$or": []bson.M{{VersionKey: bson.M{"$exists": false}}, {VersionKey: bson.M{"$lt": version}}}}
+}
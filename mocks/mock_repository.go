@@ -5,9 +5,10 @@
 package mocks
 
 import (
-	bson "github.com/globalsign/mgo/bson"
+	context "context"
 	gomock "github.com/golang/mock/gomock"
 	model "payment-service/model"
+	repository "payment-service/repository"
 	reflect "reflect"
 )
 
@@ -34,64 +35,160 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
+// Ack mocks base method
+func (m *MockRepository) Ack(arg0 context.Context, arg1 string, arg2 ...string) error {
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Ack", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ack indicates an expected call of Ack
+func (mr *MockRepositoryMockRecorder) Ack(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ack", reflect.TypeOf((*MockRepository)(nil).Ack), varargs...)
+}
+
+// Close mocks base method
+func (m *MockRepository) Close() error {
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close
+func (mr *MockRepositoryMockRecorder) Close() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockRepository)(nil).Close))
+}
+
 // Delete mocks base method
-func (m *MockRepository) Delete(arg0, arg1 string, arg2 bson.ObjectId) error {
-	ret := m.ctrl.Call(m, "Delete", arg0, arg1, arg2)
+func (m *MockRepository) Delete(arg0 context.Context, arg1, arg2 string, arg3 model.ID) error {
+	ret := m.ctrl.Call(m, "Delete", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Delete indicates an expected call of Delete
-func (mr *MockRepositoryMockRecorder) Delete(arg0, arg1, arg2 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockRepository)(nil).Delete), arg0, arg1, arg2)
+func (mr *MockRepositoryMockRecorder) Delete(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockRepository)(nil).Delete), arg0, arg1, arg2, arg3)
+}
+
+// EnsureIndexes mocks base method
+func (m *MockRepository) EnsureIndexes(arg0 context.Context, arg1, arg2 string) error {
+	ret := m.ctrl.Call(m, "EnsureIndexes", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnsureIndexes indicates an expected call of EnsureIndexes
+func (mr *MockRepositoryMockRecorder) EnsureIndexes(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnsureIndexes", reflect.TypeOf((*MockRepository)(nil).EnsureIndexes), arg0, arg1, arg2)
 }
 
 // Find mocks base method
-func (m *MockRepository) Find(arg0, arg1 string, arg2 bson.ObjectId) (model.PaymentResponse, error) {
-	ret := m.ctrl.Call(m, "Find", arg0, arg1, arg2)
+func (m *MockRepository) Find(arg0 context.Context, arg1, arg2 string, arg3 model.ID) (model.PaymentResponse, error) {
+	ret := m.ctrl.Call(m, "Find", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(model.PaymentResponse)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Find indicates an expected call of Find
-func (mr *MockRepositoryMockRecorder) Find(arg0, arg1, arg2 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockRepository)(nil).Find), arg0, arg1, arg2)
+func (mr *MockRepositoryMockRecorder) Find(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockRepository)(nil).Find), arg0, arg1, arg2, arg3)
 }
 
 // FindAll mocks base method
-func (m *MockRepository) FindAll(arg0, arg1 string) (model.PaymentResponse, error) {
-	ret := m.ctrl.Call(m, "FindAll", arg0, arg1)
+func (m *MockRepository) FindAll(arg0 context.Context, arg1, arg2 string) (model.PaymentResponse, error) {
+	ret := m.ctrl.Call(m, "FindAll", arg0, arg1, arg2)
 	ret0, _ := ret[0].(model.PaymentResponse)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindAll indicates an expected call of FindAll
-func (mr *MockRepositoryMockRecorder) FindAll(arg0, arg1 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAll", reflect.TypeOf((*MockRepository)(nil).FindAll), arg0, arg1)
+func (mr *MockRepositoryMockRecorder) FindAll(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAll", reflect.TypeOf((*MockRepository)(nil).FindAll), arg0, arg1, arg2)
+}
+
+// FindPage mocks base method
+func (m *MockRepository) FindPage(arg0 context.Context, arg1, arg2 string, arg3 repository.Filter, arg4, arg5 int) (model.PaymentResponse, error) {
+	ret := m.ctrl.Call(m, "FindPage", arg0, arg1, arg2, arg3, arg4, arg5)
+	ret0, _ := ret[0].(model.PaymentResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindPage indicates an expected call of FindPage
+func (mr *MockRepositoryMockRecorder) FindPage(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindPage", reflect.TypeOf((*MockRepository)(nil).FindPage), arg0, arg1, arg2, arg3, arg4, arg5)
 }
 
 // Insert mocks base method
-func (m *MockRepository) Insert(arg0, arg1 string, arg2 interface{}) error {
-	ret := m.ctrl.Call(m, "Insert", arg0, arg1, arg2)
+func (m *MockRepository) Insert(arg0 context.Context, arg1, arg2 string, arg3 interface{}) error {
+	ret := m.ctrl.Call(m, "Insert", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Insert indicates an expected call of Insert
-func (mr *MockRepositoryMockRecorder) Insert(arg0, arg1, arg2 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockRepository)(nil).Insert), arg0, arg1, arg2)
+func (mr *MockRepositoryMockRecorder) Insert(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockRepository)(nil).Insert), arg0, arg1, arg2, arg3)
+}
+
+// Patch mocks base method
+func (m *MockRepository) Patch(arg0 context.Context, arg1, arg2 string, arg3 model.ID, arg4, arg5 model.Payment) error {
+	ret := m.ctrl.Call(m, "Patch", arg0, arg1, arg2, arg3, arg4, arg5)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Patch indicates an expected call of Patch
+func (mr *MockRepositoryMockRecorder) Patch(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Patch", reflect.TypeOf((*MockRepository)(nil).Patch), arg0, arg1, arg2, arg3, arg4, arg5)
+}
+
+// Pending mocks base method
+func (m *MockRepository) Pending(arg0 context.Context, arg1 string, arg2 int, arg3 ...model.ID) ([]model.Event, error) {
+	varargs := []interface{}{arg0, arg1, arg2}
+	for _, a := range arg3 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Pending", varargs...)
+	ret0, _ := ret[0].([]model.Event)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Pending indicates an expected call of Pending
+func (mr *MockRepositoryMockRecorder) Pending(arg0, arg1, arg2 interface{}, arg3 ...interface{}) *gomock.Call {
+	varargs := append([]interface{}{arg0, arg1, arg2}, arg3...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pending", reflect.TypeOf((*MockRepository)(nil).Pending), varargs...)
+}
+
+// Ping mocks base method
+func (m *MockRepository) Ping(arg0 context.Context) error {
+	ret := m.ctrl.Call(m, "Ping", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping
+func (mr *MockRepositoryMockRecorder) Ping(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockRepository)(nil).Ping), arg0)
 }
 
 // Update mocks base method
-func (m *MockRepository) Update(arg0, arg1 string, arg2 bson.ObjectId, arg3 interface{}) error {
-	ret := m.ctrl.Call(m, "Update", arg0, arg1, arg2, arg3)
+func (m *MockRepository) Update(arg0 context.Context, arg1, arg2 string, arg3 model.ID, arg4 interface{}) error {
+	ret := m.ctrl.Call(m, "Update", arg0, arg1, arg2, arg3, arg4)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Update indicates an expected call of Update
-func (mr *MockRepositoryMockRecorder) Update(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockRepository)(nil).Update), arg0, arg1, arg2, arg3)
+func (mr *MockRepositoryMockRecorder) Update(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockRepository)(nil).Update), arg0, arg1, arg2, arg3, arg4)
 }
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrCanceled returned when the client cancelled the request before it completed
+	ErrCanceled = errors.New("request canceled")
+
+	// ErrDeadlineExceeded returned when the request ran past its deadline
+	ErrDeadlineExceeded = errors.New("request deadline exceeded")
+)
+
+// ContextError translates the error of a done context into ErrCanceled or ErrDeadlineExceeded
+func ContextError(err error) error {
+	switch err {
+	case context.Canceled:
+		return ErrCanceled
+	case context.DeadlineExceeded:
+		return ErrDeadlineExceeded
+	}
+	return err
+}
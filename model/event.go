@@ -0,0 +1,62 @@
+package model
+
+import (
+	"time"
+)
+
+// Payment domain event types
+const (
+	// PaymentCreated recorded when a payment is created
+	PaymentCreated = "PaymentCreated"
+
+	// PaymentUpdated recorded when a payment is replaced or patched
+	PaymentUpdated = "PaymentUpdated"
+
+	// PaymentDeleted recorded when a payment is deleted, the event carrying the deleted payment
+	PaymentDeleted = "PaymentDeleted"
+
+	// PaymentStatusChanged recorded along PaymentUpdated when the update changes the payment status
+	PaymentStatusChanged = "PaymentStatusChanged"
+)
+
+// IsEventType tells whether the given string is one of the payment domain event types
+func IsEventType(eventType string) bool {
+	switch eventType {
+	case PaymentCreated, PaymentUpdated, PaymentDeleted, PaymentStatusChanged:
+		return true
+	}
+	return false
+}
+
+// Event a payment domain event, recorded in the outbox by the repository write which caused it
+type Event struct {
+	ID         string    `json:"id" bson:"_id"`
+	Type       string    `json:"type"`
+	PaymentID  ID        `json:"payment_id"`
+	Payment    Payment   `json:"payment"`
+	OccurredAt time.Time `json:"occurred_at"`
+
+	// PreviousStatus the status before a PaymentStatusChanged event
+	PreviousStatus string `json:"previous_status,omitempty" bson:",omitempty"`
+}
+
+// NewEvent creates an event of the given type for the payment
+func NewEvent(eventType string, payment Payment) Event {
+	return Event{ID: NewID().String(), Type: eventType, PaymentID: payment.ID, Payment: payment, OccurredAt: time.Now().UTC()}
+}
+
+// UpdateEvents returns the events recorded when the original payment is replaced by the updated one
+func UpdateEvents(original, updated Payment) []Event {
+	events := []Event{NewEvent(PaymentUpdated, updated)}
+	if original.Status != updated.Status {
+		changed := NewEvent(PaymentStatusChanged, updated)
+		changed.PreviousStatus = original.Status
+		events = append(events, changed)
+	}
+	return events
+}
+
+// EventResponse the recorded events of a payment, oldest first
+type EventResponse struct {
+	Data []Event `json:"data"`
+}
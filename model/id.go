@@ -0,0 +1,76 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidID returned when a string is neither an ObjectId nor a UUID
+var ErrInvalidID = errors.New("invalid payment identifier")
+
+var uuidPattern = regexp.MustCompile("^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$")
+
+// ID the payment identifier - either the hex form of a Mongo ObjectId or a client supplied UUID
+type ID string
+
+// NewID generates a new ObjectId based payment identifier
+func NewID() ID {
+	return ID(primitive.NewObjectID().Hex())
+}
+
+// ParseID validates the given string and returns it as a payment identifier
+func ParseID(s string) (ID, error) {
+	id := ID(strings.ToLower(s))
+	if id.IsObjectId() || uuidPattern.MatchString(string(id)) {
+		return id, nil
+	}
+	return "", ErrInvalidID
+}
+
+// IsObjectId reports whether the identifier is an ObjectId
+func (id ID) IsObjectId() bool {
+	_, err := primitive.ObjectIDFromHex(string(id))
+	return err == nil
+}
+
+// String returns the identifier as a string
+func (id ID) String() string {
+	return string(id)
+}
+
+// Less orders the identifiers the way mongo sorts the stored ones, the UUIDs stored as strings coming before the
+// ObjectIds, each in the order of their string form
+func (id ID) Less(other ID) bool {
+	if id.IsObjectId() != other.IsObjectId() {
+		return !id.IsObjectId()
+	}
+	return id < other
+}
+
+// MarshalBSONValue stores ObjectId identifiers as native ObjectIds and anything else as a string
+func (id ID) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	if oid, err := primitive.ObjectIDFromHex(string(id)); err == nil {
+		return bson.MarshalValue(oid)
+	}
+	return bson.MarshalValue(string(id))
+}
+
+// UnmarshalBSONValue reads an identifier stored either as an ObjectId or as a string
+func (id *ID) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	raw := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bsontype.ObjectID:
+		*id = ID(raw.ObjectID().Hex())
+	case bsontype.String:
+		*id = ID(raw.StringValue())
+	default:
+		return fmt.Errorf("cannot decode %s into a payment ID", t)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package model_test
+
+import (
+	"payment-service/model"
+	"payment-service/test"
+	"testing"
+)
+
+func TestParseID_ShouldAcceptObjectIdAndUUID(t *testing.T) {
+	t.Logf("Given the need to validate payment identifiers")
+	{
+		for _, id := range []string{"5bd7506a9900b30008edf576", "5BD7506A9900B30008EDF576", "743d5b63-8e6f-432e-a8fa-c5d8d2ee5fcb"} {
+			t.Logf("\tWhen parsing \"%s\"", id)
+			{
+				if _, err := model.ParseID(id); err == nil {
+					t.Logf("\t\tThe identifier should be valid %v", test.CheckMark)
+				} else {
+					t.Errorf("\t\tThe identifier should be valid %v %v", test.BallotX, err)
+				}
+			}
+		}
+	}
+}
+
+func TestParseID_ShouldRejectMalformedIdentifiers(t *testing.T) {
+	t.Logf("Given the need to validate payment identifiers")
+	{
+		for _, id := range []string{"", "not-an-id", "5bd7506a9900b30008edf57", "743d5b638e6f432ea8fac5d8d2ee5fcb"} {
+			t.Logf("\tWhen parsing \"%s\"", id)
+			{
+				if _, err := model.ParseID(id); err == model.ErrInvalidID {
+					t.Logf("\t\tThe identifier should be rejected %v", test.CheckMark)
+				} else {
+					t.Errorf("\t\tThe identifier should be rejected %v %v", test.BallotX, err)
+				}
+			}
+		}
+	}
+}
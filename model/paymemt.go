@@ -2,10 +2,11 @@ package model
 
 import (
 	"time"
-
-	"github.com/globalsign/mgo/bson"
 )
 
+// SchemaVersion the version of the stored payment documents written by this code, bumped with each migration step
+const SchemaVersion = 1
+
 // PaymentResponse type
 type PaymentResponse struct {
 	Data  []Payment `json:"data"`
@@ -15,7 +16,8 @@ type PaymentResponse struct {
 // ErrorResponse generic error response
 type ErrorResponse struct {
 	Message string `json:"message"`
-	Code    int    `json:code`
+	Code    int    `json:"Code"`
+	TraceID string `json:"trace_id,omitempty"`
 }
 
 // HealthResponse the health json response
@@ -25,6 +27,7 @@ type HealthResponse struct {
 
 // CreatePaymentRequest paylod for creating payment
 type CreatePaymentRequest struct {
+	ID                   string       `json:"id"`
 	OrganisationID       string       `json:"organisation_id" binding:"required"`
 	BeneficiaryParty     Party        `json:"beneficiary_party" binding:"required"`
 	DebtorParty          Party        `json:"debtor_party" binding:"required"`
@@ -51,29 +54,36 @@ type CreatePaymentResponse struct {
 
 // Links containing hyper media link
 type Links struct {
-	Self string `json: "self"`
+	Self string `json:"Self"`
+
+	// Next the link of the next page of a paginated list, empty on the last page
+	Next string `json:"next,omitempty"`
 }
 
 // Payment type
 type Payment struct {
-	Type           string        `json:"type"`
-	ID             bson.ObjectId `json:"id" bson:"_id,omitempty"`
-	Version        int           `json:"version"`
-	OrganisationId string        `json:"organisation_id"`
-	Attributes     `json: "attributes"`
+	Type           string `json:"type"`
+	ID             ID     `json:"id" bson:"_id,omitempty"`
+	Version        int    `json:"version"`
+	OrganisationId string `json:"organisation_id"`
+	Status         string `json:"status,omitempty" bson:"status,omitempty"`
+	Attributes
+
+	// SchemaVersion the version of the stored document shape, zero for documents stored before versioning
+	SchemaVersion int `json:"-" bson:"schemaversion,omitempty"`
 }
 
 // Attributes payment attributes
 type Attributes struct {
 	Amount               float64            `json:"amount"`
-	BeneficiaryParty     Party              `json:"beneficiary_party`
+	BeneficiaryParty     Party              `json:"BeneficiaryParty"`
 	ChargesInformation   ChargesInformation `json:"charges_information"`
 	Currency             string             `json:"currency"`
 	DebtorParty          Party              `json:"debtor_party"`
 	EndToEndReference    string             `json:"end_to_end_reference"`
-	Fx                   ForeignExchange    `json:"fx", omitempty`
+	Fx                   ForeignExchange    `json:"fx"`
 	NumericReference     string             `json:"numeric_reference"`
-	PaymentID            string             `json:"payment_id"`
+	PaymentID            string             `json:"payment_id" bson:"paymentid,omitempty"`
 	PaymentPurpose       string             `json:"payment_purpose"`
 	PaymentScheme        string             `json:"payment_scheme"`
 	PaymentType          string             `json:"payment_type"`
@@ -91,17 +101,17 @@ type SponsorParty struct {
 	BankIDCode    string `json:"bank_id_code"`
 }
 
-//Party party type to hold beneficiary or debtor details
+//Party party type to hold beneficiary or debtor details, the JSON keys being the ones the API has always used
 type Party struct {
-	AccountName       string `json:"account_name`
-	AccountNumber     string `json:"account_number`
+	AccountName       string `json:"AccountName"`
+	AccountNumber     string `json:"AccountNumber"`
 	AccountNumberCode string `json:"account_number_code"`
-	AccountType       int    `json:"account_type", omitempty`
+	AccountType       int    `json:"account_type"`
 	Address           string `json:"address"`
 	BankID            string `json:"bank_id:"`
 	BankIDCode        string `json:"bank_id_code"`
 	Name              string `json:"name"`
-	Currency          string `json:"currency", omitempty`
+	Currency          string `json:"currency"`
 }
 
 // Charge type
@@ -112,8 +122,8 @@ type Charge struct {
 
 // ChargesInformation type to hold bank charges details
 type ChargesInformation struct {
-	BearerCode              string   `json:"bearer_code`
-	SenderCharges           []Charge `json:"sender_charges`
+	BearerCode              string   `json:"BearerCode"`
+	SenderCharges           []Charge `json:"SenderCharges"`
 	ReceiverChargesAmount   float64  `json:"receiver_charges_amount"`
 	ReceiverChargesCurrency string   `json:"receiver_charges_currency"`
 }
@@ -0,0 +1,10 @@
+package model
+
+// Payment statuses. Payments created without a status are pending.
+const (
+	StatusPending   = "pending"
+	StatusAccepted  = "accepted"
+	StatusRejected  = "rejected"
+	StatusSettled   = "settled"
+	StatusCancelled = "cancelled"
+)
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"time"
+)
+
+// Delivery statuses
+const (
+	// DeliveryPending the delivery is waiting for its next attempt
+	DeliveryPending = "pending"
+
+	// DeliverySucceeded the endpoint acknowledged the delivery with a 2xx status
+	DeliverySucceeded = "succeeded"
+
+	// DeliveryDead the delivery failed too many times and is no longer attempted until redelivered
+	DeliveryDead = "dead"
+)
+
+// Webhook an organisation endpoint notified of the events of its payments
+type Webhook struct {
+	ID             string    `json:"id" bson:"_id"`
+	OrganisationID string    `json:"organisation_id"`
+	URL            string    `json:"url"`
+	EventTypes     []string  `json:"event_types,omitempty"`
+	Secret         string    `json:"secret,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Subscribed tells whether the webhook is notified of the given event type, all types when none is listed
+func (w Webhook) Subscribed(eventType string) bool {
+	if len(w.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range w.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateWebhookRequest payload for subscribing an endpoint, the secret being generated when missing
+type CreateWebhookRequest struct {
+	OrganisationID string   `json:"organisation_id" binding:"required"`
+	URL            string   `json:"url" binding:"required"`
+	EventTypes     []string `json:"event_types"`
+	Secret         string   `json:"secret"`
+}
+
+// WebhookResponse the webhooks of an organisation, their secrets omitted
+type WebhookResponse struct {
+	Data []Webhook `json:"data"`
+}
+
+// Delivery an event to deliver to a webhook, and the outcome of the attempts so far
+type Delivery struct {
+	ID             string     `json:"id" bson:"_id"`
+	WebhookID      string     `json:"webhook_id"`
+	OrganisationID string     `json:"organisation_id"`
+	EventID        string     `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Payload        []byte     `json:"-"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttempt    time.Time  `json:"next_attempt"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty" bson:",omitempty"`
+
+	// Lease identifies the claim of the worker attempting the delivery, empty when no worker holds it, and LeasedBy
+	// that worker. A claim expires at the NextAttempt it pushed back.
+	Lease    string `json:"-" bson:",omitempty"`
+	LeasedBy string `json:"-" bson:",omitempty"`
+}
+
+// DeliveryResponse the delivery log of a webhook
+type DeliveryResponse struct {
+	Data []Delivery `json:"data"`
+}
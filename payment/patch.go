@@ -0,0 +1,143 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"payment-service/model"
+)
+
+// Patch applies a JSON merge patch (RFC 7396) to the payment with the given ID or payment_id, as represented by Get.
+// FX and charges are only recalculated when the amount, currencies or bearer code change, and cannot be patched
+// otherwise. The amount is the one in the beneficiary currency: a patched amount is stored as sent, its original amount
+// in the debtor currency following the exchange rate.
+func (s *Service) Patch(ctx context.Context, id model.ID, patch []byte) (model.PaymentResponse, error) {
+	resp, err := s.Repo.Find(ctx, s.DB, s.Collection, id)
+	if err != nil {
+		return model.PaymentResponse{}, err
+	}
+
+	original := resp.Data[0]
+	patched, err := applyMergePatch(original, patch)
+	if err != nil {
+		return model.PaymentResponse{}, ErrInvalidPatch
+	}
+	if patched.ID != original.ID || patched.Type != original.Type || patched.Version != original.Version {
+		return model.PaymentResponse{}, ErrImmutableField
+	}
+	if calculatedChanged(original, patched) {
+		return model.PaymentResponse{}, ErrCalculatedField
+	}
+
+	// the patched payment must still be a valid payment request
+	req := paymentRequest(patched)
+	if err := validate(req); err != nil {
+		return model.PaymentResponse{}, err
+	}
+
+	if pricingChanged(original, patched) {
+		attr, err := s.price(ctx, req)
+		if err != nil {
+			return model.PaymentResponse{}, err
+		}
+		if patched.Amount != original.Amount {
+			attr.Amount = patched.Amount
+			if foreignExchangeRequired(req) {
+				attr.Fx.OriginalAmount = patched.Amount * attr.Fx.ExchangeRate
+			}
+		}
+		patched.Attributes = attr
+	}
+
+	if err := s.Repo.Patch(ctx, s.DB, s.Collection, original.ID, original, patched); err != nil {
+		return model.PaymentResponse{}, err
+	}
+	return model.PaymentResponse{Data: []model.Payment{patched}, Links: resp.Links}, nil
+}
+
+// Helper function to apply a JSON merge patch to the JSON representation of the payment, the members unknown to the
+// representation being rejected rather than ignored
+func applyMergePatch(payment model.Payment, patch []byte) (model.Payment, error) {
+	var doc, p interface{}
+	data, err := json.Marshal(payment)
+	if err != nil {
+		return payment, err
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return payment, err
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return payment, err
+	}
+	// a patch which is not an object would replace the whole payment
+	if _, ok := p.(map[string]interface{}); !ok {
+		return payment, ErrInvalidPatch
+	}
+
+	merged, err := json.Marshal(mergePatch(doc, p))
+	if err != nil {
+		return payment, err
+	}
+	var patched model.Payment
+	dec := json.NewDecoder(bytes.NewReader(merged))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&patched)
+	// not part of the JSON representation
+	patched.SchemaVersion = payment.SchemaVersion
+	return patched, err
+}
+
+// Helper function implementing the RFC 7396 merge algorithm
+func mergePatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+	for k, v := range patchObj {
+		if v == nil {
+			delete(targetObj, k)
+			continue
+		}
+		targetObj[k] = mergePatch(targetObj[k], v)
+	}
+	return targetObj
+}
+
+// Helper function to determine if the patch touched the fields calculated by pricing, which only the bearer code of
+// the charges drives
+func calculatedChanged(original, patched model.Payment) bool {
+	charges := patched.ChargesInformation
+	charges.BearerCode = original.ChargesInformation.BearerCode
+	return original.Currency != patched.Currency || original.Fx != patched.Fx ||
+		!reflect.DeepEqual(original.ChargesInformation, charges)
+}
+
+// Helper function to determine if the patch touched the fields driving FX and charges
+func pricingChanged(original, patched model.Payment) bool {
+	return original.Amount != patched.Amount ||
+		original.BeneficiaryParty.Currency != patched.BeneficiaryParty.Currency ||
+		original.DebtorParty.Currency != patched.DebtorParty.Currency ||
+		original.ChargesInformation.BearerCode != patched.ChargesInformation.BearerCode
+}
+
+// Helper function to rebuild the request a payment would have been created from
+func paymentRequest(patched model.Payment) model.CreatePaymentRequest {
+	// the stored amount is converted, so get back the amount before the exchange at the stored rate
+	amount := patched.Amount
+	if patched.Fx.ExchangeRate != 0 {
+		amount = patched.Amount * patched.Fx.ExchangeRate
+	}
+	return model.CreatePaymentRequest{ID: patched.ID.String(), OrganisationID: patched.OrganisationId,
+		BeneficiaryParty: patched.BeneficiaryParty, DebtorParty: patched.DebtorParty, PaymentPurpose: patched.PaymentPurpose,
+		PaymentScheme: patched.PaymentScheme, PaymentType: patched.PaymentType, Reference: patched.Reference,
+		EndToEndReference: patched.EndToEndReference, SchemePaymentSubType: patched.SchemePaymentSubType,
+		SchemePaymentType: patched.SchemePaymentType, SponsorParty: patched.SponsorParty, NumericReference: patched.NumericReference,
+		PaymentID: patched.PaymentID, Amount: amount, BearerCode: patched.ChargesInformation.BearerCode,
+		ProcessingDate: patched.ProcessingDate}
+}
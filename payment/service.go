@@ -0,0 +1,245 @@
+// Package payment implements the payment use cases independently of the transport, shared by the REST and gRPC APIs.
+package payment
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"payment-service/metrics"
+	"payment-service/model"
+	"payment-service/repository"
+	"payment-service/service"
+	"payment-service/tracing"
+)
+
+var (
+	// ErrNotFound returned when no payment matches the given ID
+	ErrNotFound = repository.ErrNotFound
+
+	// ErrDuplicate returned when a payment with the same ID or payment_id already exists
+	ErrDuplicate = repository.ErrDuplicate
+
+	// ErrInvalidID returned when a client supplied payment ID is not a UUID
+	ErrInvalidID = errors.New("invalid payment ID")
+
+	// ErrIDMismatch returned when the ID of a replacing payment differs from the replaced one
+	ErrIDMismatch = errors.New("payment ID does not match")
+
+
+	// ErrInvalidRequest returned when a payment request misses a required field
+	ErrInvalidRequest = errors.New("invalid payment request")
+
+	// ErrInvalidPatch returned when a merge patch is not a JSON object or does not apply to a payment
+	ErrInvalidPatch = errors.New("invalid payment patch")
+
+	// ErrInvalidPage returned when a page number is negative or a page size is not positive
+	ErrInvalidPage = errors.New("invalid page")
+
+	// ErrImmutableField returned when a merge patch changes the id, type or version of a payment
+	ErrImmutableField = errors.New("payment id, type and version cannot be patched")
+
+	// ErrCalculatedField returned when a merge patch changes the currency, FX or charges calculated for a payment, the
+	// bearer code aside
+	ErrCalculatedField = errors.New("payment currency, fx and charges are calculated and cannot be patched")
+)
+
+// PricingError returned when the exchange rate or the charges of a payment cannot be obtained
+type PricingError struct {
+	Err error
+}
+
+// Error returns the message of the underlying error
+func (e PricingError) Error() string {
+	return "failed to price payment: " + e.Err.Error()
+}
+
+// Service the payment use cases, storing the payments in the given database and collection
+type Service struct {
+	Repo       repository.Repository
+	FX         service.FXService
+	Charges    service.ChargesService
+	DB         string
+	Collection string
+}
+
+// NewService creates a Service
+func NewService(repo repository.Repository, fx service.FXService, charges service.ChargesService, db, col string) *Service {
+	return &Service{Repo: repo, FX: fx, Charges: charges, DB: db, Collection: col}
+}
+
+// Create prices and stores a new payment, its ID being the client supplied UUID when present
+func (s *Service) Create(ctx context.Context, req model.CreatePaymentRequest) (model.Payment, error) {
+	if err := validate(req); err != nil {
+		return model.Payment{}, err
+	}
+	id := model.NewID()
+	if req.ID != "" {
+		clientID, err := model.ParseID(req.ID)
+		if err != nil || clientID.IsObjectId() {
+			return model.Payment{}, ErrInvalidID
+		}
+		id = clientID
+	}
+
+	attr, err := s.price(ctx, req)
+	if err != nil {
+		return model.Payment{}, err
+	}
+	payment := newPayment(id, req, attr)
+	if err := s.Repo.Insert(ctx, s.DB, s.Collection, payment); err != nil {
+		return payment, err
+	}
+	metrics.PaymentCreated(payment)
+	return payment, nil
+}
+
+// Get returns the payment with the given ID or payment_id
+func (s *Service) Get(ctx context.Context, id model.ID) (model.PaymentResponse, error) {
+	return s.Repo.Find(ctx, s.DB, s.Collection, id)
+}
+
+// List returns all the payments
+func (s *Service) List(ctx context.Context) (model.PaymentResponse, error) {
+	return s.Repo.FindAll(ctx, s.DB, s.Collection)
+}
+
+// Page selects a page of the listed payments, numbered from zero, among the payments matching the filter
+type Page struct {
+	Number int
+	Size   int
+	Filter repository.Filter
+}
+
+// ListPage returns a page of the payments matching the filter of the page ordered by ID, more telling whether later pages hold payments. The page is
+// queried along the first payment of the next one, which tells whether there is one.
+func (s *Service) ListPage(ctx context.Context, page Page) (resp model.PaymentResponse, more bool, err error) {
+	if page.Number < 0 || page.Size <= 0 || page.Size >= math.MaxInt32 || page.Number > math.MaxInt32/page.Size {
+		return model.PaymentResponse{}, false, ErrInvalidPage
+	}
+	resp, err = s.Repo.FindPage(ctx, s.DB, s.Collection, page.Filter, page.Number*page.Size, page.Size+1)
+	if err != nil {
+		return model.PaymentResponse{}, false, err
+	}
+	if more = len(resp.Data) > page.Size; more {
+		resp.Data = resp.Data[:page.Size]
+	}
+	return resp, more, nil
+}
+
+// Delete deletes the payment with the given ID
+func (s *Service) Delete(ctx context.Context, id model.ID) error {
+	if _, err := s.Repo.Find(ctx, s.DB, s.Collection, id); err != nil {
+		return err
+	}
+	return s.Repo.Delete(ctx, s.DB, s.Collection, id)
+}
+
+// Update prices the request and replaces the payment with the given ID or payment_id, keeping its status. A missing
+// payment whose ID is a UUID is created instead, created being true.
+func (s *Service) Update(ctx context.Context, id model.ID, req model.CreatePaymentRequest) (payment model.Payment, created bool, err error) {
+	if bodyID, err := model.ParseID(req.ID); req.ID != "" && (err != nil || bodyID != id) {
+		return model.Payment{}, false, ErrIDMismatch
+	}
+	if err := validate(req); err != nil {
+		return model.Payment{}, false, err
+	}
+
+	// the ID may be a payment_id alias so the stored payment tells which resource to replace. It is looked up first for
+	// the unknown payments to be refused without being priced.
+	resp, err := s.Repo.Find(ctx, s.DB, s.Collection, id)
+	missing := err == ErrNotFound && !id.IsObjectId()
+	if err != nil && !missing {
+		return model.Payment{}, false, err
+	}
+
+	attr, err := s.price(ctx, req)
+	if err != nil {
+		return model.Payment{}, false, err
+	}
+	if missing {
+		payment = newPayment(id, req, attr)
+		if err := s.Repo.Insert(ctx, s.DB, s.Collection, payment); err != nil {
+			return payment, true, err
+		}
+		metrics.PaymentCreated(payment)
+		return payment, true, nil
+	}
+
+	payment = newPayment(resp.Data[0].ID, req, attr)
+	payment.Status = resp.Data[0].Status
+	return payment, false, s.Repo.Update(ctx, s.DB, s.Collection, payment.ID, payment)
+}
+
+// Helper function to get the exchange rate and charges of the payment request and build the attributes of the
+// payment, the amount being converted into the beneficiary currency. The calls are recorded by the metrics and traced.
+func (s *Service) price(ctx context.Context, req model.CreatePaymentRequest) (model.Attributes, error) {
+	fx := model.ForeignExchange{ExchangeRate: 1.0}
+
+	if foreignExchangeRequired(req) {
+		fxCtx, span := tracing.Start(ctx, "fx GetExchangeRate", trace.WithSpanKind(trace.SpanKindClient))
+		start := time.Now()
+		err, rate := s.FX.GetExchangeRate(fxCtx, req.BeneficiaryParty.Currency, req.DebtorParty.Currency, req.Amount)
+		metrics.ObserveUpstream(metrics.UpstreamFX, start, err)
+		tracing.End(span, err)
+		if err != nil {
+			return model.Attributes{}, pricingError(err)
+		}
+		fx = rate
+	}
+
+	chargesCtx, span := tracing.Start(ctx, "charges GetCharges", trace.WithSpanKind(trace.SpanKindClient))
+	start := time.Now()
+	err, charges := s.Charges.GetCharges(chargesCtx, fx.ExchangeRate, req.BearerCode, req.BeneficiaryParty.Currency, req.DebtorParty.Currency)
+	metrics.ObserveUpstream(metrics.UpstreamCharges, start, err)
+	tracing.End(span, err)
+	if err != nil {
+		return model.Attributes{}, pricingError(err)
+	}
+	return buildAttr(getAmount(req.Amount, fx.ExchangeRate), req, charges, fx), nil
+}
+
+// Helper function to wrap the failure of the FX or charges service, cancellations being returned as they are
+func pricingError(err error) error {
+	if err == model.ErrCanceled || err == model.ErrDeadlineExceeded {
+		return err
+	}
+	return PricingError{err}
+}
+
+// Helper function to check the fields a payment request requires
+func validate(req model.CreatePaymentRequest) error {
+	if req.OrganisationID == "" || req.Amount == 0 || req.BearerCode == "" ||
+		req.BeneficiaryParty == (model.Party{}) || req.DebtorParty == (model.Party{}) {
+		return ErrInvalidRequest
+	}
+	return nil
+}
+
+// Helper function to build payment instance
+func newPayment(id model.ID, req model.CreatePaymentRequest, attr model.Attributes) model.Payment {
+	return model.Payment{Type: "Payment", ID: id, OrganisationId: req.OrganisationID, Attributes: attr, Version: 0,
+		SchemaVersion: model.SchemaVersion}
+}
+
+// Helper function to calculate the new amount based on the given exchange rate
+func getAmount(amount float64, rate float64) float64 {
+	return amount / rate
+}
+
+// Helper function to determine if foreign exchange to this payment is relevant
+func foreignExchangeRequired(req model.CreatePaymentRequest) bool {
+	return req.DebtorParty.Currency != req.BeneficiaryParty.Currency
+}
+
+// Helper function to build payment attributes
+func buildAttr(amount float64, req model.CreatePaymentRequest, charges model.ChargesInformation, fx model.ForeignExchange) model.Attributes {
+	attr := model.Attributes{Amount: amount, BeneficiaryParty: req.BeneficiaryParty, DebtorParty: req.DebtorParty,
+		ChargesInformation: charges, Currency: req.BeneficiaryParty.Currency, EndToEndReference: req.EndToEndReference,
+		Fx: fx, NumericReference: req.NumericReference, PaymentID: req.PaymentID, PaymentPurpose: req.PaymentPurpose,
+		PaymentScheme: req.PaymentScheme, PaymentType: req.PaymentType, ProcessingDate: req.ProcessingDate, Reference: req.Reference,
+		SchemePaymentSubType: req.SchemePaymentSubType, SchemePaymentType: req.SchemePaymentType, SponsorParty: req.SponsorParty}
+	return attr
+}
@@ -0,0 +1,275 @@
+package payment_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"payment-service/mocks"
+	"payment-service/model"
+	"payment-service/payment"
+	"payment-service/repository"
+	"payment-service/service"
+	"payment-service/test"
+)
+
+const (
+	db  = "PaymentDB"
+	col = "Payment"
+
+	uuid = "9b5b6e3a-1a3c-4d9e-8b1e-0f6f1c2d3e4f"
+)
+
+func TestService_Create(t *testing.T) {
+	t.Logf("Given the need to create payments")
+	{
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		repo := mocks.NewMockRepository(ctrl)
+		payments := newService(repo)
+
+		t.Logf("\tWhen the beneficiary currency differs from the debtor one")
+		{
+			req := test.CreatePaymentRequest("31926819", "GB29XABC10161234567801", "USD")
+			repo.EXPECT().Insert(gomock.Any(), db, col, gomock.Any()).Return(nil).Times(1)
+			p, err := payments.Create(context.Background(), req)
+			check(t, err == nil && p.ID != "" && p.Status == "", "The payment should have been stored", err)
+			check(t, p.Fx.ExchangeRate == 2 && p.Amount == req.Amount/2 && p.Currency == "USD",
+				"The amount should have been converted into the beneficiary currency", p.Attributes)
+			check(t, len(p.ChargesInformation.SenderCharges) == 2 && p.ChargesInformation.BearerCode == req.BearerCode,
+				"The charges should have been added", p.ChargesInformation)
+		}
+
+		t.Logf("\tWhen both currencies are the same")
+		{
+			req := test.CreatePaymentRequest("31926819", "GB29XABC10161234567801", "GBP")
+			req.ID = uuid
+			repo.EXPECT().Insert(gomock.Any(), db, col, gomock.Any()).Return(nil).Times(1)
+			p, err := payments.Create(context.Background(), req)
+			check(t, err == nil && p.ID == uuid, "The client chosen ID should have been used", p.ID)
+			check(t, p.Fx.ExchangeRate == 1 && p.Amount == req.Amount, "No exchange should have been applied", p.Attributes)
+		}
+
+		t.Logf("\tWhen the request is invalid")
+		{
+			req := test.CreatePaymentRequest("31926819", "GB29XABC10161234567801", "GBP")
+			req.ID = model.NewID().String()
+			_, err := payments.Create(context.Background(), req)
+			check(t, err == payment.ErrInvalidID, "A client chosen ObjectId should be refused", err)
+
+			req = test.CreatePaymentRequest("31926819", "GB29XABC10161234567801", "GBP")
+			req.BearerCode = ""
+			_, err = payments.Create(context.Background(), req)
+			check(t, err == payment.ErrInvalidRequest, "A missing bearer code should be refused", err)
+		}
+
+		t.Logf("\tWhen the request is cancelled")
+		{
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := payments.Create(ctx, test.CreatePaymentRequest("31926819", "GB29XABC10161234567801", "USD"))
+			check(t, err == model.ErrCanceled, "The cancellation should be returned", err)
+		}
+
+		t.Logf("\tWhen the payment already exists")
+		{
+			repo.EXPECT().Insert(gomock.Any(), db, col, gomock.Any()).Return(payment.ErrDuplicate).Times(1)
+			_, err := payments.Create(context.Background(), test.CreatePaymentRequest("31926819", "GB29XABC10161234567801", "GBP"))
+			check(t, err == payment.ErrDuplicate, "The duplicate should be returned", err)
+		}
+	}
+}
+
+func TestService_Update(t *testing.T) {
+	t.Logf("Given the need to replace payments")
+	{
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		repo := mocks.NewMockRepository(ctrl)
+		payments := newService(repo)
+		stored := storedPayment()
+		stored.Status = model.StatusAccepted
+
+		t.Logf("\tWhen replacing a stored payment through its payment_id")
+		{
+			req := test.CreatePaymentRequest("31926819", "GB29XABC10161234567801", "GBP")
+			req.Reference = "Replaced"
+			repo.EXPECT().Find(gomock.Any(), db, col, model.ID(uuid)).Return(model.PaymentResponse{Data: []model.Payment{stored}}, nil)
+			repo.EXPECT().Update(gomock.Any(), db, col, stored.ID, gomock.Any()).Return(nil).Times(1)
+			p, created, err := payments.Update(context.Background(), uuid, req)
+			check(t, err == nil && !created, "The payment should have been replaced", err)
+			check(t, p.ID == stored.ID && p.Status == model.StatusAccepted && p.Reference == "Replaced",
+				"The stored payment should have been replaced, keeping its status", p)
+		}
+
+		t.Logf("\tWhen the payment does not exist")
+		{
+			req := test.CreatePaymentRequest("31926819", "GB29XABC10161234567801", "GBP")
+			repo.EXPECT().Find(gomock.Any(), db, col, model.ID(uuid)).Return(model.PaymentResponse{}, payment.ErrNotFound)
+			repo.EXPECT().Insert(gomock.Any(), db, col, gomock.Any()).Return(nil).Times(1)
+			p, created, err := payments.Update(context.Background(), uuid, req)
+			check(t, err == nil && created && p.ID == uuid, "A payment with a UUID should have been created", err)
+
+			// pricing fails with the cancelled context, the payment not being priced when it cannot be created
+			id := model.NewID()
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			repo.EXPECT().Find(gomock.Any(), db, col, id).Return(model.PaymentResponse{}, payment.ErrNotFound)
+			_, _, err = payments.Update(ctx, id, req)
+			check(t, err == payment.ErrNotFound, "A payment with an ObjectId should not be found, nor priced", err)
+		}
+
+		t.Logf("\tWhen the body holds another ID")
+		{
+			req := test.CreatePaymentRequest("31926819", "GB29XABC10161234567801", "GBP")
+			req.ID = model.NewID().String()
+			_, _, err := payments.Update(context.Background(), uuid, req)
+			check(t, err == payment.ErrIDMismatch, "The mismatch should be refused", err)
+		}
+	}
+}
+
+func TestService_GetListDelete(t *testing.T) {
+	t.Logf("Given a stored payment")
+	{
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		repo := mocks.NewMockRepository(ctrl)
+		payments := newService(repo)
+		stored := storedPayment()
+
+		t.Logf("\tWhen querying and deleting it")
+		{
+			repo.EXPECT().Find(gomock.Any(), db, col, stored.ID).Return(model.PaymentResponse{Data: []model.Payment{stored}}, nil).Times(2)
+			repo.EXPECT().FindAll(gomock.Any(), db, col).Return(model.PaymentResponse{Data: []model.Payment{stored}}, nil)
+			repo.EXPECT().Delete(gomock.Any(), db, col, stored.ID).Return(nil).Times(1)
+
+			resp, err := payments.Get(context.Background(), stored.ID)
+			check(t, err == nil && resp.Data[0].ID == stored.ID, "The payment should be found", err)
+			resp, err = payments.List(context.Background())
+			check(t, err == nil && len(resp.Data) == 1, "The payment should be listed", err)
+			err = payments.Delete(context.Background(), stored.ID)
+			check(t, err == nil, "The payment should have been deleted", err)
+		}
+
+		t.Logf("\tWhen deleting a missing payment")
+		{
+			id := model.NewID()
+			repo.EXPECT().Find(gomock.Any(), db, col, id).Return(model.PaymentResponse{}, payment.ErrNotFound)
+			err := payments.Delete(context.Background(), id)
+			check(t, err == payment.ErrNotFound, "The payment should not be found", err)
+		}
+	}
+}
+
+func TestService_ListPage(t *testing.T) {
+	t.Logf("Given three stored payments")
+	{
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		repo := mocks.NewMockRepository(ctrl)
+		payments := newService(repo)
+		stored := []model.Payment{storedPayment(), storedPayment(), storedPayment()}
+
+		t.Logf("\tWhen listing them two at a time")
+		{
+			repo.EXPECT().FindPage(gomock.Any(), db, col, repository.Filter{}, 0, 3).Return(model.PaymentResponse{Data: stored}, nil)
+			repo.EXPECT().FindPage(gomock.Any(), db, col, repository.Filter{}, 2, 3).Return(model.PaymentResponse{Data: stored[2:]}, nil)
+
+			resp, more, err := payments.ListPage(context.Background(), payment.Page{Number: 0, Size: 2})
+			check(t, err == nil && len(resp.Data) == 2 && more, "The first page should be followed by another", resp.Data)
+			resp, more, err = payments.ListPage(context.Background(), payment.Page{Number: 1, Size: 2})
+			check(t, err == nil && len(resp.Data) == 1 && !more, "The second page should be the last", resp.Data)
+		}
+
+		t.Logf("\tWhen listing invalid pages")
+		{
+			for _, page := range []payment.Page{{Number: -1, Size: 2}, {Number: 0, Size: 0}, {Number: 1 << 40, Size: 2}} {
+				_, _, err := payments.ListPage(context.Background(), page)
+				check(t, err == payment.ErrInvalidPage, "The page should be refused without querying the payments", page)
+			}
+		}
+	}
+}
+
+func TestService_Patch(t *testing.T) {
+	t.Logf("Given a stored payment")
+	{
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		repo := mocks.NewMockRepository(ctrl)
+		payments := newService(repo)
+		original := storedPayment()
+		repo.EXPECT().Find(gomock.Any(), db, col, original.ID).Return(model.PaymentResponse{Data: []model.Payment{original}}, nil).AnyTimes()
+
+		t.Logf("\tWhen patching its reference")
+		{
+			expected := original
+			expected.Reference = "New reference"
+			repo.EXPECT().Patch(gomock.Any(), db, col, original.ID, original, expected).Return(nil).Times(1)
+			resp, err := payments.Patch(context.Background(), original.ID, []byte(`{"reference": "New reference"}`))
+			check(t, err == nil && resp.Data[0].Fx == original.Fx, "The payment should be patched without repricing", err)
+		}
+
+		t.Logf("\tWhen patching its amount")
+		{
+			repo.EXPECT().Patch(gomock.Any(), db, col, original.ID, original, gomock.Any()).Return(nil).Times(1)
+			resp, err := payments.Patch(context.Background(), original.ID, []byte(`{"amount": 50}`))
+			check(t, err == nil && resp.Data[0].Amount == 50 && resp.Data[0].Fx.ExchangeRate == 2,
+				"The amount should be stored as sent", resp)
+			check(t, resp.Data[0].Fx.OriginalAmount == 100, "The original amount should follow the exchange rate", resp.Data[0].Fx)
+		}
+
+		t.Logf("\tWhen patching its bearer code")
+		{
+			repo.EXPECT().Patch(gomock.Any(), db, col, original.ID, original, gomock.Any()).Return(nil).Times(1)
+			resp, err := payments.Patch(context.Background(), original.ID, []byte(`{"charges_information": {"BearerCode": "OUR"}}`))
+			check(t, err == nil && resp.Data[0].ChargesInformation.BearerCode == "OUR" && len(resp.Data[0].ChargesInformation.SenderCharges) == 2,
+				"The charges should have been recalculated", resp)
+		}
+
+		t.Logf("\tWhen sending invalid patches")
+		{
+			_, err := payments.Patch(context.Background(), original.ID, []byte(`{"reference":`))
+			check(t, err == payment.ErrInvalidPatch, "Malformed JSON should be refused", err)
+			for _, patch := range []string{`null`, `[]`, `"reference"`} {
+				_, err = payments.Patch(context.Background(), original.ID, []byte(patch))
+				check(t, err == payment.ErrInvalidPatch, "A patch which is not an object should be refused: "+patch, err)
+			}
+			_, err = payments.Patch(context.Background(), original.ID, []byte(`{"amout": 5}`))
+			check(t, err == payment.ErrInvalidPatch, "A patch with an unknown member should be refused", err)
+			_, err = payments.Patch(context.Background(), original.ID, []byte(`{"version": 3}`))
+			check(t, err == payment.ErrImmutableField, "Changing the version should be refused", err)
+			for _, patch := range []string{`{"fx": {"exchange_rate": 1000}}`, `{"currency": "EUR"}`,
+				`{"charges_information": {"receiver_charges_amount": 5}}`, `{"charges_information": {"SenderCharges": [{"amount": 1}]}}`} {
+				_, err = payments.Patch(context.Background(), original.ID, []byte(patch))
+				check(t, err == payment.ErrCalculatedField, "Changing the calculated fields should be refused: "+patch, err)
+			}
+			_, err = payments.Patch(context.Background(), original.ID, []byte(`{"organisation_id": null}`))
+			check(t, err == payment.ErrInvalidRequest, "Removing the organisation should be refused", err)
+		}
+	}
+}
+
+// Helper function to create the service under test
+func newService(repo *mocks.MockRepository) *payment.Service {
+	return payment.NewService(repo, service.NewFxService("urlFX"), service.NewChargesService("urlCF"), db, col)
+}
+
+// Helper function returning a stored payment with a 2.0 exchange rate applied
+func storedPayment() model.Payment {
+	req := test.CreatePaymentRequest("31926819", "GB29XABC10161234567801", "USD")
+	return model.Payment{Type: "Payment", ID: "5bd7506a9900b30008edf576", OrganisationId: req.OrganisationID,
+		Attributes: model.Attributes{Amount: req.Amount / 2, BeneficiaryParty: req.BeneficiaryParty, DebtorParty: req.DebtorParty,
+			Currency: "USD", Reference: req.Reference, ChargesInformation: model.ChargesInformation{BearerCode: req.BearerCode},
+			Fx: model.ForeignExchange{ExchangeRate: 2.0, OriginalAmount: req.Amount, OriginalCurrency: "USD"}}}
+}
+
+// Helper function to log an expectation with a check mark, failing the test with a ballot when it is not met
+func check(t *testing.T, ok bool, expectation string, got interface{}) {
+	if ok {
+		t.Logf("\t\t%s. %v", expectation, test.CheckMark)
+	} else {
+		t.Errorf("\t\t%s. %v %v", expectation, got, test.BallotX)
+	}
+}
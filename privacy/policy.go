@@ -0,0 +1,36 @@
+package privacy
+
+// DefaultRoleHeader the header carrying the role of the caller, set by the gateway authenticating the requests
+const DefaultRoleHeader = "X-Role"
+
+// Policy tells which roles see the party details unmasked, every other role and the requests without role receiving
+// them masked. The role is the one given by the caller. A nil Policy masks nothing.
+type Policy struct {
+	RoleHeader string
+
+	unmasked map[string]bool
+	masker   *Masker
+}
+
+// NewPolicy creates a Policy masking the given party fields for every role but the given unmasked ones
+func NewPolicy(roleHeader string, unmaskedRoles, maskedFields []string) (*Policy, error) {
+	masker, err := NewMasker(maskedFields)
+	if err != nil {
+		return nil, err
+	}
+	p := &Policy{RoleHeader: roleHeader, unmasked: make(map[string]bool), masker: masker}
+	for _, role := range unmaskedRoles {
+		if role != "" {
+			p.unmasked[role] = true
+		}
+	}
+	return p, nil
+}
+
+// Masker returns the Masker of the given role, nil when the role sees the party details
+func (p *Policy) Masker(role string) *Masker {
+	if p == nil || p.unmasked[role] {
+		return nil
+	}
+	return p.masker
+}
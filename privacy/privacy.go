@@ -0,0 +1,165 @@
+// Package privacy masks the personal data of the payments, the names, addresses and account numbers of their parties,
+// in the logs and in the responses to the roles which may not see them.
+package privacy
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"payment-service/model"
+)
+
+// The party fields which may be masked
+const (
+	FieldAccountName   = "account_name"
+	FieldAccountNumber = "account_number"
+	FieldAddress       = "address"
+	FieldName          = "name"
+	FieldBankID        = "bank_id"
+)
+
+// DefaultFields the party fields holding personal data, always masked in the logs
+var DefaultFields = []string{FieldAccountName, FieldAccountNumber, FieldAddress, FieldName}
+
+// IsField tells whether the given name is a party field which may be masked
+func IsField(name string) bool {
+	switch name {
+	case FieldAccountName, FieldAccountNumber, FieldAddress, FieldName, FieldBankID:
+		return true
+	}
+	return false
+}
+
+// Masker masks the given fields of the payment parties. A nil Masker masks nothing.
+type Masker struct {
+	fields map[string]bool
+}
+
+// NewMasker creates a Masker of the given party fields, see IsField
+func NewMasker(fields []string) (*Masker, error) {
+	m := &Masker{fields: make(map[string]bool)}
+	for _, f := range fields {
+		if !IsField(f) {
+			return nil, fmt.Errorf("unknown party field %q", f)
+		}
+		m.fields[f] = true
+	}
+	return m, nil
+}
+
+// Logs the Masker of the logs, masking the DefaultFields
+var Logs, _ = NewMasker(DefaultFields)
+
+// Party returns a copy of the party with its fields masked
+func (m *Masker) Party(p model.Party) model.Party {
+	if m == nil {
+		return p
+	}
+	if m.fields[FieldAccountName] {
+		p.AccountName = MaskName(p.AccountName)
+	}
+	if m.fields[FieldAccountNumber] {
+		p.AccountNumber = MaskAccountNumber(p.AccountNumber)
+	}
+	if m.fields[FieldAddress] {
+		p.Address = MaskAll(p.Address)
+	}
+	if m.fields[FieldName] {
+		p.Name = MaskName(p.Name)
+	}
+	if m.fields[FieldBankID] {
+		p.BankID = MaskAccountNumber(p.BankID)
+	}
+	return p
+}
+
+// Sponsor returns a copy of the sponsor party with its account number and bank ID masked like the ones of parties
+func (m *Masker) Sponsor(p model.SponsorParty) model.SponsorParty {
+	if m == nil {
+		return p
+	}
+	if m.fields[FieldAccountNumber] {
+		p.AccountNumber = MaskAccountNumber(p.AccountNumber)
+	}
+	if m.fields[FieldBankID] {
+		p.BankID = MaskAccountNumber(p.BankID)
+	}
+	return p
+}
+
+// Payment returns a copy of the payment with the fields of its parties masked
+func (m *Masker) Payment(p model.Payment) model.Payment {
+	if m == nil {
+		return p
+	}
+	p.BeneficiaryParty = m.Party(p.BeneficiaryParty)
+	p.DebtorParty = m.Party(p.DebtorParty)
+	p.SponsorParty = m.Sponsor(p.SponsorParty)
+	return p
+}
+
+// Payments returns a copy of the response with the parties of its payments masked
+func (m *Masker) Payments(resp model.PaymentResponse) model.PaymentResponse {
+	if m == nil {
+		return resp
+	}
+	masked := make([]model.Payment, len(resp.Data))
+	for i, p := range resp.Data {
+		masked[i] = m.Payment(p)
+	}
+	resp.Data = masked
+	return resp
+}
+
+// Event returns a copy of the event with the parties of its payment masked
+func (m *Masker) Event(e model.Event) model.Event {
+	e.Payment = m.Payment(e.Payment)
+	return e
+}
+
+// Request returns a copy of the payment request with the fields of its parties masked
+func (m *Masker) Request(r model.CreatePaymentRequest) model.CreatePaymentRequest {
+	if m == nil {
+		return r
+	}
+	r.BeneficiaryParty = m.Party(r.BeneficiaryParty)
+	r.DebtorParty = m.Party(r.DebtorParty)
+	r.SponsorParty = m.Sponsor(r.SponsorParty)
+	return r
+}
+
+// MaskAccountNumber keeps the last 4 characters of an account number, and the country code of an IBAN, e.g.
+// GB****************7801
+func MaskAccountNumber(s string) string {
+	n := utf8.RuneCountInString(s)
+	if n <= 4 {
+		return MaskAll(s)
+	}
+	keep := 0
+	if isIBAN(s) {
+		keep = 2
+	}
+	runes := []rune(s)
+	return string(runes[:keep]) + strings.Repeat("*", n-4-keep) + string(runes[n-4:])
+}
+
+// MaskName keeps the initial of each word of a name, e.g. W****** O****
+func MaskName(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		r, size := utf8.DecodeRuneInString(w)
+		words[i] = string(r) + strings.Repeat("*", utf8.RuneCountInString(w[size:]))
+	}
+	return strings.Join(words, " ")
+}
+
+// MaskAll masks every character other than spaces, e.g. of an address
+func MaskAll(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' {
+			return r
+		}
+		return '*'
+	}, s)
+}
@@ -0,0 +1,73 @@
+package privacy_test
+
+import (
+	"testing"
+
+	"payment-service/model"
+	"payment-service/privacy"
+	"payment-service/test"
+)
+
+func TestMasker_ShouldMaskTheGivenPartyFields(t *testing.T) {
+	t.Logf("Given a payment and a masker of the account numbers and names")
+	{
+		masker, err := privacy.NewMasker([]string{privacy.FieldAccountNumber, privacy.FieldName})
+		check(t, err == nil, "The masker should have been created", err)
+		payment := model.Payment{ID: model.NewID()}
+		payment.BeneficiaryParty = model.Party{AccountName: "W Owens", AccountNumber: "31926819", Name: "Wilfred Owens", Address: "1 Clarendon Road"}
+		payment.DebtorParty = model.Party{AccountNumber: "GB29XABC10161234567801", Name: "Emelia Brown"}
+		payment.SponsorParty.AccountNumber = "56781234"
+
+		t.Logf("\tWhen masking the payment")
+		{
+			masked := masker.Payments(model.PaymentResponse{Data: []model.Payment{payment}}).Data[0]
+			check(t, masked.BeneficiaryParty.AccountNumber == "****6819" && masked.DebtorParty.AccountNumber == "GB****************7801" &&
+				masked.SponsorParty.AccountNumber == "****1234", "The account numbers should keep their last digits", masked)
+			check(t, masked.BeneficiaryParty.Name == "W****** O****" && masked.DebtorParty.Name == "E***** B****",
+				"The names should keep their initials", masked)
+			check(t, masked.BeneficiaryParty.AccountName == "W Owens" && masked.BeneficiaryParty.Address == "1 Clarendon Road",
+				"The other fields should be kept", masked)
+			check(t, payment.BeneficiaryParty.AccountNumber == "31926819", "The payment should not have been changed", payment)
+		}
+	}
+
+	t.Logf("Given an unknown field")
+	{
+		_, err := privacy.NewMasker([]string{"reference"})
+		check(t, err != nil, "The masker should be rejected", err)
+	}
+}
+
+func TestPolicy_ShouldMaskForAllButTheUnmaskedRoles(t *testing.T) {
+	t.Logf("Given a policy leaving the parties unmasked for the admin role")
+	{
+		policy, _ := privacy.NewPolicy(privacy.DefaultRoleHeader, []string{"admin"}, privacy.DefaultFields)
+		party := model.Party{Name: "Wilfred Owens"}
+
+		check(t, policy.Masker("admin").Party(party).Name == party.Name, "The admin should get the parties as is", nil)
+		check(t, policy.Masker("viewer").Party(party).Name == "W****** O****" && policy.Masker("").Party(party).Name == "W****** O****",
+			"The other roles and the callers without role should get masked parties", nil)
+
+		var none *privacy.Policy
+		check(t, none.Masker("viewer").Party(party).Name == party.Name, "A nil policy should mask nothing", nil)
+	}
+}
+
+func TestMaskText_ShouldMaskIBANsAndAccountNumbers(t *testing.T) {
+	t.Logf("Given free text holding account numbers and identifiers")
+	{
+		id := "31926819-aaaa-4bbb-8ccc-123456789012"
+		masked := privacy.MaskText("Failed to pay GB29XABC10161234567801 from 31926819 for payment " + id + " at 2018-10-24T10:00:00Z")
+		check(t, masked == "Failed to pay GB****************7801 from ****6819 for payment "+id+" at 2018-10-24T10:00:00Z",
+			"The IBAN and account number should have been masked, the identifiers and dates kept", masked)
+	}
+}
+
+// Helper function to log an expectation with a check mark, failing the test with a ballot when it is not met
+func check(t *testing.T, ok bool, expectation string, got interface{}) {
+	if ok {
+		t.Logf("\t\t%s. %v", expectation, test.CheckMark)
+	} else {
+		t.Errorf("\t\t%s. %v %v", expectation, got, test.BallotX)
+	}
+}
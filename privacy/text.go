@@ -0,0 +1,31 @@
+package privacy
+
+import (
+	"regexp"
+)
+
+var (
+	// words of free text, hyphens included for identifiers such as UUIDs not to be split into numbers
+	word = regexp.MustCompile(`[0-9A-Za-z-]+`)
+
+	// iban the shape of an IBAN: a country code, check digits and up to 30 alphanumeric characters
+	iban = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}$`)
+
+	// accountNumber a number long enough to be an account number, e.g. a BBAN
+	accountNumber = regexp.MustCompile(`^[0-9]{8,}$`)
+)
+
+// MaskText masks the IBANs and account numbers found in free text, e.g. an error message
+func MaskText(s string) string {
+	return word.ReplaceAllStringFunc(s, func(w string) string {
+		if isIBAN(w) || accountNumber.MatchString(w) {
+			return MaskAccountNumber(w)
+		}
+		return w
+	})
+}
+
+// Helper function to tell whether a string has the shape of an IBAN
+func isIBAN(s string) bool {
+	return iban.MatchString(s)
+}
@@ -0,0 +1,525 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"sort"
+	"time"
+
+	"go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/bson"
+	"payment-service/migration"
+	"payment-service/model"
+)
+
+// buckets of a collection
+var (
+	paymentsBucket       = []byte("payments")
+	idsBucket            = []byte("ids")
+	paymentIDsBucket     = []byte("payment_ids")
+	organisationBucket   = []byte("by_organisation")
+	processingDateBucket = []byte("by_processing_date")
+)
+
+// buckets of the outbox of a db
+var (
+	eventsBucket   = []byte("events")
+	eventIDsBucket = []byte("ids")
+)
+
+// sortable layout of the processing date index keys
+const dateKeyLayout = "2006-01-02T15:04:05.000000000Z"
+
+// BoltRepository stores the payments in a single bbolt file, for deployments which cannot run a database server.
+// Every write is a transaction synced to disk before returning, so that the file stays consistent after a crash.
+//
+// Each collection is a bucket holding the BSON encoded payments keyed by an insertion sequence, the ID and payment_id
+// lookup indexes and the organisation and processing date secondary indexes. The outbox of a db is the bucket of its
+// OutboxCollection, holding the BSON encoded events keyed by a sequence and their ID index.
+type BoltRepository struct {
+	DB *bbolt.DB
+}
+
+// NewBoltRepository opens, or creates, the bbolt file at the given path
+func NewBoltRepository(path string) (*BoltRepository, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	return &BoltRepository{db}, nil
+}
+
+// Ping checks the file is still open with a read transaction
+func (repo *BoltRepository) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return repo.DB.View(func(*bbolt.Tx) error { return nil })
+}
+
+// Close releases the file
+func (repo *BoltRepository) Close() error {
+	return repo.DB.Close()
+}
+
+// Backup writes a consistent copy of the database to w while the repository keeps serving requests
+func (repo *BoltRepository) Backup(ctx context.Context, w io.Writer) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, model.ContextError(err)
+	}
+	var n int64
+	err := repo.DB.View(func(tx *bbolt.Tx) error {
+		var err error
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	return n, err
+}
+
+// Insert content into db
+func (repo *BoltRepository) Insert(ctx context.Context, db string, col string, content interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return model.ContextError(err)
+	}
+	payment, err := decode(content)
+	if err != nil {
+		return err
+	}
+	if payment.ID == "" {
+		payment.ID = model.NewID()
+	}
+
+	return repo.DB.Update(func(tx *bbolt.Tx) error {
+		b, err := collectionBucket(tx, db, col)
+		if err != nil {
+			return err
+		}
+		if b.Bucket(idsBucket).Get([]byte(payment.ID)) != nil {
+			return ErrDuplicate
+		}
+		seq, err := b.Bucket(paymentsBucket).NextSequence()
+		if err != nil {
+			return err
+		}
+		if err := put(b, sequenceKey(seq), payment); err != nil {
+			return err
+		}
+		return appendEvents(tx, db, model.NewEvent(model.PaymentCreated, payment))
+	})
+}
+
+// FindAll query all the payments, in insertion order
+func (repo *BoltRepository) FindAll(ctx context.Context, db string, col string) (model.PaymentResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return model.PaymentResponse{}, model.ContextError(err)
+	}
+
+	var result []model.Payment
+	err := repo.DB.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(collectionKey(db, col)))
+		if b == nil {
+			return nil
+		}
+		return b.Bucket(paymentsBucket).ForEach(func(k, v []byte) error {
+			payment, err := unmarshal(v)
+			result = append(result, payment)
+			return err
+		})
+	})
+	if err != nil {
+		return model.PaymentResponse{}, err
+	}
+	return model.PaymentResponse{Data: result, Links: model.Links{Self: "https://api.test.form3.tech/v1/payments"}}, nil
+}
+
+// FindPage query a page of the payments matching the filter in ID order, walking the ID index twice for the UUIDs
+// to come before the ObjectIds when there is no filter
+func (repo *BoltRepository) FindPage(ctx context.Context, db, col string, filter Filter, skip, limit int) (model.PaymentResponse, error) {
+	if !filter.IsZero() {
+		return repo.findFiltered(ctx, db, col, filter, skip, limit)
+	}
+	if err := ctx.Err(); err != nil {
+		return model.PaymentResponse{}, model.ContextError(err)
+	}
+
+	var result []model.Payment
+	err := repo.DB.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(collectionKey(db, col)))
+		if b == nil {
+			return nil
+		}
+		payments := b.Bucket(paymentsBucket)
+		c := b.Bucket(idsBucket).Cursor()
+		for _, objectIds := range []bool{false, true} {
+			for k, v := c.First(); k != nil && len(result) < limit; k, v = c.Next() {
+				if model.ID(k).IsObjectId() != objectIds {
+					continue
+				}
+				if skip > 0 {
+					skip--
+					continue
+				}
+				payment, err := unmarshal(payments.Get(v))
+				if err != nil {
+					return err
+				}
+				result = append(result, payment)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return model.PaymentResponse{}, err
+	}
+	return model.PaymentResponse{Data: result, Links: model.Links{Self: "https://api.test.form3.tech/v1/payments"}}, nil
+}
+
+// Find query payment for a given id
+func (repo *BoltRepository) Find(ctx context.Context, db string, col string, id model.ID) (model.PaymentResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return model.PaymentResponse{}, model.ContextError(err)
+	}
+
+	var result model.Payment
+	err := repo.DB.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(collectionKey(db, col)))
+		if b == nil {
+			return ErrNotFound
+		}
+		key := resolve(b, id)
+		if key == nil {
+			return ErrNotFound
+		}
+		var err error
+		result, err = unmarshal(b.Bucket(paymentsBucket).Get(key))
+		return err
+	})
+	if err != nil {
+		return model.PaymentResponse{}, err
+	}
+	return model.PaymentResponse{Data: []model.Payment{result}, Links: model.Links{Self: "https://api.test.form3.tech/v1/payments"}}, nil
+}
+
+// Delete payment
+func (repo *BoltRepository) Delete(ctx context.Context, db, col string, id model.ID) error {
+	if err := ctx.Err(); err != nil {
+		return model.ContextError(err)
+	}
+
+	return repo.DB.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(collectionKey(db, col)))
+		if b == nil {
+			return ErrNotFound
+		}
+		key := resolve(b, id)
+		if key == nil {
+			return ErrNotFound
+		}
+		deleted, err := unmarshal(b.Bucket(paymentsBucket).Get(key))
+		if err != nil {
+			return err
+		}
+		if err := remove(b, key); err != nil {
+			return err
+		}
+		return appendEvents(tx, db, model.NewEvent(model.PaymentDeleted, deleted))
+	})
+}
+
+// Update Given Payment
+func (repo *BoltRepository) Update(ctx context.Context, db string, col string, id model.ID, content interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return model.ContextError(err)
+	}
+	payment, err := decode(content)
+	if err != nil {
+		return err
+	}
+	return repo.replace(db, col, id, payment)
+}
+
+// Patch replaces the stored payment with the patched one
+func (repo *BoltRepository) Patch(ctx context.Context, db, col string, id model.ID, original, patched model.Payment) error {
+	if err := ctx.Err(); err != nil {
+		return model.ContextError(err)
+	}
+	payment, err := decode(patched)
+	if err != nil {
+		return err
+	}
+	return repo.replace(db, col, id, payment)
+}
+
+// EnsureIndexes creates the collection buckets, the indexes being maintained on every write
+func (repo *BoltRepository) EnsureIndexes(ctx context.Context, db, col string) error {
+	if err := ctx.Err(); err != nil {
+		return model.ContextError(err)
+	}
+	return repo.DB.Update(func(tx *bbolt.Tx) error {
+		_, err := collectionBucket(tx, db, col)
+		return err
+	})
+}
+
+// Helper function to replace the payment matching the given ID, the stored ID and insertion sequence being immutable
+func (repo *BoltRepository) replace(db, col string, id model.ID, payment model.Payment) error {
+	return repo.DB.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(collectionKey(db, col)))
+		if b == nil {
+			return ErrNotFound
+		}
+		key := resolve(b, id)
+		if key == nil {
+			return ErrNotFound
+		}
+		stored, err := unmarshal(b.Bucket(paymentsBucket).Get(key))
+		if err != nil {
+			return err
+		}
+		if err := remove(b, key); err != nil {
+			return err
+		}
+		payment.ID = stored.ID
+		if err := put(b, key, payment); err != nil {
+			return err
+		}
+		return appendEvents(tx, db, model.UpdateEvents(stored, payment)...)
+	})
+}
+
+// Pending returns the oldest events of the outbox, in the order they were recorded, leaving out the events of the
+// excluded payments
+func (repo *BoltRepository) Pending(ctx context.Context, db string, limit int, exclude ...model.ID) ([]model.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, model.ContextError(err)
+	}
+
+	excluded := excludedPayments(exclude)
+	var events []model.Event
+	err := repo.DB.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(collectionKey(db, OutboxCollection)))
+		if b == nil {
+			return nil
+		}
+		c := b.Bucket(eventsBucket).Cursor()
+		for k, v := c.First(); k != nil && len(events) < limit; k, v = c.Next() {
+			var event model.Event
+			if err := bson.Unmarshal(v, &event); err != nil {
+				return err
+			}
+			if !excluded[event.PaymentID] {
+				events = append(events, event)
+			}
+		}
+		return nil
+	})
+	return events, err
+}
+
+// Ack removes the published events from the outbox
+func (repo *BoltRepository) Ack(ctx context.Context, db string, ids ...string) error {
+	if err := ctx.Err(); err != nil {
+		return model.ContextError(err)
+	}
+
+	return repo.DB.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(collectionKey(db, OutboxCollection)))
+		if b == nil {
+			return nil
+		}
+		for _, id := range ids {
+			key := b.Bucket(eventIDsBucket).Get([]byte(id))
+			if key == nil {
+				continue
+			}
+			if err := b.Bucket(eventsBucket).Delete(key); err != nil {
+				return err
+			}
+			if err := b.Bucket(eventIDsBucket).Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Helper function to query a page of the payments matching a filter. The candidates are read through the
+// organisation index, or the processing date index for the filters without organisation, then sorted by ID.
+func (repo *BoltRepository) findFiltered(ctx context.Context, db, col string, filter Filter, skip, limit int) (model.PaymentResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return model.PaymentResponse{}, model.ContextError(err)
+	}
+
+	var matched []model.Payment
+	err := repo.DB.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(collectionKey(db, col)))
+		if b == nil {
+			return nil
+		}
+		payments := b.Bucket(paymentsBucket)
+		index, from, to, prefix := organisationBucket, append([]byte(filter.OrganisationID), 0), []byte(nil), true
+		if filter.OrganisationID == "" {
+			index, from, prefix = processingDateBucket, dateKey(filter.ProcessedFrom), false
+			if !filter.ProcessedTo.IsZero() {
+				to = dateKey(filter.ProcessedTo)
+			}
+		}
+		c := b.Bucket(index).Cursor()
+		for k, v := c.Seek(from); k != nil; k, v = c.Next() {
+			if (prefix && !bytes.HasPrefix(k, from)) || (to != nil && bytes.Compare(k, to) >= 0) {
+				break
+			}
+			payment, err := unmarshal(payments.Get(v))
+			if err != nil {
+				return err
+			}
+			if filter.Match(payment) {
+				matched = append(matched, payment)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return model.PaymentResponse{}, err
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID.Less(matched[j].ID) })
+	var result []model.Payment
+	for i := skip; i < len(matched) && len(result) < limit; i++ {
+		result = append(result, matched[i])
+	}
+	return model.PaymentResponse{Data: result, Links: model.Links{Self: "https://api.test.form3.tech/v1/payments"}}, nil
+}
+
+// Helper function to get the bucket of a collection, creating it and its nested buckets on first use
+func collectionBucket(tx *bbolt.Tx, db, col string) (*bbolt.Bucket, error) {
+	b, err := tx.CreateBucketIfNotExists([]byte(collectionKey(db, col)))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range [][]byte{paymentsBucket, idsBucket, paymentIDsBucket, organisationBucket, processingDateBucket} {
+		if _, err := b.CreateBucketIfNotExists(name); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Helper function to append events to the outbox of a db, in the transaction of the write they record
+func appendEvents(tx *bbolt.Tx, db string, events ...model.Event) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(collectionKey(db, OutboxCollection)))
+	if err != nil {
+		return err
+	}
+	for _, name := range [][]byte{eventsBucket, eventIDsBucket} {
+		if _, err := b.CreateBucketIfNotExists(name); err != nil {
+			return err
+		}
+	}
+	for _, event := range events {
+		seq, err := b.Bucket(eventsBucket).NextSequence()
+		if err != nil {
+			return err
+		}
+		data, err := bson.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if err := b.Bucket(eventsBucket).Put(sequenceKey(seq), data); err != nil {
+			return err
+		}
+		if err := b.Bucket(eventIDsBucket).Put([]byte(event.ID), sequenceKey(seq)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Helper function to get the key of the payment matching the ID or, for UUIDs, the payment_id attribute
+func resolve(b *bbolt.Bucket, id model.ID) []byte {
+	if key := b.Bucket(idsBucket).Get([]byte(id)); key != nil {
+		return key
+	}
+	if !id.IsObjectId() {
+		return b.Bucket(paymentIDsBucket).Get([]byte(id))
+	}
+	return nil
+}
+
+// Helper function to store a payment under the given key and add it to the indexes. The payment must have gone
+// through decode, so that the index keys match the ones computed from the stored document.
+func put(b *bbolt.Bucket, key []byte, payment model.Payment) error {
+	if payment.PaymentID != "" {
+		if b.Bucket(paymentIDsBucket).Get([]byte(payment.PaymentID)) != nil {
+			return ErrDuplicate
+		}
+		if err := b.Bucket(paymentIDsBucket).Put([]byte(payment.PaymentID), key); err != nil {
+			return err
+		}
+	}
+	data, err := bson.Marshal(payment)
+	if err != nil {
+		return err
+	}
+	if err := b.Bucket(paymentsBucket).Put(key, data); err != nil {
+		return err
+	}
+	if err := b.Bucket(idsBucket).Put([]byte(payment.ID), key); err != nil {
+		return err
+	}
+	if err := b.Bucket(organisationBucket).Put(organisationKey(payment, key), key); err != nil {
+		return err
+	}
+	return b.Bucket(processingDateBucket).Put(processingDateKey(payment, key), key)
+}
+
+// Helper function to remove the payment stored under the given key and its index entries
+func remove(b *bbolt.Bucket, key []byte) error {
+	payment, err := unmarshal(b.Bucket(paymentsBucket).Get(key))
+	if err != nil {
+		return err
+	}
+	if payment.PaymentID != "" {
+		if err := b.Bucket(paymentIDsBucket).Delete([]byte(payment.PaymentID)); err != nil {
+			return err
+		}
+	}
+	if err := b.Bucket(idsBucket).Delete([]byte(payment.ID)); err != nil {
+		return err
+	}
+	if err := b.Bucket(organisationBucket).Delete(organisationKey(payment, key)); err != nil {
+		return err
+	}
+	if err := b.Bucket(processingDateBucket).Delete(processingDateKey(payment, key)); err != nil {
+		return err
+	}
+	return b.Bucket(paymentsBucket).Delete(key)
+}
+
+// Helper function to decode a stored payment, upgrading outdated documents to the current schema version
+func unmarshal(data []byte) (model.Payment, error) {
+	return migration.DecodePayment(data)
+}
+
+// Helper function to get the big endian payment key of an insertion sequence, so that keys sort in insertion order
+func sequenceKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+// Helper function to get the organisation index key: the organisation, a separator and the payment key
+func organisationKey(payment model.Payment, key []byte) []byte {
+	return append(append([]byte(payment.OrganisationId), 0), key...)
+}
+
+// Helper function to get the processing date index key: the sortable date followed by the payment key
+func processingDateKey(payment model.Payment, key []byte) []byte {
+	return append(dateKey(payment.ProcessingDate), key...)
+}
+
+// Helper function to format a date so that the keys sort chronologically
+func dateKey(t time.Time) []byte {
+	return []byte(t.UTC().Format(dateKeyLayout))
+}
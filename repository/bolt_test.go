@@ -0,0 +1,115 @@
+package repository_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"payment-service/model"
+	"payment-service/repository"
+	"payment-service/repository/repositorytest"
+	"payment-service/test"
+	"testing"
+	"time"
+)
+
+func TestBoltRepository_Conformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+		return openBolt(t, filepath.Join(t.TempDir(), "payments.db"))
+	})
+}
+
+func TestBoltRepository_ShouldUpdateTheSecondaryIndexes(t *testing.T) {
+	repo := openBolt(t, filepath.Join(t.TempDir(), "payments.db"))
+	day := time.Date(2018, 9, 10, 0, 0, 0, 0, time.UTC)
+
+	t.Logf("Given a payment of an organisation")
+	{
+		payment := model.Payment{Type: "Payment", ID: model.NewID(), OrganisationId: "org-a"}
+		payment.ProcessingDate = day
+		if err := repo.Insert(context.Background(), "paymentDb", "payments", payment); err != nil {
+			t.Fatalf("\t\tThe insert should have been successful %v %v", test.BallotX, err)
+		}
+
+		t.Logf("\tWhen moving it to another organisation and processing date")
+		{
+			moved := payment
+			moved.OrganisationId = "org-b"
+			moved.ProcessingDate = day.Add(24 * time.Hour)
+			repo.Update(context.Background(), "paymentDb", "payments", payment.ID, moved)
+
+			before, errB := repo.FindPage(context.Background(), "paymentDb", "payments", repository.Filter{OrganisationID: "org-a"}, 0, 10)
+			after, errA := repo.FindPage(context.Background(), "paymentDb", "payments", repository.Filter{OrganisationID: "org-b"}, 0, 10)
+			if errB == nil && errA == nil && len(before.Data) == 0 && len(after.Data) == 1 {
+				t.Logf("\t\tThe organisation index should have been updated %v", test.CheckMark)
+			} else {
+				t.Errorf("\t\tThe organisation index should have been updated %v %v %v %v", test.BallotX, errB, before.Data, after.Data)
+			}
+
+			filter := repository.Filter{ProcessedFrom: day, ProcessedTo: day.Add(time.Hour)}
+			res, err := repo.FindPage(context.Background(), "paymentDb", "payments", filter, 0, 10)
+			if err == nil && len(res.Data) == 0 {
+				t.Logf("\t\tThe processing date index should have been updated %v", test.CheckMark)
+			} else {
+				t.Errorf("\t\tThe processing date index should have been updated %v %v %v", test.BallotX, err, res.Data)
+			}
+		}
+	}
+}
+
+func TestBoltRepository_BackupAndReopen(t *testing.T) {
+	dir := t.TempDir()
+	repo := openBolt(t, filepath.Join(dir, "payments.db"))
+
+	t.Logf("Given a payment stored in a bolt file")
+	{
+		payment := model.Payment{Type: "Payment", ID: model.NewID()}
+		if err := repo.Insert(context.Background(), "paymentDb", "payments", payment); err != nil {
+			t.Fatalf("\t\tThe insert should have been successful %v %v", test.BallotX, err)
+		}
+
+		t.Logf("\tWhen taking an online backup and opening it")
+		{
+			var backup bytes.Buffer
+			if _, err := repo.Backup(context.Background(), &backup); err != nil {
+				t.Fatalf("\t\tThe backup should have been successful %v %v", test.BallotX, err)
+			}
+			path := filepath.Join(dir, "backup.db")
+			if err := ioutil.WriteFile(path, backup.Bytes(), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			restored := openBolt(t, path)
+			if _, err := restored.Find(context.Background(), "paymentDb", "payments", payment.ID); err == nil {
+				t.Logf("\t\tThe backup should contain the payment %v", test.CheckMark)
+			} else {
+				t.Errorf("\t\tThe backup should contain the payment %v %v", test.BallotX, err)
+			}
+		}
+
+		t.Logf("\tWhen reopening the file through its URL")
+		{
+			repo.Close()
+			reopened, err := repository.Open("bolt://"+filepath.Join(dir, "payments.db"), repository.Options{})
+			if err != nil {
+				t.Fatalf("\t\tThe file should have been reopened %v %v", test.BallotX, err)
+			}
+			defer reopened.(*repository.BoltRepository).Close()
+			if _, err := reopened.Find(context.Background(), "paymentDb", "payments", payment.ID); err == nil {
+				t.Logf("\t\tThe payment should have been persisted %v", test.CheckMark)
+			} else {
+				t.Errorf("\t\tThe payment should have been persisted %v %v", test.BallotX, err)
+			}
+		}
+	}
+}
+
+// Helper function to open a bolt repository closed at the end of the test
+func openBolt(t *testing.T, path string) *repository.BoltRepository {
+	repo, err := repository.NewBoltRepository(path)
+	if err != nil {
+		t.Fatalf("\t\tThe bolt file should have been opened %v %v", test.BallotX, err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
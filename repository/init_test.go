@@ -1,54 +1,98 @@
 package repository
 
 import (
-	"github.com/globalsign/mgo"
-	"github.com/globalsign/mgo/dbtest"
-	"io/ioutil"
-	"net/http/httptest"
+	"context"
+	"fmt"
 	"os"
 	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"payment-service/test/mongotest"
+	"payment-service/test/pgtest"
 )
 
 const (
 	DBName = "tag-test"
-	dir    = "repo-db"
 )
 
 var (
-	Server              dbtest.DBServer
-	HttpServer          *httptest.Server
-	Session             *mgo.Session
+	Server              *mongotest.Server
+	Client              *mongo.Client
 	RepositoryUnderTest *MongoRepository
+
+	PostgresServer    *pgtest.Server
+	PostgresUnderTest *PostgresRepository
 )
 
 // TestMain wraps all tests with the needed initialized mock DB and fixtures
-// This test runs before other integration test. It starts an instance of mongo db in the background (provided you have mongo
-// installed on the server on which this test will be running) and shuts it down.
+// This test runs before other integration test. It starts an instance of mongo db and of postgres in the background
+// (provided you have them installed on the server on which this test will be running, or MONGO_TEST_URL and
+// POSTGRES_TEST_URL point at running ones) and shuts them down. The tests of an unavailable database are skipped.
 func TestMain(m *testing.M) {
 
-	// The tempdir is created so MongoDB has a location to store its files.
-	// Contents are wiped once the server stops
-	tempDir, _ := ioutil.TempDir("", dir)
-	Server.SetPath(tempDir)
+	// Start a mongod storing its files in a temporary directory wiped once the server stops
+	var err error
+	Server, err = mongotest.Start()
+	switch {
+	case err == mongotest.ErrUnavailable:
+		fmt.Println("skipping mongo repository tests:", err)
+	case err != nil:
+		fmt.Println("failed to start mongo:", err)
+		os.Exit(1)
+	default:
+		// The client is now connected to the temporary MongoDB instance
+		Client = Server.Client
+		RepositoryUnderTest = &MongoRepository{Client}
+	}
 
-	// My main session var is now set to the temporary MongoDB instance
-	Session = Server.Session()
-
-	RepositoryUnderTest = &MongoRepository{Session}
+	// Start a postgres cluster stored in a temporary directory as well
+	PostgresServer, err = pgtest.Start()
+	switch {
+	case err == pgtest.ErrUnavailable:
+		fmt.Println("skipping postgres repository tests:", err)
+	case err != nil:
+		fmt.Println("failed to start postgres:", err)
+		os.Exit(1)
+	default:
+		PostgresUnderTest, err = NewPostgresRepository(PostgresServer.URL)
+		if err != nil {
+			fmt.Println("failed to migrate postgres:", err)
+			os.Exit(1)
+		}
+	}
 
 	// Run the test suite
 	retCode := m.Run()
 
-	// Make sure we DropDatabase so we make absolutely sure nothing is left or locked while wiping the data and
-	// close session
-	Session.DB(DBName).DropDatabase()
-	Session.Close()
-
-	// Stop shuts down the temporary server and removes data on disk.
-	Server.Stop()
+	// Make sure we DropDatabase so we make absolutely sure nothing is left or locked while wiping the data
+	if Server != nil {
+		Client.Database(DBName).Drop(context.Background())
 
-	os.RemoveAll(tempDir)
+		// Stop shuts down the temporary server and removes data on disk.
+		Server.Stop()
+	}
+	if PostgresServer != nil {
+		PostgresUnderTest.DB.Close()
+		PostgresServer.Stop()
+	}
 
 	// call with result of m.Run()
 	os.Exit(retCode)
 }
+
+// MongoUnderTest returns the repository connected to the test database, skipping the test when mongo is not available
+func MongoUnderTest(t *testing.T) *MongoRepository {
+	if RepositoryUnderTest == nil {
+		t.Skip("mongo is not available")
+	}
+	return RepositoryUnderTest
+}
+
+// PostgresRepositoryUnderTest returns the repository connected to the test postgres, skipping the test when postgres
+// is not available
+func PostgresRepositoryUnderTest(t *testing.T) *PostgresRepository {
+	if PostgresUnderTest == nil {
+		t.Skip("postgres is not available")
+	}
+	return PostgresUnderTest
+}
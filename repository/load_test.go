@@ -0,0 +1,65 @@
+package repository_test
+
+import (
+	"context"
+	"payment-service/model"
+	"payment-service/repository"
+	"payment-service/test"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	loadWorkers    = 50
+	loadOperations = 40
+)
+
+// Load test running concurrent inserts and queries through the shared client and its connection pool, each operation
+// under its own deadline. Skipped with -short.
+func TestMongoRepository_ConcurrentLoad(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping load test in short mode")
+	}
+
+	repo := repository.MongoUnderTest(t)
+
+	t.Logf("Given the DB is up and running")
+	{
+		t.Logf("\tWhen %d clients insert and query %d payments each", loadWorkers, loadOperations)
+		{
+			var wg sync.WaitGroup
+			errs := make(chan error, loadWorkers*loadOperations)
+			start := time.Now()
+
+			for w := 0; w < loadWorkers; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < loadOperations; i++ {
+						ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+						payment := model.Payment{Type: "Payment", ID: model.NewID()}
+						if err := repo.Insert(ctx, "paymentDb", "load", payment); err != nil {
+							errs <- err
+						} else if _, err := repo.Find(ctx, "paymentDb", "load", payment.ID); err != nil {
+							errs <- err
+						}
+						cancel()
+					}
+				}()
+			}
+			wg.Wait()
+			close(errs)
+
+			elapsed := time.Since(start)
+			t.Logf("\t\t%d operations in %s (%.0f ops/s)", 2*loadWorkers*loadOperations, elapsed,
+				float64(2*loadWorkers*loadOperations)/elapsed.Seconds())
+
+			if len(errs) == 0 {
+				t.Logf("\t\tAll the operations should have been successful %v", test.CheckMark)
+			} else {
+				t.Errorf("\t\tAll the operations should have been successful %v %v failures, first: %v", test.BallotX, len(errs), <-errs)
+			}
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"payment-service/model"
+)
+
+// MemoryRepository keeps the payments in memory. It is safe for concurrent use and follows the same matching, ordering
+// and error semantics as the MongoRepository, which makes it suitable for tests and running the service locally.
+type MemoryRepository struct {
+	mu          sync.RWMutex
+	collections map[string]*collection
+	outbox      map[string][]model.Event
+}
+
+// a collection keeps the payments in insertion order, the natural order mongo returns them in
+type collection struct {
+	payments []model.Payment
+	indexed  bool
+}
+
+// NewMemoryRepository creates an empty MemoryRepository
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{collections: make(map[string]*collection), outbox: make(map[string][]model.Event)}
+}
+
+// Insert content into db
+func (repo *MemoryRepository) Insert(ctx context.Context, db string, col string, content interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return model.ContextError(err)
+	}
+	payment, err := decode(content)
+	if err != nil {
+		return err
+	}
+	if payment.ID == "" {
+		payment.ID = model.NewID()
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	c := repo.collection(db, col)
+	for _, p := range c.payments {
+		if p.ID == payment.ID || c.conflicts(p, payment) {
+			return ErrDuplicate
+		}
+	}
+	c.payments = append(c.payments, payment)
+	repo.record(db, model.NewEvent(model.PaymentCreated, clone(payment)))
+	return nil
+}
+
+// FindAll query all the payments
+func (repo *MemoryRepository) FindAll(ctx context.Context, db string, col string) (model.PaymentResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return model.PaymentResponse{}, model.ContextError(err)
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	var result []model.Payment
+	for _, p := range repo.lookup(db, col).payments {
+		result = append(result, clone(p))
+	}
+	return model.PaymentResponse{Data: result, Links: model.Links{Self: "https://api.test.form3.tech/v1/payments"}}, nil
+}
+
+// FindPage query a page of the payments matching the filter in ID order
+func (repo *MemoryRepository) FindPage(ctx context.Context, db, col string, filter Filter, skip, limit int) (model.PaymentResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return model.PaymentResponse{}, model.ContextError(err)
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	var payments []model.Payment
+	for _, p := range repo.lookup(db, col).payments {
+		if filter.Match(p) {
+			payments = append(payments, p)
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool { return payments[i].ID.Less(payments[j].ID) })
+	var result []model.Payment
+	for i := skip; i < len(payments) && len(result) < limit; i++ {
+		result = append(result, clone(payments[i]))
+	}
+	return model.PaymentResponse{Data: result, Links: model.Links{Self: "https://api.test.form3.tech/v1/payments"}}, nil
+}
+
+// Find query payment for a given id
+func (repo *MemoryRepository) Find(ctx context.Context, db string, col string, id model.ID) (model.PaymentResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return model.PaymentResponse{}, model.ContextError(err)
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	c := repo.lookup(db, col)
+	i := c.find(id)
+	if i < 0 {
+		return model.PaymentResponse{}, ErrNotFound
+	}
+	return model.PaymentResponse{Data: []model.Payment{clone(c.payments[i])}, Links: model.Links{Self: "https://api.test.form3.tech/v1/payments"}}, nil
+}
+
+// Delete payment
+func (repo *MemoryRepository) Delete(ctx context.Context, db, col string, id model.ID) error {
+	if err := ctx.Err(); err != nil {
+		return model.ContextError(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	c := repo.collection(db, col)
+	i := c.find(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	deleted := c.payments[i]
+	c.payments = append(c.payments[:i], c.payments[i+1:]...)
+	repo.record(db, model.NewEvent(model.PaymentDeleted, deleted))
+	return nil
+}
+
+// Update Given Payment
+func (repo *MemoryRepository) Update(ctx context.Context, db string, col string, id model.ID, content interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return model.ContextError(err)
+	}
+	payment, err := decode(content)
+	if err != nil {
+		return err
+	}
+	return repo.replace(db, col, id, payment)
+}
+
+// Patch replaces the stored payment with the patched one
+func (repo *MemoryRepository) Patch(ctx context.Context, db, col string, id model.ID, original, patched model.Payment) error {
+	if err := ctx.Err(); err != nil {
+		return model.ContextError(err)
+	}
+	payment, err := decode(patched)
+	if err != nil {
+		return err
+	}
+	return repo.replace(db, col, id, payment)
+}
+
+// Ping only fails when the context is done, the payments being in memory
+func (repo *MemoryRepository) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// Close does nothing, the payments being kept until the repository is garbage collected
+func (repo *MemoryRepository) Close() error {
+	return nil
+}
+
+// EnsureIndexes enforces the uniqueness of the client assigned payment_id attribute in the collection
+func (repo *MemoryRepository) EnsureIndexes(ctx context.Context, db, col string) error {
+	if err := ctx.Err(); err != nil {
+		return model.ContextError(err)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	c := repo.collection(db, col)
+	for i, p := range c.payments {
+		for _, other := range c.payments[i+1:] {
+			if p.PaymentID != "" && p.PaymentID == other.PaymentID {
+				return ErrDuplicate
+			}
+		}
+	}
+	c.indexed = true
+	return nil
+}
+
+// Helper function to replace the payment matching the given ID, the stored ID being immutable
+func (repo *MemoryRepository) replace(db, col string, id model.ID, payment model.Payment) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	c := repo.collection(db, col)
+	i := c.find(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	payment.ID = c.payments[i].ID
+	for j, p := range c.payments {
+		if j != i && c.conflicts(p, payment) {
+			return ErrDuplicate
+		}
+	}
+	original := c.payments[i]
+	c.payments[i] = payment
+	repo.record(db, model.UpdateEvents(original, clone(payment))...)
+	return nil
+}
+
+// Pending returns up to limit events of the db which have not been acknowledged, in the order they were recorded,
+// leaving out the events of the excluded payments
+func (repo *MemoryRepository) Pending(ctx context.Context, db string, limit int, exclude ...model.ID) ([]model.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, model.ContextError(err)
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	excluded := excludedPayments(exclude)
+	var events []model.Event
+	for _, e := range repo.outbox[db] {
+		if len(events) == limit {
+			break
+		}
+		if !excluded[e.PaymentID] {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// Ack removes published events from the outbox
+func (repo *MemoryRepository) Ack(ctx context.Context, db string, ids ...string) error {
+	if err := ctx.Err(); err != nil {
+		return model.ContextError(err)
+	}
+
+	acked := make(map[string]bool)
+	for _, id := range ids {
+		acked[id] = true
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	var pending []model.Event
+	for _, e := range repo.outbox[db] {
+		if !acked[e.ID] {
+			pending = append(pending, e)
+		}
+	}
+	repo.outbox[db] = pending
+	return nil
+}
+
+// Helper function to record events in the outbox. Must be called with the lock held.
+func (repo *MemoryRepository) record(db string, events ...model.Event) {
+	repo.outbox[db] = append(repo.outbox[db], events...)
+}
+
+// Helper function to get a collection, creating it on first use as mongo does. Must be called with the lock held.
+func (repo *MemoryRepository) collection(db, col string) *collection {
+	key := db + "." + col
+	c, ok := repo.collections[key]
+	if !ok {
+		c = &collection{}
+		repo.collections[key] = c
+	}
+	return c
+}
+
+// Helper function to get a collection without creating it, for use under the read lock
+func (repo *MemoryRepository) lookup(db, col string) *collection {
+	if c, ok := repo.collections[db+"."+col]; ok {
+		return c
+	}
+	return &collection{}
+}
+
+// Helper function to get the position of the payment matching the ID or, for UUIDs, the payment_id attribute
+func (c *collection) find(id model.ID) int {
+	for i, p := range c.payments {
+		if p.ID == id || (!id.IsObjectId() && p.PaymentID == id.String()) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Helper function to check the payment_id unique index
+func (c *collection) conflicts(stored, payment model.Payment) bool {
+	return c.indexed && payment.PaymentID != "" && stored.PaymentID == payment.PaymentID
+}
+
+// Helper function to copy the content through its BSON representation, so that stored payments are detached from
+// the caller and go through the same mapping as in mongo
+func decode(content interface{}) (model.Payment, error) {
+	var payment model.Payment
+	data, err := bson.Marshal(content)
+	if err != nil {
+		return payment, err
+	}
+	err = bson.Unmarshal(data, &payment)
+	return payment, err
+}
+
+// Helper function to copy a stored payment so that callers cannot modify it in place
+func clone(payment model.Payment) model.Payment {
+	copied, err := decode(payment)
+	if err != nil {
+		// a payment decoded once always round trips
+		panic(err)
+	}
+	return copied
+}
@@ -0,0 +1,80 @@
+package repository_test
+
+import (
+	"context"
+	"payment-service/model"
+	"payment-service/repository"
+	"payment-service/repository/repositorytest"
+	"payment-service/test"
+	"sync"
+	"testing"
+)
+
+func TestMemoryRepository_Conformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+		return repository.NewMemoryRepository()
+	})
+}
+
+func TestMemoryRepository_ConcurrentAccess(t *testing.T) {
+
+	t.Logf("Given an in memory repository")
+	{
+		t.Logf("\tWhen clients insert, query and delete payments concurrently")
+		{
+			repo := repository.NewMemoryRepository()
+			var wg sync.WaitGroup
+			errs := make(chan error, 100)
+			for w := 0; w < 10; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < 10; i++ {
+						payment := model.Payment{Type: "Payment", ID: model.NewID()}
+						if err := repo.Insert(context.Background(), "paymentDb", "payments", payment); err != nil {
+							errs <- err
+						} else if _, err := repo.FindAll(context.Background(), "paymentDb", "payments"); err != nil {
+							errs <- err
+						} else if err := repo.Delete(context.Background(), "paymentDb", "payments", payment.ID); err != nil {
+							errs <- err
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			close(errs)
+
+			if len(errs) == 0 {
+				t.Logf("\t\tAll the operations should have been successful %v", test.CheckMark)
+			} else {
+				t.Errorf("\t\tAll the operations should have been successful %v %v", test.BallotX, <-errs)
+			}
+		}
+	}
+}
+
+func TestMemoryRepository_StoredPaymentsShouldNotBeShared(t *testing.T) {
+
+	t.Logf("Given a payment inserted in the in memory repository")
+	{
+		repo := repository.NewMemoryRepository()
+		payment := model.Payment{Type: "Payment", ID: model.NewID()}
+		payment.ChargesInformation.SenderCharges = []model.Charge{{Amount: 5, Currency: "GBP"}}
+		repo.Insert(context.Background(), "paymentDb", "payments", payment)
+
+		t.Logf("\tWhen modifying the inserted and the returned payments")
+		{
+			payment.ChargesInformation.SenderCharges[0].Amount = 10
+			res, _ := repo.Find(context.Background(), "paymentDb", "payments", payment.ID)
+			res.Data[0].ChargesInformation.SenderCharges[0].Currency = "USD"
+
+			res, _ = repo.Find(context.Background(), "paymentDb", "payments", payment.ID)
+			charge := res.Data[0].ChargesInformation.SenderCharges[0]
+			if charge.Amount == 5 && charge.Currency == "GBP" {
+				t.Logf("\t\tThe stored payment should be unchanged %v", test.CheckMark)
+			} else {
+				t.Errorf("\t\tThe stored payment should be unchanged %v %v", test.BallotX, charge)
+			}
+		}
+	}
+}
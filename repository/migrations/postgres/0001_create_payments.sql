@@ -0,0 +1,82 @@
+-- Payments are scoped by collection, the equivalent of the mongo database and collection the repository is given.
+
+CREATE TABLE payments (
+    collection                TEXT             NOT NULL,
+    id                        TEXT             NOT NULL,
+    seq                       BIGSERIAL        NOT NULL,
+    type                      TEXT             NOT NULL DEFAULT '',
+    version                   INTEGER          NOT NULL DEFAULT 0,
+    organisation_id           TEXT             NOT NULL DEFAULT '',
+    payment_id                TEXT,
+    amount                    DOUBLE PRECISION NOT NULL DEFAULT 0,
+    currency                  TEXT             NOT NULL DEFAULT '',
+    end_to_end_reference      TEXT             NOT NULL DEFAULT '',
+    numeric_reference         TEXT             NOT NULL DEFAULT '',
+    payment_purpose           TEXT             NOT NULL DEFAULT '',
+    payment_scheme            TEXT             NOT NULL DEFAULT '',
+    payment_type              TEXT             NOT NULL DEFAULT '',
+    processing_date           TIMESTAMPTZ      NOT NULL,
+    reference                 TEXT             NOT NULL DEFAULT '',
+    scheme_payment_sub_type   TEXT             NOT NULL DEFAULT '',
+    scheme_payment_type       TEXT             NOT NULL DEFAULT '',
+    sponsor_account_number    TEXT             NOT NULL DEFAULT '',
+    sponsor_bank_id           TEXT             NOT NULL DEFAULT '',
+    sponsor_bank_id_code      TEXT             NOT NULL DEFAULT '',
+    PRIMARY KEY (collection, id),
+    -- NULLs are distinct, payments without payment_id never conflict
+    UNIQUE (collection, payment_id)
+);
+
+CREATE INDEX payments_seq_idx ON payments (collection, seq);
+
+-- beneficiary and debtor parties
+CREATE TABLE parties (
+    collection          TEXT    NOT NULL,
+    payment             TEXT    NOT NULL,
+    role                TEXT    NOT NULL CHECK (role IN ('beneficiary', 'debtor')),
+    account_name        TEXT    NOT NULL DEFAULT '',
+    account_number      TEXT    NOT NULL DEFAULT '',
+    account_number_code TEXT    NOT NULL DEFAULT '',
+    account_type        INTEGER NOT NULL DEFAULT 0,
+    address             TEXT    NOT NULL DEFAULT '',
+    bank_id             TEXT    NOT NULL DEFAULT '',
+    bank_id_code        TEXT    NOT NULL DEFAULT '',
+    name                TEXT    NOT NULL DEFAULT '',
+    currency            TEXT    NOT NULL DEFAULT '',
+    PRIMARY KEY (collection, payment, role),
+    FOREIGN KEY (collection, payment) REFERENCES payments (collection, id) ON DELETE CASCADE
+);
+
+-- charges information, one row per payment
+CREATE TABLE charges (
+    collection                TEXT             NOT NULL,
+    payment                   TEXT             NOT NULL,
+    bearer_code               TEXT             NOT NULL DEFAULT '',
+    receiver_charges_amount   DOUBLE PRECISION NOT NULL DEFAULT 0,
+    receiver_charges_currency TEXT             NOT NULL DEFAULT '',
+    PRIMARY KEY (collection, payment),
+    FOREIGN KEY (collection, payment) REFERENCES payments (collection, id) ON DELETE CASCADE
+);
+
+-- the sender charges, in order
+CREATE TABLE sender_charges (
+    collection TEXT             NOT NULL,
+    payment    TEXT             NOT NULL,
+    position   INTEGER          NOT NULL,
+    amount     DOUBLE PRECISION NOT NULL DEFAULT 0,
+    currency   TEXT             NOT NULL DEFAULT '',
+    PRIMARY KEY (collection, payment, position),
+    FOREIGN KEY (collection, payment) REFERENCES charges (collection, payment) ON DELETE CASCADE
+);
+
+-- foreign exchange details
+CREATE TABLE fx (
+    collection         TEXT             NOT NULL,
+    payment            TEXT             NOT NULL,
+    contract_reference TEXT             NOT NULL DEFAULT '',
+    exchange_rate      DOUBLE PRECISION NOT NULL DEFAULT 0,
+    original_amount    DOUBLE PRECISION NOT NULL DEFAULT 0,
+    original_currency  TEXT             NOT NULL DEFAULT '',
+    PRIMARY KEY (collection, payment),
+    FOREIGN KEY (collection, payment) REFERENCES payments (collection, id) ON DELETE CASCADE
+);
@@ -0,0 +1,15 @@
+-- the status of a payment, set by the payment scheme
+ALTER TABLE payments ADD COLUMN status TEXT NOT NULL DEFAULT '';
+
+-- domain events recorded in the same transaction as the payment writes, until the relay acknowledges them.
+-- The event is stored as BSON, the same encoding as the other repositories.
+CREATE TABLE outbox (
+    seq         BIGSERIAL   PRIMARY KEY,
+    db          TEXT        NOT NULL,
+    id          TEXT        NOT NULL UNIQUE,
+    payment     TEXT        NOT NULL,
+    event       BYTEA       NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX outbox_db_seq_idx ON outbox (db, seq);
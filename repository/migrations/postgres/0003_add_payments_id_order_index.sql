@@ -0,0 +1,3 @@
+-- the order the payments are paged in, the UUIDs before the 24 characters long ObjectIds as mongo sorts them, each in
+-- byte order
+CREATE INDEX payments_id_order_idx ON payments (collection, (char_length(id) = 24), id COLLATE "C");
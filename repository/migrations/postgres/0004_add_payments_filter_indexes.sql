@@ -0,0 +1,3 @@
+-- the filters of the paged list, by organisation and by processing date
+CREATE INDEX payments_organisation_idx ON payments (collection, organisation_id);
+CREATE INDEX payments_processing_date_idx ON payments (collection, processing_date);
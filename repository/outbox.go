@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+
+	"payment-service/model"
+)
+
+// OutboxCollection the collection holding the events recorded along the payment writes
+const OutboxCollection = "outbox"
+
+// Outbox the payment domain events recorded by the repository writes, in the same transaction as the write, and
+// waiting to be published.
+type Outbox interface {
+
+	// Pending returns up to limit events of the db which have not been acknowledged, in the order they were recorded,
+	// leaving out the events of the excluded payments
+	Pending(ctx context.Context, db string, limit int, exclude ...model.ID) ([]model.Event, error)
+
+	// Ack removes published events from the outbox
+	Ack(ctx context.Context, db string, ids ...string) error
+}
+
+// Helper function to index the excluded payments of a Pending query
+func excludedPayments(exclude []model.ID) map[model.ID]bool {
+	excluded := make(map[model.ID]bool, len(exclude))
+	for _, id := range exclude {
+		excluded[id] = true
+	}
+	return excluded
+}
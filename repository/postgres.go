@@ -0,0 +1,518 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/bson"
+	"payment-service/model"
+)
+
+// postgres SQLSTATE of unique constraint violations
+const uniqueViolation = "23505"
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+// PostgresRepository stores the payments in a normalised PostgreSQL schema. The db and collection given to the
+// operations scope the rows, so that several collections share the same tables.
+type PostgresRepository struct {
+	DB *sql.DB
+}
+
+// NewPostgresRepository connects to postgres and applies the pending migrations
+func NewPostgresRepository(url string) (*PostgresRepository, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	repo := &PostgresRepository{db}
+	if err := repo.Migrate(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return repo, nil
+}
+
+// Migrate applies the embedded migrations which have not been applied yet, each in its own transaction
+func (repo *PostgresRepository) Migrate(ctx context.Context) error {
+	_, err := repo.DB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now())`)
+	if err != nil {
+		return mapPostgresError(err)
+	}
+
+	files, err := fs.Glob(postgresMigrations, "migrations/postgres/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		version, err := strconv.Atoi(strings.SplitN(file[len("migrations/postgres/"):], "_", 2)[0])
+		if err != nil {
+			return fmt.Errorf("invalid migration file name %s", file)
+		}
+		script, err := postgresMigrations.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		err = repo.transaction(ctx, func(tx *sql.Tx) error {
+			// concurrent instances wait for each other, the version being checked once the lock is held
+			if _, err := tx.ExecContext(ctx, "LOCK TABLE schema_migrations IN EXCLUSIVE MODE"); err != nil {
+				return err
+			}
+			var applied bool
+			err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", version).Scan(&applied)
+			if err != nil || applied {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+				return fmt.Errorf("migration %s failed: %s", file, err)
+			}
+			_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Insert content into db
+func (repo *PostgresRepository) Insert(ctx context.Context, db string, col string, content interface{}) error {
+	payment, err := decode(content)
+	if err != nil {
+		return err
+	}
+	if payment.ID == "" {
+		payment.ID = model.NewID()
+	}
+	return repo.transaction(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT INTO payments (collection, id, type, version, organisation_id, payment_id,
+			amount, currency, end_to_end_reference, numeric_reference, payment_purpose, payment_scheme, payment_type,
+			processing_date, reference, scheme_payment_sub_type, scheme_payment_type, sponsor_account_number,
+			sponsor_bank_id, sponsor_bank_id_code, status)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)`,
+			append([]interface{}{collectionKey(db, col), payment.ID.String()}, paymentColumns(payment)...)...)
+		if err != nil {
+			return err
+		}
+		if err := insertDetails(ctx, tx, collectionKey(db, col), payment); err != nil {
+			return err
+		}
+		payment.SchemaVersion = model.SchemaVersion
+		return insertEvents(ctx, tx, db, model.NewEvent(model.PaymentCreated, payment))
+	})
+}
+
+// FindAll query all the payments, in insertion order
+func (repo *PostgresRepository) FindAll(ctx context.Context, db string, col string) (model.PaymentResponse, error) {
+	var result []model.Payment
+	err := repo.transaction(ctx, func(tx *sql.Tx) error {
+		payments, err := queryPayments(ctx, tx, collectionKey(db, col))
+		result = payments
+		return err
+	})
+	if err != nil {
+		return model.PaymentResponse{}, err
+	}
+	return model.PaymentResponse{Data: result, Links: model.Links{Self: "https://api.test.form3.tech/v1/payments"}}, nil
+}
+
+// FindPage query a page of the payments matching the filter in ID order, through the index ordering the IDs as
+// model.ID.Less does
+func (repo *PostgresRepository) FindPage(ctx context.Context, db, col string, filter Filter, skip, limit int) (model.PaymentResponse, error) {
+	var result []model.Payment
+	err := repo.transaction(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT id FROM payments WHERE collection = $1
+			AND ($4 = '' OR organisation_id = $4)
+			AND ($5::timestamptz IS NULL OR processing_date >= $5) AND ($6::timestamptz IS NULL OR processing_date < $6)
+			ORDER BY char_length(id) = 24, id COLLATE "C" OFFSET $2 LIMIT $3`, collectionKey(db, col), skip, limit,
+			filter.OrganisationID, nullTime(filter.ProcessedFrom), nullTime(filter.ProcessedTo))
+		if err != nil {
+			return err
+		}
+		var keys []string
+		for rows.Next() {
+			var key string
+			if err := rows.Scan(&key); err != nil {
+				rows.Close()
+				return err
+			}
+			keys = append(keys, key)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil || len(keys) == 0 {
+			return err
+		}
+		result, err = queryPayments(ctx, tx, collectionKey(db, col), keys...)
+		sort.Slice(result, func(i, j int) bool { return result[i].ID.Less(result[j].ID) })
+		return err
+	})
+	if err != nil {
+		return model.PaymentResponse{}, err
+	}
+	return model.PaymentResponse{Data: result, Links: model.Links{Self: "https://api.test.form3.tech/v1/payments"}}, nil
+}
+
+// Find query payment for a given id
+func (repo *PostgresRepository) Find(ctx context.Context, db string, col string, id model.ID) (model.PaymentResponse, error) {
+	var result []model.Payment
+	err := repo.transaction(ctx, func(tx *sql.Tx) error {
+		key, err := lookup(ctx, tx, collectionKey(db, col), id, false)
+		if err != nil {
+			return err
+		}
+		result, err = queryPayments(ctx, tx, collectionKey(db, col), key)
+		return err
+	})
+	if err != nil {
+		return model.PaymentResponse{}, err
+	}
+	return model.PaymentResponse{Data: result, Links: model.Links{Self: "https://api.test.form3.tech/v1/payments"}}, nil
+}
+
+// Delete payment, its parties, charges and fx being deleted in cascade
+func (repo *PostgresRepository) Delete(ctx context.Context, db, col string, id model.ID) error {
+	return repo.transaction(ctx, func(tx *sql.Tx) error {
+		key, err := lookup(ctx, tx, collectionKey(db, col), id, true)
+		if err != nil {
+			return err
+		}
+		deleted, err := queryPayments(ctx, tx, collectionKey(db, col), key)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "DELETE FROM payments WHERE collection = $1 AND id = $2", collectionKey(db, col), key)
+		if err != nil {
+			return err
+		}
+		return insertEvents(ctx, tx, db, model.NewEvent(model.PaymentDeleted, deleted[0]))
+	})
+}
+
+// Update Given Payment
+func (repo *PostgresRepository) Update(ctx context.Context, db string, col string, id model.ID, content interface{}) error {
+	payment, err := decode(content)
+	if err != nil {
+		return err
+	}
+	return repo.replace(ctx, db, col, id, payment)
+}
+
+// Patch replaces the stored payment with the patched one
+func (repo *PostgresRepository) Patch(ctx context.Context, db, col string, id model.ID, original, patched model.Payment) error {
+	return repo.replace(ctx, db, col, id, patched)
+}
+
+// Ping checks a connection of the pool is alive
+func (repo *PostgresRepository) Ping(ctx context.Context) error {
+	return repo.DB.PingContext(ctx)
+}
+
+// Close closes the connection pool
+func (repo *PostgresRepository) Close() error {
+	return repo.DB.Close()
+}
+
+// EnsureIndexes checks the schema is reachable, the indexes being created by the migrations
+func (repo *PostgresRepository) EnsureIndexes(ctx context.Context, db, col string) error {
+	return mapPostgresError(repo.DB.PingContext(ctx))
+}
+
+// Pending returns the oldest events of the outbox, in the order they were recorded, leaving out the events of the
+// excluded payments
+func (repo *PostgresRepository) Pending(ctx context.Context, db string, limit int, exclude ...model.ID) ([]model.Event, error) {
+	payments := make([]string, len(exclude))
+	for i, id := range exclude {
+		payments[i] = id.String()
+	}
+	rows, err := repo.DB.QueryContext(ctx, "SELECT event FROM outbox WHERE db = $1 AND NOT (payment = ANY($2)) ORDER BY seq LIMIT $3",
+		db, pq.Array(payments), limit)
+	if err != nil {
+		return nil, mapPostgresError(err)
+	}
+	defer rows.Close()
+	var events []model.Event
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, mapPostgresError(err)
+		}
+		var event model.Event
+		if err := bson.Unmarshal(data, &event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, mapPostgresError(rows.Err())
+}
+
+// Ack removes the published events from the outbox
+func (repo *PostgresRepository) Ack(ctx context.Context, db string, ids ...string) error {
+	_, err := repo.DB.ExecContext(ctx, "DELETE FROM outbox WHERE db = $1 AND id = ANY($2)", db, pq.Array(ids))
+	return mapPostgresError(err)
+}
+
+// Helper function to replace the payment matching the given ID, the stored ID being immutable
+func (repo *PostgresRepository) replace(ctx context.Context, db, col string, id model.ID, payment model.Payment) error {
+	collection := collectionKey(db, col)
+	return repo.transaction(ctx, func(tx *sql.Tx) error {
+		key, err := lookup(ctx, tx, collection, id, true)
+		if err != nil {
+			return err
+		}
+		original, err := queryPayments(ctx, tx, collection, key)
+		if err != nil {
+			return err
+		}
+		payment.ID = model.ID(key)
+		_, err = tx.ExecContext(ctx, `UPDATE payments SET type = $3, version = $4, organisation_id = $5, payment_id = $6,
+			amount = $7, currency = $8, end_to_end_reference = $9, numeric_reference = $10, payment_purpose = $11,
+			payment_scheme = $12, payment_type = $13, processing_date = $14, reference = $15, scheme_payment_sub_type = $16,
+			scheme_payment_type = $17, sponsor_account_number = $18, sponsor_bank_id = $19, sponsor_bank_id_code = $20,
+			status = $21
+			WHERE collection = $1 AND id = $2`,
+			append([]interface{}{collection, key}, paymentColumns(payment)...)...)
+		if err != nil {
+			return err
+		}
+		for _, table := range []string{"parties", "charges", "fx"} {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE collection = $1 AND payment = $2", collection, key); err != nil {
+				return err
+			}
+		}
+		if err := insertDetails(ctx, tx, collection, payment); err != nil {
+			return err
+		}
+		payment.SchemaVersion = model.SchemaVersion
+		return insertEvents(ctx, tx, db, model.UpdateEvents(original[0], payment)...)
+	})
+}
+
+// Helper function to run the function in a transaction, committed when it succeeds
+func (repo *PostgresRepository) transaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return mapPostgresError(err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return mapPostgresError(err)
+	}
+	return mapPostgresError(tx.Commit())
+}
+
+// Helper function to get the stored ID of the payment matching the ID or, for UUIDs, the payment_id attribute
+func lookup(ctx context.Context, tx *sql.Tx, collection string, id model.ID, forUpdate bool) (string, error) {
+	query := "SELECT id FROM payments WHERE collection = $1 AND id = $2"
+	if !id.IsObjectId() {
+		query = "SELECT id FROM payments WHERE collection = $1 AND (id = $2 OR payment_id = $2) ORDER BY id = $2 DESC LIMIT 1"
+	}
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	var key string
+	err := tx.QueryRowContext(ctx, query, collection, id.String()).Scan(&key)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	return key, err
+}
+
+// Helper function to load the payments of a collection, or only the ones with the given keys, with their details
+func queryPayments(ctx context.Context, tx *sql.Tx, collection string, keys ...string) ([]model.Payment, error) {
+	filter, detailsFilter := "", ""
+	args := []interface{}{collection}
+	if len(keys) > 0 {
+		filter, detailsFilter = " AND p.id = ANY($2)", " AND p.payment = ANY($2)"
+		args = append(args, pq.Array(keys))
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT p.id, p.type, p.version, p.organisation_id, COALESCE(p.payment_id, ''), p.amount,
+		p.currency, p.end_to_end_reference, p.numeric_reference, p.payment_purpose, p.payment_scheme, p.payment_type,
+		p.processing_date, p.reference, p.scheme_payment_sub_type, p.scheme_payment_type, p.sponsor_account_number,
+		p.sponsor_bank_id, p.sponsor_bank_id_code, p.status,
+		COALESCE(c.bearer_code, ''), COALESCE(c.receiver_charges_amount, 0), COALESCE(c.receiver_charges_currency, ''),
+		COALESCE(f.contract_reference, ''), COALESCE(f.exchange_rate, 0), COALESCE(f.original_amount, 0),
+		COALESCE(f.original_currency, '')
+		FROM payments p
+		LEFT JOIN charges c ON c.collection = p.collection AND c.payment = p.id
+		LEFT JOIN fx f ON f.collection = p.collection AND f.payment = p.id
+		WHERE p.collection = $1`+filter+` ORDER BY p.seq`, args...)
+	if err != nil {
+		return nil, err
+	}
+	var payments []model.Payment
+	index := map[string]int{}
+	for rows.Next() {
+		var p model.Payment
+		var id string
+		err := rows.Scan(&id, &p.Type, &p.Version, &p.OrganisationId, &p.PaymentID, &p.Amount, &p.Currency,
+			&p.EndToEndReference, &p.NumericReference, &p.PaymentPurpose, &p.PaymentScheme, &p.PaymentType,
+			&p.ProcessingDate, &p.Reference, &p.SchemePaymentSubType, &p.SchemePaymentType, &p.SponsorParty.AccountNumber,
+			&p.SponsorParty.BankID, &p.SponsorParty.BankIDCode, &p.Status, &p.ChargesInformation.BearerCode,
+			&p.ChargesInformation.ReceiverChargesAmount, &p.ChargesInformation.ReceiverChargesCurrency,
+			&p.Fx.ContactReference, &p.Fx.ExchangeRate, &p.Fx.OriginalAmount, &p.Fx.OriginalCurrency)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		// the rows are always in the current shape, the schema being migrated by the SQL migrations
+		p.ID = model.ID(id)
+		p.SchemaVersion = model.SchemaVersion
+		index[id] = len(payments)
+		payments = append(payments, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(payments) == 0 {
+		return payments, err
+	}
+
+	rows, err = tx.QueryContext(ctx, `SELECT p.payment, p.role, p.account_name, p.account_number, p.account_number_code,
+		p.account_type, p.address, p.bank_id, p.bank_id_code, p.name, p.currency
+		FROM parties p WHERE p.collection = $1`+detailsFilter, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id, role string
+		var party model.Party
+		err := rows.Scan(&id, &role, &party.AccountName, &party.AccountNumber, &party.AccountNumberCode, &party.AccountType,
+			&party.Address, &party.BankID, &party.BankIDCode, &party.Name, &party.Currency)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if role == "beneficiary" {
+			payments[index[id]].BeneficiaryParty = party
+		} else {
+			payments[index[id]].DebtorParty = party
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.QueryContext(ctx, `SELECT p.payment, p.amount, p.currency FROM sender_charges p
+		WHERE p.collection = $1`+detailsFilter+` ORDER BY p.payment, p.position`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var charge model.Charge
+		if err := rows.Scan(&id, &charge.Amount, &charge.Currency); err != nil {
+			return nil, err
+		}
+		charges := &payments[index[id]].ChargesInformation
+		charges.SenderCharges = append(charges.SenderCharges, charge)
+	}
+	return payments, rows.Err()
+}
+
+// Helper function to insert the parties, charges and fx of a payment
+func insertDetails(ctx context.Context, tx *sql.Tx, collection string, payment model.Payment) error {
+	parties := map[string]model.Party{"beneficiary": payment.BeneficiaryParty, "debtor": payment.DebtorParty}
+	for role, party := range parties {
+		_, err := tx.ExecContext(ctx, `INSERT INTO parties (collection, payment, role, account_name, account_number,
+			account_number_code, account_type, address, bank_id, bank_id_code, name, currency)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+			collection, payment.ID.String(), role, party.AccountName, party.AccountNumber, party.AccountNumberCode,
+			party.AccountType, party.Address, party.BankID, party.BankIDCode, party.Name, party.Currency)
+		if err != nil {
+			return err
+		}
+	}
+
+	charges := payment.ChargesInformation
+	_, err := tx.ExecContext(ctx, `INSERT INTO charges (collection, payment, bearer_code, receiver_charges_amount,
+		receiver_charges_currency) VALUES ($1, $2, $3, $4, $5)`,
+		collection, payment.ID.String(), charges.BearerCode, charges.ReceiverChargesAmount, charges.ReceiverChargesCurrency)
+	if err != nil {
+		return err
+	}
+	for i, charge := range charges.SenderCharges {
+		_, err := tx.ExecContext(ctx, `INSERT INTO sender_charges (collection, payment, position, amount, currency)
+			VALUES ($1, $2, $3, $4, $5)`, collection, payment.ID.String(), i, charge.Amount, charge.Currency)
+		if err != nil {
+			return err
+		}
+	}
+
+	fx := payment.Fx
+	_, err = tx.ExecContext(ctx, `INSERT INTO fx (collection, payment, contract_reference, exchange_rate, original_amount,
+		original_currency) VALUES ($1, $2, $3, $4, $5, $6)`,
+		collection, payment.ID.String(), fx.ContactReference, fx.ExchangeRate, fx.OriginalAmount, fx.OriginalCurrency)
+	return err
+}
+
+// Helper function to get the payments table columns following the collection and id, in insert order
+func paymentColumns(payment model.Payment) []interface{} {
+	var paymentID sql.NullString
+	if payment.PaymentID != "" {
+		paymentID = sql.NullString{String: payment.PaymentID, Valid: true}
+	}
+	return []interface{}{payment.Type, payment.Version, payment.OrganisationId, paymentID, payment.Amount,
+		payment.Currency, payment.EndToEndReference, payment.NumericReference, payment.PaymentPurpose,
+		payment.PaymentScheme, payment.PaymentType, payment.ProcessingDate, payment.Reference,
+		payment.SchemePaymentSubType, payment.SchemePaymentType, payment.SponsorParty.AccountNumber,
+		payment.SponsorParty.BankID, payment.SponsorParty.BankIDCode, payment.Status}
+}
+
+// Helper function to record events in the outbox of the db
+func insertEvents(ctx context.Context, tx *sql.Tx, db string, events ...model.Event) error {
+	for _, event := range events {
+		data, err := bson.Marshal(event)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "INSERT INTO outbox (db, id, payment, event, occurred_at) VALUES ($1, $2, $3, $4, $5)",
+			db, event.ID, event.PaymentID.String(), data, event.OccurredAt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Helper function to pass a zero time bound as NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// Helper function to get the key scoping the rows of a db and collection
+func collectionKey(db, col string) string {
+	return db + "." + col
+}
+
+// Helper function to translate postgres errors into repository errors
+func mapPostgresError(err error) error {
+	var pqErr *pq.Error
+	switch {
+	case err == nil:
+		return nil
+	case err == ErrNotFound:
+		return err
+	case errors.As(err, &pqErr) && pqErr.Code == uniqueViolation:
+		return ErrDuplicate
+	case errors.Is(err, context.Canceled):
+		return model.ErrCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return model.ErrDeadlineExceeded
+	}
+	return err
+}
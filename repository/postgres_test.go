@@ -0,0 +1,67 @@
+package repository_test
+
+import (
+	"context"
+	"payment-service/model"
+	"payment-service/repository"
+	"payment-service/repository/repositorytest"
+	"payment-service/test"
+	"testing"
+)
+
+func TestPostgresRepository_Conformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+		return repository.PostgresRepositoryUnderTest(t)
+	})
+}
+
+func TestPostgresRepository_MigrateShouldBeIdempotent(t *testing.T) {
+	repo := repository.PostgresRepositoryUnderTest(t)
+
+	t.Logf("Given the migrations have been applied")
+	{
+		t.Logf("\tWhen applying them again")
+		{
+			if err := repo.Migrate(context.Background()); err == nil {
+				t.Logf("\t\tThe migration should have been successful %v", test.CheckMark)
+			} else {
+				t.Errorf("\t\tThe migration should have been successful %v %v", test.BallotX, err)
+			}
+		}
+	}
+}
+
+func TestPostgresRepository_ShouldStoreTheNormalisedDetails(t *testing.T) {
+	repo := repository.PostgresRepositoryUnderTest(t)
+
+	t.Logf("Given a payment with parties, charges and fx")
+	{
+		payment := model.Payment{Type: "Payment", ID: model.NewID()}
+		payment.BeneficiaryParty = model.Party{Name: "Wilfred Jeremiah Owens", AccountNumber: "31926819", Currency: "USD"}
+		payment.DebtorParty = model.Party{Name: "Emelia Jane Brown", AccountNumber: "GB29XABC10161234567801", Currency: "GBP"}
+		payment.ChargesInformation = model.ChargesInformation{BearerCode: "SHAR", ReceiverChargesAmount: 1,
+			ReceiverChargesCurrency: "USD", SenderCharges: []model.Charge{{Amount: 5, Currency: "GBP"}, {Amount: 10, Currency: "USD"}}}
+		payment.Fx = model.ForeignExchange{ContactReference: "FX123", ExchangeRate: 2, OriginalAmount: 200.42, OriginalCurrency: "GBP"}
+
+		t.Logf("\tWhen inserting and querying it")
+		{
+			err := repo.Insert(context.Background(), "paymentDb", "payments", payment)
+			if err != nil {
+				t.Fatalf("\t\tThe insert should have been successful %v %v", test.BallotX, err)
+			}
+			res, err := repo.Find(context.Background(), "paymentDb", "payments", payment.ID)
+			if err != nil {
+				t.Fatalf("\t\tThe find should have been successful %v %v", test.BallotX, err)
+			}
+
+			found := res.Data[0]
+			if found.BeneficiaryParty == payment.BeneficiaryParty && found.DebtorParty == payment.DebtorParty &&
+				found.Fx == payment.Fx && len(found.ChargesInformation.SenderCharges) == 2 &&
+				found.ChargesInformation.SenderCharges[1] == payment.ChargesInformation.SenderCharges[1] {
+				t.Logf("\t\tThe details should have been stored %v", test.CheckMark)
+			} else {
+				t.Errorf("\t\tThe details should have been stored %v %v", test.BallotX, found)
+			}
+		}
+	}
+}
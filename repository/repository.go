@@ -1,80 +1,513 @@
 package repository
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/globalsign/mgo"
-	"github.com/globalsign/mgo/bson"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"payment-service/migration"
 	"payment-service/model"
 )
 
-// MongoRepository type
+var (
+	// ErrNotFound returned when no payment matches the given ID
+	ErrNotFound = errors.New("payment not found")
+
+	// ErrDuplicate returned when a payment with the same ID or payment_id already exists
+	ErrDuplicate = errors.New("payment already exists")
+)
+
+// keys of the client assigned payment_id attribute and of the filtered fields in the stored document
+const (
+	paymentIDKey       = "attributes.paymentid"
+	organisationIDKey  = "organisationid"
+	processingDateAttr = "attributes.processingdate"
+)
+
+// DisconnectTimeout bounds how long closing the mongo repository waits for the connections in use
+const DisconnectTimeout = 10 * time.Second
+
+// MongoRepository type. The Client holds a connection pool shared by all the requests, it monitors the servers and
+// reconnects on its own after a dropped connection.
 type MongoRepository struct {
-	Session *mgo.Session
+	Client *mongo.Client
+}
+
+// Options tunes the mongo connection pool and the read and write consistency
+type Options struct {
+	// PoolLimit the maximum number of connections per server, zero keeps the driver default
+	PoolLimit int
+
+	// ReadPreference one of primary, primaryPreferred, secondary, secondaryPreferred or nearest
+	ReadPreference string
+
+	// WriteConcern the number of nodes which must acknowledge writes, or "majority"
+	WriteConcern string
+
+	// Journal makes writes wait for the journal commit
+	Journal bool
+
+	// WriteTimeout bounds how long writes wait for the write concern, zero meaning no limit
+	WriteTimeout time.Duration
 }
 
 // Repository interface
 type Repository interface {
 
 	// Insert content in the given db and collection
-	Insert(db, col string, content interface{}) error
+	Insert(ctx context.Context, db, col string, content interface{}) error
 
 	// Find all the notes
-	FindAll(db, col string) (model.PaymentResponse, error)
+	FindAll(ctx context.Context, db, col string) (model.PaymentResponse, error)
+
+	// FindPage finds up to limit payments matching the filter ordered by ID, as model.ID.Less orders them, after
+	// skipping the first skip
+	FindPage(ctx context.Context, db, col string, filter Filter, skip, limit int) (model.PaymentResponse, error)
 
 	// Find a payment for a given ID
-	Find(db, col string, oid bson.ObjectId) (model.PaymentResponse, error)
+	Find(ctx context.Context, db, col string, id model.ID) (model.PaymentResponse, error)
 
 	// Delete a payment for a given ID
-	Delete(db, col string, oid bson.ObjectId) error
+	Delete(ctx context.Context, db, col string, id model.ID) error
 
 	// Update a payment for given ID
-	Update(db, col string, oid bson.ObjectId, content interface{}) error
+	Update(ctx context.Context, db, col string, id model.ID, content interface{}) error
+
+	// Patch persists the fields which differ between the original and patched payment
+	Patch(ctx context.Context, db, col string, id model.ID, original, patched model.Payment) error
+
+	// EnsureIndexes creates the indexes guaranteeing payment identifiers uniqueness and serving the list filters
+	EnsureIndexes(ctx context.Context, db, col string) error
+
+	// Ping checks the storage is reachable and serving requests
+	Ping(ctx context.Context) error
+
+	// Close releases the connections or files of the repository, which must no longer be used
+	Close() error
+
+	// Outbox of the events recorded by the writes
+	Outbox
+}
+
+// Filter narrows the queried payments to an organisation and a processing date range, the zero Filter matching every
+// payment
+type Filter struct {
+	OrganisationID string
+
+	// ProcessedFrom and ProcessedTo bound the processing date in [ProcessedFrom, ProcessedTo), a zero bound leaving
+	// the range open on its side
+	ProcessedFrom time.Time
+	ProcessedTo   time.Time
+}
+
+// IsZero tells whether the filter matches every payment
+func (f Filter) IsZero() bool {
+	return f.OrganisationID == "" && f.ProcessedFrom.IsZero() && f.ProcessedTo.IsZero()
+}
+
+// Match tells whether a payment matches the filter
+func (f Filter) Match(payment model.Payment) bool {
+	return (f.OrganisationID == "" || payment.OrganisationId == f.OrganisationID) &&
+		(f.ProcessedFrom.IsZero() || !payment.ProcessingDate.Before(f.ProcessedFrom)) &&
+		(f.ProcessedTo.IsZero() || payment.ProcessingDate.Before(f.ProcessedTo))
+}
+
+// Ping checks the primary is reachable, the writes going to it
+func (repo *MongoRepository) Ping(ctx context.Context) error {
+	return repo.Client.Ping(ctx, readpref.Primary())
+}
+
+// Close disconnects from mongo, waiting up to DisconnectTimeout for the connections in use to be returned
+func (repo *MongoRepository) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), DisconnectTimeout)
+	defer cancel()
+	return repo.Client.Disconnect(ctx)
 }
 
 // Insert content into db
-func (repo *MongoRepository) Insert(db string, col string, content interface{}) error {
-	return repo.Session.DB(db).C(col).Insert(&content)
+func (repo *MongoRepository) Insert(ctx context.Context, db string, col string, content interface{}) error {
+	payment, err := decode(content)
+	if err != nil {
+		return err
+	}
+	if payment.ID == "" {
+		payment.ID = model.NewID()
+	}
+	return repo.transaction(ctx, db, func(sc mongo.SessionContext) ([]model.Event, error) {
+		_, err := repo.Client.Database(db).Collection(col).InsertOne(sc, payment)
+		return []model.Event{model.NewEvent(model.PaymentCreated, payment)}, err
+	})
 }
 
 // Find query tag for a given id
-func (repo *MongoRepository) Find(db string, collection string, oid bson.ObjectId) (model.PaymentResponse, error) {
-	var result model.Payment
-	err := repo.Session.DB(db).C(collection).FindId(oid).One(&result)
-	return model.PaymentResponse{Data: []model.Payment{result}, Links: model.Links{Self: "https://api.test.form3.tech/v1/payments"}}, err
+func (repo *MongoRepository) Find(ctx context.Context, db string, collection string, id model.ID) (model.PaymentResponse, error) {
+	raw, err := repo.Client.Database(db).Collection(collection).FindOne(ctx, selector(id)).Raw()
+	if err != nil {
+		return model.PaymentResponse{}, mapError(err)
+	}
+	result, err := migration.DecodePayment(raw)
+	if err != nil {
+		return model.PaymentResponse{}, err
+	}
+	return model.PaymentResponse{Data: []model.Payment{result}, Links: model.Links{Self: "https://api.test.form3.tech/v1/payments"}}, nil
 }
 
 // FindAll query all the
-func (repo *MongoRepository) FindAll(db string, col string) (model.PaymentResponse, error) {
+func (repo *MongoRepository) FindAll(ctx context.Context, db string, col string) (model.PaymentResponse, error) {
+	return repo.find(ctx, db, col, bson.M{})
+}
+
+// FindPage query a page of the payments matching the filter, sorted and skipped through the _id index
+func (repo *MongoRepository) FindPage(ctx context.Context, db, col string, filter Filter, skip, limit int) (model.PaymentResponse, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetSkip(int64(skip)).SetLimit(int64(limit))
+	return repo.find(ctx, db, col, query(filter), opts)
+}
+
+// Helper function to query the payments of a collection matching the query with the given options
+func (repo *MongoRepository) find(ctx context.Context, db, col string, query bson.M, opts ...*options.FindOptions) (model.PaymentResponse, error) {
 	var result []model.Payment
-	err := repo.Session.DB(db).C(col).Find(nil).All(&result)
-	return model.PaymentResponse{Data: result, Links: model.Links{Self: "https://api.test.form3.tech/v1/payments"}}, err
+	cursor, err := repo.Client.Database(db).Collection(col).Find(ctx, query, opts...)
+	if err != nil {
+		return model.PaymentResponse{}, mapError(err)
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		payment, err := migration.DecodePayment(cursor.Current)
+		if err != nil {
+			return model.PaymentResponse{}, err
+		}
+		result = append(result, payment)
+	}
+	if err := cursor.Err(); err != nil {
+		return model.PaymentResponse{}, mapError(err)
+	}
+	return model.PaymentResponse{Data: result, Links: model.Links{Self: "https://api.test.form3.tech/v1/payments"}}, nil
 }
 
 // Delete payment
-func (repo *MongoRepository) Delete(db, col string, oid bson.ObjectId) error {
-	return repo.Session.DB(db).C(col).RemoveId(oid)
+func (repo *MongoRepository) Delete(ctx context.Context, db, col string, id model.ID) error {
+	return repo.transaction(ctx, db, func(sc mongo.SessionContext) ([]model.Event, error) {
+		raw, err := repo.Client.Database(db).Collection(col).FindOneAndDelete(sc, selector(id)).Raw()
+		if err != nil {
+			return nil, err
+		}
+		deleted, err := migration.DecodePayment(raw)
+		return []model.Event{model.NewEvent(model.PaymentDeleted, deleted)}, err
+	})
 }
 
 // Update Given Payment
-func (repo *MongoRepository) Update(db string, collection string, oid bson.ObjectId, content interface{}) error {
-	return repo.Session.DB(db).C(collection).UpdateId(oid, content)
+func (repo *MongoRepository) Update(ctx context.Context, db string, collection string, id model.ID, content interface{}) error {
+	payment, err := decode(content)
+	if err != nil {
+		return err
+	}
+	return repo.transaction(ctx, db, func(sc mongo.SessionContext) ([]model.Event, error) {
+		raw, err := repo.Client.Database(db).Collection(collection).FindOneAndReplace(sc, selector(id), content).Raw()
+		if err != nil {
+			return nil, err
+		}
+		original, err := migration.DecodePayment(raw)
+		payment.ID = original.ID
+		return model.UpdateEvents(original, payment), err
+	})
 }
 
-// NewRepository creates a Repository type
-func NewRepository(uri string) Repository {
-	dialInfo, err := mgo.ParseURL(uri)
+// Patch applies a partial update setting only the changed fields of the payment. The original being read in the
+// current schema version, the update only applies to documents stored in it, outdated ones being replaced instead.
+func (repo *MongoRepository) Patch(ctx context.Context, db, col string, id model.ID, original, patched model.Payment) error {
+	update, err := diff(original, patched)
+	if err != nil || len(update) == 0 {
+		return err
+	}
+	filter := bson.M{"$and": []bson.M{selector(id), {migration.VersionKey: model.SchemaVersion}}}
+	return repo.transaction(ctx, db, func(sc mongo.SessionContext) ([]model.Event, error) {
+		res, err := repo.Client.Database(db).Collection(col).UpdateOne(sc, filter, update)
+		if err == nil && res.MatchedCount == 0 {
+			patched.SchemaVersion = model.SchemaVersion
+			res, err = repo.Client.Database(db).Collection(col).ReplaceOne(sc, selector(id), patched)
+		}
+		return model.UpdateEvents(original, patched), matched(res, err)
+	})
+}
+
+// Pending returns up to limit events of the db which have not been acknowledged, in the order they were recorded,
+// leaving out the events of the excluded payments
+func (repo *MongoRepository) Pending(ctx context.Context, db string, limit int, exclude ...model.ID) ([]model.Event, error) {
+	filter := bson.M{}
+	if len(exclude) > 0 {
+		filter["paymentid"] = bson.M{"$nin": exclude}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "occurredat", Value: 1}, {Key: "_id", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := repo.Client.Database(db).Collection(OutboxCollection).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	var events []model.Event
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, mapError(err)
+	}
+	return events, nil
+}
+
+// Ack removes published events from the outbox
+func (repo *MongoRepository) Ack(ctx context.Context, db string, ids ...string) error {
+	_, err := repo.Client.Database(db).Collection(OutboxCollection).DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	return mapError(err)
+}
+
+// Helper function to run a write in a transaction recording the events it returns in the outbox. Transactions need
+// mongo to run as a replica set, a single node one being enough.
+func (repo *MongoRepository) transaction(ctx context.Context, db string, write func(sc mongo.SessionContext) ([]model.Event, error)) error {
+	session, err := repo.Client.StartSession()
+	if err != nil {
+		return mapError(err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		events, err := write(sc)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			if _, err := repo.Client.Database(db).Collection(OutboxCollection).InsertOne(sc, event); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	return mapError(err)
+}
+
+// EnsureIndexes adds a unique index on the client assigned payment_id attribute, and the outbox ordering index
+func (repo *MongoRepository) EnsureIndexes(ctx context.Context, db, col string) error {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: paymentIDKey, Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true)},
+		// the filters of the paginated list, sorted by _id
+		{Keys: bson.D{{Key: organisationIDKey, Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: processingDateAttr, Value: 1}}},
+	}
+	if _, err := repo.Client.Database(db).Collection(col).Indexes().CreateMany(ctx, indexes); err != nil {
+		return mapError(err)
+	}
+	outbox := mongo.IndexModel{Keys: bson.D{{Key: "occurredat", Value: 1}, {Key: "_id", Value: 1}}}
+	_, err := repo.Client.Database(db).Collection(OutboxCollection).Indexes().CreateOne(ctx, outbox)
+	return mapError(err)
+}
+
+// Helper function to build the query matching the payments of a filter
+func query(filter Filter) bson.M {
+	q := bson.M{}
+	if filter.OrganisationID != "" {
+		q[organisationIDKey] = filter.OrganisationID
+	}
+	date := bson.M{}
+	if !filter.ProcessedFrom.IsZero() {
+		date["$gte"] = filter.ProcessedFrom
+	}
+	if !filter.ProcessedTo.IsZero() {
+		date["$lt"] = filter.ProcessedTo
+	}
+	if len(date) > 0 {
+		q[processingDateAttr] = date
+	}
+	return q
+}
+
+// Helper function to build the query matching either the resource ID or, for UUIDs, the payment_id attribute
+func selector(id model.ID) bson.M {
+	if id.IsObjectId() {
+		return bson.M{"_id": id}
+	}
+	return bson.M{"$or": []bson.M{{"_id": id}, {paymentIDKey: id.String()}}}
+}
+
+// Helper function to build the $set/$unset update turning the original document into the patched one
+func diff(original, patched model.Payment) (bson.M, error) {
+	before, err := flatten(original)
+	if err != nil {
+		return nil, err
+	}
+	after, err := flatten(patched)
+	if err != nil {
+		return nil, err
+	}
+
+	set, unset := bson.M{}, bson.M{}
+	for k, v := range after {
+		if !reflect.DeepEqual(before[k], v) {
+			set[k] = v
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			unset[k] = ""
+		}
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update, nil
+}
+
+// Helper function to get the stored fields of a payment keyed by their dotted path
+func flatten(payment model.Payment) (bson.M, error) {
+	data, err := bson.Marshal(payment)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	fields := bson.M{}
+	for k, v := range doc {
+		if nested, ok := v.(bson.M); ok {
+			for nk, nv := range nested {
+				fields[k+"."+nk] = nv
+			}
+			continue
+		}
+		fields[k] = v
+	}
+	return fields, nil
+}
+
+// Helper function to turn an update matching no document into ErrNotFound
+func matched(res *mongo.UpdateResult, err error) error {
 	if err != nil {
-		log.Panicf("Failed to parse Mongo URI")
+		return mapError(err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
 	}
+	return nil
+}
+
+// Helper function to translate driver errors into repository errors
+func mapError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case err == mongo.ErrNoDocuments:
+		return ErrNotFound
+	case mongo.IsDuplicateKeyError(err):
+		return ErrDuplicate
+	case errors.Is(err, context.Canceled):
+		return model.ErrCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return model.ErrDeadlineExceeded
+	}
+	return err
+}
 
-	// get session
-	session, err := mgo.DialWithInfo(dialInfo)
+// NewRepository creates a Repository type
+func NewRepository(uri string) Repository {
+	return NewRepositoryWithOptions(uri, Options{})
+}
 
+// NewRepositoryWithOptions creates a Repository type with the given pool and consistency options
+func NewRepositoryWithOptions(uri string, opts Options) Repository {
+	repository, err := NewMongoRepository(uri, opts)
 	if err != nil {
 		panic(err)
 	}
-	repository := &MongoRepository{session}
 	return repository
+}
 
+// Open creates the Repository selected by the scheme of the given URL: mongodb:// and mongodb+srv:// connect to mongo,
+// postgres:// and postgresql:// to PostgreSQL, bolt:// opens the bbolt file at the path following the scheme and
+// memory:// keeps the payments in memory.
+func Open(url string, opts Options) (Repository, error) {
+	switch scheme(url) {
+	case "mongodb", "mongodb+srv":
+		return NewMongoRepository(url, opts)
+	case "postgres", "postgresql":
+		return NewPostgresRepository(url)
+	case "bolt":
+		return NewBoltRepository(url[len("bolt://"):])
+	case "memory":
+		return NewMemoryRepository(), nil
+	}
+	return nil, fmt.Errorf("unsupported repository url %q", url)
+}
+
+// NewMongoRepository connects to mongo with the given pool and consistency options
+func NewMongoRepository(uri string, opts Options) (*MongoRepository, error) {
+	clientOpts := options.Client().ApplyURI(uri)
+	if err := clientOpts.Validate(); err != nil {
+		return nil, fmt.Errorf("failed to parse mongo URI: %s", err)
+	}
+	if opts.PoolLimit > 0 {
+		clientOpts.SetMaxPoolSize(uint64(opts.PoolLimit))
+	}
+	if opts.ReadPreference != "" {
+		mode, err := readpref.ModeFromString(opts.ReadPreference)
+		if err != nil {
+			return nil, fmt.Errorf("unknown read preference %s", opts.ReadPreference)
+		}
+		pref, err := readpref.New(mode)
+		if err != nil {
+			return nil, err
+		}
+		clientOpts.SetReadPreference(pref)
+	}
+	if wc := writeConcern(opts); wc != nil {
+		clientOpts.SetWriteConcern(wc)
+	}
+
+	// connect and make sure the server is reachable
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, clientOpts)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+	return &MongoRepository{client}, nil
+}
+
+// Helper function to get the scheme of a repository URL
+func scheme(url string) string {
+	if i := strings.Index(url, "://"); i > 0 {
+		return strings.ToLower(url[:i])
+	}
+	return ""
+}
+
+// Helper function to build the write concern from the options, nil keeping the one of the connection string
+func writeConcern(opts Options) *writeconcern.WriteConcern {
+	if opts.WriteConcern == "" && !opts.Journal && opts.WriteTimeout == 0 {
+		return nil
+	}
+	wc := &writeconcern.WriteConcern{WTimeout: opts.WriteTimeout}
+	if w, err := strconv.Atoi(opts.WriteConcern); err == nil {
+		wc.W = w
+	} else if opts.WriteConcern != "" {
+		wc.W = opts.WriteConcern
+	}
+	if opts.Journal {
+		journal := true
+		wc.Journal = &journal
+	}
+	return wc
 }
@@ -1,9 +1,10 @@
 package repository_test
 
 import (
-	"github.com/globalsign/mgo/bson"
+	"context"
 	"payment-service/model"
 	"payment-service/repository"
+	"payment-service/repository/repositorytest"
 	"payment-service/test"
 	"testing"
 )
@@ -14,8 +15,8 @@ func TestMongoRepository_InsertShoulBeSuccessful(t *testing.T) {
 	{
 		t.Logf("\tWhen Inserting objct into DB")
 		{
-			payment := model.Payment{Type: "Payment", ID: bson.NewObjectId()}
-			err := repository.RepositoryUnderTest.Insert("paymentDb", "payments", payment)
+			payment := model.Payment{Type: "Payment", ID: model.NewID()}
+			err := repository.MongoUnderTest(t).Insert(context.Background(), "paymentDb", "payments", payment)
 			if err == nil {
 				t.Logf("\t\tThe insert should have been successful %v", test.CheckMark)
 			} else {
@@ -32,9 +33,9 @@ func TestMongoRepository_DeleteShoulBeSuccessful(t *testing.T) {
 		t.Logf("\tWhen Inserting objct into DB")
 		{
 			// Insert payment
-			obi := bson.NewObjectId()
+			obi := model.NewID()
 			payment := model.Payment{Type: "Payment", ID: obi}
-			err := repository.RepositoryUnderTest.Insert("paymentDb", "payments", payment)
+			err := repository.MongoUnderTest(t).Insert(context.Background(), "paymentDb", "payments", payment)
 			if err == nil {
 				t.Logf("\t\tThe insert should have been successful %v", test.CheckMark)
 			} else {
@@ -42,7 +43,7 @@ func TestMongoRepository_DeleteShoulBeSuccessful(t *testing.T) {
 			}
 
 			// delete
-			err = repository.RepositoryUnderTest.Delete("paymentDb", "payments", obi)
+			err = repository.MongoUnderTest(t).Delete(context.Background(), "paymentDb", "payments", obi)
 			if err == nil {
 				t.Logf("\t\tThe insert should have been successful %v", test.CheckMark)
 			} else {
@@ -59,9 +60,9 @@ func TestMongoRepository_FindShouldBeSuccessful(t *testing.T) {
 		t.Logf("\tWhen Inserting objct into DB")
 		{
 			// Insert payment
-			obi := bson.NewObjectId()
+			obi := model.NewID()
 			payment := model.Payment{Type: "Payment", ID: obi}
-			err := repository.RepositoryUnderTest.Insert("paymentDb", "payments", payment)
+			err := repository.MongoUnderTest(t).Insert(context.Background(), "paymentDb", "payments", payment)
 			if err == nil {
 				t.Logf("\t\tThe insert should have been successful %v", test.CheckMark)
 			} else {
@@ -69,7 +70,7 @@ func TestMongoRepository_FindShouldBeSuccessful(t *testing.T) {
 			}
 
 			// find
-			res, err := repository.RepositoryUnderTest.Find("paymentDb", "payments", obi)
+			res, err := repository.MongoUnderTest(t).Find(context.Background(), "paymentDb", "payments", obi)
 
 			if err == nil {
 				t.Logf("\t\tThe insert should have been successful %v", test.CheckMark)
@@ -93,9 +94,9 @@ func TestMongoRepository_FindAllBeSuccessful(t *testing.T) {
 		t.Logf("\tWhen Inserting objct into DB")
 		{
 			// Insert payment
-			obi := bson.NewObjectId()
+			obi := model.NewID()
 			payment := model.Payment{Type: "Payment", ID: obi}
-			err := repository.RepositoryUnderTest.Insert("paymentDb", "payments", payment)
+			err := repository.MongoUnderTest(t).Insert(context.Background(), "paymentDb", "payments", payment)
 			if err == nil {
 				t.Logf("\t\tThe insert should have been successful %v", test.CheckMark)
 			} else {
@@ -103,7 +104,7 @@ func TestMongoRepository_FindAllBeSuccessful(t *testing.T) {
 			}
 
 			// find all
-			_, err = repository.RepositoryUnderTest.FindAll("paymentDb", "payments")
+			_, err = repository.MongoUnderTest(t).FindAll(context.Background(), "paymentDb", "payments")
 
 			if err == nil {
 				t.Logf("\t\tThe insert should have been successful %v", test.CheckMark)
@@ -121,9 +122,9 @@ func TestMongoRepository_UpdateBeSuccessful(t *testing.T) {
 		t.Logf("\tWhen Inserting objct into DB")
 		{
 			// Insert payment
-			obi := bson.NewObjectId()
+			obi := model.NewID()
 			payment := model.Payment{Type: "Payment", ID: obi, OrganisationId: "org1"}
-			err := repository.RepositoryUnderTest.Insert("paymentDb", "payments", payment)
+			err := repository.MongoUnderTest(t).Insert(context.Background(), "paymentDb", "payments", payment)
 			if err == nil {
 				t.Logf("\t\tThe insert should have been successful %v", test.CheckMark)
 			} else {
@@ -132,7 +133,7 @@ func TestMongoRepository_UpdateBeSuccessful(t *testing.T) {
 
 			// update
 			updated := model.Payment{Type: "Payment", ID: obi, OrganisationId: "org2"}
-			err = repository.RepositoryUnderTest.Update("paymentDb", "payments", obi, updated)
+			err = repository.MongoUnderTest(t).Update(context.Background(), "paymentDb", "payments", obi, updated)
 
 			if err == nil {
 				t.Logf("\t\tThe insert should have been successful %v", test.CheckMark)
@@ -141,7 +142,7 @@ func TestMongoRepository_UpdateBeSuccessful(t *testing.T) {
 			}
 
 			// find
-			res, err := repository.RepositoryUnderTest.Find("paymentDb", "payments", obi)
+			res, err := repository.MongoUnderTest(t).Find(context.Background(), "paymentDb", "payments", obi)
 
 			if err == nil {
 				t.Logf("\t\tThe insert should have been successful %v", test.CheckMark)
@@ -157,3 +158,87 @@ func TestMongoRepository_UpdateBeSuccessful(t *testing.T) {
 		}
 	}
 }
+
+func TestMongoRepository_DuplicatePaymentIDShouldFail(t *testing.T) {
+
+	t.Logf("Given the DB is up and running with the payment indexes")
+	{
+		t.Logf("\tWhen Inserting two payments with the same payment_id into DB")
+		{
+			if err := repository.MongoUnderTest(t).EnsureIndexes(context.Background(), "paymentDb", "payments"); err != nil {
+				t.Fatalf("\t\tThe indexes should have been created %v %v", test.BallotX, err)
+			}
+
+			paymentID := "6a6c0d86-4c0f-4ab4-a2c3-6d3c9fbd5e2a"
+			first := model.Payment{Type: "Payment", ID: model.NewID(), Attributes: model.Attributes{PaymentID: paymentID}}
+			second := model.Payment{Type: "Payment", ID: model.NewID(), Attributes: model.Attributes{PaymentID: paymentID}}
+
+			if err := repository.MongoUnderTest(t).Insert(context.Background(), "paymentDb", "payments", first); err == nil {
+				t.Logf("\t\tThe first insert should have been successful %v", test.CheckMark)
+			} else {
+				t.Errorf("\t\tThe first insert should have been successful %v %v", test.BallotX, err)
+			}
+
+			if err := repository.MongoUnderTest(t).Insert(context.Background(), "paymentDb", "payments", second); err == repository.ErrDuplicate {
+				t.Logf("\t\tThe second insert should have been rejected %v", test.CheckMark)
+			} else {
+				t.Errorf("\t\tThe second insert should have been rejected %v %v", test.BallotX, err)
+			}
+
+			// find by the payment_id attribute
+			res, err := repository.MongoUnderTest(t).Find(context.Background(), "paymentDb", "payments", model.ID(paymentID))
+			if err == nil && res.Data[0].ID == first.ID {
+				t.Logf("\t\tThe payment should be found by its payment_id %v", test.CheckMark)
+			} else {
+				t.Errorf("\t\tThe payment should be found by its payment_id %v %v", test.BallotX, err)
+			}
+		}
+	}
+}
+
+func TestMongoRepository_FindUnknownShouldReturnNotFound(t *testing.T) {
+
+	t.Logf("Given the DB is up and running")
+	{
+		t.Logf("\tWhen querying an unknown payment")
+		{
+			_, err := repository.MongoUnderTest(t).Find(context.Background(), "paymentDb", "payments", model.NewID())
+			if err == repository.ErrNotFound {
+				t.Logf("\t\tThe query should return not found %v", test.CheckMark)
+			} else {
+				t.Errorf("\t\tThe query should return not found %v %v", test.BallotX, err)
+			}
+		}
+	}
+}
+
+func TestMongoRepository_Conformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+		return repository.MongoUnderTest(t)
+	})
+}
+
+func TestOpen_ShouldSelectTheRepositoryFromTheURLScheme(t *testing.T) {
+
+	t.Logf("Given repository URLs")
+	{
+		t.Logf("\tWhen opening a memory:// URL")
+		{
+			repo, err := repository.Open("memory://", repository.Options{})
+			if _, ok := repo.(*repository.MemoryRepository); ok && err == nil {
+				t.Logf("\t\tAn in memory repository should have been created %v", test.CheckMark)
+			} else {
+				t.Errorf("\t\tAn in memory repository should have been created %v %v", test.BallotX, err)
+			}
+		}
+
+		t.Logf("\tWhen opening an URL with an unknown scheme")
+		{
+			if _, err := repository.Open("ftp://localhost/payments", repository.Options{}); err != nil {
+				t.Logf("\t\tOpen should have failed %v", test.CheckMark)
+			} else {
+				t.Errorf("\t\tOpen should have failed %v", test.BallotX)
+			}
+		}
+	}
+}
@@ -0,0 +1,495 @@
+// Package repositorytest provides the conformance suite every repository.Repository implementation must pass.
+package repositorytest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"payment-service/model"
+	"payment-service/repository"
+	"payment-service/test"
+)
+
+// DBName the database the suite writes to, each test using its own collection
+const DBName = "conformance"
+
+// Factory returns the repository under test
+type Factory func(t *testing.T) repository.Repository
+
+// collection counter keeping the tests isolated when the factory returns a shared repository
+var collections int64
+
+// Run runs the conformance suite against the repositories returned by the factory
+func Run(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, repo repository.Repository, col string)
+	}{
+		{"InsertAndFind", testInsertAndFind},
+		{"InsertAssignsID", testInsertAssignsID},
+		{"FindByPaymentID", testFindByPaymentID},
+		{"FindUnknown", testFindUnknown},
+		{"DuplicateID", testDuplicateID},
+		{"DuplicatePaymentID", testDuplicatePaymentID},
+		{"FindAllOrder", testFindAllOrder},
+		{"FindPage", testFindPage},
+		{"FindPageFiltered", testFindPageFiltered},
+		{"Update", testUpdate},
+		{"Patch", testPatch},
+		{"Delete", testDelete},
+		{"CanceledContext", testCanceledContext},
+		{"Ping", testPing},
+		{"OutboxEvents", testOutboxEvents},
+		{"OutboxFailedWrite", testOutboxFailedWrite},
+		{"OutboxAck", testOutboxAck},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			repo := factory(t)
+			col := fmt.Sprintf("%s-%d", tc.name, atomic.AddInt64(&collections, 1))
+			tc.run(t, repo, col)
+		})
+	}
+}
+
+func testInsertAndFind(t *testing.T, repo repository.Repository, col string) {
+	t.Logf("Given a payment inserted in the repository")
+	{
+		payment := newPayment()
+		mustInsert(t, repo, col, payment)
+
+		t.Logf("\tWhen querying it by its ID")
+		{
+			res, err := repo.Find(context.Background(), DBName, col, payment.ID)
+			check(t, err == nil, "The find should have been successful", err)
+			check(t, len(res.Data) == 1 && res.Data[0].ID == payment.ID, "The payment should have been returned", res.Data)
+			check(t, len(res.Data) == 1 && res.Data[0].Reference == payment.Reference, "The payment attributes should have been stored", res.Data)
+			check(t, res.Links.Self != "", "The response should link to the payments", res.Links)
+		}
+	}
+}
+
+func testInsertAssignsID(t *testing.T, repo repository.Repository, col string) {
+	t.Logf("Given a payment without ID")
+	{
+		payment := newPayment()
+		payment.ID = ""
+		mustInsert(t, repo, col, payment)
+
+		t.Logf("\tWhen querying all the payments")
+		{
+			res, err := repo.FindAll(context.Background(), DBName, col)
+			check(t, err == nil, "The find should have been successful", err)
+			check(t, len(res.Data) == 1 && res.Data[0].ID.IsObjectId(), "An ObjectId should have been assigned", res.Data)
+		}
+	}
+}
+
+func testFindByPaymentID(t *testing.T, repo repository.Repository, col string) {
+	t.Logf("Given a payment with a payment_id attribute")
+	{
+		payment := newPayment()
+		payment.PaymentID = "1b4e28ba-2fa1-11d2-883f-0016d3cca427"
+		mustInsert(t, repo, col, payment)
+
+		t.Logf("\tWhen querying it by its payment_id")
+		{
+			res, err := repo.Find(context.Background(), DBName, col, model.ID(payment.PaymentID))
+			check(t, err == nil, "The find should have been successful", err)
+			check(t, len(res.Data) == 1 && res.Data[0].ID == payment.ID, "The payment should have been returned", res.Data)
+		}
+	}
+}
+
+func testFindUnknown(t *testing.T, repo repository.Repository, col string) {
+	t.Logf("Given an empty repository")
+	{
+		t.Logf("\tWhen querying unknown ObjectId and UUID identifiers")
+		{
+			_, err := repo.Find(context.Background(), DBName, col, model.NewID())
+			check(t, err == repository.ErrNotFound, "The ObjectId should not have been found", err)
+			_, err = repo.Find(context.Background(), DBName, col, "6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+			check(t, err == repository.ErrNotFound, "The UUID should not have been found", err)
+		}
+	}
+}
+
+func testDuplicateID(t *testing.T, repo repository.Repository, col string) {
+	t.Logf("Given a payment inserted in the repository")
+	{
+		payment := newPayment()
+		mustInsert(t, repo, col, payment)
+
+		t.Logf("\tWhen inserting another payment with the same ID")
+		{
+			err := repo.Insert(context.Background(), DBName, col, payment)
+			check(t, err == repository.ErrDuplicate, "The insert should have failed with ErrDuplicate", err)
+		}
+	}
+}
+
+func testDuplicatePaymentID(t *testing.T, repo repository.Repository, col string) {
+	t.Logf("Given the indexes are created and a payment with a payment_id is inserted")
+	{
+		err := repo.EnsureIndexes(context.Background(), DBName, col)
+		check(t, err == nil, "The indexes should have been created", err)
+		payment := newPayment()
+		payment.PaymentID = "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+		mustInsert(t, repo, col, payment)
+
+		t.Logf("\tWhen inserting payments without payment_id and with the same payment_id")
+		{
+			mustInsert(t, repo, col, newPayment())
+			mustInsert(t, repo, col, newPayment())
+
+			duplicate := newPayment()
+			duplicate.PaymentID = payment.PaymentID
+			err := repo.Insert(context.Background(), DBName, col, duplicate)
+			check(t, err == repository.ErrDuplicate, "The insert of the same payment_id should have failed with ErrDuplicate", err)
+		}
+	}
+}
+
+func testFindAllOrder(t *testing.T, repo repository.Repository, col string) {
+	t.Logf("Given three payments inserted in the repository")
+	{
+		var ids []model.ID
+		for i := 0; i < 3; i++ {
+			payment := newPayment()
+			mustInsert(t, repo, col, payment)
+			ids = append(ids, payment.ID)
+		}
+
+		t.Logf("\tWhen querying all the payments")
+		{
+			res, err := repo.FindAll(context.Background(), DBName, col)
+			check(t, err == nil, "The find should have been successful", err)
+			ordered := len(res.Data) == len(ids)
+			for i := 0; ordered && i < len(ids); i++ {
+				ordered = res.Data[i].ID == ids[i]
+			}
+			check(t, ordered, "The payments should have been returned in insertion order", res.Data)
+		}
+	}
+}
+
+func testFindPage(t *testing.T, repo repository.Repository, col string) {
+	t.Logf("Given payments with ObjectId and UUID identifiers inserted in the repository")
+	{
+		var ids []model.ID
+		for _, id := range []model.ID{model.NewID(), "9b5b6e3a-1a3c-4d9e-8b1e-0f6f1c2d3e4f", model.NewID(),
+			"1b4e28ba-2fa1-11d2-883f-0016d3cca427", model.NewID()} {
+			payment := newPayment()
+			payment.ID = id
+			mustInsert(t, repo, col, payment)
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i].Less(ids[j]) })
+
+		t.Logf("\tWhen querying them two at a time")
+		{
+			var paged []model.ID
+			for skip := 0; skip <= len(ids); skip += 2 {
+				res, err := repo.FindPage(context.Background(), DBName, col, repository.Filter{}, skip, 2)
+				check(t, err == nil && len(res.Data) <= 2, "The page should have been found", err)
+				for _, p := range res.Data {
+					paged = append(paged, p.ID)
+				}
+			}
+			check(t, fmt.Sprint(paged) == fmt.Sprint(ids), "The payments should have been paged in ID order, the UUIDs first", paged)
+			check(t, ids[0] == "1b4e28ba-2fa1-11d2-883f-0016d3cca427" && ids[2].IsObjectId(), "The UUIDs should sort before the ObjectIds", ids)
+
+			res, err := repo.FindPage(context.Background(), DBName, col, repository.Filter{}, len(ids), 2)
+			check(t, err == nil && len(res.Data) == 0, "The page past the last payment should be empty", res.Data)
+		}
+	}
+}
+
+func testFindPageFiltered(t *testing.T, repo repository.Repository, col string) {
+	day := time.Date(2018, 9, 10, 0, 0, 0, 0, time.UTC)
+
+	t.Logf("Given payments of two organisations processed on consecutive days")
+	{
+		var ids []model.ID
+		for i := 0; i < 4; i++ {
+			payment := newPayment()
+			payment.OrganisationId = []string{"org-a", "org-b"}[i%2]
+			payment.ProcessingDate = day.Add(time.Duration(3-i) * 24 * time.Hour)
+			mustInsert(t, repo, col, payment)
+			ids = append(ids, payment.ID)
+		}
+
+		t.Logf("\tWhen querying the pages of an organisation")
+		{
+			orgA := []model.ID{ids[0], ids[2]}
+			sort.Slice(orgA, func(i, j int) bool { return orgA[i].Less(orgA[j]) })
+			res, err := repo.FindPage(context.Background(), DBName, col, repository.Filter{OrganisationID: "org-a"}, 0, 10)
+			check(t, err == nil && fmt.Sprint(pageIDs(res)) == fmt.Sprint(orgA), "The payments of the organisation should have been returned in ID order", pageIDs(res))
+			res, err = repo.FindPage(context.Background(), DBName, col, repository.Filter{OrganisationID: "org-a"}, 1, 1)
+			check(t, err == nil && fmt.Sprint(pageIDs(res)) == fmt.Sprint(orgA[1:]), "The second page should hold the last payment", pageIDs(res))
+		}
+
+		t.Logf("\tWhen querying the payments processed in a date range")
+		{
+			processed := []model.ID{ids[2], ids[3]}
+			sort.Slice(processed, func(i, j int) bool { return processed[i].Less(processed[j]) })
+			filter := repository.Filter{ProcessedFrom: day, ProcessedTo: day.Add(48 * time.Hour)}
+			res, err := repo.FindPage(context.Background(), DBName, col, filter, 0, 10)
+			check(t, err == nil && fmt.Sprint(pageIDs(res)) == fmt.Sprint(processed), "The payments processed in the range should have been returned", pageIDs(res))
+
+			filter.OrganisationID = "org-a"
+			res, err = repo.FindPage(context.Background(), DBName, col, filter, 0, 10)
+			check(t, err == nil && fmt.Sprint(pageIDs(res)) == fmt.Sprint([]model.ID{ids[2]}), "The payments of the organisation processed in the range should have been returned", pageIDs(res))
+
+			res, err = repo.FindPage(context.Background(), DBName, col, repository.Filter{ProcessedFrom: day.Add(72 * time.Hour)}, 0, 10)
+			check(t, err == nil && fmt.Sprint(pageIDs(res)) == fmt.Sprint([]model.ID{ids[0]}), "A range open on its end should have been honoured", pageIDs(res))
+		}
+	}
+}
+
+// Helper function to get the IDs of the payments of a page
+func pageIDs(res model.PaymentResponse) []model.ID {
+	var ids []model.ID
+	for _, p := range res.Data {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func testUpdate(t *testing.T, repo repository.Repository, col string) {
+	t.Logf("Given a payment inserted in the repository")
+	{
+		payment := newPayment()
+		mustInsert(t, repo, col, payment)
+
+		t.Logf("\tWhen updating it")
+		{
+			payment.Reference = "Updated reference"
+			err := repo.Update(context.Background(), DBName, col, payment.ID, payment)
+			check(t, err == nil, "The update should have been successful", err)
+
+			res, err := repo.Find(context.Background(), DBName, col, payment.ID)
+			check(t, err == nil && res.Data[0].Reference == payment.Reference, "The payment should have been updated", res.Data)
+
+			err = repo.Update(context.Background(), DBName, col, model.NewID(), payment)
+			check(t, err == repository.ErrNotFound, "Updating an unknown payment should fail with ErrNotFound", err)
+		}
+	}
+}
+
+func testPatch(t *testing.T, repo repository.Repository, col string) {
+	t.Logf("Given a payment inserted in the repository")
+	{
+		original := newPayment()
+		mustInsert(t, repo, col, original)
+
+		t.Logf("\tWhen patching a field and removing another")
+		{
+			patched := original
+			patched.Reference = "Patched reference"
+			patched.EndToEndReference = ""
+			err := repo.Patch(context.Background(), DBName, col, original.ID, original, patched)
+			check(t, err == nil, "The patch should have been successful", err)
+
+			res, err := repo.Find(context.Background(), DBName, col, original.ID)
+			check(t, err == nil && res.Data[0].Reference == patched.Reference && res.Data[0].EndToEndReference == "",
+				"The payment should have been patched", res.Data)
+			check(t, err == nil && res.Data[0].PaymentScheme == original.PaymentScheme, "The other fields should have been kept", res.Data)
+
+			err = repo.Patch(context.Background(), DBName, col, model.NewID(), original, patched)
+			check(t, err == repository.ErrNotFound, "Patching an unknown payment should fail with ErrNotFound", err)
+		}
+	}
+}
+
+func testDelete(t *testing.T, repo repository.Repository, col string) {
+	t.Logf("Given a payment inserted in the repository")
+	{
+		payment := newPayment()
+		mustInsert(t, repo, col, payment)
+
+		t.Logf("\tWhen deleting it twice")
+		{
+			err := repo.Delete(context.Background(), DBName, col, payment.ID)
+			check(t, err == nil, "The first delete should have been successful", err)
+
+			_, err = repo.Find(context.Background(), DBName, col, payment.ID)
+			check(t, err == repository.ErrNotFound, "The payment should have been removed", err)
+
+			err = repo.Delete(context.Background(), DBName, col, payment.ID)
+			check(t, err == repository.ErrNotFound, "The second delete should fail with ErrNotFound", err)
+		}
+	}
+}
+
+func testCanceledContext(t *testing.T, repo repository.Repository, col string) {
+	t.Logf("Given a cancelled request context")
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		t.Logf("\tWhen calling the repository")
+		{
+			payment := newPayment()
+			err := repo.Insert(ctx, DBName, col, payment)
+			check(t, err == model.ErrCanceled, "The insert should fail with ErrCanceled", err)
+			_, err = repo.Find(ctx, DBName, col, payment.ID)
+			check(t, err == model.ErrCanceled, "The find should fail with ErrCanceled", err)
+			_, err = repo.FindAll(ctx, DBName, col)
+			check(t, err == model.ErrCanceled, "The find all should fail with ErrCanceled", err)
+			_, err = repo.FindPage(ctx, DBName, col, repository.Filter{}, 0, 10)
+			check(t, err == model.ErrCanceled, "The find page should fail with ErrCanceled", err)
+			err = repo.Delete(ctx, DBName, col, payment.ID)
+			check(t, err == model.ErrCanceled, "The delete should fail with ErrCanceled", err)
+		}
+	}
+}
+
+func testPing(t *testing.T, repo repository.Repository, col string) {
+	t.Logf("Given a repository")
+	{
+		t.Logf("\tWhen pinging it")
+		{
+			err := repo.Ping(context.Background())
+			check(t, err == nil, "The ping should succeed", err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			err = repo.Ping(ctx)
+			check(t, err != nil, "The ping should fail with a cancelled context", err)
+		}
+	}
+}
+
+// The outbox tests use their collection name as db, each db having its own outbox
+func testOutboxEvents(t *testing.T, repo repository.Repository, db string) {
+	ctx := context.Background()
+	t.Logf("Given a payment inserted, updated with a new status, patched and deleted")
+	{
+		payment := newPayment()
+		check(t, repo.Insert(ctx, db, "payments", payment) == nil, "The insert should have been successful", nil)
+		updated := payment
+		updated.Status = "accepted"
+		check(t, repo.Update(ctx, db, "payments", payment.ID, updated) == nil, "The update should have been successful", nil)
+		patched := updated
+		patched.Reference = "Patched reference"
+		check(t, repo.Patch(ctx, db, "payments", payment.ID, updated, patched) == nil, "The patch should have been successful", nil)
+		check(t, repo.Delete(ctx, db, "payments", payment.ID) == nil, "The delete should have been successful", nil)
+
+		t.Logf("\tWhen reading the pending events")
+		{
+			events, err := repo.Pending(ctx, db, 100)
+			check(t, err == nil, "The pending events should have been read", err)
+			var types []string
+			for _, e := range events {
+				types = append(types, e.Type)
+			}
+			expected := []string{model.PaymentCreated, model.PaymentUpdated, model.PaymentStatusChanged, model.PaymentUpdated, model.PaymentDeleted}
+			check(t, fmt.Sprint(types) == fmt.Sprint(expected), "An event should have been recorded per change, in order", types)
+			if len(events) != len(expected) {
+				return
+			}
+			for _, e := range events {
+				check(t, e.ID != "" && e.PaymentID == payment.ID && e.Payment.ID == payment.ID && !e.OccurredAt.IsZero(),
+					"The "+e.Type+" event should identify the payment", e)
+			}
+			check(t, events[2].PreviousStatus == "" && events[2].Payment.Status == "accepted",
+				"The status change should carry the previous and new status", events[2])
+			check(t, events[3].Payment.Reference == patched.Reference, "The update should carry the patched payment", events[3].Payment)
+			check(t, events[4].Payment.Reference == patched.Reference, "The delete should carry the deleted payment", events[4].Payment)
+		}
+	}
+}
+
+func testOutboxFailedWrite(t *testing.T, repo repository.Repository, db string) {
+	ctx := context.Background()
+	t.Logf("Given a payment inserted twice")
+	{
+		payment := newPayment()
+		check(t, repo.Insert(ctx, db, "payments", payment) == nil, "The first insert should have been successful", nil)
+		err := repo.Insert(ctx, db, "payments", payment)
+		check(t, err == repository.ErrDuplicate, "The second insert should fail with ErrDuplicate", err)
+
+		t.Logf("\tWhen writing to an unknown payment")
+		{
+			err := repo.Update(ctx, db, "payments", model.NewID(), payment)
+			check(t, err == repository.ErrNotFound, "The update should fail with ErrNotFound", err)
+			err = repo.Delete(ctx, db, "payments", model.NewID())
+			check(t, err == repository.ErrNotFound, "The delete should fail with ErrNotFound", err)
+
+			events, err := repo.Pending(ctx, db, 100)
+			check(t, err == nil && len(events) == 1, "Only the successful write should have recorded an event", events)
+		}
+	}
+}
+
+func testOutboxAck(t *testing.T, repo repository.Repository, db string) {
+	ctx := context.Background()
+	t.Logf("Given three payments inserted")
+	{
+		var ids []model.ID
+		for i := 0; i < 3; i++ {
+			payment := newPayment()
+			check(t, repo.Insert(ctx, db, "payments", payment) == nil, "The insert should have been successful", nil)
+			ids = append(ids, payment.ID)
+		}
+
+		t.Logf("\tWhen reading a batch of two events and acknowledging the first")
+		{
+			events, err := repo.Pending(ctx, db, 2)
+			check(t, err == nil && len(events) == 2, "Two events should have been read", events)
+			if len(events) != 2 {
+				return
+			}
+			check(t, events[0].PaymentID == ids[0] && events[1].PaymentID == ids[1], "The oldest events should have been read first", events)
+
+			err = repo.Ack(ctx, db, events[0].ID, "unknown")
+			check(t, err == nil, "The acknowledgement should have been successful", err)
+			events, err = repo.Pending(ctx, db, 100)
+			check(t, err == nil && len(events) == 2 && events[0].PaymentID == ids[1] && events[1].PaymentID == ids[2],
+				"The acknowledged event should have been removed", events)
+
+			other, err := repo.Pending(ctx, DBName+"-empty", 100)
+			check(t, err == nil && len(other) == 0, "The outbox of another db should be empty", other)
+		}
+
+		t.Logf("\tWhen reading a batch of one event leaving out a payment")
+		{
+			events, err := repo.Pending(ctx, db, 1, ids[1])
+			check(t, err == nil && len(events) == 1 && events[0].PaymentID == ids[2],
+				"The events of the excluded payment should have been left out", events)
+		}
+	}
+}
+
+// Helper function to build a payment with a new ID
+func newPayment() model.Payment {
+	payment := model.Payment{Type: "Payment", ID: model.NewID(), OrganisationId: "743d5b63-8e6f-432e-a8fa-c5d8d2ee5fcb"}
+	payment.Reference = "Payment for Em's piano lessons"
+	payment.EndToEndReference = "Wil piano Jan"
+	payment.PaymentScheme = "FPS"
+	payment.Amount = 100.21
+	payment.ChargesInformation.SenderCharges = []model.Charge{{Amount: 5, Currency: "GBP"}}
+	return payment
+}
+
+// Helper function to insert a payment failing the test on error
+func mustInsert(t *testing.T, repo repository.Repository, col string, payment model.Payment) {
+	if err := repo.Insert(context.Background(), DBName, col, payment); err != nil {
+		t.Fatalf("\t\tThe insert should have been successful %v %v", test.BallotX, err)
+	}
+}
+
+// Helper function to log the outcome of an expectation
+func check(t *testing.T, ok bool, expectation string, got interface{}) {
+	if ok {
+		t.Logf("\t\t%s %v", expectation, test.CheckMark)
+	} else {
+		t.Errorf("\t\t%s %v %v", expectation, test.BallotX, got)
+	}
+}
@@ -0,0 +1,118 @@
+package rpc
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"payment-service/model"
+	"payment-service/rpc/paymentpb"
+)
+
+// Helper function to convert a payment into its protobuf message
+func toPayment(p model.Payment) *paymentpb.Payment {
+	return &paymentpb.Payment{
+		Type:           p.Type,
+		Id:             p.ID.String(),
+		Version:        int32(p.Version),
+		OrganisationId: p.OrganisationId,
+		Status:         p.Status,
+		Attributes: &paymentpb.Attributes{
+			Amount:               p.Amount,
+			BeneficiaryParty:     toParty(p.BeneficiaryParty),
+			ChargesInformation:   toCharges(p.ChargesInformation),
+			Currency:             p.Currency,
+			DebtorParty:          toParty(p.DebtorParty),
+			EndToEndReference:    p.EndToEndReference,
+			Fx:                   toFx(p.Fx),
+			NumericReference:     p.NumericReference,
+			PaymentId:            p.PaymentID,
+			PaymentPurpose:       p.PaymentPurpose,
+			PaymentScheme:        p.PaymentScheme,
+			PaymentType:          p.PaymentType,
+			ProcessingDate:       toTimestamp(p.ProcessingDate),
+			Reference:            p.Reference,
+			SchemePaymentSubType: p.SchemePaymentSubType,
+			SchemePaymentType:    p.SchemePaymentType,
+			SponsorParty:         toSponsor(p.SponsorParty),
+		},
+	}
+}
+
+// Helper function to convert a protobuf payment request into the request of the payment use cases
+func fromPaymentRequest(req *paymentpb.PaymentRequest) model.CreatePaymentRequest {
+	return model.CreatePaymentRequest{
+		ID:                   req.GetId(),
+		OrganisationID:       req.GetOrganisationId(),
+		BeneficiaryParty:     fromParty(req.GetBeneficiaryParty()),
+		DebtorParty:          fromParty(req.GetDebtorParty()),
+		PaymentPurpose:       req.GetPaymentPurpose(),
+		PaymentScheme:        req.GetPaymentScheme(),
+		PaymentType:          req.GetPaymentType(),
+		Reference:            req.GetReference(),
+		EndToEndReference:    req.GetEndToEndReference(),
+		SchemePaymentSubType: req.GetSchemePaymentSubType(),
+		SchemePaymentType:    req.GetSchemePaymentType(),
+		SponsorParty:         fromSponsor(req.GetSponsorParty()),
+		NumericReference:     req.GetNumericReference(),
+		PaymentID:            req.GetPaymentId(),
+		Amount:               req.GetAmount(),
+		BearerCode:           req.GetBearerCode(),
+		ProcessingDate:       fromTimestamp(req.GetProcessingDate()),
+	}
+}
+
+// Helper function to convert a party into its protobuf message
+func toParty(p model.Party) *paymentpb.Party {
+	return &paymentpb.Party{AccountName: p.AccountName, AccountNumber: p.AccountNumber,
+		AccountNumberCode: p.AccountNumberCode, AccountType: int32(p.AccountType), Address: p.Address, BankId: p.BankID,
+		BankIdCode: p.BankIDCode, Name: p.Name, Currency: p.Currency}
+}
+
+// Helper function to convert a protobuf party, a missing one being the zero party
+func fromParty(p *paymentpb.Party) model.Party {
+	return model.Party{AccountName: p.GetAccountName(), AccountNumber: p.GetAccountNumber(),
+		AccountNumberCode: p.GetAccountNumberCode(), AccountType: int(p.GetAccountType()), Address: p.GetAddress(),
+		BankID: p.GetBankId(), BankIDCode: p.GetBankIdCode(), Name: p.GetName(), Currency: p.GetCurrency()}
+}
+
+// Helper function to convert a sponsor party into its protobuf message
+func toSponsor(p model.SponsorParty) *paymentpb.SponsorParty {
+	return &paymentpb.SponsorParty{AccountNumber: p.AccountNumber, BankId: p.BankID, BankIdCode: p.BankIDCode}
+}
+
+// Helper function to convert a protobuf sponsor party
+func fromSponsor(p *paymentpb.SponsorParty) model.SponsorParty {
+	return model.SponsorParty{AccountNumber: p.GetAccountNumber(), BankID: p.GetBankId(), BankIDCode: p.GetBankIdCode()}
+}
+
+// Helper function to convert the charges information into its protobuf message
+func toCharges(c model.ChargesInformation) *paymentpb.ChargesInformation {
+	charges := &paymentpb.ChargesInformation{BearerCode: c.BearerCode, ReceiverChargesAmount: c.ReceiverChargesAmount,
+		ReceiverChargesCurrency: c.ReceiverChargesCurrency}
+	for _, charge := range c.SenderCharges {
+		charges.SenderCharges = append(charges.SenderCharges, &paymentpb.Charge{Amount: charge.Amount, Currency: charge.Currency})
+	}
+	return charges
+}
+
+// Helper function to convert the foreign exchange details into their protobuf message
+func toFx(fx model.ForeignExchange) *paymentpb.ForeignExchange {
+	return &paymentpb.ForeignExchange{ContractReference: fx.ContactReference, ExchangeRate: fx.ExchangeRate,
+		OriginalAmount: fx.OriginalAmount, OriginalCurrency: fx.OriginalCurrency}
+}
+
+// Helper function to convert a time, the zero time being left unset
+func toTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// Helper function to convert a timestamp, an unset one being the zero time
+func fromTimestamp(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
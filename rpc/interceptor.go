@@ -0,0 +1,97 @@
+package rpc
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"payment-service/logger"
+	"payment-service/tracing"
+)
+
+// RequestIDMetadata the metadata key of the request ID, the gRPC counterpart of the X-Request-ID header
+var RequestIDMetadata = strings.ToLower(logger.RequestIDHeader)
+
+// RequestID reads the request ID of the call metadata, generating one when it is missing or invalid, and adds it to
+// the response headers and to the call context, then logs the served call along its code and duration
+func RequestID(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDMetadata); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	if !logger.ValidRequestID(id) {
+		id = logger.NewRequestID()
+	}
+	ctx = logger.WithRequestID(ctx, id)
+	grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadata, id))
+
+	resp, err := handler(ctx, req)
+	slog.InfoContext(ctx, "Served RPC", "method", info.FullMethod, "code", status.Code(err).String(),
+		"duration", time.Since(start))
+	return resp, err
+}
+
+// TraceIDMetadata the trailer key of the trace ID of the failed calls, the gRPC counterpart of the trace_id of the REST
+// error responses
+const TraceIDMetadata = "trace-id"
+
+// Trace serves the call in a server span continuing the trace of the traceparent metadata, or starting one, the
+// trace ID being returned in the trailer of the failed calls
+func Trace(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	name := strings.TrimPrefix(info.FullMethod, "/")
+	service, method, _ := strings.Cut(name, "/")
+	ctx, span := tracing.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.RPCSystemGRPC, semconv.RPCService(service), semconv.RPCMethod(method)))
+	defer span.End()
+
+	resp, err := handler(ctx, req)
+	code := status.Code(err)
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Internal, codes.Unavailable, codes.DataLoss:
+		span.SetStatus(otelcodes.Error, code.String())
+	}
+	if id := tracing.TraceID(ctx); err != nil && id != "" {
+		grpc.SetTrailer(ctx, metadata.Pairs(TraceIDMetadata, id))
+	}
+	return resp, err
+}
+
+// metadataCarrier reads and writes the trace context in the gRPC metadata
+type metadataCarrier metadata.MD
+
+// Get returns the first value of the key
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Set sets the value of the key
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// Keys lists the keys of the metadata
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
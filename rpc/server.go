@@ -0,0 +1,188 @@
+// Package rpc serves the payment use cases over gRPC, alongside the REST API of package api.
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative paymentpb/payment.proto
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"payment-service/model"
+	"payment-service/payment"
+	"payment-service/privacy"
+	"payment-service/rpc/paymentpb"
+)
+
+// defaultPageSize the size of the pages when only a page token is given, as the default page size of the REST API
+const defaultPageSize = 100
+
+// Server implements the PaymentService RPCs on top of the payment use cases
+type Server struct {
+	paymentpb.UnimplementedPaymentServiceServer
+
+	payments *payment.Service
+	privacy  *privacy.Policy
+}
+
+// NewServer creates a Server for the given payment use cases
+func NewServer(payments *payment.Service) *Server {
+	return &Server{payments: payments}
+}
+
+// WithPrivacy masks the party details of the payments returned to the roles not unmasked by the given policy, the role
+// being read from the call metadata keyed by the lower case header of the policy
+func (s *Server) WithPrivacy(policy *privacy.Policy) *Server {
+	s.privacy = policy
+	return s
+}
+
+// NewGRPCServer creates a gRPC server with the PaymentService registered, the calls going through the Trace and
+// RequestID interceptors before the ones of the given options
+func NewGRPCServer(payments *payment.Service, opts ...grpc.ServerOption) *grpc.Server {
+	return NewGRPCServerFor(NewServer(payments), opts...)
+}
+
+// NewGRPCServerFor creates a gRPC server with the given PaymentService registered, see NewGRPCServer
+func NewGRPCServerFor(server *Server, opts ...grpc.ServerOption) *grpc.Server {
+	srv := grpc.NewServer(append([]grpc.ServerOption{grpc.ChainUnaryInterceptor(Trace, RequestID)}, opts...)...)
+	paymentpb.RegisterPaymentServiceServer(srv, server)
+	return srv
+}
+
+// CreatePayment prices and stores a new payment
+func (s *Server) CreatePayment(ctx context.Context, req *paymentpb.CreatePaymentRequest) (*paymentpb.Payment, error) {
+	slog.InfoContext(ctx, "Received RPC to create payment", "organisation_id", req.GetPayment().GetOrganisationId())
+	stored, err := s.payments.Create(ctx, fromPaymentRequest(req.GetPayment()))
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return toPayment(s.masker(ctx).Payment(stored)), nil
+}
+
+// GetPayment returns the payment with the given ID or payment_id
+func (s *Server) GetPayment(ctx context.Context, req *paymentpb.GetPaymentRequest) (*paymentpb.Payment, error) {
+	id, err := model.ParseID(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid payment ID")
+	}
+	resp, err := s.payments.Get(ctx, id)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return toPayment(s.masker(ctx).Payment(resp.Data[0])), nil
+}
+
+// ListPayments returns the payments ordered by ID, one page at a time when a page size or token is given, all of them
+// otherwise. The page token is the number of the next page.
+func (s *Server) ListPayments(ctx context.Context, req *paymentpb.ListPaymentsRequest) (*paymentpb.ListPaymentsResponse, error) {
+	list := &paymentpb.ListPaymentsResponse{}
+	var resp model.PaymentResponse
+	var err error
+	if req.GetPageSize() == 0 && req.GetPageToken() == "" {
+		resp, err = s.payments.List(ctx)
+	} else {
+		page := payment.Page{Size: int(req.GetPageSize())}
+		if page.Size == 0 {
+			page.Size = defaultPageSize
+		}
+		if req.GetPageToken() != "" {
+			if page.Number, err = strconv.Atoi(req.GetPageToken()); err != nil {
+				return nil, status.Error(codes.InvalidArgument, "invalid page token")
+			}
+		}
+		var more bool
+		if resp, more, err = s.payments.ListPage(ctx, page); more {
+			list.NextPageToken = strconv.Itoa(page.Number + 1)
+		}
+	}
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	for _, p := range s.masker(ctx).Payments(resp).Data {
+		list.Payments = append(list.Payments, toPayment(p))
+	}
+	return list, nil
+}
+
+// UpdatePayment replaces a payment, creating it when its ID is a UUID which does not exist yet
+func (s *Server) UpdatePayment(ctx context.Context, req *paymentpb.UpdatePaymentRequest) (*paymentpb.UpdatePaymentResponse, error) {
+	id, err := model.ParseID(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid payment ID")
+	}
+	stored, created, err := s.payments.Update(ctx, id, fromPaymentRequest(req.GetPayment()))
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return &paymentpb.UpdatePaymentResponse{Payment: toPayment(s.masker(ctx).Payment(stored)), Created: created}, nil
+}
+
+// PatchPayment applies a JSON merge patch to a payment, as the PATCH method of the REST API
+func (s *Server) PatchPayment(ctx context.Context, req *paymentpb.PatchPaymentRequest) (*paymentpb.Payment, error) {
+	id, err := model.ParseID(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid payment ID")
+	}
+	slog.InfoContext(ctx, "Received RPC to patch payment", "payment_id", id.String())
+	resp, err := s.payments.Patch(ctx, id, []byte(req.GetMergePatch()))
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return toPayment(s.masker(ctx).Payment(resp.Data[0])), nil
+}
+
+// DeletePayment deletes the payment with the given ID
+func (s *Server) DeletePayment(ctx context.Context, req *paymentpb.DeletePaymentRequest) (*emptypb.Empty, error) {
+	id, err := model.ParseID(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid payment ID")
+	}
+	if err := s.payments.Delete(ctx, id); err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// Helper function to get the Masker of the role of the caller, nil when the party details may be returned as is
+func (s *Server) masker(ctx context.Context) *privacy.Masker {
+	if s.privacy == nil {
+		return nil
+	}
+	var role string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(s.privacy.RoleHeader); len(values) > 0 {
+			role = values[0]
+		}
+	}
+	return s.privacy.Masker(role)
+}
+
+// Helper function to translate the error of a payment use case into a gRPC status, the counterpart of the REST
+// status codes
+func statusError(ctx context.Context, err error) error {
+	if _, ok := err.(payment.PricingError); ok {
+		slog.ErrorContext(ctx, "Failed to price payment", "error", err)
+		return status.Error(codes.Unavailable, "failed to price payment")
+	}
+	switch err {
+	case payment.ErrInvalidID, payment.ErrIDMismatch, payment.ErrInvalidRequest, payment.ErrInvalidPatch,
+		payment.ErrImmutableField, payment.ErrCalculatedField, payment.ErrInvalidPage:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case payment.ErrNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case payment.ErrDuplicate:
+		return status.Error(codes.AlreadyExists, err.Error())
+	case model.ErrCanceled:
+		return status.Error(codes.Canceled, err.Error())
+	case model.ErrDeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	slog.ErrorContext(ctx, "Failed to serve RPC", "error", err)
+	return status.Error(codes.Internal, "internal error")
+}
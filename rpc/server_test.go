@@ -0,0 +1,275 @@
+package rpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"payment-service/api"
+	"payment-service/model"
+	"payment-service/privacy"
+	"payment-service/repository"
+	"payment-service/rpc"
+	"payment-service/rpc/paymentpb"
+	"payment-service/test"
+	"payment-service/tracing"
+)
+
+func TestServer_ShouldServeThePaymentsLikeTheRESTAPI(t *testing.T) {
+	t.Logf("Given the gRPC and REST APIs sharing a repository")
+	{
+		repo := repository.NewMemoryRepository()
+		repo.EnsureIndexes(context.Background(), api.DatabaseName, api.CollectionName)
+		payments := api.NewPaymentService(repo, "urlFX", "urlCF")
+		client := dial(t, rpc.NewGRPCServer(payments))
+		router := api.NewPaymentHandlerFor(payments).NewRouter()
+		ctx := context.Background()
+
+		t.Logf("\tWhen creating a payment over gRPC")
+		{
+			req := paymentRequest()
+			created, err := client.CreatePayment(ctx, &paymentpb.CreatePaymentRequest{Payment: req})
+			check(t, err == nil && created.GetId() != "", "The payment should have been created", err)
+
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest(http.MethodGet, "/payment/"+created.GetId(), nil)
+			router.ServeHTTP(w, r)
+			var resp model.PaymentResponse
+			json.NewDecoder(w.Body).Decode(&resp)
+			check(t, w.Code == http.StatusOK && len(resp.Data) == 1, "The REST API should find it", w.Code)
+			got, err := client.GetPayment(ctx, &paymentpb.GetPaymentRequest{Id: created.GetId()})
+			check(t, err == nil && got.GetId() == created.GetId(), "The payment should be returned over gRPC", err)
+			stored := resp.Data[0]
+			check(t, stored.OrganisationId == req.OrganisationId && stored.Amount == got.GetAttributes().GetAmount() &&
+				stored.Fx.ExchangeRate == got.GetAttributes().GetFx().GetExchangeRate() &&
+				stored.ProcessingDate.Equal(got.GetAttributes().GetProcessingDate().AsTime()),
+				"Both APIs should return the same priced payment", got)
+			list, err := client.ListPayments(ctx, &paymentpb.ListPaymentsRequest{})
+			check(t, err == nil && len(list.GetPayments()) == 1, "The payment should be listed", list)
+
+			t.Logf("\tWhen patching and updating it")
+			{
+				patched, err := client.PatchPayment(ctx, &paymentpb.PatchPaymentRequest{Id: created.GetId(),
+					MergePatch: `{"status": "accepted", "reference": "Patched reference"}`})
+				check(t, err == nil && patched.GetStatus() == model.StatusAccepted &&
+					patched.GetAttributes().GetReference() == "Patched reference", "The patch should have been applied", err)
+				_, err = client.PatchPayment(ctx, &paymentpb.PatchPaymentRequest{Id: created.GetId(), MergePatch: `{"version": 3}`})
+				check(t, status.Code(err) == codes.InvalidArgument, "Patching the version should be an invalid argument", err)
+
+				update := paymentRequest()
+				update.Reference = "Updated reference"
+				updated, err := client.UpdatePayment(ctx, &paymentpb.UpdatePaymentRequest{Id: created.GetId(), Payment: update})
+				check(t, err == nil && !updated.GetCreated() && updated.GetPayment().GetStatus() == model.StatusAccepted &&
+					updated.GetPayment().GetAttributes().GetReference() == update.Reference, "The update should keep the status", err)
+			}
+
+			t.Logf("\tWhen deleting it")
+			{
+				_, err := client.DeletePayment(ctx, &paymentpb.DeletePaymentRequest{Id: created.GetId()})
+				check(t, err == nil, "The payment should have been deleted", err)
+				_, err = client.GetPayment(ctx, &paymentpb.GetPaymentRequest{Id: created.GetId()})
+				check(t, status.Code(err) == codes.NotFound, "The payment should no longer be found", err)
+			}
+		}
+	}
+}
+
+func TestServer_ShouldListThePaymentsOnePageAtATime(t *testing.T) {
+	t.Logf("Given five payments")
+	{
+		repo := repository.NewMemoryRepository()
+		repo.EnsureIndexes(context.Background(), api.DatabaseName, api.CollectionName)
+		client := dial(t, rpc.NewGRPCServer(api.NewPaymentService(repo, "urlFX", "urlCF")))
+		ctx := context.Background()
+		for i := 0; i < 5; i++ {
+			if _, err := client.CreatePayment(ctx, &paymentpb.CreatePaymentRequest{Payment: paymentRequest()}); err != nil {
+				t.Fatalf("Failed to create payment %v", err)
+			}
+		}
+
+		t.Logf("\tWhen listing them two at a time")
+		{
+			seen := map[string]bool{}
+			req := &paymentpb.ListPaymentsRequest{PageSize: 2}
+			pages := 0
+			for {
+				list, err := client.ListPayments(ctx, req)
+				if err != nil {
+					t.Fatalf("Failed to list payments %v", err)
+				}
+				pages++
+				for _, p := range list.GetPayments() {
+					seen[p.GetId()] = true
+				}
+				if req.PageToken = list.GetNextPageToken(); req.PageToken == "" {
+					break
+				}
+			}
+			check(t, pages == 3 && len(seen) == 5, "The payments should be returned once over three pages", seen)
+		}
+
+		t.Logf("\tWhen sending an invalid page")
+		{
+			_, err := client.ListPayments(ctx, &paymentpb.ListPaymentsRequest{PageToken: "not-a-token"})
+			check(t, status.Code(err) == codes.InvalidArgument, "An invalid page token should be an invalid argument", err)
+			_, err = client.ListPayments(ctx, &paymentpb.ListPaymentsRequest{PageSize: -1})
+			check(t, status.Code(err) == codes.InvalidArgument, "A negative page size should be an invalid argument", err)
+		}
+	}
+}
+
+func TestServer_ShouldMapTheErrorsLikeTheRESTAPI(t *testing.T) {
+	t.Logf("Given the gRPC API")
+	{
+		repo := repository.NewMemoryRepository()
+		repo.EnsureIndexes(context.Background(), api.DatabaseName, api.CollectionName)
+		client := dial(t, rpc.NewGRPCServer(api.NewPaymentService(repo, "urlFX", "urlCF")))
+		ctx := context.Background()
+
+		t.Logf("\tWhen sending invalid requests")
+		{
+			_, err := client.GetPayment(ctx, &paymentpb.GetPaymentRequest{Id: "not-an-id"})
+			check(t, status.Code(err) == codes.InvalidArgument, "A malformed ID should be an invalid argument", err)
+
+			req := paymentRequest()
+			req.Id = model.NewID().String()
+			_, err = client.CreatePayment(ctx, &paymentpb.CreatePaymentRequest{Payment: req})
+			check(t, status.Code(err) == codes.InvalidArgument, "A client chosen ObjectId should be an invalid argument", err)
+
+			req = paymentRequest()
+			req.OrganisationId = ""
+			_, err = client.CreatePayment(ctx, &paymentpb.CreatePaymentRequest{Payment: req})
+			check(t, status.Code(err) == codes.InvalidArgument, "A missing organisation should be an invalid argument", err)
+
+			req = paymentRequest()
+			req.Id = "9b5b6e3a-1a3c-4d9e-8b1e-0f6f1c2d3e4f"
+			_, err = client.CreatePayment(ctx, &paymentpb.CreatePaymentRequest{Payment: req})
+			check(t, err == nil, "A client chosen UUID should be accepted", err)
+			_, err = client.CreatePayment(ctx, &paymentpb.CreatePaymentRequest{Payment: req})
+			check(t, status.Code(err) == codes.AlreadyExists, "Reusing it should be refused", err)
+
+			_, err = client.UpdatePayment(ctx, &paymentpb.UpdatePaymentRequest{Id: model.NewID().String(), Payment: paymentRequest()})
+			check(t, status.Code(err) == codes.NotFound, "Updating a missing ObjectId payment should not be found", err)
+		}
+	}
+}
+
+func TestServer_ShouldReturnTheRequestID(t *testing.T) {
+	t.Logf("Given the gRPC API")
+	{
+		repo := repository.NewMemoryRepository()
+		client := dial(t, rpc.NewGRPCServer(api.NewPaymentService(repo, "urlFX", "urlCF")))
+
+		t.Logf("\tWhen calling it with and without a request ID")
+		{
+			var header metadata.MD
+			ctx := metadata.AppendToOutgoingContext(context.Background(), rpc.RequestIDMetadata, "req-1")
+			_, err := client.ListPayments(ctx, &paymentpb.ListPaymentsRequest{}, grpc.Header(&header))
+			check(t, err == nil && len(header.Get(rpc.RequestIDMetadata)) == 1 && header.Get(rpc.RequestIDMetadata)[0] == "req-1",
+				"The given request ID should be returned", header)
+
+			_, err = client.ListPayments(context.Background(), &paymentpb.ListPaymentsRequest{}, grpc.Header(&header))
+			check(t, err == nil && len(header.Get(rpc.RequestIDMetadata)) == 1 && header.Get(rpc.RequestIDMetadata)[0] != "req-1",
+				"A request ID should be generated", header)
+		}
+	}
+}
+
+func TestServer_PartiesShouldBeMaskedForAllButTheUnmaskedRoles(t *testing.T) {
+	t.Logf("Given the gRPC API leaving the party details unmasked for the admin role only")
+	{
+		repo := repository.NewMemoryRepository()
+		policy, _ := privacy.NewPolicy(privacy.DefaultRoleHeader, []string{"admin"}, privacy.DefaultFields)
+		client := dial(t, rpc.NewGRPCServerFor(rpc.NewServer(api.NewPaymentService(repo, "urlFX", "urlCF")).WithPrivacy(policy)))
+		_, err := client.CreatePayment(context.Background(), &paymentpb.CreatePaymentRequest{Payment: paymentRequest()})
+		check(t, err == nil, "The payment should have been created", err)
+
+		t.Logf("\tWhen a viewer, a caller without role and an admin list the payments")
+		{
+			ctx := metadata.AppendToOutgoingContext(context.Background(), privacy.DefaultRoleHeader, "viewer")
+			masked, err := client.ListPayments(ctx, &paymentpb.ListPaymentsRequest{})
+			check(t, err == nil && len(masked.GetPayments()) == 1 &&
+				masked.GetPayments()[0].GetAttributes().GetBeneficiaryParty().GetAccountNumber() == "****6819" &&
+				masked.GetPayments()[0].GetAttributes().GetBeneficiaryParty().GetName() == "W****** J******* O****",
+				"The viewer should get the party details masked", masked)
+
+			anonymous, err := client.ListPayments(context.Background(), &paymentpb.ListPaymentsRequest{})
+			check(t, err == nil && len(anonymous.GetPayments()) == 1 &&
+				anonymous.GetPayments()[0].GetAttributes().GetBeneficiaryParty().GetAccountNumber() == "****6819",
+				"The caller without role should get them masked", anonymous)
+
+			ctx = metadata.AppendToOutgoingContext(context.Background(), privacy.DefaultRoleHeader, "admin")
+			list, err := client.ListPayments(ctx, &paymentpb.ListPaymentsRequest{})
+			check(t, err == nil && len(list.GetPayments()) == 1 &&
+				list.GetPayments()[0].GetAttributes().GetBeneficiaryParty().GetAccountNumber() == "31926819",
+				"The admin should get them as is", list)
+		}
+	}
+}
+
+func TestServer_ShouldReturnTheTraceIDOfTheFailedCalls(t *testing.T) {
+	t.Logf("Given the gRPC API")
+	{
+		tracing.Setup(context.Background(), tracing.Options{Exporter: tracing.ExporterNone})
+		client := dial(t, rpc.NewGRPCServer(api.NewPaymentService(repository.NewMemoryRepository(), "urlFX", "urlCF")))
+
+		t.Logf("\tWhen a call of a trace fails")
+		{
+			var trailer metadata.MD
+			traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+			ctx := metadata.AppendToOutgoingContext(context.Background(), "traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+			_, err := client.GetPayment(ctx, &paymentpb.GetPaymentRequest{Id: model.NewID().String()}, grpc.Trailer(&trailer))
+			check(t, status.Code(err) == codes.NotFound && len(trailer.Get(rpc.TraceIDMetadata)) == 1 &&
+				trailer.Get(rpc.TraceIDMetadata)[0] == traceID, "The trace ID should be returned in the trailer", trailer)
+		}
+	}
+}
+
+// Helper function to serve the gRPC server in memory and connect a client to it
+func dial(t *testing.T, srv *grpc.Server) paymentpb.PaymentServiceClient {
+	l := bufconn.Listen(1 << 20)
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn", grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }))
+	if err != nil {
+		t.Fatalf("Failed to connect to the gRPC server %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return paymentpb.NewPaymentServiceClient(conn)
+}
+
+// Helper function to build the protobuf counterpart of the sample payment request
+func paymentRequest() *paymentpb.PaymentRequest {
+	req := test.CreatePaymentRequest("31926819", "GB29XABC10161234567801", "USD")
+	return &paymentpb.PaymentRequest{
+		OrganisationId:   req.OrganisationID,
+		BeneficiaryParty: &paymentpb.Party{AccountName: req.BeneficiaryParty.AccountName, AccountNumber: req.BeneficiaryParty.AccountNumber, Name: req.BeneficiaryParty.Name, Currency: req.BeneficiaryParty.Currency},
+		DebtorParty:      &paymentpb.Party{AccountName: req.DebtorParty.AccountName, AccountNumber: req.DebtorParty.AccountNumber, Name: req.DebtorParty.Name, Currency: req.DebtorParty.Currency},
+		PaymentScheme:    req.PaymentScheme,
+		Reference:        req.Reference,
+		Amount:           req.Amount,
+		BearerCode:       req.BearerCode,
+		ProcessingDate:   timestamppb.New(req.ProcessingDate),
+	}
+}
+
+// Helper function to log an expectation with a check mark, failing the test with a ballot when it is not met
+func check(t *testing.T, ok bool, expectation string, got interface{}) {
+	if ok {
+		t.Logf("\t\t%s. %v", expectation, test.CheckMark)
+	} else {
+		t.Errorf("\t\t%s. %v %v", expectation, got, test.BallotX)
+	}
+}
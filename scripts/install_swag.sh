@@ -3,10 +3,5 @@
 set -e
 
 echo "Installing swaggo command line tool"
-go get -u github.com/swaggo/swag
-go get github.com/ghodss/yaml
-go get github.com/urfave/cli
-cd $GOPATH/src/github.com/swaggo/swag/cmd/swag
-go build && go install
+go install github.com/swaggo/swag/cmd/swag@v1.16.1
 echo "swag install complete."
-
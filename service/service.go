@@ -1,6 +1,18 @@
 package service
 
-import "payment-service/model"
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"payment-service/logger"
+	"payment-service/model"
+	"payment-service/tracing"
+)
+
+// HTTPClient the client calling the pricing services, forwarding the request ID and trace of the request context to
+// them
+var HTTPClient = &http.Client{Transport: tracing.Transport{Base: logger.Transport{}}}
 
 // FXService the foreign exchange service
 type FXService struct {
@@ -13,13 +25,21 @@ type ChargesService struct {
 }
 
 // Mocking Foreign exchange response
-func (fxService FXService) GetExchangeRate(base, currency string, amount float64) (error, model.ForeignExchange) {
+func (fxService FXService) GetExchangeRate(ctx context.Context, base, currency string, amount float64) (error, model.ForeignExchange) {
+	if err := ctx.Err(); err != nil {
+		return model.ContextError(err), model.ForeignExchange{}
+	}
+	slog.DebugContext(ctx, "Mocked the FX service", "url", fxService.url, "base", base, "currency", currency)
 	fx := model.ForeignExchange{ContactReference: "FX123", ExchangeRate: 2.00000, OriginalAmount: amount, OriginalCurrency: base}
 	return nil, fx
 }
 
 // Mocking the Charges service response
-func (chService ChargesService) GetCharges(exRate float64, bearerCode string, senderCurrency string, receiverCurrency string) (error, model.ChargesInformation) {
+func (chService ChargesService) GetCharges(ctx context.Context, exRate float64, bearerCode string, senderCurrency string, receiverCurrency string) (error, model.ChargesInformation) {
+	if err := ctx.Err(); err != nil {
+		return model.ContextError(err), model.ChargesInformation{}
+	}
+	slog.DebugContext(ctx, "Mocked the charges service", "url", chService.url, "bearer_code", bearerCode)
 	senderChargesAmount := 10.0
 	senderCharges := []model.Charge{{Amount: senderChargesAmount, Currency: senderCurrency}, {Amount: senderChargesAmount / exRate, Currency: receiverCurrency}}
 	return nil, model.ChargesInformation{BearerCode: bearerCode, SenderCharges: senderCharges, ReceiverChargesAmount: 1.0, ReceiverChargesCurrency: receiverCurrency}
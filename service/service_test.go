@@ -1,6 +1,8 @@
 package service_test
 
 import (
+	"context"
+	"payment-service/model"
 	"payment-service/service"
 	"payment-service/test"
 	"testing"
@@ -12,7 +14,7 @@ func TestGetForeignExchangeService_GetFXDetatils(t *testing.T) {
 		t.Logf("\tWhen invoking foreign exchange service")
 		{
 			fx := service.NewFxService("url1")
-			_, res := fx.GetExchangeRate("USD", "GBP", 100.0)
+			_, res := fx.GetExchangeRate(context.Background(), "USD", "GBP", 100.0)
 
 			if res.ExchangeRate == 2.0 {
 				t.Logf("\t\tThe exchange rate is . %v %v", 2.0, test.CheckMark)
@@ -29,7 +31,7 @@ func TestChargesService_GetChargesDetails(t *testing.T) {
 		t.Logf("\tWhen invoking foreign exchange service")
 		{
 			fx := service.NewChargesService("url1")
-			_, res := fx.GetCharges(2.0, "SHAR", "USD", "GBP")
+			_, res := fx.GetCharges(context.Background(), 2.0, "SHAR", "USD", "GBP")
 
 			if res.ReceiverChargesAmount == 1.0 {
 				t.Logf("\t\tThe exchange rate is . %v %v", 1.0, test.CheckMark)
@@ -39,3 +41,43 @@ func TestChargesService_GetChargesDetails(t *testing.T) {
 		}
 	}
 }
+
+func TestGetForeignExchangeService_CancelledContextShouldFail(t *testing.T) {
+	t.Logf("Given the need to get forgein exchange details")
+	{
+		t.Logf("\tWhen invoking foreign exchange service for a cancelled request")
+		{
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			fx := service.NewFxService("url1")
+			err, _ := fx.GetExchangeRate(ctx, "USD", "GBP", 100.0)
+
+			if err == model.ErrCanceled {
+				t.Logf("\t\tThe call should fail with %v %v", model.ErrCanceled, test.CheckMark)
+			} else {
+				t.Errorf("\t\tThe call should fail with %v %v %v", model.ErrCanceled, test.BallotX, err)
+			}
+		}
+	}
+}
+
+func TestChargesService_ExpiredDeadlineShouldFail(t *testing.T) {
+	t.Logf("Given the need to get charges details")
+	{
+		t.Logf("\tWhen invoking charges service past the request deadline")
+		{
+			ctx, cancel := context.WithTimeout(context.Background(), 0)
+			defer cancel()
+
+			ch := service.NewChargesService("url1")
+			err, _ := ch.GetCharges(ctx, 2.0, "SHAR", "USD", "GBP")
+
+			if err == model.ErrDeadlineExceeded {
+				t.Logf("\t\tThe call should fail with %v %v", model.ErrDeadlineExceeded, test.CheckMark)
+			} else {
+				t.Errorf("\t\tThe call should fail with %v %v %v", model.ErrDeadlineExceeded, test.BallotX, err)
+			}
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"payment-service/api"
-	"payment-service/logger"
 	"payment-service/model"
 	"net/http"
 	"net/http/httptest"
@@ -73,7 +72,7 @@ func AssertForCallErrorAndHttpStatusCode(err error, t *testing.T, code int, expe
 func CreatePaymentAndAssertResponse(t *testing.T, handler *api.PaymentHandler) model.CreatePaymentResponse {
 	body := CreatePaymentRequest("31926819", "GB29XABC10161234567801", "GBP")
 	bytes, _ := json.Marshal(body)
-	logger.Info.Println(string(bytes))
+	t.Logf("\t\t%s", bytes)
 	w := httptest.NewRecorder()
 	req, err := HttpRequest(body, "/payment", http.MethodPost)
 	router := handler.NewRouter()
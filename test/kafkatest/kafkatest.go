@@ -0,0 +1,239 @@
+// Package kafkatest provides the Kafka brokers of the integration tests: an in-process stand-in answering the
+// kafka-go protocol messages, or the Redpanda or Kafka cluster given in KAFKA_TEST_BROKERS.
+package kafkatest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol"
+	"github.com/segmentio/kafka-go/protocol/metadata"
+	"github.com/segmentio/kafka-go/protocol/produce"
+)
+
+// ErrUnavailable returned when KAFKA_TEST_BROKERS is not set
+var ErrUnavailable = errors.New("KAFKA_TEST_BROKERS not set, set it to run the integration tests against Redpanda or Kafka")
+
+// Broker an in-process stand-in for a single node cluster, used as the Transport of a kafka.Writer. It answers the
+// metadata and produce requests, every topic having Partitions partitions, and keeps the produced messages in memory.
+type Broker struct {
+	Partitions int
+
+	mu       sync.Mutex
+	messages map[string][]kafka.Message
+	err      error
+}
+
+// NewBroker creates a Broker with topics of the given number of partitions
+func NewBroker(partitions int) *Broker {
+	return &Broker{Partitions: partitions, messages: make(map[string][]kafka.Message)}
+}
+
+// Addr the address to give to the writers, never dialled
+func (b *Broker) Addr() net.Addr {
+	return kafka.TCP("kafkatest:9092")
+}
+
+// Fail makes the produce requests fail with err until called with nil
+func (b *Broker) Fail(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.err = err
+}
+
+// Messages returns the messages produced to a topic, in the order they were received
+func (b *Broker) Messages(topic string) []kafka.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]kafka.Message(nil), b.messages[topic]...)
+}
+
+// RoundTrip answers a request as a cluster whose only broker leads every partition
+func (b *Broker) RoundTrip(ctx context.Context, addr net.Addr, req kafka.Request) (kafka.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	switch r := req.(type) {
+	case *metadata.Request:
+		return b.metadata(r), nil
+	case *produce.Request:
+		return b.produce(r)
+	}
+	return nil, errors.New("kafkatest: unsupported request " + req.ApiKey().String())
+}
+
+// Helper function to describe the requested topics
+func (b *Broker) metadata(req *metadata.Request) *metadata.Response {
+	res := &metadata.Response{Brokers: []metadata.ResponseBroker{{NodeID: 0, Host: "kafkatest", Port: 9092}}}
+	for _, topic := range req.TopicNames {
+		t := metadata.ResponseTopic{Name: topic}
+		for p := 0; p < b.Partitions; p++ {
+			t.Partitions = append(t.Partitions, metadata.ResponsePartition{PartitionIndex: int32(p), ReplicaNodes: []int32{0}, IsrNodes: []int32{0}})
+		}
+		res.Topics = append(res.Topics, t)
+	}
+	return res
+}
+
+// Helper function to append the produced records to their topics
+func (b *Broker) produce(req *produce.Request) (*produce.Response, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	res := &produce.Response{}
+	for _, topic := range req.Topics {
+		t := produce.ResponseTopic{Topic: topic.Topic}
+		for _, partition := range topic.Partitions {
+			offset := int64(len(b.messages[topic.Topic]))
+			t.Partitions = append(t.Partitions, produce.ResponsePartition{Partition: partition.Partition, BaseOffset: offset})
+			for {
+				record, err := partition.RecordSet.Records.ReadRecord()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					return nil, err
+				}
+				message, err := toMessage(topic.Topic, int(partition.Partition), record)
+				if err != nil {
+					return nil, err
+				}
+				message.Offset = int64(len(b.messages[topic.Topic]))
+				b.messages[topic.Topic] = append(b.messages[topic.Topic], message)
+			}
+		}
+		res.Topics = append(res.Topics, t)
+	}
+	return res, nil
+}
+
+// Helper function to copy a record, valid until the next one is read, into a message
+func toMessage(topic string, partition int, record *protocol.Record) (kafka.Message, error) {
+	key, err := protocol.ReadAll(record.Key)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+	value, err := protocol.ReadAll(record.Value)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+	message := kafka.Message{Topic: topic, Partition: partition, Key: key, Value: value, Time: record.Time}
+	for _, h := range record.Headers {
+		message.Headers = append(message.Headers, kafka.Header{Key: h.Key, Value: append([]byte(nil), h.Value...)})
+	}
+	return message, nil
+}
+
+// Cluster a Redpanda or Kafka cluster reached through KAFKA_TEST_BROKERS, a comma separated list of brokers
+type Cluster struct {
+	Brokers []string
+}
+
+// Start returns the cluster given in KAFKA_TEST_BROKERS, once it accepts connections
+func Start() (*Cluster, error) {
+	brokers := os.Getenv("KAFKA_TEST_BROKERS")
+	if brokers == "" {
+		return nil, ErrUnavailable
+	}
+	cluster := &Cluster{Brokers: strings.Split(brokers, ",")}
+
+	// wait for the cluster to accept connections
+	deadline := time.Now().Add(30 * time.Second)
+	conn, err := kafka.Dial("tcp", cluster.Brokers[0])
+	for err != nil && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+		conn, err = kafka.Dial("tcp", cluster.Brokers[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cluster, conn.Close()
+}
+
+// CreateTopic creates a topic with the given number of partitions
+func (c *Cluster) CreateTopic(topic string, partitions int) error {
+	conn, err := kafka.Dial("tcp", c.Brokers[0])
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	controller, err := conn.Controller()
+	if err != nil {
+		return err
+	}
+	controllerConn, err := kafka.Dial("tcp", net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)))
+	if err != nil {
+		return err
+	}
+	defer controllerConn.Close()
+	return controllerConn.CreateTopics(kafka.TopicConfig{Topic: topic, NumPartitions: partitions, ReplicationFactor: 1})
+}
+
+// Messages reads all the messages of a topic, partition by partition
+func (c *Cluster) Messages(ctx context.Context, topic string) ([]kafka.Message, error) {
+	conn, err := kafka.DialContext(ctx, "tcp", c.Brokers[0])
+	if err != nil {
+		return nil, err
+	}
+	partitions, err := conn.ReadPartitions(topic)
+	conn.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []kafka.Message
+	for _, p := range partitions {
+		read, err := c.readPartition(ctx, topic, p.ID)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, read...)
+	}
+	return messages, nil
+}
+
+// Helper function to read the messages of a partition up to its last offset
+func (c *Cluster) readPartition(ctx context.Context, topic string, partition int) ([]kafka.Message, error) {
+	conn, err := kafka.DialLeader(ctx, "tcp", c.Brokers[0], topic, partition)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	last, err := conn.ReadLastOffset()
+	if err != nil || last == 0 {
+		return nil, err
+	}
+	if _, err := conn.Seek(0, kafka.SeekAbsolute); err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetReadDeadline(deadline)
+	}
+	var messages []kafka.Message
+	for int64(len(messages)) < last {
+		// a fetch may stop before the last offset, the next one resuming where it stopped
+		batch := conn.ReadBatch(1, 10<<20)
+		for int64(len(messages)) < last {
+			message, err := batch.ReadMessage()
+			if err != nil {
+				break
+			}
+			messages = append(messages, message)
+		}
+		if err := batch.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return messages, nil
+}
@@ -0,0 +1,147 @@
+// Package mongotest starts a throwaway mongod for the integration tests.
+package mongotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrUnavailable returned when neither MONGO_TEST_URL is set nor a mongod binary can be found
+var ErrUnavailable = errors.New("no mongod binary found, set MONGOD or MONGO_TEST_URL to run the integration tests")
+
+// Server a mongo instance used by the tests, either started locally or reached through MONGO_TEST_URL
+type Server struct {
+	URI    string
+	Client *mongo.Client
+	cmd    *exec.Cmd
+	dir    string
+
+	initiated bool
+}
+
+// name of the replica set of the local mongod
+const replicaSet = "rs0"
+
+// Start connects to MONGO_TEST_URL when set, otherwise starts the mongod found in MONGOD or on the PATH on a free
+// port with its files stored in a temporary directory. The local mongod runs as a single node replica set, the
+// repository writes being transactions.
+func Start() (*Server, error) {
+	server := &Server{URI: os.Getenv("MONGO_TEST_URL")}
+	if server.URI == "" {
+		if err := server.launch(); err != nil {
+			return nil, err
+		}
+	}
+
+	// wait for the server to accept connections
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(server.URI))
+	if err == nil {
+		server.Client = client
+		err = server.waitForPrimary(ctx)
+	}
+	if err != nil {
+		server.Stop()
+		return nil, err
+	}
+	return server, nil
+}
+
+// Stop disconnects the client, shuts down the local mongod and removes its data on disk.
+func (s *Server) Stop() {
+	if s.Client != nil {
+		s.Client.Disconnect(context.Background())
+	}
+	if s.cmd != nil {
+		s.cmd.Process.Kill()
+		s.cmd.Wait()
+	}
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+	}
+}
+
+// Helper function to start a mongod process
+func (s *Server) launch() error {
+	binary := os.Getenv("MONGOD")
+	if binary == "" {
+		path, err := exec.LookPath("mongod")
+		if err != nil {
+			return ErrUnavailable
+		}
+		binary = path
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return err
+	}
+	s.dir, err = ioutil.TempDir("", "mongotest")
+	if err != nil {
+		return err
+	}
+
+	s.cmd = exec.Command(binary, "--dbpath", s.dir, "--bind_ip", "127.0.0.1", "--port", fmt.Sprint(port), "--nounixsocket",
+		"--replSet", replicaSet)
+	if err := s.cmd.Start(); err != nil {
+		s.cmd = nil
+		os.RemoveAll(s.dir)
+		return err
+	}
+	s.URI = fmt.Sprintf("mongodb://127.0.0.1:%d/?directConnection=true", port)
+	return nil
+}
+
+// Helper function to wait for the server to accept connections and, for a local mongod, initiate the replica set
+// and wait for the node to become primary
+func (s *Server) waitForPrimary(ctx context.Context) error {
+	admin := s.Client.Database("admin")
+	for {
+		var hello struct {
+			IsWritablePrimary bool `bson:"isWritablePrimary"`
+		}
+		err := admin.RunCommand(ctx, bson.M{"hello": 1}).Decode(&hello)
+		if err == nil && (hello.IsWritablePrimary || s.cmd == nil) {
+			return nil
+		}
+		if err == nil && !s.initiated {
+			config := bson.M{"_id": replicaSet, "members": bson.A{bson.M{"_id": 0, "host": s.host()}}}
+			if err := admin.RunCommand(ctx, bson.M{"replSetInitiate": config}).Err(); err != nil {
+				return err
+			}
+			s.initiated = true
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Helper function to get the host:port of the local mongod
+func (s *Server) host() string {
+	return strings.TrimSuffix(strings.TrimPrefix(s.URI, "mongodb://"), "/?directConnection=true")
+}
+
+// Helper function to get a port nothing is listening on
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
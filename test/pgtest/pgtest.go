@@ -0,0 +1,130 @@
+// Package pgtest starts a throwaway PostgreSQL server for the integration tests, without docker.
+package pgtest
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	// postgres driver
+	_ "github.com/lib/pq"
+)
+
+// ErrUnavailable returned when neither POSTGRES_TEST_URL is set nor the postgres binaries can be found
+var ErrUnavailable = errors.New("no postgres binaries found, set POSTGRES_BIN or POSTGRES_TEST_URL to run the integration tests")
+
+// Server a postgres instance used by the tests, either started locally or reached through POSTGRES_TEST_URL
+type Server struct {
+	URL string
+	DB  *sql.DB
+	cmd *exec.Cmd
+	dir string
+}
+
+// Start connects to POSTGRES_TEST_URL when set, otherwise initialises a cluster in a temporary directory with the
+// initdb and postgres binaries found in POSTGRES_BIN or on the PATH, and starts it on a free port.
+func Start() (*Server, error) {
+	server := &Server{URL: os.Getenv("POSTGRES_TEST_URL")}
+	if server.URL == "" {
+		if err := server.launch(); err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := sql.Open("postgres", server.URL)
+	if err != nil {
+		server.Stop()
+		return nil, err
+	}
+	server.DB = db
+
+	// wait for the server to accept connections
+	deadline := time.Now().Add(30 * time.Second)
+	for err = db.Ping(); err != nil && time.Now().Before(deadline); err = db.Ping() {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		server.Stop()
+		return nil, err
+	}
+	return server, nil
+}
+
+// Stop closes the connections, shuts down the local postgres and removes its data on disk.
+func (s *Server) Stop() {
+	if s.DB != nil {
+		s.DB.Close()
+	}
+	if s.cmd != nil {
+		s.cmd.Process.Signal(os.Interrupt)
+		s.cmd.Wait()
+	}
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+	}
+}
+
+// Helper function to initialise and start a postgres cluster
+func (s *Server) launch() error {
+	initdb, err := binary("initdb")
+	if err != nil {
+		return err
+	}
+	postgres, err := binary("postgres")
+	if err != nil {
+		return err
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return err
+	}
+	s.dir, err = ioutil.TempDir("", "pgtest")
+	if err != nil {
+		return err
+	}
+	data := filepath.Join(s.dir, "data")
+
+	if out, err := exec.Command(initdb, "-D", data, "-U", "postgres", "-A", "trust", "--no-sync").CombinedOutput(); err != nil {
+		os.RemoveAll(s.dir)
+		return fmt.Errorf("initdb failed: %s: %s", err, out)
+	}
+
+	// fsync is disabled, the data being thrown away anyway
+	s.cmd = exec.Command(postgres, "-D", data, "-p", fmt.Sprint(port), "-k", s.dir, "-c", "listen_addresses=127.0.0.1", "-F")
+	if err := s.cmd.Start(); err != nil {
+		s.cmd = nil
+		os.RemoveAll(s.dir)
+		return err
+	}
+	s.URL = fmt.Sprintf("postgres://postgres@127.0.0.1:%d/postgres?sslmode=disable", port)
+	return nil
+}
+
+// Helper function to find a postgres binary
+func binary(name string) (string, error) {
+	if dir := os.Getenv("POSTGRES_BIN"); dir != "" {
+		return filepath.Join(dir, name), nil
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", ErrUnavailable
+	}
+	return path, nil
+}
+
+// Helper function to get a port nothing is listening on
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
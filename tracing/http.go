@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StartHTTP starts the server span of a request, continuing the trace of its traceparent header. The span is named
+// after the method until the route is known, see SetRoute.
+func StartHTTP(r *http.Request) (context.Context, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	return Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method), semconv.URLPath(r.URL.Path)))
+}
+
+// SetRoute names the server span of the context after the route of the request, e.g. GET /payment/:id
+func SetRoute(ctx context.Context, method, route string) {
+	span := trace.SpanFromContext(ctx)
+	span.SetName(method + " " + route)
+	span.SetAttributes(semconv.HTTPRoute(route))
+}
+
+// EndHTTP ends the server span of a request with its status, the server errors failing it
+func EndHTTP(span trace.Span, status int) {
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	span.End()
+}
+
+// Transport sends the requests through Base, http.DefaultTransport when nil, in client spans and propagates their
+// trace to the called service in the traceparent header. The requests outside of a trace, e.g. the readiness checks,
+// are sent as is.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// RoundTrip sends a copy of the request carrying the trace context
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if !trace.SpanContextFromContext(req.Context()).IsValid() {
+		return base.RoundTrip(req)
+	}
+
+	ctx, span := Start(req.Context(), req.Method, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPRequestMethodKey.String(req.Method), semconv.ServerAddress(req.URL.Hostname()),
+			semconv.URLPath(req.URL.Path)))
+	traced := req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(traced.Header))
+	resp, err := base.RoundTrip(traced)
+	if err != nil {
+		End(span, err)
+		return nil, err
+	}
+	EndHTTP(span, resp.StatusCode)
+	return resp, nil
+}
@@ -0,0 +1,100 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"payment-service/model"
+	"payment-service/repository"
+)
+
+// Repository traces the payment and outbox operations of the wrapped repository in client spans, the other
+// operations being passed through. A missing or duplicate payment does not fail the span.
+type Repository struct {
+	repository.Repository
+}
+
+// InstrumentRepository wraps the repository for its operations to be traced
+func InstrumentRepository(repo repository.Repository) *Repository {
+	return &Repository{repo}
+}
+
+// Insert traces the insertion of a payment
+func (r *Repository) Insert(ctx context.Context, db, col string, content interface{}) error {
+	ctx, span := startOperation(ctx, "insert", db, col)
+	return endOperation(span, r.Repository.Insert(ctx, db, col, content))
+}
+
+// FindAll traces the query of all the payments
+func (r *Repository) FindAll(ctx context.Context, db, col string) (model.PaymentResponse, error) {
+	ctx, span := startOperation(ctx, "find_all", db, col)
+	resp, err := r.Repository.FindAll(ctx, db, col)
+	span.SetAttributes(attribute.Int("db.response.returned_rows", len(resp.Data)))
+	return resp, endOperation(span, err)
+}
+
+// FindPage traces the query of a page of the payments
+func (r *Repository) FindPage(ctx context.Context, db, col string, filter repository.Filter, skip, limit int) (model.PaymentResponse, error) {
+	ctx, span := startOperation(ctx, "find_page", db, col)
+	resp, err := r.Repository.FindPage(ctx, db, col, filter, skip, limit)
+	span.SetAttributes(attribute.Int("db.response.returned_rows", len(resp.Data)))
+	return resp, endOperation(span, err)
+}
+
+// Find traces the query of a payment
+func (r *Repository) Find(ctx context.Context, db, col string, id model.ID) (model.PaymentResponse, error) {
+	ctx, span := startOperation(ctx, "find", db, col)
+	resp, err := r.Repository.Find(ctx, db, col, id)
+	return resp, endOperation(span, err)
+}
+
+// Delete traces the deletion of a payment
+func (r *Repository) Delete(ctx context.Context, db, col string, id model.ID) error {
+	ctx, span := startOperation(ctx, "delete", db, col)
+	return endOperation(span, r.Repository.Delete(ctx, db, col, id))
+}
+
+// Update traces the replacement of a payment
+func (r *Repository) Update(ctx context.Context, db, col string, id model.ID, content interface{}) error {
+	ctx, span := startOperation(ctx, "update", db, col)
+	return endOperation(span, r.Repository.Update(ctx, db, col, id, content))
+}
+
+// Patch traces the partial update of a payment
+func (r *Repository) Patch(ctx context.Context, db, col string, id model.ID, original, patched model.Payment) error {
+	ctx, span := startOperation(ctx, "patch", db, col)
+	return endOperation(span, r.Repository.Patch(ctx, db, col, id, original, patched))
+}
+
+// Pending traces the query of the outbox
+func (r *Repository) Pending(ctx context.Context, db string, limit int, exclude ...model.ID) ([]model.Event, error) {
+	ctx, span := startOperation(ctx, "pending", db, "outbox")
+	events, err := r.Repository.Pending(ctx, db, limit, exclude...)
+	return events, endOperation(span, err)
+}
+
+// Ack traces the removal of published events from the outbox
+func (r *Repository) Ack(ctx context.Context, db string, ids ...string) error {
+	ctx, span := startOperation(ctx, "ack", db, "outbox")
+	return endOperation(span, r.Repository.Ack(ctx, db, ids...))
+}
+
+// Helper function to start the span of a repository operation, named after the operation and collection
+func startOperation(ctx context.Context, operation, db, col string) (context.Context, trace.Span) {
+	return Start(ctx, operation+" "+col, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("db.operation.name", operation),
+		attribute.String("db.namespace", db),
+		attribute.String("db.collection.name", col)))
+}
+
+// Helper function to end the span of a repository operation and return its error
+func endOperation(span trace.Span, err error) error {
+	if err == repository.ErrNotFound || err == repository.ErrDuplicate {
+		span.SetAttributes(attribute.String("db.outcome", err.Error()))
+		span.End()
+		return err
+	}
+	End(span, err)
+	return err
+}
@@ -0,0 +1,107 @@
+// Package tracing traces the payment requests with OpenTelemetry: the served requests, the repository operations and
+// the calls to the pricing services are spans of the trace of the request, continued from the W3C traceparent header
+// of the caller and propagated to the called services.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The exporters of the spans
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// ServiceName the name of the service in the traces, and of the tracer creating its spans
+const ServiceName = "payment-service"
+
+// Options configure the export of the spans
+type Options struct {
+	// Exporter one of ExporterNone, ExporterStdout or ExporterOTLP
+	Exporter string
+
+	// Endpoint the URL of the OTLP gRPC collector, e.g. http://localhost:4317
+	Endpoint string
+
+	// SampleRatio the share of the traces started by the service which are sampled, the service following the
+	// sampling decision of its callers
+	SampleRatio float64
+
+	// Environment the deployment environment of the service, e.g. production
+	Environment string
+
+	// Output the writer of the stdout exporter, os.Stdout when nil
+	Output io.Writer
+}
+
+// Setup installs the W3C trace context propagator and, unless the exporter is ExporterNone, the tracer provider
+// exporting the spans. The returned function flushes the pending spans and stops the provider. Without exporter the
+// trace of the callers is still propagated and its ID logged.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch opts.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		out := opts.Output
+		if out == nil {
+			out = os.Stdout
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(out))
+	case ExporterOTLP:
+		exporter, err = otlptracegrpc.New(ctx, otlptracegrpc.WithEndpointURL(opts.Endpoint))
+	default:
+		err = fmt.Errorf("unknown span exporter %q", opts.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(ServiceName), semconv.DeploymentEnvironment(opts.Environment))))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start starts a span of the trace of the context with the tracer of the service
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(ServiceName).Start(ctx, name, opts...)
+}
+
+// End ends the span, recording the error which failed its operation
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceID returns the ID of the trace of the context, empty outside of a trace
+func TraceID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
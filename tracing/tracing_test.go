@@ -0,0 +1,101 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+	"payment-service/model"
+	"payment-service/repository"
+	"payment-service/test"
+	"payment-service/tracing"
+)
+
+func TestTransport_ShouldPropagateTheTrace(t *testing.T) {
+	t.Logf("Given a traced HTTP client and a downstream service")
+	{
+		recorder := record(t)
+		var traceparent string
+		downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			traceparent = r.Header.Get("traceparent")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer downstream.Close()
+		client := &http.Client{Transport: tracing.Transport{}}
+
+		t.Logf("\tWhen calling it within a trace")
+		{
+			ctx, span := tracing.Start(context.Background(), "parent")
+			req, _ := http.NewRequest(http.MethodGet, downstream.URL+"/fx?amount=10", nil)
+			resp, err := client.Do(req.WithContext(ctx))
+			if err == nil {
+				resp.Body.Close()
+			}
+			span.End()
+
+			spans := recorder.Ended()
+			check(t, err == nil && len(spans) == 2 && spans[0].Name() == http.MethodGet && spans[0].Status().Code == codes.Error,
+				"A failed client span should have been recorded", spans)
+			check(t, strings.Contains(traceparent, tracing.TraceID(ctx)) && strings.Contains(traceparent, spans[0].SpanContext().SpanID().String()),
+				"The client span should have been propagated in the traceparent header", traceparent)
+			check(t, req.Header.Get("traceparent") == "", "The request should not have been changed", req.Header)
+		}
+
+		t.Logf("\tWhen calling it outside of a trace")
+		{
+			traceparent = ""
+			resp, err := client.Get(downstream.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			check(t, err == nil && traceparent == "" && len(recorder.Ended()) == 2, "The request should not have been traced", traceparent)
+		}
+	}
+}
+
+func TestInstrumentRepository_ShouldTraceTheOperations(t *testing.T) {
+	t.Logf("Given a traced repository")
+	{
+		recorder := record(t)
+		repo := tracing.InstrumentRepository(repository.NewMemoryRepository())
+
+		t.Logf("\tWhen finding a missing payment")
+		{
+			ctx, span := tracing.Start(context.Background(), "parent")
+			_, err := repo.Find(ctx, "db", "payments", model.NewID())
+			span.End()
+
+			spans := recorder.Ended()
+			check(t, err == repository.ErrNotFound, "The error should be returned as is", err)
+			check(t, len(spans) == 2 && spans[0].Name() == "find payments" && spans[0].Parent().SpanID() == span.SpanContext().SpanID() &&
+				spans[0].Status().Code != codes.Error, "A child span should have been recorded without failing", spans)
+		}
+	}
+}
+
+// Helper function to record the spans of the test, the propagator being the one of the service
+func record(t *testing.T) *tracetest.SpanRecorder {
+	if _, err := tracing.Setup(context.Background(), tracing.Options{Exporter: tracing.ExporterNone}); err != nil {
+		t.Fatalf("Failed to set up the tracing %v", err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+	return recorder
+}
+
+// Helper function to log an expectation with a check mark, failing the test with a ballot when it is not met
+func check(t *testing.T, ok bool, expectation string, got interface{}) {
+	if ok {
+		t.Logf("\t\t%s. %v", expectation, test.CheckMark)
+	} else {
+		t.Errorf("\t\t%s. %v %v", expectation, got, test.BallotX)
+	}
+}
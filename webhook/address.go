@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrInvalidURL returned for the webhook URLs which are not absolute http(s) URLs
+	ErrInvalidURL = errors.New("webhook URL must be an absolute http or https URL")
+
+	// ErrForbiddenAddress returned for the webhook URLs whose host is, or resolves to, an address of the service
+	// network: loopback, private, link-local, multicast or unspecified
+	ErrForbiddenAddress = errors.New("webhook URL must resolve to public addresses only")
+)
+
+// CheckURL checks that a webhook URL is an absolute http(s) URL whose host resolves to public addresses only, so
+// that organisations cannot have the service post to itself or to its network
+func CheckURL(ctx context.Context, resolver *net.Resolver, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrInvalidURL
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		if Forbidden(ip) {
+			return ErrForbiddenAddress
+		}
+		return nil
+	}
+	addrs, err := resolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if Forbidden(addr.IP) {
+			return ErrForbiddenAddress
+		}
+	}
+	return nil
+}
+
+// Address ranges not covered by the net.IP predicates which the webhooks may not be delivered to either
+var forbiddenNets = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),     // this network
+	mustParseCIDR("100.64.0.0/10"), // carrier-grade NAT
+	mustParseCIDR("198.18.0.0/15"), // benchmarking
+}
+
+// The NAT64 prefix embedding IPv4 addresses in its last 4 bytes
+var nat64 = mustParseCIDR("64:ff9b::/96")
+
+// Forbidden reports whether the webhooks may not be delivered to an IP address. The IPv4 addresses embedded in
+// IPv4-mapped and NAT64 IPv6 addresses are checked as such.
+func Forbidden(ip net.IP) bool {
+	if len(ip) == net.IPv6len && nat64.Contains(ip) {
+		ip = net.IPv4(ip[12], ip[13], ip[14], ip[15])
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, n := range forbiddenNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+// Helper function parsing the CIDR notation of the forbidden ranges
+func mustParseCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// NewClient creates the HTTP client delivering the webhooks. Its dialer refuses to connect to the forbidden addresses,
+// which CheckURL cannot guarantee on its own: the host of a webhook may resolve to other addresses by the time it is
+// delivered to, and redirects may lead anywhere.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: control}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would connect to the webhook on our behalf, out of reach of the dialer
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// Helper function refusing the connections to forbidden addresses, called once the host has been resolved
+func control(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || Forbidden(ip) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
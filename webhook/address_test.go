@@ -0,0 +1,79 @@
+package webhook_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"payment-service/webhook"
+)
+
+func TestCheckURL_ShouldOnlyAcceptPublicHTTPURLs(t *testing.T) {
+	t.Logf("Given webhook URLs")
+	{
+		t.Logf("\tWhen checking them")
+		{
+			for url, expected := range map[string]error{
+				"https://203.0.113.10/hooks":              nil,
+				"ftp://203.0.113.10/hooks":                webhook.ErrInvalidURL,
+				"/hooks":                                  webhook.ErrInvalidURL,
+				"http://127.0.0.1:8080/hooks":             webhook.ErrForbiddenAddress,
+				"http://localhost/hooks":                  webhook.ErrForbiddenAddress,
+				"http://[::1]/hooks":                      webhook.ErrForbiddenAddress,
+				"http://10.1.2.3/hooks":                   webhook.ErrForbiddenAddress,
+				"http://192.168.0.1/hooks":                webhook.ErrForbiddenAddress,
+				"http://169.254.169.254/latest/meta-data": webhook.ErrForbiddenAddress,
+				"http://0.0.0.0/hooks":                    webhook.ErrForbiddenAddress,
+				"http://[::ffff:127.0.0.1]/hooks":         webhook.ErrForbiddenAddress,
+			} {
+				err := webhook.CheckURL(context.Background(), net.DefaultResolver, url)
+				check(t, err == expected, "Checking "+url+" should return the expected error", err)
+			}
+		}
+	}
+}
+
+func TestForbidden_ShouldRefuseTheAddressesOfTheServiceNetwork(t *testing.T) {
+	t.Logf("Given IP addresses")
+	{
+		t.Logf("\tWhen checking whether the webhooks may be delivered to them")
+		{
+			for _, tc := range []struct {
+				ip        string
+				forbidden bool
+			}{
+				{"203.0.113.10", false},
+				{"8.8.8.8", false},
+				{"2001:4860:4860::8888", false},
+				{"100.63.255.255", false},
+				{"100.128.0.0", false},
+				{"198.20.0.1", false},
+				{"127.0.0.1", true},
+				{"10.0.0.1", true},
+				{"172.16.0.1", true},
+				{"192.168.1.1", true},
+				{"169.254.169.254", true},
+				{"0.0.0.0", true},
+				{"0.1.2.3", true},
+				{"100.64.0.1", true},
+				{"100.127.255.255", true},
+				{"198.18.0.1", true},
+				{"198.19.255.255", true},
+				{"224.0.0.1", true},
+				{"::", true},
+				{"::1", true},
+				{"fc00::1", true},
+				{"fe80::1", true},
+				{"::ffff:10.0.0.1", true},
+				{"::ffff:100.64.0.1", true},
+				{"::ffff:203.0.113.10", false},
+				{"64:ff9b::7f00:1", true},
+				{"64:ff9b::a9fe:a9fe", true},
+				{"64:ff9b::cb00:710a", false},
+			} {
+				got := webhook.Forbidden(net.ParseIP(tc.ip))
+				check(t, got == tc.forbidden, "Checking "+tc.ip+" should report whether it is forbidden", got)
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"payment-service/event"
+	"payment-service/model"
+)
+
+// Dispatcher an event.Publisher recording a delivery of each payment event to the webhooks of the payment
+// organisation subscribed to its type. Deliveries are identified by event and webhook, so that publishing an event
+// again, as the at least once relay does, records no duplicate.
+type Dispatcher struct {
+	Store Store
+
+	// Source the CloudEvents source of the payloads
+	Source string
+}
+
+// NewDispatcher creates a Dispatcher recording the deliveries in the store
+func NewDispatcher(store Store) *Dispatcher {
+	return &Dispatcher{Store: store, Source: event.DefaultSource}
+}
+
+// Publish records the deliveries of the event, the payload being the event in the CloudEvents JSON format
+func (d *Dispatcher) Publish(ctx context.Context, e model.Event) error {
+	webhooks, err := d.Store.Webhooks(ctx, e.Payment.OrganisationId)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(event.NewCloudEvent(d.Source, e))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, w := range webhooks {
+		if !w.Subscribed(e.Type) {
+			continue
+		}
+		delivery := model.Delivery{
+			ID:             e.ID + "-" + w.ID,
+			WebhookID:      w.ID,
+			OrganisationID: w.OrganisationID,
+			EventID:        e.ID,
+			EventType:      e.Type,
+			Payload:        payload,
+			Status:         model.DeliveryPending,
+			NextAttempt:    now,
+			CreatedAt:      now,
+		}
+		if err := d.Store.AddDelivery(ctx, delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
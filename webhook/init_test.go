@@ -0,0 +1,36 @@
+package webhook_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"payment-service/test/mongotest"
+)
+
+const DBName = "webhook-test"
+
+var Server *mongotest.Server
+
+// TestMain starts a mongod in the background for the mongo store tests (provided you have mongo installed on the
+// server on which this test will be running, or MONGO_TEST_URL points at one), which are skipped otherwise.
+func TestMain(m *testing.M) {
+	var err error
+	Server, err = mongotest.Start()
+	switch {
+	case err == mongotest.ErrUnavailable:
+		fmt.Println("skipping mongo store tests:", err)
+	case err != nil:
+		fmt.Println("failed to start mongo:", err)
+		os.Exit(1)
+	}
+
+	retCode := m.Run()
+
+	if Server != nil {
+		Server.Client.Database(DBName).Drop(context.Background())
+		Server.Stop()
+	}
+	os.Exit(retCode)
+}
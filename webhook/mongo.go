@@ -0,0 +1,162 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"payment-service/model"
+)
+
+// collections of the webhooks and their deliveries
+const (
+	WebhooksCollection   = "webhooks"
+	DeliveriesCollection = "deliveries"
+)
+
+// MongoStore a Store keeping the webhooks and deliveries in two collections of a mongo database
+type MongoStore struct {
+	DB *mongo.Database
+}
+
+// NewMongoStore creates a MongoStore on the given database
+func NewMongoStore(db *mongo.Database) *MongoStore {
+	return &MongoStore{db}
+}
+
+// EnsureIndexes creates the indexes of the organisation queries and of the due deliveries query
+func (s *MongoStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.DB.Collection(WebhooksCollection).Indexes().CreateOne(ctx,
+		mongo.IndexModel{Keys: bson.D{{Key: "organisationid", Value: 1}, {Key: "createdat", Value: 1}}})
+	if err != nil {
+		return mapError(err)
+	}
+	_, err = s.DB.Collection(DeliveriesCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextattempt", Value: 1}}},
+		{Keys: bson.D{{Key: "organisationid", Value: 1}, {Key: "webhookid", Value: 1}, {Key: "createdat", Value: 1}}},
+	})
+	return mapError(err)
+}
+
+// CreateWebhook stores a new webhook
+func (s *MongoStore) CreateWebhook(ctx context.Context, webhook model.Webhook) error {
+	_, err := s.DB.Collection(WebhooksCollection).InsertOne(ctx, webhook)
+	return mapError(err)
+}
+
+// Webhooks returns the webhooks of an organisation, in creation order
+func (s *MongoStore) Webhooks(ctx context.Context, organisationID string) ([]model.Webhook, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdat", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := s.DB.Collection(WebhooksCollection).Find(ctx, bson.M{"organisationid": organisationID}, opts)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	var webhooks []model.Webhook
+	return webhooks, mapError(cursor.All(ctx, &webhooks))
+}
+
+// Webhook returns a webhook of an organisation
+func (s *MongoStore) Webhook(ctx context.Context, organisationID, id string) (model.Webhook, error) {
+	var webhook model.Webhook
+	err := s.DB.Collection(WebhooksCollection).FindOne(ctx, bson.M{"_id": id, "organisationid": organisationID}).Decode(&webhook)
+	return webhook, mapError(err)
+}
+
+// DeleteWebhook removes a webhook of an organisation
+func (s *MongoStore) DeleteWebhook(ctx context.Context, organisationID, id string) error {
+	res, err := s.DB.Collection(WebhooksCollection).DeleteOne(ctx, bson.M{"_id": id, "organisationid": organisationID})
+	if err != nil {
+		return mapError(err)
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AddDelivery stores a new delivery, doing nothing when a delivery with the same ID exists
+func (s *MongoStore) AddDelivery(ctx context.Context, delivery model.Delivery) error {
+	_, err := s.DB.Collection(DeliveriesCollection).InsertOne(ctx, delivery)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return mapError(err)
+}
+
+// UpdateDelivery replaces a delivery provided it is still under the given lease, the lease being compared in the
+// same operation as the replacement
+func (s *MongoStore) UpdateDelivery(ctx context.Context, delivery model.Delivery, lease string) error {
+	filter := bson.M{"_id": delivery.ID, "lease": lease}
+	if lease == "" {
+		// the lease is omitted from the documents of the deliveries no worker holds
+		filter["lease"] = bson.M{"$exists": false}
+	}
+	res, err := s.DB.Collection(DeliveriesCollection).ReplaceOne(ctx, filter, delivery)
+	if err != nil {
+		return mapError(err)
+	}
+	if res.MatchedCount == 0 {
+		n, err := s.DB.Collection(DeliveriesCollection).CountDocuments(ctx, bson.M{"_id": delivery.ID})
+		if err != nil {
+			return mapError(err)
+		}
+		if n == 0 {
+			return ErrNotFound
+		}
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Claim leases the oldest due pending delivery to the owner, finding and updating it in a single operation so that
+// two workers cannot claim the same delivery
+func (s *MongoStore) Claim(ctx context.Context, owner string, now time.Time, lease time.Duration) (model.Delivery, error) {
+	filter := bson.M{"status": model.DeliveryPending, "nextattempt": bson.M{"$lte": now}}
+	update := bson.M{"$set": bson.M{"lease": model.NewID().String(), "leasedby": owner, "nextattempt": now.Add(lease)}}
+	opts := options.FindOneAndUpdate().SetSort(bson.D{{Key: "nextattempt", Value: 1}}).SetReturnDocument(options.After)
+	var delivery model.Delivery
+	err := s.DB.Collection(DeliveriesCollection).FindOneAndUpdate(ctx, filter, update, opts).Decode(&delivery)
+	return delivery, mapError(err)
+}
+
+// Deliveries returns the deliveries of a webhook of an organisation, in creation order
+func (s *MongoStore) Deliveries(ctx context.Context, organisationID, webhookID string) ([]model.Delivery, error) {
+	filter := bson.M{"organisationid": organisationID, "webhookid": webhookID}
+	return s.find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdat", Value: 1}, {Key: "_id", Value: 1}}))
+}
+
+// Delivery returns a delivery of a webhook of an organisation
+func (s *MongoStore) Delivery(ctx context.Context, organisationID, webhookID, id string) (model.Delivery, error) {
+	var delivery model.Delivery
+	filter := bson.M{"_id": id, "organisationid": organisationID, "webhookid": webhookID}
+	err := s.DB.Collection(DeliveriesCollection).FindOne(ctx, filter).Decode(&delivery)
+	return delivery, mapError(err)
+}
+
+// Helper function to query deliveries
+func (s *MongoStore) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]model.Delivery, error) {
+	cursor, err := s.DB.Collection(DeliveriesCollection).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	var deliveries []model.Delivery
+	return deliveries, mapError(cursor.All(ctx, &deliveries))
+}
+
+// Helper function to translate mongo errors into store errors
+func mapError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case err == mongo.ErrNoDocuments:
+		return ErrNotFound
+	case errors.Is(err, context.Canceled):
+		return model.ErrCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return model.ErrDeadlineExceeded
+	}
+	return err
+}
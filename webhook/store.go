@@ -0,0 +1,215 @@
+// Package webhook notifies the organisations of the events of their payments on their own endpoints.
+//
+// The Dispatcher is an event.Publisher recording a Delivery per event and subscribed webhook, and the Worker delivers
+// them asynchronously: each payload is signed with the webhook secret, failed attempts are retried with an
+// exponential backoff and deliveries failing MaxAttempts times are dead-lettered until redelivered. Workers lease the
+// deliveries they attempt, so that several of them share the deliveries without attempting one twice at once.
+package webhook
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"payment-service/model"
+)
+
+var (
+	// ErrNotFound returned when the webhook or delivery does not exist for the organisation
+	ErrNotFound = errors.New("not found")
+
+	// ErrLeaseLost returned when updating a delivery whose lease changed since it was read, because it was claimed
+	// or redelivered meanwhile
+	ErrLeaseLost = errors.New("delivery lease lost")
+)
+
+// Store persists the webhooks and their deliveries. Every query is scoped by organisation.
+type Store interface {
+
+	// CreateWebhook stores a new webhook
+	CreateWebhook(ctx context.Context, webhook model.Webhook) error
+
+	// Webhooks returns the webhooks of an organisation, in creation order
+	Webhooks(ctx context.Context, organisationID string) ([]model.Webhook, error)
+
+	// Webhook returns a webhook of an organisation
+	Webhook(ctx context.Context, organisationID, id string) (model.Webhook, error)
+
+	// DeleteWebhook removes a webhook of an organisation
+	DeleteWebhook(ctx context.Context, organisationID, id string) error
+
+	// AddDelivery stores a new delivery, doing nothing when a delivery with the same ID exists
+	AddDelivery(ctx context.Context, delivery model.Delivery) error
+
+	// UpdateDelivery replaces a delivery provided it is still under the given lease, the empty lease standing for the
+	// deliveries no worker holds, returning ErrLeaseLost otherwise
+	UpdateDelivery(ctx context.Context, delivery model.Delivery, lease string) error
+
+	// Claim leases the pending delivery whose next attempt is the oldest before now to the owner, pushing its next
+	// attempt back to now plus the lease duration so that no other worker claims it meanwhile. It returns ErrNotFound
+	// when no delivery is due.
+	Claim(ctx context.Context, owner string, now time.Time, lease time.Duration) (model.Delivery, error)
+
+	// Deliveries returns the deliveries of a webhook of an organisation, in creation order
+	Deliveries(ctx context.Context, organisationID, webhookID string) ([]model.Delivery, error)
+
+	// Delivery returns a delivery of a webhook of an organisation
+	Delivery(ctx context.Context, organisationID, webhookID, id string) (model.Delivery, error)
+}
+
+// MemoryStore a Store keeping the webhooks in memory, for the tests and the deployments without mongo
+type MemoryStore struct {
+	mu         sync.RWMutex
+	webhooks   []model.Webhook
+	deliveries []model.Delivery
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// CreateWebhook stores a new webhook
+func (s *MemoryStore) CreateWebhook(ctx context.Context, webhook model.Webhook) error {
+	if err := ctx.Err(); err != nil {
+		return model.ContextError(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.webhooks = append(s.webhooks, webhook)
+	return nil
+}
+
+// Webhooks returns the webhooks of an organisation, in creation order
+func (s *MemoryStore) Webhooks(ctx context.Context, organisationID string) ([]model.Webhook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, model.ContextError(err)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var webhooks []model.Webhook
+	for _, w := range s.webhooks {
+		if w.OrganisationID == organisationID {
+			webhooks = append(webhooks, w)
+		}
+	}
+	return webhooks, nil
+}
+
+// Webhook returns a webhook of an organisation
+func (s *MemoryStore) Webhook(ctx context.Context, organisationID, id string) (model.Webhook, error) {
+	webhooks, err := s.Webhooks(ctx, organisationID)
+	for _, w := range webhooks {
+		if w.ID == id {
+			return w, nil
+		}
+	}
+	if err == nil {
+		err = ErrNotFound
+	}
+	return model.Webhook{}, err
+}
+
+// DeleteWebhook removes a webhook of an organisation
+func (s *MemoryStore) DeleteWebhook(ctx context.Context, organisationID, id string) error {
+	if err := ctx.Err(); err != nil {
+		return model.ContextError(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, w := range s.webhooks {
+		if w.ID == id && w.OrganisationID == organisationID {
+			s.webhooks = append(s.webhooks[:i], s.webhooks[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// AddDelivery stores a new delivery, doing nothing when a delivery with the same ID exists
+func (s *MemoryStore) AddDelivery(ctx context.Context, delivery model.Delivery) error {
+	if err := ctx.Err(); err != nil {
+		return model.ContextError(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range s.deliveries {
+		if d.ID == delivery.ID {
+			return nil
+		}
+	}
+	s.deliveries = append(s.deliveries, delivery)
+	return nil
+}
+
+// UpdateDelivery replaces a delivery provided it is still under the given lease
+func (s *MemoryStore) UpdateDelivery(ctx context.Context, delivery model.Delivery, lease string) error {
+	if err := ctx.Err(); err != nil {
+		return model.ContextError(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, d := range s.deliveries {
+		if d.ID == delivery.ID {
+			if d.Lease != lease {
+				return ErrLeaseLost
+			}
+			s.deliveries[i] = delivery
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// Claim leases the oldest due pending delivery to the owner
+func (s *MemoryStore) Claim(ctx context.Context, owner string, now time.Time, lease time.Duration) (model.Delivery, error) {
+	if err := ctx.Err(); err != nil {
+		return model.Delivery{}, model.ContextError(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	claimed := -1
+	for i, d := range s.deliveries {
+		if d.Status == model.DeliveryPending && !d.NextAttempt.After(now) &&
+			(claimed < 0 || d.NextAttempt.Before(s.deliveries[claimed].NextAttempt)) {
+			claimed = i
+		}
+	}
+	if claimed < 0 {
+		return model.Delivery{}, ErrNotFound
+	}
+	d := &s.deliveries[claimed]
+	d.Lease, d.LeasedBy, d.NextAttempt = model.NewID().String(), owner, now.Add(lease)
+	return *d, nil
+}
+
+// Deliveries returns the deliveries of a webhook of an organisation, in creation order
+func (s *MemoryStore) Deliveries(ctx context.Context, organisationID, webhookID string) ([]model.Delivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, model.ContextError(err)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var deliveries []model.Delivery
+	for _, d := range s.deliveries {
+		if d.OrganisationID == organisationID && d.WebhookID == webhookID {
+			deliveries = append(deliveries, d)
+		}
+	}
+	return deliveries, nil
+}
+
+// Delivery returns a delivery of a webhook of an organisation
+func (s *MemoryStore) Delivery(ctx context.Context, organisationID, webhookID, id string) (model.Delivery, error) {
+	deliveries, err := s.Deliveries(ctx, organisationID, webhookID)
+	for _, d := range deliveries {
+		if d.ID == id {
+			return d, nil
+		}
+	}
+	if err == nil {
+		err = ErrNotFound
+	}
+	return model.Delivery{}, err
+}
@@ -0,0 +1,98 @@
+package webhook_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"payment-service/model"
+	"payment-service/test"
+	"payment-service/webhook"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, webhook.NewMemoryStore())
+}
+
+func TestMongoStore(t *testing.T) {
+	if Server == nil {
+		t.Skip("mongo is not available")
+	}
+	store := webhook.NewMongoStore(Server.Client.Database(fmt.Sprintf("%s-%d", DBName, time.Now().UnixNano())))
+	if err := store.EnsureIndexes(context.Background()); err != nil {
+		t.Fatalf("Failed to create the indexes %v %v", test.BallotX, err)
+	}
+	defer store.DB.Drop(context.Background())
+	testStore(t, store)
+}
+
+// Helper function running the store tests
+func testStore(t *testing.T, store webhook.Store) {
+	ctx := context.Background()
+	t.Logf("Given webhooks of two organisations")
+	{
+		now := time.Now().UTC().Truncate(time.Millisecond)
+		first := model.Webhook{ID: model.NewID().String(), OrganisationID: "org-1", URL: "https://example.com/1", CreatedAt: now}
+		second := model.Webhook{ID: model.NewID().String(), OrganisationID: "org-1", URL: "https://example.com/2", CreatedAt: now.Add(time.Second)}
+		other := model.Webhook{ID: model.NewID().String(), OrganisationID: "org-2", URL: "https://example.com/3", CreatedAt: now}
+		for _, w := range []model.Webhook{first, second, other} {
+			check(t, store.CreateWebhook(ctx, w) == nil, "The webhook should have been created", w)
+		}
+
+		t.Logf("\tWhen querying and deleting the webhooks")
+		{
+			webhooks, err := store.Webhooks(ctx, "org-1")
+			check(t, err == nil && len(webhooks) == 2 && webhooks[0].ID == first.ID && webhooks[1].ID == second.ID,
+				"The webhooks of the organisation should have been returned in creation order", webhooks)
+			_, err = store.Webhook(ctx, "org-1", other.ID)
+			check(t, err == webhook.ErrNotFound, "The webhook of another organisation should not be found", err)
+			err = store.DeleteWebhook(ctx, "org-1", other.ID)
+			check(t, err == webhook.ErrNotFound, "The webhook of another organisation should not be deleted", err)
+			err = store.DeleteWebhook(ctx, "org-1", second.ID)
+			check(t, err == nil, "The webhook should have been deleted", err)
+			webhooks, _ = store.Webhooks(ctx, "org-1")
+			check(t, len(webhooks) == 1, "The webhook should be gone", webhooks)
+		}
+
+		t.Logf("\tWhen adding deliveries")
+		{
+			late := model.Delivery{ID: "event-1-" + first.ID, WebhookID: first.ID, OrganisationID: "org-1", Payload: []byte("{}"),
+				Status: model.DeliveryPending, NextAttempt: now.Add(time.Hour), CreatedAt: now}
+			due := late
+			due.ID, due.NextAttempt, due.CreatedAt = "event-2-"+first.ID, now, now.Add(time.Second)
+			for _, d := range []model.Delivery{late, due, late} {
+				check(t, store.AddDelivery(ctx, d) == nil, "The delivery should have been added", d.ID)
+			}
+
+			deliveries, err := store.Deliveries(ctx, "org-1", first.ID)
+			check(t, err == nil && len(deliveries) == 2 && deliveries[0].ID == late.ID, "The deliveries should have been added once, in order", deliveries)
+			claimed, err := store.Claim(ctx, "worker-1", now, time.Minute)
+			check(t, err == nil && claimed.ID == due.ID && claimed.Lease != "" && claimed.LeasedBy == "worker-1" &&
+				claimed.NextAttempt.Equal(now.Add(time.Minute)), "Only the due delivery should have been claimed", claimed)
+			_, err = store.Claim(ctx, "worker-2", now, time.Minute)
+			check(t, err == webhook.ErrNotFound, "A claimed delivery should not be claimed again during its lease", err)
+
+			due.Status = model.DeliverySucceeded
+			err = store.UpdateDelivery(ctx, due, "")
+			check(t, err == webhook.ErrLeaseLost, "The delivery should not be updated without its lease", err)
+			check(t, store.UpdateDelivery(ctx, due, claimed.Lease) == nil, "The delivery should have been updated under its lease", due)
+			claimed, err = store.Claim(ctx, "worker-2", now.Add(2*time.Hour), time.Minute)
+			check(t, err == nil && claimed.ID == late.ID, "The succeeded delivery should no longer be due", claimed)
+
+			d, err := store.Delivery(ctx, "org-1", first.ID, due.ID)
+			check(t, err == nil && d.Status == model.DeliverySucceeded && string(d.Payload) == "{}", "The delivery should have been stored", d)
+			_, err = store.Delivery(ctx, "org-2", first.ID, due.ID)
+			check(t, err == webhook.ErrNotFound, "The delivery should not be found for another organisation", err)
+		}
+	}
+}
+
+// Helper function to log the outcome of an expectation
+func check(t *testing.T, ok bool, expectation string, got interface{}) {
+	if ok {
+		t.Logf("\t\t%s %v", expectation, test.CheckMark)
+	} else {
+		t.Errorf("\t\t%s %v %v", expectation, test.BallotX, got)
+	}
+}
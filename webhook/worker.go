@@ -0,0 +1,219 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"payment-service/event"
+	"payment-service/model"
+)
+
+// Worker defaults
+const (
+	DefaultInterval    = time.Second
+	DefaultBatchSize   = 100
+	DefaultMaxAttempts = 8
+	DefaultMinBackoff  = 10 * time.Second
+	DefaultMaxBackoff  = time.Hour
+	DefaultTimeout     = 10 * time.Second
+	DefaultLease       = time.Minute
+)
+
+// the number of times Redeliver reads a delivery again after losing the race to a worker
+const redeliverTries = 3
+
+// Worker delivers the due deliveries to their webhooks.
+//
+// A delivery succeeds when the endpoint answers with a 2xx status. Otherwise it is attempted again after a delay
+// doubling from MinBackoff up to MaxBackoff, and is dead-lettered after MaxAttempts failed attempts. Delivery is at
+// least once, receivers identify duplicates by the event id of the payload.
+//
+// Each delivery is claimed for Lease before being attempted, so that several workers, e.g. one per replica of the
+// service, share the due deliveries. The outcome of an attempt is only recorded if the claim still holds: a delivery
+// redelivered meanwhile keeps its redelivery, and a delivery whose lease expired is attempted again.
+type Worker struct {
+	Store  Store
+	Client *http.Client
+
+	// Owner identifies the worker in the leases it holds
+	Owner string
+
+	// Lease the duration a delivery is claimed for, longer than the timeout of the Client
+	Lease time.Duration
+
+	// Interval between two polls of the due deliveries
+	Interval time.Duration
+
+	// BatchSize the maximum number of deliveries attempted per poll
+	BatchSize int
+
+	// MaxAttempts the number of failed attempts after which a delivery is dead-lettered
+	MaxAttempts int
+
+	// MinBackoff and MaxBackoff bound the delay before attempting a failed delivery again
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// NewWorker creates a Worker with the default settings
+func NewWorker(store Store) *Worker {
+	return &Worker{
+		Store:       store,
+		Client:      NewClient(DefaultTimeout),
+		Owner:       owner(),
+		Lease:       DefaultLease,
+		Interval:    DefaultInterval,
+		BatchSize:   DefaultBatchSize,
+		MaxAttempts: DefaultMaxAttempts,
+		MinBackoff:  DefaultMinBackoff,
+		MaxBackoff:  DefaultMaxBackoff,
+	}
+}
+
+// Run attempts the due deliveries every Interval until the context is done
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.Flush(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Failed to deliver the webhooks", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush attempts a batch of due deliveries, returning how many succeeded. A failed attempt is not an error, the
+// delivery being attempted again later.
+func (w *Worker) Flush(ctx context.Context) (int, error) {
+	succeeded := 0
+	for i := 0; i < w.BatchSize; i++ {
+		delivery, err := w.Store.Claim(ctx, w.Owner, time.Now().UTC(), w.Lease)
+		if err == ErrNotFound {
+			break
+		}
+		if err != nil {
+			return succeeded, err
+		}
+		lease := delivery.Lease
+		delivery = w.attempt(ctx, delivery)
+		delivery.Lease, delivery.LeasedBy = "", ""
+		err = w.Store.UpdateDelivery(ctx, delivery, lease)
+		if err == ErrLeaseLost {
+			slog.WarnContext(ctx, "Discarded the outcome of a delivery redelivered or claimed meanwhile",
+				"delivery_id", delivery.ID, "webhook_id", delivery.WebhookID)
+			continue
+		}
+		if err != nil {
+			return succeeded, err
+		}
+		if delivery.Status == model.DeliverySucceeded {
+			succeeded++
+		}
+	}
+	return succeeded, nil
+}
+
+// Helper function to post a delivery to its webhook, returning the delivery updated with the outcome
+func (w *Worker) attempt(ctx context.Context, delivery model.Delivery) model.Delivery {
+	now := time.Now().UTC()
+	delivery.Attempts++
+	webhook, err := w.Store.Webhook(ctx, delivery.OrganisationID, delivery.WebhookID)
+	if err == ErrNotFound {
+		delivery.Status = model.DeliveryDead
+		delivery.LastError = "webhook deleted"
+		return delivery
+	}
+	if err == nil {
+		delivery.LastStatusCode, err = w.post(ctx, webhook, delivery, now)
+	}
+	if err == nil {
+		delivery.Status = model.DeliverySucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		return delivery
+	}
+
+	slog.WarnContext(ctx, "Failed to deliver to the webhook", "delivery_id", delivery.ID, "webhook_id", delivery.WebhookID,
+		"attempt", delivery.Attempts, "error", err)
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= w.MaxAttempts {
+		delivery.Status = model.DeliveryDead
+		return delivery
+	}
+	delivery.NextAttempt = now.Add(w.backoff(delivery.Attempts))
+	return delivery
+}
+
+// Helper function to post the signed payload, returning the response status
+func (w *Worker) post(ctx context.Context, webhook model.Webhook, delivery model.Delivery, now time.Time) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", event.CloudEventsContentType)
+	req.Header.Set("X-Webhook-ID", webhook.ID)
+	req.Header.Set("X-Delivery-ID", delivery.ID)
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, now, delivery.Payload))
+
+	res, err := w.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, 64<<10))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook answered %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// Helper function to get the delay before the next attempt, doubling with each failed attempt
+func (w *Worker) backoff(attempts int) time.Duration {
+	delay := w.MaxBackoff
+	if attempts <= 32 && w.MinBackoff<<uint(attempts-1) < delay {
+		delay = w.MinBackoff << uint(attempts-1)
+	}
+	return delay
+}
+
+// Redeliver schedules a delivery of a webhook of an organisation to be attempted again right away, whatever its
+// status, with a fresh number of attempts. A delivery being attempted is released, the outcome of the attempt
+// in progress being discarded.
+func Redeliver(ctx context.Context, store Store, organisationID, webhookID, id string) (model.Delivery, error) {
+	for try := 1; ; try++ {
+		delivery, err := store.Delivery(ctx, organisationID, webhookID, id)
+		if err != nil {
+			return delivery, err
+		}
+		lease := delivery.Lease
+		delivery.Status = model.DeliveryPending
+		delivery.Attempts = 0
+		delivery.NextAttempt = time.Now().UTC()
+		delivery.Lease, delivery.LeasedBy = "", ""
+		err = store.UpdateDelivery(ctx, delivery, lease)
+		if err != ErrLeaseLost || try == redeliverTries {
+			return delivery, err
+		}
+	}
+}
+
+// Helper function to identify the worker of this process
+func owner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
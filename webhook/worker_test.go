@@ -0,0 +1,243 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"payment-service/event"
+	"payment-service/model"
+	"payment-service/webhook"
+)
+
+const secret = "whsec_test"
+
+// endpoint an organisation endpoint verifying the signature of the deliveries, answering status
+type endpoint struct {
+	mu       sync.Mutex
+	status   int
+	received []event.CloudEvent
+	invalid  int
+}
+
+func (e *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	body, _ := ioutil.ReadAll(r.Body)
+	if err := webhook.Verify(secret, r.Header.Get(webhook.SignatureHeader), body, time.Now(), time.Minute); err != nil {
+		e.invalid++
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var ce event.CloudEvent
+	json.Unmarshal(body, &ce)
+	e.received = append(e.received, ce)
+	w.WriteHeader(e.status)
+}
+
+func (e *endpoint) answer(status int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.status = status
+}
+
+func TestWorker_ShouldDeliverSignedEventsToTheSubscribedWebhooks(t *testing.T) {
+	t.Logf("Given two webhooks of an organisation, one subscribed to the status changes only")
+	{
+		store, ep := webhook.NewMemoryStore(), &endpoint{status: http.StatusOK}
+		server := httptest.NewServer(ep)
+		defer server.Close()
+		all := subscribe(t, store, "org-1", server.URL)
+		statuses := subscribe(t, store, "org-1", server.URL, model.PaymentStatusChanged)
+		subscribe(t, store, "org-2", server.URL)
+
+		t.Logf("\tWhen a payment of the organisation changes status")
+		{
+			payment := model.Payment{ID: model.NewID(), OrganisationId: "org-1"}
+			accepted := payment
+			accepted.Status = "accepted"
+			dispatcher := webhook.NewDispatcher(store)
+			for _, e := range model.UpdateEvents(payment, accepted) {
+				check(t, dispatcher.Publish(context.Background(), e) == nil, "The "+e.Type+" event should have been dispatched", e)
+				check(t, dispatcher.Publish(context.Background(), e) == nil, "Dispatching it again should be harmless", e)
+			}
+
+			worker := webhook.NewWorker(store)
+			worker.Client = server.Client()
+			n, err := worker.Flush(context.Background())
+			check(t, err == nil && n == 3, "The three deliveries should have succeeded", n)
+			check(t, len(ep.received) == 3 && ep.invalid == 0, "The endpoint should have received signed deliveries", ep.received)
+
+			deliveries, _ := store.Deliveries(context.Background(), "org-1", all.ID)
+			check(t, len(deliveries) == 2, "The webhook subscribed to all events should have two deliveries", deliveries)
+			deliveries, _ = store.Deliveries(context.Background(), "org-1", statuses.ID)
+			check(t, len(deliveries) == 1 && deliveries[0].EventType == model.PaymentStatusChanged,
+				"The other webhook should only have the status change", deliveries)
+			check(t, len(deliveries) == 1 && deliveries[0].Status == model.DeliverySucceeded && deliveries[0].Attempts == 1 &&
+				deliveries[0].DeliveredAt != nil && deliveries[0].LastStatusCode == http.StatusOK,
+				"The delivery should record its outcome", deliveries)
+		}
+	}
+}
+
+func TestWorker_ShouldRetryThenDeadLetterFailingDeliveries(t *testing.T) {
+	t.Logf("Given a webhook whose endpoint fails")
+	{
+		store, ep := webhook.NewMemoryStore(), &endpoint{status: http.StatusServiceUnavailable}
+		server := httptest.NewServer(ep)
+		defer server.Close()
+		hook := subscribe(t, store, "org-1", server.URL)
+		payment := model.Payment{ID: model.NewID(), OrganisationId: "org-1"}
+		webhook.NewDispatcher(store).Publish(context.Background(), model.NewEvent(model.PaymentCreated, payment))
+
+		worker := webhook.NewWorker(store)
+		worker.Client = server.Client()
+		worker.MaxAttempts = 3
+		worker.MinBackoff = 20 * time.Millisecond
+
+		t.Logf("\tWhen attempting the delivery")
+		{
+			n, err := worker.Flush(context.Background())
+			check(t, err == nil && n == 0, "The delivery should have failed", n)
+			deliveries, _ := store.Deliveries(context.Background(), "org-1", hook.ID)
+			d := deliveries[0]
+			check(t, d.Status == model.DeliveryPending && d.Attempts == 1 && d.LastStatusCode == http.StatusServiceUnavailable &&
+				d.LastError != "", "The failure should have been recorded", d)
+			check(t, d.NextAttempt.After(time.Now()), "The next attempt should have been delayed", d.NextAttempt)
+
+			n, _ = worker.Flush(context.Background())
+			check(t, n == 0 && len(ep.received) == 1, "The delivery should not be attempted during the backoff", len(ep.received))
+		}
+
+		t.Logf("\tWhen the delivery keeps failing")
+		{
+			time.Sleep(25 * time.Millisecond)
+			worker.Flush(context.Background())
+			time.Sleep(45 * time.Millisecond)
+			worker.Flush(context.Background())
+			deliveries, _ := store.Deliveries(context.Background(), "org-1", hook.ID)
+			check(t, deliveries[0].Status == model.DeliveryDead && deliveries[0].Attempts == 3,
+				"The delivery should have been dead-lettered after three attempts", deliveries[0])
+			time.Sleep(100 * time.Millisecond)
+			worker.Flush(context.Background())
+			check(t, len(ep.received) == 3, "A dead delivery should no longer be attempted", len(ep.received))
+		}
+
+		t.Logf("\tWhen redelivering it once the endpoint recovered")
+		{
+			ep.answer(http.StatusNoContent)
+			deliveries, _ := store.Deliveries(context.Background(), "org-1", hook.ID)
+			d, err := webhook.Redeliver(context.Background(), store, "org-1", hook.ID, deliveries[0].ID)
+			check(t, err == nil && d.Status == model.DeliveryPending && d.Attempts == 0, "The delivery should have been rescheduled", d)
+			_, err = webhook.Redeliver(context.Background(), store, "org-2", hook.ID, deliveries[0].ID)
+			check(t, err == webhook.ErrNotFound, "Another organisation should not redeliver it", err)
+
+			n, err := worker.Flush(context.Background())
+			check(t, err == nil && n == 1, "The redelivery should have succeeded", n)
+		}
+	}
+}
+
+func TestWorker_ShouldKeepARedeliveryRequestedDuringTheAttempt(t *testing.T) {
+	t.Logf("Given a delivery redelivered while the worker attempts it")
+	{
+		store := webhook.NewMemoryStore()
+		var hook model.Webhook
+		var redelivered model.Delivery
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			redelivered, _ = webhook.Redeliver(r.Context(), store, "org-1", hook.ID, r.Header.Get("X-Delivery-ID"))
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+		hook = subscribe(t, store, "org-1", server.URL)
+		payment := model.Payment{ID: model.NewID(), OrganisationId: "org-1"}
+		webhook.NewDispatcher(store).Publish(context.Background(), model.NewEvent(model.PaymentCreated, payment))
+
+		t.Logf("\tWhen the attempt fails")
+		{
+			worker := webhook.NewWorker(store)
+			worker.Client = server.Client()
+			n, err := worker.Flush(context.Background())
+			deliveries, _ := store.Deliveries(context.Background(), "org-1", hook.ID)
+			check(t, err == nil && n == 0 && redelivered.ID != "", "The delivery should have been redelivered", redelivered)
+			check(t, len(deliveries) == 1 && deliveries[0].Attempts == 0 && deliveries[0].LastError == "" &&
+				deliveries[0].Lease == "" && !deliveries[0].NextAttempt.After(time.Now()),
+				"The redelivery should not have been overwritten by the outcome of the attempt", deliveries)
+		}
+	}
+}
+
+func TestWorker_ShouldRefuseToConnectToPrivateAddresses(t *testing.T) {
+	t.Logf("Given a webhook whose host resolves to the loopback address by the time it is delivered to")
+	{
+		store, ep := webhook.NewMemoryStore(), &endpoint{status: http.StatusOK}
+		server := httptest.NewServer(ep)
+		defer server.Close()
+		hook := subscribe(t, store, "org-1", server.URL)
+		payment := model.Payment{ID: model.NewID(), OrganisationId: "org-1"}
+		webhook.NewDispatcher(store).Publish(context.Background(), model.NewEvent(model.PaymentCreated, payment))
+
+		t.Logf("\tWhen attempting the delivery with the default client")
+		{
+			n, err := webhook.NewWorker(store).Flush(context.Background())
+			deliveries, _ := store.Deliveries(context.Background(), "org-1", hook.ID)
+			check(t, err == nil && n == 0 && len(ep.received) == 0, "The endpoint should not have been reached", ep.received)
+			check(t, len(deliveries) == 1 && strings.Contains(deliveries[0].LastError, webhook.ErrForbiddenAddress.Error()),
+				"The refused connection should have been recorded", deliveries)
+		}
+	}
+}
+
+func TestWorker_ShouldDeadLetterTheDeliveriesOfDeletedWebhooks(t *testing.T) {
+	t.Logf("Given a delivery of a deleted webhook")
+	{
+		store := webhook.NewMemoryStore()
+		hook := subscribe(t, store, "org-1", "http://127.0.0.1:1")
+		payment := model.Payment{ID: model.NewID(), OrganisationId: "org-1"}
+		webhook.NewDispatcher(store).Publish(context.Background(), model.NewEvent(model.PaymentCreated, payment))
+		store.DeleteWebhook(context.Background(), "org-1", hook.ID)
+
+		t.Logf("\tWhen attempting the delivery")
+		{
+			webhook.NewWorker(store).Flush(context.Background())
+			deliveries, _ := store.Deliveries(context.Background(), "org-1", hook.ID)
+			check(t, len(deliveries) == 1 && deliveries[0].Status == model.DeliveryDead, "The delivery should have been dead-lettered", deliveries)
+		}
+	}
+}
+
+func TestVerify_ShouldRejectTamperedOrReplayedPayloads(t *testing.T) {
+	t.Logf("Given a signed payload")
+	{
+		now := time.Now()
+		payload := []byte(`{"id":"1"}`)
+		signature := webhook.Sign(secret, now, payload)
+
+		err := webhook.Verify(secret, signature, payload, now, time.Minute)
+		check(t, err == nil, "The signature should be valid", err)
+		err = webhook.Verify(secret, signature, []byte(`{"id":"2"}`), now, time.Minute)
+		check(t, err == webhook.ErrInvalidSignature, "A tampered payload should be rejected", err)
+		err = webhook.Verify("other", signature, payload, now, time.Minute)
+		check(t, err == webhook.ErrInvalidSignature, "Another secret should be rejected", err)
+		err = webhook.Verify(secret, signature, payload, now.Add(10*time.Minute), time.Minute)
+		check(t, err == webhook.ErrInvalidSignature, "An old signature should be rejected", err)
+		err = webhook.Verify(secret, "v1=abc", payload, now, time.Minute)
+		check(t, err == webhook.ErrInvalidSignature, "A malformed header should be rejected", err)
+	}
+}
+
+// Helper function to subscribe an endpoint to the events of an organisation
+func subscribe(t *testing.T, store webhook.Store, organisationID, url string, eventTypes ...string) model.Webhook {
+	w := model.Webhook{ID: model.NewID().String(), OrganisationID: organisationID, URL: url, EventTypes: eventTypes,
+		Secret: secret, CreatedAt: time.Now().UTC()}
+	if err := store.CreateWebhook(context.Background(), w); err != nil {
+		t.Fatalf("Failed to create the webhook %v", err)
+	}
+	return w
+}